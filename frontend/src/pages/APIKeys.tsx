@@ -1,5 +1,6 @@
 import React, { useState, useEffect } from 'react';
 import { apiKeysService, APIKey } from '../services/apiKeys';
+import { usageService, IngestUsage } from '../services/usage';
 import Navbar from '../components/Navbar';
 
 const APIKeys: React.FC = () => {
@@ -11,9 +12,11 @@ const APIKeys: React.FC = () => {
   const [createdKey, setCreatedKey] = useState<string | null>(null);
   const [isCreating, setIsCreating] = useState(false);
   const [showCopiedToast, setShowCopiedToast] = useState(false);
+  const [usage, setUsage] = useState<IngestUsage | null>(null);
 
   useEffect(() => {
     fetchAPIKeys();
+    usageService.getUsage().then(setUsage).catch(() => setUsage(null));
   }, []);
 
   const fetchAPIKeys = async () => {
@@ -80,6 +83,10 @@ const APIKeys: React.FC = () => {
     return new Date(dateStr).toLocaleString();
   };
 
+  const formatLimit = (value: number, unit: string) => {
+    return value > 0 ? `${value.toLocaleString()} ${unit}` : 'Unlimited';
+  };
+
   const maskKey = (key: string | undefined | null) => {
     if (!key) return 'N/A';
     if (key.length <= 8) return key;
@@ -107,6 +114,39 @@ const APIKeys: React.FC = () => {
             </div>
           )}
 
+          {usage && (
+            <div className="mb-6 bg-white shadow sm:rounded-md p-6">
+              <h3 className="text-lg font-medium text-gray-900 mb-4">Ingestion Usage</h3>
+              <dl className="grid grid-cols-2 md:grid-cols-4 gap-4">
+                <div>
+                  <dt className="text-xs text-gray-500">Events today</dt>
+                  <dd className="text-sm font-medium text-gray-900">
+                    {usage.events_today.toLocaleString()} / {formatLimit(usage.limits.daily_event_quota, 'events')}
+                  </dd>
+                </div>
+                <div>
+                  <dt className="text-xs text-gray-500">Bytes today</dt>
+                  <dd className="text-sm font-medium text-gray-900">
+                    {usage.bytes_today.toLocaleString()} / {formatLimit(usage.limits.daily_byte_quota, 'bytes')}
+                  </dd>
+                </div>
+                <div>
+                  <dt className="text-xs text-gray-500">Rate limit</dt>
+                  <dd className="text-sm font-medium text-gray-900">
+                    {formatLimit(usage.limits.events_per_second, 'events/s')}
+                  </dd>
+                </div>
+                <div>
+                  <dt className="text-xs text-gray-500">Rejected today</dt>
+                  <dd className="text-sm font-medium text-gray-900">{usage.rejected_today.toLocaleString()}</dd>
+                </div>
+              </dl>
+              <p className="mt-3 text-xs text-gray-500">
+                Daily quotas reset at {formatDate(usage.quota_resets_at)}
+              </p>
+            </div>
+          )}
+
           {isLoading ? (
             <div className="text-center py-12">
               <div className="inline-block animate-spin rounded-full h-8 w-8 border-b-2 border-indigo-600"></div>
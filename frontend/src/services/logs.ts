@@ -18,6 +18,7 @@ export interface QueryRequest {
   source?: string;
   service?: string;
   message_contains?: string;
+  search?: string;
   levels?: string[];
   sources?: string[];
   start_time?: string;
@@ -37,6 +38,13 @@ export interface QueryResponse {
   limit: number;
   offset: number;
   executed_at: string;
+  matches?: SearchMatch[];
+}
+
+export interface SearchMatch {
+  log_id: number;
+  rank: number;
+  snippet: string;
 }
 
 export const logsService = {
@@ -0,0 +1,27 @@
+import api from './api';
+
+export interface IngestLimits {
+  events_per_second: number;
+  bytes_per_second: number;
+  key_events_per_second: number;
+  key_bytes_per_second: number;
+  daily_event_quota: number;
+  daily_byte_quota: number;
+  is_default: boolean;
+}
+
+export interface IngestUsage {
+  limits: IngestLimits;
+  events_today: number;
+  bytes_today: number;
+  quota_resets_at: string;
+  events_available: number;
+  rejected_today: number;
+}
+
+export const usageService = {
+  async getUsage(): Promise<IngestUsage> {
+    const response = await api.get<IngestUsage>('/account/usage');
+    return response.data;
+  },
+};
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/app"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/config"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/ingester"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/processor"
+	"github.com/sirupsen/logrus"
+)
+
+/*
+logbuilder runs the ingester and a processor in one process, for small installs and local development.
+With QUEUE_BACKEND=memory logs pass between them in process and the rest of the shared state (caches,
+rate limits, multiline buffers) stays in process too, so Redis isn't used and only Postgres is needed.
+Metrics and probes are served by the ingester's HTTP server
+*/
+
+func main() {
+	configs, err := config.LoadManager()
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to load configuration")
+	}
+	cfg := configs.Get()
+
+	res, err := app.Open(cfg)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to open storage")
+	}
+	defer res.Close(logrus.StandardLogger())
+
+	ingest, err := ingester.New(configs, res)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to create ingestion service")
+	}
+	defer ingest.Close()
+
+	process, err := processor.New(cfg, res)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to create processor service")
+	}
+	defer process.Close()
+	logger := ingest.Logger()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// The ingester applies its own reloads; the processor follows the log level and the dedup window
+	configs.OnReload(func(next *config.Config) {
+		res.Logs.SetDedupWindow(next.IdempotencyTTL)
+		if level, err := logrus.ParseLevel(next.LogLevel); err == nil {
+			process.Logger().SetLevel(level)
+		}
+	})
+	configs.WatchSIGHUP(ctx, logger)
+
+	// The processor keeps running after a shutdown signal until the ingester has published its last logs
+	processCtx, stopProcessing := context.WithCancel(context.Background())
+	defer stopProcessing()
+	ingestCtx, stopIngesting := context.WithCancel(ctx)
+	defer stopIngesting()
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- process.Start(processCtx)
+		// Without a processor nothing accepted would be stored, so stop ingesting too
+		stopIngesting()
+	}()
+
+	if err := ingest.Run(ingestCtx); err != nil {
+		logger.WithError(err).Error("Ingestion service stopped with error")
+	}
+
+	if cfg.QueueBackend == config.QueueMemory {
+		// Nothing outlives the process, so give the processor the shutdown timeout to store what is queued
+		drainQueue(res, cfg, logger)
+	}
+	stopProcessing()
+
+	select {
+	case err := <-stopped:
+		if err != nil && !errors.Is(err, context.Canceled) {
+			logger.WithError(err).Error("Processor stopped with error")
+		}
+	case <-time.After(cfg.ShutdownTimeout + 5*time.Second):
+		logger.Warn("Processor did not stop in time, exiting with messages still pending")
+	}
+	logger.Info("LogBuilder stopped")
+}
+
+// waits until the processors' consumer group has stored everything in the queue, or ShutdownTimeout passes
+func drainQueue(res *app.Resources, cfg *config.Config, logger *logrus.Logger) {
+	deadline := time.Now().Add(cfg.ShutdownTimeout)
+	for time.Now().Before(deadline) {
+		info, err := res.Queue.Info(context.Background())
+		if err != nil {
+			logger.WithError(err).Warn("Failed to check the queue, stopping without draining it")
+			return
+		}
+		group := info.Group(cfg.ConsumerGroup)
+		if group == nil || group.Lag+group.Pending == 0 {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	logger.Warn("Shutdown timeout reached with logs still queued; they are lost")
+}
@@ -2,130 +2,103 @@ package main
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/app"
 	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/config"
-	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
-	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/storage"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/metrics"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/processor"
 	"github.com/sirupsen/logrus"
 )
 
-type ProcessorService struct {
-	storage     *storage.PostgresStorage
-	redisClient *storage.RedisClient
-	logger      *logrus.Logger
-	config      *config.Config
-}
-
-// creates a new processor service
-func NewProcessorService(cfg *config.Config) (*ProcessorService, error) {
-	logger := logrus.New()
-
-	// Set log level
-	level, err := logrus.ParseLevel(cfg.LogLevel)
+// loads configuration, initializes the processor service, runs the log process in the background
+// waits for an interrupt signal, cancels the context to stop log consumption, waits for the consumer to drain, exits
+func main() {
+	configs, err := config.LoadManager()
 	if err != nil {
-		level = logrus.InfoLevel
+		logrus.WithError(err).Fatal("Failed to load configuration")
 	}
-	logger.SetLevel(level)
-
-	// Connect to PostgreSQL
-	pgStorage, err := storage.NewPostgresStorage(cfg.DatabaseURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create storage: %w", err)
+	cfg := configs.Get()
+	if cfg.QueueBackend == config.QueueMemory {
+		logrus.Fatal("QUEUE_BACKEND=memory only works in the combined logbuilder binary")
 	}
 
-	// Connect to Redis
-	redisClient, err := storage.NewRedisClient(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB)
+	res, err := app.Open(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Redis client: %w", err)
-	}
-
-	return &ProcessorService{
-		storage:     pgStorage,
-		redisClient: redisClient,
-		logger:      logger,
-		config:      cfg,
-	}, nil
-}
-
-func (s *ProcessorService) Close() error {
-	if err := s.storage.Close(); err != nil {
-		s.logger.WithError(err).Error("Failed to close database")
-	}
-	if err := s.redisClient.Close(); err != nil {
-		s.logger.WithError(err).Error("Failed to close Redis")
+		logrus.WithError(err).Fatal("Failed to open storage")
 	}
-	return nil
-}
-
-// processLog handles a single log entry
-func (s *ProcessorService) processLog(log *models.LogEntry) error {
-	// Store in PostgreSQL
-	if err := s.storage.InsertLog(log); err != nil {
-		return fmt.Errorf("failed to store log in database: %w", err)
-	}
-
-	s.logger.WithFields(logrus.Fields{
-		"log_id":  log.ID,
-		"user_id": log.UserID,
-		"level":   log.Level,
-		"source":  log.Source,
-	}).Debug("Log processed and stored")
+	defer res.Close(logrus.StandardLogger())
 
-	return nil
-}
-
-// begins processing logs from Redis Stream
-func (s *ProcessorService) Start(ctx context.Context) error {
-	consumerGroup := "log-processors"
-	consumerName := fmt.Sprintf("processor-%d", os.Getpid())
-
-	s.logger.WithFields(logrus.Fields{
-		"consumer_group": consumerGroup,
-		"consumer_name":  consumerName,
-	}).Info("Starting log processor")
-
-	// Start consuming from Redis Stream
-	return s.redisClient.ConsumeLogStream(ctx, consumerGroup, consumerName, s.processLog)
-}
-
-// loads configuration, initializes the processor service, runs the log process in the background
-// waits for an interrupt signal, cancels the context to stop log consumption, waits briefly to allow cleanup, exits
-func main() {
-	cfg := config.Load()
-
-	processor, err := NewProcessorService(cfg)
+	service, err := processor.New(cfg, res)
 	if err != nil {
 		logrus.WithError(err).Fatal("Failed to create processor service")
 	}
-	defer processor.Close()
+	defer service.Close()
+	logger := service.Logger()
 
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Only the log level and dedup window apply to the processor on SIGHUP; its other settings need a restart
+	configs.OnReload(func(next *config.Config) {
+		res.Logs.SetDedupWindow(next.IdempotencyTTL)
+		if level, err := logrus.ParseLevel(next.LogLevel); err == nil {
+			logger.SetLevel(level)
+		}
+	})
+	configs.WatchSIGHUP(ctx, logger)
+
+	// Serve Prometheus metrics and probes
+	if cfg.MetricsAddr != "none" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		mux.Handle("/livez", service.Health().LiveHandler())
+		mux.Handle("/readyz", service.Health().ReadyHandler())
+		metricsServer := &http.Server{Addr: cfg.MetricsAddr, Handler: mux}
+		go func() {
+			logger.Infof("Serving metrics on %s", cfg.MetricsAddr)
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.WithError(err).Error("Metrics listener stopped with error")
+			}
+		}()
+		defer metricsServer.Close()
+	}
+
 	// Start processor in goroutine
+	stopped := make(chan error, 1)
 	go func() {
-		if err := processor.Start(ctx); err != nil && err != context.Canceled {
-			processor.logger.WithError(err).Error("Processor stopped with error")
-		}
+		stopped <- service.Start(ctx)
 	}()
 
-	processor.logger.Info("Processor service started successfully")
+	logger.Info("Processor service started successfully")
 
-	// Wait for interrupt signal
+	// Wait for interrupt signal, or for the consumer to fail on its own
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	select {
+	case <-quit:
+	case err := <-stopped:
+		logger.WithError(err).Error("Processor stopped with error")
+		return
+	}
 
-	processor.logger.Info("Shutting down processor service...")
-	cancel() // Cancel context to stop consumer
+	logger.Info("Shutting down processor service...")
+	cancel() // Stop reading new messages; the current batch is drained
 
-	// Give some time for graceful shutdown
-	time.Sleep(2 * time.Second)
-	processor.logger.Info("Processor service stopped")
+	// The consumer gives up on its batch after ShutdownTimeout, this only guards against a hung handler
+	select {
+	case err := <-stopped:
+		if err != nil && !errors.Is(err, context.Canceled) {
+			logger.WithError(err).Error("Processor stopped with error")
+		}
+	case <-time.After(cfg.ShutdownTimeout + 5*time.Second):
+		logger.Warn("Processor did not stop in time, exiting with messages still pending")
+	}
+	logger.Info("Processor service stopped")
 }
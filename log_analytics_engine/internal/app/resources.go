@@ -0,0 +1,95 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/config"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/queue"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+/*
+this file opens the databases, Redis and the log queue the services run on. The separate ingester and
+processor binaries each open their own; the combined logbuilder binary opens one set and shares it, which
+is what lets it use the in-process queue and keep the rest of the shared state in process too
+*/
+
+type Resources struct {
+	Postgres *storage.PostgresStorage
+	Logs     storage.LogStore     // Postgres unless LOG_STORE selects SQLite
+	Redis    *storage.RedisClient // nil when QUEUE_BACKEND is memory
+	State    storage.StateStore   // caches, rate limits and multiline buffers; Redis unless QUEUE_BACKEND is memory
+	Queue    queue.Queue          // the Redis stream unless QUEUE_BACKEND selects the in-process queue
+}
+
+// connects to everything the configuration names, closing what was opened if any of it fails
+func Open(cfg *config.Config) (_ *Resources, err error) {
+	res := &Resources{}
+	defer func() {
+		if err != nil {
+			res.Close(logrus.StandardLogger())
+		}
+	}()
+
+	res.Postgres, err = storage.NewPostgresStorage(cfg.DatabaseURL, storage.PoolConfig{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage: %w", err)
+	}
+
+	// Logs stay in Postgres unless LOG_STORE selects the embedded SQLite store
+	res.Logs, err = storage.OpenLogStore(cfg.LogStore, cfg.SQLitePath, res.Postgres)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log store: %w", err)
+	}
+	// Event IDs, including the ones derived from Idempotency-Keys, are deduplicated for as long as the keys are
+	res.Logs.SetDedupWindow(cfg.IdempotencyTTL)
+
+	if cfg.QueueBackend == config.QueueMemory {
+		// Nothing is shared with other processes, so Redis isn't needed at all
+		res.State = storage.NewMemoryState()
+		res.Queue = queue.NewMemory(cfg.StreamName, cfg.MemoryQueueSize)
+		return res, nil
+	}
+
+	res.Redis, err = storage.NewRedisClient(storage.RedisOptions{
+		Addr:         cfg.RedisAddr,
+		Password:     cfg.RedisPassword,
+		DB:           cfg.RedisDB,
+		PoolSize:     cfg.RedisPoolSize,
+		MinIdleConns: cfg.RedisMinIdleConns,
+		DialTimeout:  cfg.RedisDialTimeout,
+		ReadTimeout:  cfg.RedisReadTimeout,
+		WriteTimeout: cfg.RedisWriteTimeout,
+		Stream:       cfg.StreamName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Redis client: %w", err)
+	}
+	res.State = res.Redis
+	res.Queue = res.Redis
+	return res, nil
+}
+
+// closes whatever was opened
+func (r *Resources) Close(logger *logrus.Logger) {
+	if r.Logs != nil && r.Logs != storage.LogStore(r.Postgres) {
+		if err := r.Logs.Close(); err != nil {
+			logger.WithError(err).Error("Failed to close log store")
+		}
+	}
+	if r.Postgres != nil {
+		if err := r.Postgres.Close(); err != nil {
+			logger.WithError(err).Error("Failed to close database")
+		}
+	}
+	if r.Redis != nil {
+		if err := r.Redis.Close(); err != nil {
+			logger.WithError(err).Error("Failed to close Redis")
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// PerUser caches one value per user in memory and reloads it once it is older than the TTL.
+// Each process keeps its own copy, so changes made through the API reach other
+// processes within one TTL.
+type PerUser[V any] struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	load    func(userID int) (V, error)
+	entries map[int]perUserEntry[V]
+}
+
+type perUserEntry[V any] struct {
+	value    V
+	loadedAt time.Time
+}
+
+// creates a cache that calls load on a miss or after ttl has passed
+func NewPerUser[V any](ttl time.Duration, load func(userID int) (V, error)) *PerUser[V] {
+	return &PerUser[V]{
+		ttl:     ttl,
+		load:    load,
+		entries: make(map[int]perUserEntry[V]),
+	}
+}
+
+// Get returns the cached value, loading it if needed. If a reload fails the stale
+// value is returned together with the error so callers can keep working.
+func (c *PerUser[V]) Get(userID int) (V, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[userID]
+	c.mu.Unlock()
+
+	if ok && time.Since(entry.loadedAt) < c.ttl {
+		return entry.value, nil
+	}
+
+	value, err := c.load(userID)
+	if err != nil {
+		return entry.value, err
+	}
+
+	c.mu.Lock()
+	c.entries[userID] = perUserEntry[V]{value: value, loadedAt: time.Now()}
+	c.mu.Unlock()
+
+	return value, nil
+}
+
+// Invalidate drops a user's value so the next Get reloads it
+func (c *PerUser[V]) Invalidate(userID int) {
+	c.mu.Lock()
+	delete(c.entries, userID)
+	c.mu.Unlock()
+}
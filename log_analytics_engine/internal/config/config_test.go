@@ -0,0 +1,185 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// unsets every environment variable the config reads, so tests only see the settings they give
+func clearEnv(t *testing.T) {
+	t.Helper()
+	s := &source{used: make(map[string]bool)}
+	s.load()
+	for key := range s.used {
+		t.Setenv(key, "")
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFile(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": `
+database:
+  url: postgres://app:secret@db:5432/logs
+server_port: 9000
+max-body-bytes: 1048576
+quarantine_rejected: true
+claim_idle: 2m
+cors_allowed_origins:
+  - https://a.example.com
+  - https://b.example.com
+`,
+		"config.toml": `
+server_port = "9000"
+max_body_bytes = 1048576
+quarantine_rejected = true
+claim_idle = "2m"
+cors_allowed_origins = ["https://a.example.com", "https://b.example.com"]
+
+[database]
+url = "postgres://app:secret@db:5432/logs"
+`,
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			clearEnv(t)
+			cfg, err := LoadFile(writeFile(t, name, content))
+			if err != nil {
+				t.Fatalf("LoadFile failed: %v", err)
+			}
+			if cfg.DatabaseURL != "postgres://app:secret@db:5432/logs" {
+				t.Errorf("DatabaseURL = %q", cfg.DatabaseURL)
+			}
+			if cfg.ServerPort != "9000" || cfg.MaxBodyBytes != 1<<20 || !cfg.Quarantine || cfg.ClaimIdle != 2*time.Minute {
+				t.Errorf("got port %q, max body %d, quarantine %v, claim idle %s", cfg.ServerPort, cfg.MaxBodyBytes, cfg.Quarantine, cfg.ClaimIdle)
+			}
+			if want := []string{"https://a.example.com", "https://b.example.com"}; !reflect.DeepEqual(cfg.CORSAllowedOrigins, want) {
+				t.Errorf("CORSAllowedOrigins = %v, want %v", cfg.CORSAllowedOrigins, want)
+			}
+			// Settings the file leaves out keep their defaults
+			if cfg.StreamName != "logs:incoming" {
+				t.Errorf("StreamName = %q, want the default", cfg.StreamName)
+			}
+		})
+	}
+}
+
+func TestEnvironmentOverridesFile(t *testing.T) {
+	clearEnv(t)
+	path := writeFile(t, "config.yml", "server_port: 9000\nlog_level: debug\n")
+	t.Setenv("SERVER_PORT", "7000")
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	if cfg.ServerPort != "7000" {
+		t.Errorf("ServerPort = %q, want the environment's 7000", cfg.ServerPort)
+	}
+	if cfg.LogLevel != "debug" {
+		t.Errorf("LogLevel = %q, want the file's debug", cfg.LogLevel)
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string
+	}{
+		{"unknown key", "config.yaml", "server_port: 9000\ndatabse_url: postgres://x\n", `unknown setting "databse_url"`},
+		{"unknown nested key", "config.toml", "[redis]\naddress = \"r:6379\"\n", `unknown setting "redis_address"`},
+		{"set twice", "config.yaml", "database_url: a\ndatabase:\n  url: b\n", "database_url is set twice"},
+		{"nested list", "config.yaml", "cors_allowed_origins:\n  - origin: x\n", "lists may only hold plain values"},
+		{"invalid value", "config.yaml", "max_body_bytes: lots\n", `MAX_BODY_BYTES: "lots" is not an integer`},
+		{"invalid yaml", "config.yaml", "server_port: [9000\n", "yaml: line 1"},
+		{"invalid toml", "config.toml", "server_port = \n", "toml:"},
+		{"unsupported extension", "config.json", `{"server_port": 9000}`, "unsupported extension"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			_, err := LoadFile(writeFile(t, tt.file, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadFile = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := LoadFile(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("LoadFile accepted a missing file")
+	}
+}
+
+func TestValidateProduction(t *testing.T) {
+	clearEnv(t)
+	secret := strings.Repeat("s", minProductionSecretLength)
+	production := func() *Config {
+		cfg := (&source{used: make(map[string]bool)}).load()
+		cfg.Environment = "Production"
+		cfg.JWTSecret = secret
+		cfg.RedactHashKey = secret
+		cfg.DatabaseURL = "postgres://app:strong@db:5432/logs"
+		return cfg
+	}
+
+	if errs := production().Validate(); len(errs) != 0 {
+		t.Fatalf("Validate = %v, want a valid production config", errs)
+	}
+
+	tests := []struct {
+		name   string
+		change func(*Config)
+		want   string
+	}{
+		{"default JWT secret", func(c *Config) { c.JWTSecret = DefaultJWTSecret }, "JWT_SECRET: the default secret cannot be used in production"},
+		{"short JWT secret", func(c *Config) { c.JWTSecret = "short" }, "JWT_SECRET: must be at least 32 characters"},
+		{"default database password", func(c *Config) { c.DatabaseURL = DefaultDatabaseURL }, "DATABASE_URL: the default development credentials"},
+		{"default redaction key", func(c *Config) { c.RedactHashKey = DefaultRedactHashKey }, "REDACT_HASH_KEY: the default key"},
+		{"short redaction key", func(c *Config) { c.RedactHashKey = "short" }, "REDACT_HASH_KEY: must be at least 32 characters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := production()
+			tt.change(cfg)
+			errs := cfg.Validate()
+			if len(errs) != 1 || !strings.Contains(errs[0].Error(), tt.want) {
+				t.Errorf("Validate = %v, want one error containing %q", errs, tt.want)
+			}
+
+			// The same settings are fine outside production
+			cfg.Environment = "development"
+			if errs := cfg.Validate(); len(errs) != 0 {
+				t.Errorf("Validate in development = %v, want no errors", errs)
+			}
+		})
+	}
+}
+
+func TestLoadRefusesDefaultsInProduction(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("ENVIRONMENT", "production")
+
+	_, err := LoadFile("")
+	if err == nil {
+		t.Fatal("LoadFile accepted the development defaults in production")
+	}
+	for _, want := range []string{"JWT_SECRET", "DATABASE_URL", "REDACT_HASH_KEY"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't mention %s", err, want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+/*
+this file reads YAML and TOML config files into the same flat key space as the environment variables, so
+database_url: ..., or database: {url: ...}, both set DATABASE_URL; lists are joined with commas
+*/
+
+// reads a .yaml, .yml or .toml file into settings keyed by environment variable name
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var tree map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported extension %q (use .yaml, .yml or .toml)", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	if err := flatten(values, "", tree); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// adds every leaf of tree to values, joining nested keys with "_"
+func flatten(values map[string]string, prefix string, tree map[string]any) error {
+	for key, value := range tree {
+		name := strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+
+		if nested, ok := value.(map[string]any); ok {
+			if err := flatten(values, name, nested); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, dup := values[name]; dup {
+			return fmt.Errorf("%s is set twice", strings.ToLower(name))
+		}
+
+		switch v := value.(type) {
+		case []any:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				if _, nested := item.(map[string]any); nested {
+					return fmt.Errorf("%s: lists may only hold plain values", strings.ToLower(name))
+				}
+				items = append(items, fmt.Sprint(item))
+			}
+			values[name] = strings.Join(items, ",")
+		case nil:
+			values[name] = ""
+		default:
+			values[name] = fmt.Sprint(v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+)
+
+/*
+this file reloads the configuration on SIGHUP; only settings read per request (log level, body and batch limits,
+rate limits and quotas, timestamp policy, CORS origins...) change at runtime, the rest need a restart
+*/
+
+// settings that take effect without a restart
+var reloadable = []string{
+	"LogLevel",
+	"MaxBodyBytes",
+	"Quarantine",
+	"IdempotencyTTL",
+	"MaxBatchSize",
+	"NDJSONChunkSize",
+	"PublishTimeout",
+	"CORSAllowedOrigins",
+	"CORSAllowCredentials",
+	"CORSMaxAge",
+	"CORSExposedHeaders",
+	"TimestampPolicy",
+	"TimestampMaxPast",
+	"TimestampMaxFuture",
+	"RateLimitEventsPerSecond",
+	"RateLimitBytesPerSecond",
+	"RateLimitKeyEventsPerSecond",
+	"RateLimitKeyBytesPerSecond",
+	"DailyEventQuota",
+	"DailyByteQuota",
+}
+
+// holds the current configuration and swaps in reloadable settings on SIGHUP
+type Manager struct {
+	path    string
+	current atomic.Pointer[Config]
+
+	mu        sync.Mutex
+	callbacks []func(*Config)
+}
+
+// loads the configuration like Load and wraps it in a manager that reloads the same file
+func LoadManager() (*Manager, error) {
+	path := os.Getenv("CONFIG_FILE")
+	cfg, err := LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewManager(path, cfg), nil
+}
+
+// creates a manager for cfg, which was loaded from path (empty when there is no config file)
+func NewManager(path string, cfg *Config) *Manager {
+	m := &Manager{path: path}
+	m.current.Store(cfg)
+	return m
+}
+
+// returns the current configuration, which must not be modified
+func (m *Manager) Get() *Config {
+	return m.current.Load()
+}
+
+// registers fn to run with the new configuration after every successful reload
+func (m *Manager) OnReload(fn func(*Config)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.callbacks = append(m.callbacks, fn)
+}
+
+// loads the file and environment again and applies the reloadable settings, returning the names
+// of changed settings that need a restart; on error the current configuration is kept
+func (m *Manager) Reload() (ignored []string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	loaded, err := LoadFile(m.path)
+	if err != nil {
+		return nil, err
+	}
+
+	old := m.Get()
+	next := *old
+	oldValue := reflect.ValueOf(old).Elem()
+	loadedValue := reflect.ValueOf(loaded).Elem()
+	nextValue := reflect.ValueOf(&next).Elem()
+
+	isReloadable := make(map[string]bool, len(reloadable))
+	for _, name := range reloadable {
+		isReloadable[name] = true
+		nextValue.FieldByName(name).Set(loadedValue.FieldByName(name))
+	}
+	for i := 0; i < oldValue.NumField(); i++ {
+		name := oldValue.Type().Field(i).Name
+		if !isReloadable[name] && !reflect.DeepEqual(oldValue.Field(i).Interface(), loadedValue.Field(i).Interface()) {
+			ignored = append(ignored, name)
+		}
+	}
+
+	m.current.Store(&next)
+	for _, fn := range m.callbacks {
+		fn(&next)
+	}
+	return ignored, nil
+}
+
+// reloads the configuration whenever the process receives SIGHUP, until ctx is done
+func (m *Manager) WatchSIGHUP(ctx context.Context, logger *logrus.Logger) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				ignored, err := m.Reload()
+				if err != nil {
+					logger.WithError(err).Error("Configuration reload failed, keeping the current settings")
+					continue
+				}
+				if len(ignored) > 0 {
+					logger.WithField("settings", ignored).Warn("Changed settings need a restart to take effect")
+				}
+				logger.WithField("file", m.path).Info("Configuration reloaded")
+			}
+		}
+	}()
+}
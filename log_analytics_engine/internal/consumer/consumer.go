@@ -0,0 +1,204 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/metrics"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+/*
+this file runs the processor's read-process-acknowledge loop over a stream consumer group
+
+messages are acknowledged only after their handler succeeded, so anything not acknowledged stays pending
+for the group and is delivered again. On shutdown the consumer stops reading, finishes the batch it
+already read for up to DrainTimeout, acknowledges what was committed and leaves the rest pending.
+Messages left pending longer than ClaimIdle, by a failed handler or a processor that died, are claimed
+and handled again
+*/
+
+// Stream is the consumer-group API the consumer needs; storage.RedisClient implements it over
+// Redis Streams and queue.Memory within the process
+type Stream interface {
+	EnsureGroup(ctx context.Context, group string) error
+	// returns up to count new messages for the consumer, waiting at most block for one to arrive
+	ReadGroup(ctx context.Context, group, consumer string, count int, block time.Duration) ([]Message, error)
+	Ack(ctx context.Context, group string, ids ...string) error
+	// takes over up to count messages that have been pending for at least minIdle
+	Claim(ctx context.Context, group, consumer string, minIdle time.Duration, count int) ([]Message, error)
+}
+
+// Message is a stream entry whose Data is a JSON-encoded models.LogEntry
+type Message struct {
+	ID   string
+	Data string
+}
+
+type Consumer struct {
+	stream  Stream
+	group   string
+	name    string
+	handler func(*models.LogEntry) error
+	logger  *logrus.Logger
+
+	BatchSize    int           // messages per read, default 10
+	Block        time.Duration // how long a read waits for messages, default 1s
+	DrainTimeout time.Duration // how long the in-flight batch may run after shutdown starts, default 10s
+	ClaimIdle    time.Duration // how long a message stays pending before it is claimed again, default 1m, 0 disables
+}
+
+func New(stream Stream, group, name string, handler func(*models.LogEntry) error, logger *logrus.Logger) *Consumer {
+	return &Consumer{
+		stream:       stream,
+		group:        group,
+		name:         name,
+		handler:      handler,
+		logger:       logger,
+		BatchSize:    10,
+		Block:        time.Second,
+		DrainTimeout: 10 * time.Second,
+		ClaimIdle:    time.Minute,
+	}
+}
+
+// Run consumes until ctx is cancelled, then drains the in-flight batch and returns ctx.Err()
+func (c *Consumer) Run(ctx context.Context) error {
+	if err := c.stream.EnsureGroup(ctx, c.group); err != nil {
+		return fmt.Errorf("failed to create consumer group: %w", err)
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"group":    c.group,
+		"consumer": c.name,
+	}).Info("Starting to consume from stream")
+
+	var lastClaim time.Time
+	for {
+		if ctx.Err() != nil {
+			c.logger.Info("Consumer context cancelled, stopping...")
+			return ctx.Err()
+		}
+
+		// Retry stale pending messages first, starting with whatever a previous run left behind
+		if c.ClaimIdle > 0 && time.Since(lastClaim) >= c.ClaimIdle {
+			lastClaim = time.Now()
+			if claimed := c.claim(ctx); len(claimed) > 0 {
+				if len(claimed) == c.BatchSize {
+					// There may be more, claim again on the next pass
+					lastClaim = time.Time{}
+				}
+				c.processBatch(ctx, claimed)
+				continue
+			}
+		}
+
+		messages, err := c.stream.ReadGroup(ctx, c.group, c.name, c.BatchSize, c.Block)
+		if err != nil {
+			if ctx.Err() != nil {
+				continue
+			}
+			c.logger.WithError(err).Error("Failed to read from stream")
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		if len(messages) == 0 {
+			continue
+		}
+
+		metrics.ProcessorBatchSize.Observe(float64(len(messages)))
+		c.processBatch(ctx, messages)
+	}
+}
+
+// claims messages pending for longer than ClaimIdle
+func (c *Consumer) claim(ctx context.Context) []Message {
+	messages, err := c.stream.Claim(ctx, c.group, c.name, c.ClaimIdle, c.BatchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			c.logger.WithError(err).Error("Failed to claim pending messages")
+		}
+		return nil
+	}
+	if len(messages) > 0 {
+		c.logger.WithField("count", len(messages)).Warn("Claimed messages left pending, retrying them")
+		metrics.MessagesClaimed.Add(float64(len(messages)))
+	}
+	return messages
+}
+
+// handles a batch in order, then acknowledges every message that was committed or is undeliverable.
+// Once shutdown has waited DrainTimeout, the remaining messages are left pending
+func (c *Consumer) processBatch(ctx context.Context, messages []Message) {
+	var drainDeadline <-chan time.Time
+	acked := make([]string, 0, len(messages))
+
+	for i, message := range messages {
+		if ctx.Err() != nil && drainDeadline == nil {
+			timer := time.NewTimer(c.DrainTimeout)
+			defer timer.Stop()
+			drainDeadline = timer.C
+		}
+		select {
+		case <-drainDeadline:
+			c.logger.WithField("pending", len(messages)-i).Warn("Drain timeout reached, leaving remaining messages pending")
+			c.ack(acked)
+			return
+		default:
+		}
+
+		if c.handle(message) {
+			acked = append(acked, message.ID)
+		}
+	}
+	c.ack(acked)
+}
+
+// processes one message, reporting whether it should be acknowledged
+func (c *Consumer) handle(message Message) bool {
+	var log models.LogEntry
+	if message.Data == "" {
+		c.logger.WithField("message_id", message.ID).Error("Invalid message format: missing log field")
+		metrics.Errors.WithLabelValues("decode").Inc()
+		return true // acknowledge bad messages to remove them from pending
+	}
+	if err := json.Unmarshal([]byte(message.Data), &log); err != nil {
+		c.logger.WithError(err).WithField("message_id", message.ID).Error("Failed to unmarshal log")
+		metrics.Errors.WithLabelValues("decode").Inc()
+		return true
+	}
+
+	if err := c.handler(&log); err != nil {
+		// Don't acknowledge - message will be retried
+		c.logger.WithError(err).WithField("message_id", message.ID).Error("Handler failed to process log")
+		return false
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"message_id": message.ID,
+		"user_id":    log.UserID,
+		"level":      log.Level,
+	}).Debug("Message processed")
+	return true
+}
+
+// acknowledges with its own context so a cancelled consumer still acknowledges what it committed
+func (c *Consumer) ack(ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := c.stream.Ack(ctx, c.group, ids...); err != nil {
+		c.logger.WithError(err).WithField("count", len(ids)).Error("Failed to acknowledge messages")
+		metrics.Errors.WithLabelValues("ack").Inc()
+	}
+}
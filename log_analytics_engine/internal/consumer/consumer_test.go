@@ -0,0 +1,308 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeStream is an in-memory consumer group with a single group
+type fakeStream struct {
+	mu      sync.Mutex
+	queued  []Message            // not yet delivered
+	pending map[string]*delivery // delivered and not acknowledged
+	acked   []string             // in acknowledgement order
+	claims  []time.Duration      // minIdle of every Claim call
+}
+
+type delivery struct {
+	message     Message
+	consumer    string
+	deliveredAt time.Time
+}
+
+func newFakeStream(ids ...string) *fakeStream {
+	s := &fakeStream{pending: make(map[string]*delivery)}
+	for _, id := range ids {
+		s.queued = append(s.queued, testMessage(id))
+	}
+	return s
+}
+
+// a message whose log message is its ID, so handlers can tell them apart
+func testMessage(id string) Message {
+	data, _ := json.Marshal(&models.LogEntry{Message: id})
+	return Message{ID: id, Data: string(data)}
+}
+
+// marks a message as delivered to another consumer at deliveredAt, as if that consumer died
+func (s *fakeStream) deliverTo(consumer, id string, deliveredAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[id] = &delivery{message: testMessage(id), consumer: consumer, deliveredAt: deliveredAt}
+}
+
+func (s *fakeStream) EnsureGroup(ctx context.Context, group string) error {
+	return nil
+}
+
+func (s *fakeStream) ReadGroup(ctx context.Context, group, consumer string, count int, block time.Duration) ([]Message, error) {
+	s.mu.Lock()
+	if len(s.queued) == 0 {
+		s.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(block):
+			return nil, nil
+		}
+	}
+	defer s.mu.Unlock()
+
+	n := min(count, len(s.queued))
+	messages := s.queued[:n]
+	s.queued = s.queued[n:]
+	for _, message := range messages {
+		s.pending[message.ID] = &delivery{message: message, consumer: consumer, deliveredAt: time.Now()}
+	}
+	return messages, nil
+}
+
+func (s *fakeStream) Ack(ctx context.Context, group string, ids ...string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		if _, ok := s.pending[id]; ok {
+			delete(s.pending, id)
+			s.acked = append(s.acked, id)
+		}
+	}
+	return nil
+}
+
+func (s *fakeStream) Claim(ctx context.Context, group, consumer string, minIdle time.Duration, count int) ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.claims = append(s.claims, minIdle)
+
+	ids := make([]string, 0, len(s.pending))
+	for id := range s.pending {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var messages []Message
+	for _, id := range ids {
+		d := s.pending[id]
+		if len(messages) == count || time.Since(d.deliveredAt) < minIdle {
+			continue
+		}
+		d.consumer = consumer
+		d.deliveredAt = time.Now()
+		messages = append(messages, d.message)
+	}
+	return messages, nil
+}
+
+func (s *fakeStream) isAcked(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, acked := range s.acked {
+		if acked == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *fakeStream) ackedIDs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.acked...)
+}
+
+func (s *fakeStream) pendingIDs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []string
+	for id := range s.pending {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func newTestConsumer(stream Stream, handler func(*models.LogEntry) error) *Consumer {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	c := New(stream, "group", "test", handler, logger)
+	c.Block = 10 * time.Millisecond
+	c.ClaimIdle = 0
+	return c
+}
+
+// runs the consumer until done reports true, then stops it and waits for Run to return
+func runUntil(t *testing.T, c *Consumer, done func() bool) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- c.Run(ctx) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatal("timed out waiting for the consumer")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Run returned %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't return after cancel")
+	}
+}
+
+func equalIDs(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAcksOnlyAfterHandlerCommits(t *testing.T) {
+	stream := newFakeStream("1", "2", "3")
+	var handled []string
+	c := newTestConsumer(stream, func(log *models.LogEntry) error {
+		if stream.isAcked(log.Message) {
+			t.Errorf("message %s acknowledged before its handler committed", log.Message)
+		}
+		handled = append(handled, log.Message)
+		return nil
+	})
+
+	runUntil(t, c, func() bool { return len(stream.ackedIDs()) == 3 })
+
+	if want := []string{"1", "2", "3"}; !equalIDs(handled, want) {
+		t.Errorf("handled %v, want %v", handled, want)
+	}
+	if pending := stream.pendingIDs(); len(pending) != 0 {
+		t.Errorf("pending %v, want none", pending)
+	}
+}
+
+func TestHandlerErrorLeavesMessagePending(t *testing.T) {
+	stream := newFakeStream("1", "2", "3")
+	c := newTestConsumer(stream, func(log *models.LogEntry) error {
+		if log.Message == "2" {
+			return errors.New("database unavailable")
+		}
+		return nil
+	})
+
+	runUntil(t, c, func() bool { return len(stream.ackedIDs()) == 2 })
+
+	if acked, want := stream.ackedIDs(), []string{"1", "3"}; !equalIDs(acked, want) {
+		t.Errorf("acknowledged %v, want %v", acked, want)
+	}
+	if pending, want := stream.pendingIDs(), []string{"2"}; !equalIDs(pending, want) {
+		t.Errorf("pending %v, want %v", pending, want)
+	}
+}
+
+func TestDrainTimeoutLeavesRemainingMessagesPending(t *testing.T) {
+	stream := newFakeStream("1", "2", "3", "4")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := newTestConsumer(stream, func(log *models.LogEntry) error {
+		// Shutdown starts while the first message is handled, and each takes longer than the drain timeout
+		if log.Message == "1" {
+			cancel()
+		}
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+	c.DrainTimeout = 20 * time.Millisecond
+
+	if err := c.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run returned %v, want context.Canceled", err)
+	}
+
+	// 1 finished before shutdown and 2 was started within the drain timeout, both are committed and
+	// acknowledged even though ctx is cancelled; 3 and 4 weren't started
+	if acked, want := stream.ackedIDs(), []string{"1", "2"}; !equalIDs(acked, want) {
+		t.Errorf("acknowledged %v, want %v", acked, want)
+	}
+	if pending, want := stream.pendingIDs(), []string{"3", "4"}; !equalIDs(pending, want) {
+		t.Errorf("pending %v, want %v", pending, want)
+	}
+}
+
+func TestCancelledContextStillAcksCommittedMessages(t *testing.T) {
+	stream := newFakeStream("1", "2")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := newTestConsumer(stream, func(log *models.LogEntry) error {
+		cancel()
+		return nil
+	})
+
+	if err := c.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run returned %v, want context.Canceled", err)
+	}
+	if acked, want := stream.ackedIDs(), []string{"1", "2"}; !equalIDs(acked, want) {
+		t.Errorf("acknowledged %v, want %v", acked, want)
+	}
+}
+
+func TestClaimsIdlePendingMessages(t *testing.T) {
+	stream := newFakeStream()
+	start := time.Now()
+	stream.deliverTo("dead-processor", "1", start)
+
+	var handledAfter time.Duration
+	c := newTestConsumer(stream, func(log *models.LogEntry) error {
+		handledAfter = time.Since(start)
+		return nil
+	})
+	c.ClaimIdle = 50 * time.Millisecond
+
+	runUntil(t, c, func() bool { return stream.isAcked("1") })
+
+	if handledAfter < c.ClaimIdle {
+		t.Errorf("message claimed after %v, before it was idle for %v", handledAfter, c.ClaimIdle)
+	}
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	if len(stream.claims) < 2 {
+		t.Fatalf("Claim called %d times, want a claim at start and one after ClaimIdle", len(stream.claims))
+	}
+	for _, minIdle := range stream.claims {
+		if minIdle != c.ClaimIdle {
+			t.Errorf("Claim called with minIdle %v, want %v", minIdle, c.ClaimIdle)
+		}
+	}
+}
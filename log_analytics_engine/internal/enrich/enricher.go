@@ -0,0 +1,122 @@
+package enrich
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/cache"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+/*
+This package adds fields to logs in the processor, after parsing and before redaction:
+resolves an IP field against a local GeoIP database (geo_* fields)
+parses a User-Agent field into browser, OS and device (ua_* fields)
+joins fields against the user's uploaded lookup tables
+fields already present on a log are never overwritten
+*/
+
+// TableSource loads lookup tables; table metadata is polled every cache TTL,
+// rows are only reloaded when a table's updated_at changes
+type TableSource interface {
+	GetUserTables(userID int) ([]*models.LookupTable, error)
+	GetTableRows(tableID int) (map[string]map[string]string, error)
+}
+
+// Enricher applies the configured enrichments to log entries
+type Enricher struct {
+	geo     *GeoIP // nil disables GeoIP
+	ipField string
+	uaField string
+	source  TableSource
+	tables  *cache.PerUser[[]*models.LookupTable]
+	logger  *logrus.Logger
+
+	mu   sync.Mutex
+	rows map[int]*models.LookupTable // last loaded version of each table, by ID
+}
+
+// creates an enricher; geo may be nil and empty field names disable that enrichment
+func NewEnricher(geo *GeoIP, ipField, uaField string, source TableSource, ttl time.Duration, logger *logrus.Logger) *Enricher {
+	e := &Enricher{
+		geo:     geo,
+		ipField: ipField,
+		uaField: uaField,
+		source:  source,
+		logger:  logger,
+		rows:    make(map[int]*models.LookupTable),
+	}
+	e.tables = cache.NewPerUser(ttl, e.loadTables)
+	return e
+}
+
+// loads a user's tables, reusing rows of tables that haven't changed since the last load
+func (e *Enricher) loadTables(userID int) ([]*models.LookupTable, error) {
+	tables, err := e.source.GetUserTables(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, table := range tables {
+		e.mu.Lock()
+		previous := e.rows[table.ID]
+		e.mu.Unlock()
+
+		if previous != nil && previous.UpdatedAt.Equal(table.UpdatedAt) {
+			table.Rows = previous.Rows
+			continue
+		}
+
+		rows, err := e.source.GetTableRows(table.ID)
+		if err != nil {
+			return nil, err
+		}
+		table.Rows = rows
+
+		e.mu.Lock()
+		e.rows[table.ID] = table
+		e.mu.Unlock()
+	}
+	return tables, nil
+}
+
+// Apply adds enrichment fields to the entry
+func (e *Enricher) Apply(entry *models.LogEntry) {
+	if len(entry.Fields) == 0 {
+		return
+	}
+
+	if e.geo != nil && e.ipField != "" {
+		if ip := entry.Fields[e.ipField]; ip != "" {
+			addFields(entry, e.geo.Lookup(ip))
+		}
+	}
+
+	if e.uaField != "" {
+		if ua := entry.Fields[e.uaField]; ua != "" {
+			addFields(entry, ParseUserAgent(ua))
+		}
+	}
+
+	tables, err := e.tables.Get(entry.UserID)
+	if err != nil {
+		e.logger.WithError(err).WithField("user_id", entry.UserID).Warn("Failed to load lookup tables")
+	}
+	for _, table := range tables {
+		key := entry.Fields[table.MatchField]
+		if key == "" {
+			continue
+		}
+		addFields(entry, table.Rows[key])
+	}
+}
+
+// copies fields onto the entry without replacing values it already has
+func addFields(entry *models.LogEntry, fields map[string]string) {
+	for key, value := range fields {
+		if _, exists := entry.Fields[key]; !exists {
+			entry.Fields[key] = value
+		}
+	}
+}
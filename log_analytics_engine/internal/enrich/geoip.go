@@ -0,0 +1,159 @@
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/sirupsen/logrus"
+)
+
+// fields read from a MaxMind City, Country or ASN database; missing sections stay empty
+type geoRecord struct {
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	Location struct {
+		Latitude  *float64 `maxminddb:"latitude"`
+		Longitude *float64 `maxminddb:"longitude"`
+		TimeZone  string   `maxminddb:"time_zone"`
+	} `maxminddb:"location"`
+	ASN   uint   `maxminddb:"autonomous_system_number"`
+	ASOrg string `maxminddb:"autonomous_system_organization"`
+}
+
+// GeoIP resolves IP addresses against a local MaxMind-format database,
+// reopening the file when it is replaced (e.g. by geoipupdate)
+type GeoIP struct {
+	path    string
+	mu      sync.RWMutex
+	reader  *maxminddb.Reader
+	modTime time.Time
+}
+
+// opens the database at path
+func OpenGeoIP(path string) (*GeoIP, error) {
+	g := &GeoIP{path: path}
+	if err := g.reload(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (g *GeoIP) reload() error {
+	info, err := os.Stat(g.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat GeoIP database: %w", err)
+	}
+
+	reader, err := maxminddb.Open(g.path)
+	if err != nil {
+		return fmt.Errorf("failed to open GeoIP database: %w", err)
+	}
+
+	g.mu.Lock()
+	old := g.reader
+	g.reader = reader
+	g.modTime = info.ModTime()
+	g.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// Watch reopens the database whenever its modification time changes, until ctx is done
+func (g *GeoIP) Watch(ctx context.Context, interval time.Duration, logger *logrus.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(g.path)
+			if err != nil {
+				logger.WithError(err).Warn("Failed to check GeoIP database")
+				continue
+			}
+
+			g.mu.RLock()
+			changed := !info.ModTime().Equal(g.modTime)
+			g.mu.RUnlock()
+			if !changed {
+				continue
+			}
+
+			if err := g.reload(); err != nil {
+				// Keep serving lookups from the previous file
+				logger.WithError(err).Warn("Failed to reload GeoIP database")
+				continue
+			}
+			logger.WithField("path", g.path).Info("Reloaded GeoIP database")
+		}
+	}
+}
+
+// Lookup returns geo fields for an IP address, or nil if it isn't in the database
+func (g *GeoIP) Lookup(value string) map[string]string {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil
+	}
+
+	var record geoRecord
+	g.mu.RLock()
+	err := g.reader.Lookup(ip, &record)
+	g.mu.RUnlock()
+	if err != nil {
+		return nil
+	}
+
+	fields := make(map[string]string)
+	set := func(key, value string) {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+	set("geo_country_code", record.Country.ISOCode)
+	set("geo_country", record.Country.Names["en"])
+	if len(record.Subdivisions) > 0 {
+		set("geo_region", record.Subdivisions[0].Names["en"])
+	}
+	set("geo_city", record.City.Names["en"])
+	if record.Location.Latitude != nil && record.Location.Longitude != nil {
+		set("geo_latitude", strconv.FormatFloat(*record.Location.Latitude, 'f', -1, 64))
+		set("geo_longitude", strconv.FormatFloat(*record.Location.Longitude, 'f', -1, 64))
+	}
+	set("geo_timezone", record.Location.TimeZone)
+	if record.ASN != 0 {
+		set("geo_asn", strconv.FormatUint(uint64(record.ASN), 10))
+	}
+	set("geo_as_org", record.ASOrg)
+
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+func (g *GeoIP) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.reader.Close()
+}
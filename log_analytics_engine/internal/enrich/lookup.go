@@ -0,0 +1,87 @@
+package enrich
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+)
+
+// ParseCSV reads a lookup table whose first row is the header. Rows are keyed by
+// keyColumn (the first column if empty); the key column itself is not copied onto logs
+func ParseCSV(r io.Reader, keyColumn string) (*models.LookupTable, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("CSV is empty")
+		}
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+		if header[i] == "" {
+			return nil, fmt.Errorf("CSV header has an empty column name at position %d", i+1)
+		}
+	}
+	if len(header) < 2 {
+		return nil, fmt.Errorf("CSV needs a key column and at least one value column")
+	}
+
+	if keyColumn == "" {
+		keyColumn = header[0]
+	}
+	keyIndex := -1
+	for i, name := range header {
+		if name == keyColumn {
+			keyIndex = i
+		}
+	}
+	if keyIndex < 0 {
+		return nil, fmt.Errorf("key column %q is not in the CSV header", keyColumn)
+	}
+
+	table := &models.LookupTable{
+		KeyColumn: keyColumn,
+		Rows:      make(map[string]map[string]string),
+	}
+	for i, name := range header {
+		if i != keyIndex {
+			table.Columns = append(table.Columns, name)
+		}
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		if len(table.Rows) >= models.MaxLookupTableRows {
+			return nil, fmt.Errorf("lookup tables cannot exceed %d rows", models.MaxLookupTableRows)
+		}
+
+		key := strings.TrimSpace(record[keyIndex])
+		if key == "" {
+			continue
+		}
+		row := make(map[string]string, len(record)-1)
+		for i, value := range record {
+			if i != keyIndex && value != "" {
+				row[header[i]] = value
+			}
+		}
+		// Later rows win, so a CSV can be appended to in order to override a key
+		table.Rows[key] = row
+	}
+
+	table.RowCount = len(table.Rows)
+	return table, nil
+}
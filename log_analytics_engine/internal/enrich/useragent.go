@@ -0,0 +1,36 @@
+package enrich
+
+import (
+	"github.com/mssola/useragent"
+)
+
+// parses a User-Agent header into browser, OS and device fields
+func ParseUserAgent(value string) map[string]string {
+	ua := useragent.New(value)
+
+	fields := make(map[string]string)
+	set := func(key, value string) {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+
+	browser, version := ua.Browser()
+	set("ua_browser", browser)
+	set("ua_browser_version", version)
+
+	os := ua.OSInfo()
+	set("ua_os", os.Name)
+	set("ua_os_version", os.Version)
+
+	switch {
+	case ua.Bot():
+		fields["ua_device"] = "bot"
+	case ua.Mobile():
+		fields["ua_device"] = "mobile"
+	default:
+		fields["ua_device"] = "desktop"
+	}
+
+	return fields
+}
@@ -0,0 +1,170 @@
+package gelf
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/metrics"
+)
+
+/*
+This file reassembles chunked GELF UDP datagrams:
+each chunk starts with the magic bytes 0x1e 0x0f, an 8 byte message ID, a sequence number and a sequence count
+chunks are held until every sequence number has arrived, then concatenated in order
+incomplete messages are discarded once they are older than the timeout
+the number of pending messages and the bytes they buffer are capped, so a flood of first chunks can't exhaust memory
+*/
+
+const (
+	chunkHeaderSize = 12
+	maxChunks       = 128 // limit defined by the GELF spec
+
+	maxPendingMessages = 1024
+	maxPendingBytes    = 64 << 20
+)
+
+// ErrAssemblerFull is returned for chunks dropped because the pending message or byte limit was reached
+var ErrAssemblerFull = errors.New("too many pending GELF chunked messages")
+
+// DefaultChunkTimeout is the time the GELF spec allows for all chunks of a message to arrive
+const DefaultChunkTimeout = 5 * time.Second
+
+// partially received chunked message
+type pendingMessage struct {
+	chunks    [][]byte
+	received  int
+	size      int
+	firstSeen time.Time
+}
+
+// collects GELF chunks and returns complete payloads
+type Assembler struct {
+	mu       sync.Mutex
+	pending  map[[8]byte]*pendingMessage
+	buffered int // bytes held across all pending messages
+	timeout  time.Duration
+
+	maxPending int
+	maxBytes   int
+}
+
+// creates a new chunk Assembler; messages not completed within timeout are dropped
+func NewAssembler(timeout time.Duration) *Assembler {
+	if timeout <= 0 {
+		timeout = DefaultChunkTimeout
+	}
+	return &Assembler{
+		pending:    make(map[[8]byte]*pendingMessage),
+		timeout:    timeout,
+		maxPending: maxPendingMessages,
+		maxBytes:   maxPendingBytes,
+	}
+}
+
+// reports whether the datagram is a GELF chunk
+func IsChunked(packet []byte) bool {
+	return len(packet) >= 2 && packet[0] == 0x1e && packet[1] == 0x0f
+}
+
+// adds a chunk and returns the complete payload once every chunk has arrived
+func (a *Assembler) Add(packet []byte) ([]byte, bool, error) {
+	if len(packet) < chunkHeaderSize || !IsChunked(packet) {
+		return nil, false, fmt.Errorf("invalid GELF chunk header")
+	}
+
+	var id [8]byte
+	copy(id[:], packet[2:10])
+	seq := int(packet[10])
+	count := int(packet[11])
+
+	if count == 0 || count > maxChunks {
+		return nil, false, fmt.Errorf("invalid GELF chunk count: %d", count)
+	}
+	if seq >= count {
+		return nil, false, fmt.Errorf("GELF chunk sequence %d out of range (count %d)", seq, count)
+	}
+
+	body := packet[chunkHeaderSize:]
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	msg, ok := a.pending[id]
+	if !ok {
+		// New messages are dropped while full, so the ones already pending can still complete
+		if len(a.pending) >= a.maxPending || a.buffered+len(body) > a.maxBytes {
+			metrics.GELFChunkedDropped.WithLabelValues("full").Inc()
+			return nil, false, ErrAssemblerFull
+		}
+		msg = &pendingMessage{
+			chunks:    make([][]byte, count),
+			firstSeen: time.Now(),
+		}
+		a.pending[id] = msg
+	}
+
+	if len(msg.chunks) != count {
+		a.remove(id, msg)
+		return nil, false, fmt.Errorf("GELF chunk count changed mid-message")
+	}
+	if msg.chunks[seq] != nil {
+		return nil, false, nil // Duplicate chunk, ignore it
+	}
+
+	if msg.size+len(body) > maxMessageSize {
+		a.remove(id, msg)
+		return nil, false, fmt.Errorf("chunked GELF message exceeds %d bytes", maxMessageSize)
+	}
+	if a.buffered+len(body) > a.maxBytes {
+		a.remove(id, msg)
+		metrics.GELFChunkedDropped.WithLabelValues("full").Inc()
+		return nil, false, ErrAssemblerFull
+	}
+
+	msg.chunks[seq] = append([]byte(nil), body...)
+	msg.received++
+	msg.size += len(body)
+	a.buffered += len(body)
+
+	if msg.received < count {
+		return nil, false, nil
+	}
+
+	a.remove(id, msg)
+	payload := make([]byte, 0, msg.size)
+	for _, chunk := range msg.chunks {
+		payload = append(payload, chunk...)
+	}
+	return payload, true, nil
+}
+
+// drops incomplete messages older than the timeout and returns how many were dropped
+func (a *Assembler) Expire(now time.Time) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	expired := 0
+	for id, msg := range a.pending {
+		if now.Sub(msg.firstSeen) > a.timeout {
+			a.remove(id, msg)
+			expired++
+		}
+	}
+	metrics.GELFChunkedDropped.WithLabelValues("expired").Add(float64(expired))
+	return expired
+}
+
+// forgets a pending message and releases its bytes; the caller holds the lock
+func (a *Assembler) remove(id [8]byte, msg *pendingMessage) {
+	delete(a.pending, id)
+	a.buffered -= msg.size
+}
+
+// returns the number of messages still waiting for chunks
+func (a *Assembler) Pending() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.pending)
+}
@@ -0,0 +1,190 @@
+package gelf
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/metrics"
+)
+
+// reads how many chunked messages have been dropped for a reason
+func dropped(t *testing.T, reason string) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := metrics.GELFChunkedDropped.WithLabelValues(reason).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+// builds a GELF chunk of message id with the given sequence number and count
+func chunk(id byte, seq, count int, body string) []byte {
+	packet := []byte{0x1e, 0x0f, id, 0, 0, 0, 0, 0, 0, 0, byte(seq), byte(count)}
+	return append(packet, body...)
+}
+
+func TestAssemblerReassembles(t *testing.T) {
+	a := NewAssembler(time.Minute)
+
+	// Chunks may arrive in any order
+	for _, packet := range [][]byte{chunk(1, 2, 3, "!"), chunk(1, 0, 3, "hello "), chunk(2, 0, 2, "other ")} {
+		if payload, complete, err := a.Add(packet); err != nil || complete {
+			t.Fatalf("Add = %q, %v, %v, want an incomplete message", payload, complete, err)
+		}
+	}
+	if a.Pending() != 2 {
+		t.Errorf("Pending = %d, want 2", a.Pending())
+	}
+
+	payload, complete, err := a.Add(chunk(1, 1, 3, "world"))
+	if err != nil || !complete || string(payload) != "hello world!" {
+		t.Fatalf("Add = %q, %v, %v, want the complete message", payload, complete, err)
+	}
+	if a.Pending() != 1 || a.buffered != len("other ") {
+		t.Errorf("Pending = %d with %d bytes buffered, want only message 2 left", a.Pending(), a.buffered)
+	}
+
+	// A message of one chunk completes at once
+	if payload, complete, err := a.Add(chunk(3, 0, 1, "single")); err != nil || !complete || string(payload) != "single" {
+		t.Errorf("Add = %q, %v, %v, want the single chunk", payload, complete, err)
+	}
+}
+
+func TestAssemblerDuplicateChunk(t *testing.T) {
+	a := NewAssembler(time.Minute)
+	a.Add(chunk(1, 0, 2, "first "))
+
+	if payload, complete, err := a.Add(chunk(1, 0, 2, "again ")); err != nil || complete || payload != nil {
+		t.Fatalf("duplicate Add = %q, %v, %v, want it ignored", payload, complete, err)
+	}
+	if payload, _, _ := a.Add(chunk(1, 1, 2, "second")); string(payload) != "first second" {
+		t.Errorf("payload = %q, want the first copy of the duplicated chunk", payload)
+	}
+}
+
+func TestAssemblerRejectsInvalidChunks(t *testing.T) {
+	tests := []struct {
+		name   string
+		packet []byte
+	}{
+		{"short header", []byte{0x1e, 0x0f, 1, 2, 3}},
+		{"not a chunk", []byte(`{"short_message": "x"}`)},
+		{"zero count", chunk(1, 0, 0, "x")},
+		{"count over the spec limit", chunk(1, 0, maxChunks+1, "x")},
+		{"sequence equal to count", chunk(1, 2, 2, "x")},
+		{"sequence past count", chunk(1, 9, 2, "x")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAssembler(time.Minute)
+			if _, _, err := a.Add(tt.packet); err == nil {
+				t.Error("Add accepted the chunk")
+			}
+			if a.Pending() != 0 {
+				t.Errorf("Pending = %d, want 0", a.Pending())
+			}
+		})
+	}
+}
+
+func TestAssemblerCountChangedMidMessage(t *testing.T) {
+	a := NewAssembler(time.Minute)
+	a.Add(chunk(1, 0, 3, "abc"))
+	if _, _, err := a.Add(chunk(1, 1, 2, "def")); err == nil {
+		t.Fatal("Add accepted a chunk with a different count")
+	}
+	if a.Pending() != 0 || a.buffered != 0 {
+		t.Errorf("Pending = %d with %d bytes buffered, want the message dropped", a.Pending(), a.buffered)
+	}
+}
+
+func TestAssemblerMessageSizeLimit(t *testing.T) {
+	a := NewAssembler(time.Minute)
+	part := string(bytes.Repeat([]byte("x"), maxMessageSize/2))
+	a.Add(chunk(1, 0, 3, part))
+	a.Add(chunk(1, 1, 3, part))
+	if _, _, err := a.Add(chunk(1, 2, 3, "x")); err == nil {
+		t.Fatal("Add accepted a message over the size limit")
+	}
+	if a.Pending() != 0 || a.buffered != 0 {
+		t.Errorf("Pending = %d with %d bytes buffered, want the message dropped", a.Pending(), a.buffered)
+	}
+}
+
+func TestAssemblerExpire(t *testing.T) {
+	a := NewAssembler(time.Second)
+	expired := dropped(t, "expired")
+
+	a.Add(chunk(1, 0, 2, "old"))
+	if n := a.Expire(time.Now()); n != 0 {
+		t.Errorf("Expire before the timeout dropped %d messages", n)
+	}
+	if n := a.Expire(time.Now().Add(2 * time.Second)); n != 1 {
+		t.Errorf("Expire dropped %d messages, want 1", n)
+	}
+	if a.Pending() != 0 || a.buffered != 0 {
+		t.Errorf("Pending = %d with %d bytes buffered after expiry", a.Pending(), a.buffered)
+	}
+	if got := dropped(t, "expired") - expired; got != 1 {
+		t.Errorf("expired drops counted = %v, want 1", got)
+	}
+
+	// The late chunk starts a new message rather than completing the expired one
+	if _, complete, _ := a.Add(chunk(1, 1, 2, "late")); complete {
+		t.Error("a chunk completed an expired message")
+	}
+}
+
+func TestAssemblerPendingLimits(t *testing.T) {
+	full := func(t *testing.T) float64 { return dropped(t, "full") }
+
+	t.Run("messages", func(t *testing.T) {
+		a := NewAssembler(time.Minute)
+		a.maxPending = 2
+		before := full(t)
+
+		a.Add(chunk(1, 0, 2, "a"))
+		a.Add(chunk(2, 0, 2, "b"))
+		if _, _, err := a.Add(chunk(3, 0, 2, "c")); !errors.Is(err, ErrAssemblerFull) {
+			t.Fatalf("Add of a third message = %v, want ErrAssemblerFull", err)
+		}
+		if got := full(t) - before; got != 1 {
+			t.Errorf("full drops counted = %v, want 1", got)
+		}
+
+		// Messages already pending can still complete, which makes room for new ones
+		if payload, complete, err := a.Add(chunk(1, 1, 2, "z")); err != nil || !complete || string(payload) != "az" {
+			t.Fatalf("Add = %q, %v, %v, want message 1 completed", payload, complete, err)
+		}
+		if _, _, err := a.Add(chunk(3, 0, 2, "c")); err != nil {
+			t.Errorf("Add after a message completed = %v", err)
+		}
+	})
+
+	t.Run("bytes", func(t *testing.T) {
+		a := NewAssembler(time.Minute)
+		a.maxBytes = 10
+		before := full(t)
+
+		a.Add(chunk(1, 0, 3, "12345"))
+		if _, _, err := a.Add(chunk(2, 0, 2, "123456")); !errors.Is(err, ErrAssemblerFull) {
+			t.Fatalf("Add of a new message over the byte limit = %v, want ErrAssemblerFull", err)
+		}
+		if _, _, err := a.Add(chunk(1, 1, 3, "12345")); err != nil {
+			t.Fatalf("Add up to the byte limit = %v", err)
+		}
+		// A pending message that would go over the limit is dropped, releasing its bytes
+		if _, _, err := a.Add(chunk(1, 2, 3, "1")); !errors.Is(err, ErrAssemblerFull) {
+			t.Fatalf("Add of a chunk over the byte limit = %v, want ErrAssemblerFull", err)
+		}
+		if a.Pending() != 0 || a.buffered != 0 {
+			t.Errorf("Pending = %d with %d bytes buffered, want the message dropped", a.Pending(), a.buffered)
+		}
+		if got := full(t) - before; got != 2 {
+			t.Errorf("full drops counted = %v, want 2", got)
+		}
+	})
+}
@@ -0,0 +1,198 @@
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+)
+
+/*
+This file handles decoding of GELF (Graylog Extended Log Format) payloads:
+detects gzip/zlib compression and inflates the payload
+parses the GELF JSON document into a Message
+converts a Message into an IngestRequest so it goes through the normal validation path
+*/
+
+// maximum size of a decompressed GELF message, protects against zip bombs
+const maxMessageSize = 8 << 20
+
+// APIKeyField is the additional field GELF UDP senders use to authenticate, since UDP has no headers
+const APIKeyField = "_api_key"
+
+// single decoded GELF message
+type Message struct {
+	Version      string
+	Host         string
+	ShortMessage string
+	FullMessage  string
+	Timestamp    *time.Time
+	Level        *int
+	Extra        map[string]string
+}
+
+// inflates the payload if it is gzip or zlib compressed and parses it into a Message
+func Decode(data []byte) (*Message, error) {
+	payload, err := decompress(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid GELF JSON: %w", err)
+	}
+
+	msg := &Message{Extra: make(map[string]string)}
+
+	for key, value := range raw {
+		switch key {
+		case "version":
+			msg.Version = stringify(value)
+		case "host":
+			msg.Host = stringify(value)
+		case "short_message":
+			msg.ShortMessage = stringify(value)
+		case "full_message":
+			msg.FullMessage = stringify(value)
+		case "timestamp":
+			ts, err := parseTimestamp(value)
+			if err != nil {
+				return nil, err
+			}
+			msg.Timestamp = ts
+		case "level":
+			level, err := strconv.Atoi(stringify(value))
+			if err != nil {
+				return nil, fmt.Errorf("invalid GELF level: %v", value)
+			}
+			msg.Level = &level
+		default:
+			// Only "_"-prefixed keys are additional fields, everything else is ignored per the GELF spec
+			if strings.HasPrefix(key, "_") && key != "_id" {
+				msg.Extra[key] = stringify(value)
+			}
+		}
+	}
+
+	if msg.Host == "" {
+		return nil, fmt.Errorf("GELF message is missing host")
+	}
+	if msg.ShortMessage == "" {
+		return nil, fmt.Errorf("GELF message is missing short_message")
+	}
+
+	return msg, nil
+}
+
+// returns the API key carried in the additional fields, if any
+func (m *Message) APIKey() string {
+	return m.Extra[APIKeyField]
+}
+
+// converts the GELF message into an IngestRequest
+func (m *Message) ToIngestRequest() *models.IngestRequest {
+	level := 1 // GELF default is ALERT when level is missing
+	if m.Level != nil {
+		level = *m.Level
+	}
+
+	fields := make(map[string]string, len(m.Extra)+1)
+	service := ""
+	for key, value := range m.Extra {
+		name := strings.TrimPrefix(key, "_")
+		switch key {
+		case APIKeyField:
+			continue // Never store credentials
+		case "_service":
+			service = value
+			continue
+		}
+		fields[name] = value
+	}
+	if m.FullMessage != "" {
+		fields["full_message"] = m.FullMessage
+	}
+	if len(fields) == 0 {
+		fields = nil
+	}
+
+	return &models.IngestRequest{
+		Timestamp: m.Timestamp,
+		Source:    m.Host,
+		Level:     models.SyslogLevel(level),
+		Message:   m.ShortMessage,
+		Service:   service,
+		Fields:    fields,
+	}
+}
+
+// detects compression from the magic bytes and returns the inflated payload
+func decompress(data []byte) ([]byte, error) {
+	var reader io.ReadCloser
+	var err error
+
+	switch {
+	case len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b:
+		reader, err = gzip.NewReader(bytes.NewReader(data))
+	case len(data) >= 2 && data[0] == 0x78 && (uint16(data[0])<<8|uint16(data[1]))%31 == 0:
+		reader, err = zlib.NewReader(bytes.NewReader(data))
+	default:
+		return data, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open compressed GELF payload: %w", err)
+	}
+	defer reader.Close()
+
+	payload, err := io.ReadAll(io.LimitReader(reader, maxMessageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress GELF payload: %w", err)
+	}
+	if len(payload) > maxMessageSize {
+		return nil, fmt.Errorf("GELF payload exceeds %d bytes", maxMessageSize)
+	}
+
+	return payload, nil
+}
+
+// GELF timestamps are seconds since epoch with optional decimal milliseconds
+func parseTimestamp(value interface{}) (*time.Time, error) {
+	seconds, err := strconv.ParseFloat(stringify(value), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GELF timestamp: %v", value)
+	}
+	whole, frac := math.Modf(seconds)
+	ts := time.Unix(int64(whole), int64(math.Round(frac*1e3))*int64(time.Millisecond)).UTC()
+	return &ts, nil
+}
+
+// converts an arbitrary JSON value to the string form stored in Fields
+func stringify(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(encoded)
+	}
+}
@@ -0,0 +1,99 @@
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+	"testing"
+)
+
+func compress(t *testing.T, newWriter func(io.Writer) io.WriteCloser, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := newWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	return compress(t, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }, data)
+}
+
+func zlibbed(t *testing.T, data []byte) []byte {
+	return compress(t, func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }, data)
+}
+
+func TestDecodeCompressed(t *testing.T) {
+	doc := []byte(`{"version": "1.1", "host": "web-1", "short_message": "hello", "level": 3, "_service": "api"}`)
+	for name, data := range map[string][]byte{"plain": doc, "gzip": gzipped(t, doc), "zlib": zlibbed(t, doc)} {
+		t.Run(name, func(t *testing.T) {
+			msg, err := Decode(data)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			if msg.Host != "web-1" || msg.ShortMessage != "hello" || msg.Extra["_service"] != "api" {
+				t.Errorf("got %+v", msg)
+			}
+		})
+	}
+}
+
+func TestDecompressLimit(t *testing.T) {
+	atLimit := bytes.Repeat([]byte(" "), maxMessageSize)
+	overLimit := bytes.Repeat([]byte(" "), maxMessageSize+1)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{"gzip at the limit", gzipped(t, atLimit), ""},
+		{"zlib at the limit", zlibbed(t, atLimit), ""},
+		{"gzip over the limit", gzipped(t, overLimit), "exceeds"},
+		{"zlib over the limit", zlibbed(t, overLimit), "exceeds"},
+		{"truncated gzip", gzipped(t, atLimit)[:20], "failed to decompress"},
+		{"gzip header only", []byte{0x1f, 0x8b}, "failed to open"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := decompress(tt.data)
+			if tt.wantErr == "" {
+				if err != nil || len(payload) != maxMessageSize {
+					t.Errorf("decompress = %d bytes, %v, want %d bytes", len(payload), err, maxMessageSize)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("decompress = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"not JSON", "hello", "invalid GELF JSON"},
+		{"missing host", `{"short_message": "x"}`, "missing host"},
+		{"missing short_message", `{"host": "web-1"}`, "missing short_message"},
+		{"invalid level", `{"host": "web-1", "short_message": "x", "level": "loud"}`, "invalid GELF level"},
+		{"invalid timestamp", `{"host": "web-1", "short_message": "x", "timestamp": "noon"}`, "invalid GELF timestamp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode([]byte(tt.data)); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Decode = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
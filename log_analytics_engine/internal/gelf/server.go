@@ -0,0 +1,105 @@
+package gelf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// largest datagram we will read, the GELF spec recommends chunks of at most 8192 bytes
+const maxDatagramSize = 65535
+
+// UDPServer receives GELF datagrams, reassembles chunks and hands complete messages to a handler
+type UDPServer struct {
+	addr      string
+	handler   func(context.Context, *Message) error
+	assembler *Assembler
+	logger    *logrus.Logger
+}
+
+// creates a new UDPServer listening on addr
+func NewUDPServer(addr string, handler func(context.Context, *Message) error, logger *logrus.Logger) *UDPServer {
+	return &UDPServer{
+		addr:      addr,
+		handler:   handler,
+		assembler: NewAssembler(DefaultChunkTimeout),
+		logger:    logger,
+	}
+}
+
+// listens for datagrams until the context is cancelled
+func (s *UDPServer) ListenAndServe(ctx context.Context) error {
+	conn, err := net.ListenPacket("udp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+	defer conn.Close()
+
+	s.logger.Infof("GELF UDP listener started on %s", s.addr)
+
+	// Close the socket on shutdown to unblock ReadFrom
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	// Periodically drop chunked messages that never completed
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if expired := s.assembler.Expire(now); expired > 0 {
+					s.logger.WithField("count", expired).Warn("Dropped incomplete GELF chunked messages")
+				}
+			}
+		}
+	}()
+
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, remote, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			s.logger.WithError(err).Error("Failed to read GELF datagram")
+			continue
+		}
+
+		packet := make([]byte, n)
+		copy(packet, buf[:n])
+
+		if IsChunked(packet) {
+			payload, complete, err := s.assembler.Add(packet)
+			if errors.Is(err, ErrAssemblerFull) {
+				continue // Counted in metrics, logging each one would flood the log too
+			}
+			if err != nil {
+				s.logger.WithError(err).WithField("remote", remote.String()).Warn("Invalid GELF chunk")
+				continue
+			}
+			if !complete {
+				continue
+			}
+			packet = payload
+		}
+
+		msg, err := Decode(packet)
+		if err != nil {
+			s.logger.WithError(err).WithField("remote", remote.String()).Warn("Invalid GELF message")
+			continue
+		}
+
+		if err := s.handler(ctx, msg); err != nil {
+			s.logger.WithError(err).WithField("remote", remote.String()).Warn("Failed to handle GELF message")
+		}
+	}
+}
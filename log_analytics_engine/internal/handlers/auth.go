@@ -9,6 +9,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/auth"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/metrics"
 	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
 	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/storage"
 	"github.com/sirupsen/logrus"
@@ -16,16 +17,16 @@ import (
 
 type AuthHandler struct {
 	authStorage *storage.AuthStorage
-	redisClient *storage.RedisClient
+	state       storage.StateStore
 	jwtService  *auth.JWTService
 	logger      *logrus.Logger
 }
 
 // creates a new AuthHandler with JWT and logger and other dependencies
-func NewAuthHandler(authStorage *storage.AuthStorage, redisClient *storage.RedisClient, jwtService *auth.JWTService, logger *logrus.Logger) *AuthHandler {
+func NewAuthHandler(authStorage *storage.AuthStorage, state storage.StateStore, jwtService *auth.JWTService, logger *logrus.Logger) *AuthHandler {
 	return &AuthHandler{
 		authStorage: authStorage,
-		redisClient: redisClient,
+		state:       state,
 		jwtService:  jwtService,
 		logger:      logger,
 	}
@@ -270,7 +271,7 @@ func (h *AuthHandler) DeleteAPIKey(c *gin.Context) {
 				go func(apiKey string) {
 					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 					defer cancel()
-					if err := h.redisClient.InvalidateCachedAPIKey(ctx, apiKey); err != nil {
+					if err := h.state.InvalidateCachedAPIKey(ctx, apiKey); err != nil {
 						h.logger.WithError(err).Warn("Failed to invalidate cached API key")
 					}
 				}(key.APIKey)
@@ -356,38 +357,42 @@ func (h *AuthHandler) APIKeyAuthMiddleware() gin.HandlerFunc {
 		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 		defer cancel()
 
-		// Try to get from Redis cache first
-		userID, err := h.redisClient.GetCachedAPIKey(ctx, apiKey)
-		if err == nil {
-			// Cache hit - use cached user ID
-			h.logger.Debug("API key validated from cache")
-			c.Set("user_id", userID)
-			c.Next()
+		userID, err := h.AuthenticateAPIKey(ctx, apiKey)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Invalid API key",
+			})
+			c.Abort()
 			return
 		}
 
-		// Cache miss - validate from database
-		h.logger.Debug("API key not in cache, validating from database")
-		user, err := h.authStorage.ValidateAPIKey(apiKey)
+		c.Set("user_id", userID)
+		c.Set("api_key", apiKey)
+		c.Next()
+	}
+}
+
+// must run after JWTAuthMiddleware; only lets users flagged as admins through
+func (h *AuthHandler) AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		isAdmin, err := h.authStorage.IsAdmin(c.GetInt("user_id"))
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid API key",
+			h.logger.WithError(err).Error("Failed to check admin status")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to check permissions",
 			})
 			c.Abort()
 			return
 		}
 
-		// Cache the API key for 15 minutes
-		go func() {
-			cacheCtx, cacheCancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer cacheCancel()
-			if err := h.redisClient.CacheAPIKey(cacheCtx, apiKey, user.ID, 15*time.Minute); err != nil {
-				h.logger.WithError(err).Warn("Failed to cache API key")
-			}
-		}()
+		if !isAdmin {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Admin access required",
+			})
+			c.Abort()
+			return
+		}
 
-		c.Set("user_id", user.ID)
-		c.Set("username", user.Username)
 		c.Next()
 	}
 }
@@ -430,18 +435,7 @@ func (h *AuthHandler) JWTOrAPIKeyAuthMiddleware() gin.HandlerFunc {
 		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 		defer cancel()
 
-		// Try to get from Redis cache first
-		userID, err := h.redisClient.GetCachedAPIKey(ctx, token)
-		if err == nil {
-			// Cache hit - use cached user ID
-			h.logger.Debug("API key validated from cache")
-			c.Set("user_id", userID)
-			c.Next()
-			return
-		}
-
-		// Cache miss - validate from database
-		user, err := h.authStorage.ValidateAPIKey(token)
+		userID, err := h.AuthenticateAPIKey(ctx, token)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "Invalid or expired token/API key",
@@ -450,17 +444,38 @@ func (h *AuthHandler) JWTOrAPIKeyAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Cache the API key for 15 minutes
-		go func() {
-			cacheCtx, cacheCancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer cacheCancel()
-			if err := h.redisClient.CacheAPIKey(cacheCtx, token, user.ID, 15*time.Minute); err != nil {
-				h.logger.WithError(err).Warn("Failed to cache API key")
-			}
-		}()
-
-		c.Set("user_id", user.ID)
-		c.Set("username", user.Username)
+		c.Set("user_id", userID)
 		c.Next()
 	}
 }
+
+// AuthenticateAPIKey resolves an API key to its user ID, checking the Redis cache before the database
+func (h *AuthHandler) AuthenticateAPIKey(ctx context.Context, apiKey string) (int, error) {
+	// Try to get from Redis cache first
+	userID, err := h.state.GetCachedAPIKey(ctx, apiKey)
+	if err == nil {
+		// Cache hit - use cached user ID
+		h.logger.Debug("API key validated from cache")
+		metrics.APIKeyCache.WithLabelValues("hit").Inc()
+		return userID, nil
+	}
+
+	// Cache miss - validate from database
+	h.logger.Debug("API key not in cache, validating from database")
+	metrics.APIKeyCache.WithLabelValues("miss").Inc()
+	user, err := h.authStorage.ValidateAPIKey(apiKey)
+	if err != nil {
+		return 0, err
+	}
+
+	// Cache the API key for 15 minutes
+	go func() {
+		cacheCtx, cacheCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cacheCancel()
+		if err := h.state.CacheAPIKey(cacheCtx, apiKey, user.ID, 15*time.Minute); err != nil {
+			h.logger.WithError(err).Warn("Failed to cache API key")
+		}
+	}()
+
+	return user.ID, nil
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// manages the client certificates agents use to ingest logs over mutual TLS instead of API keys
+type ClientCertHandler struct {
+	certStorage *storage.ClientCertStorage
+	state       storage.StateStore
+	logger      *logrus.Logger
+}
+
+func NewClientCertHandler(certStorage *storage.ClientCertStorage, state storage.StateStore, logger *logrus.Logger) *ClientCertHandler {
+	return &ClientCertHandler{
+		certStorage: certStorage,
+		state:       state,
+		logger:      logger,
+	}
+}
+
+// GetCertificates handles GET /api/v1/client-certs
+func (h *ClientCertHandler) GetCertificates(c *gin.Context) {
+	certs, err := h.certStorage.GetUserCertificates(c.GetInt("user_id"))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get client certificates")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get client certificates",
+		})
+		return
+	}
+	if certs == nil {
+		certs = []*models.ClientCertificate{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"certificates": certs,
+		"count":        len(certs),
+	})
+}
+
+// CreateCertificate handles POST /api/v1/client-certs, registering for the user the client certificate
+// presented on this request's TLS connection. The handshake has verified it against TLS_CLIENT_CA_FILE
+// and that the caller holds its key, so nobody can claim another agent's certificate
+func (h *ClientCertHandler) CreateCertificate(c *gin.Context) {
+	var req models.CreateClientCertificateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	state := c.Request.TLS
+	if state == nil || len(state.VerifiedChains) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "No verified client certificate; call this endpoint over mutual TLS presenting the certificate to register",
+		})
+		return
+	}
+	parsed := state.VerifiedChains[0][0]
+
+	cert := &models.ClientCertificate{
+		UserID:      c.GetInt("user_id"),
+		Name:        req.Name,
+		Fingerprint: models.CertificateFingerprint(parsed),
+		Subject:     parsed.Subject.String(),
+		NotAfter:    parsed.NotAfter,
+	}
+	if err := h.certStorage.CreateCertificate(cert); err != nil {
+		if errors.Is(err, storage.ErrCertificateExists) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "Certificate is already registered",
+			})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to register client certificate")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to register client certificate",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, cert)
+}
+
+// DeleteCertificate handles DELETE /api/v1/client-certs/:id
+func (h *ClientCertHandler) DeleteCertificate(c *gin.Context) {
+	certID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid certificate ID",
+		})
+		return
+	}
+
+	fingerprint, err := h.certStorage.DeleteCertificate(certID, c.GetInt("user_id"))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to delete client certificate")
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Certificate not found",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := h.state.InvalidateCachedClientCert(ctx, fingerprint); err != nil {
+		h.logger.WithError(err).Warn("Failed to invalidate cached client certificate")
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Certificate deleted successfully",
+	})
+}
+
+// AuthenticateCertificate resolves a verified client certificate to its user ID, checking the Redis cache before the database
+func (h *ClientCertHandler) AuthenticateCertificate(ctx context.Context, cert *x509.Certificate) (int, error) {
+	fingerprint := models.CertificateFingerprint(cert)
+	if userID, err := h.state.GetCachedClientCert(ctx, fingerprint); err == nil {
+		return userID, nil
+	}
+
+	userID, err := h.certStorage.ValidateFingerprint(fingerprint)
+	if err != nil {
+		return 0, err
+	}
+
+	go func() {
+		cacheCtx, cacheCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cacheCancel()
+		if err := h.state.CacheClientCert(cacheCtx, fingerprint, userID, 15*time.Minute); err != nil {
+			h.logger.WithError(err).Warn("Failed to cache client certificate")
+		}
+	}()
+
+	return userID, nil
+}
+
+// authenticates requests whose TLS client certificate was verified and is registered to a user,
+// handing every other request to fallback (normally the API key middleware)
+func (h *ClientCertHandler) ClientCertOrAPIKeyAuthMiddleware(fallback gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		state := c.Request.TLS
+		if state == nil || len(state.VerifiedChains) == 0 {
+			fallback(c)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		leaf := state.VerifiedChains[0][0]
+		userID, err := h.AuthenticateCertificate(ctx, leaf)
+		if err != nil {
+			h.logger.WithError(err).WithField("subject", leaf.Subject.String()).Debug("Client certificate not accepted, trying API key")
+			fallback(c)
+			return
+		}
+
+		c.Set("user_id", userID)
+		// Per-key rate limits apply to each certificate as they would to an API key
+		c.Set("api_key", "cert:"+models.CertificateFingerprint(leaf))
+		c.Next()
+	}
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/ingestrules"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/metrics"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+type IngestRulesHandler struct {
+	ruleStorage *storage.IngestRuleStorage
+	state       storage.StateStore
+	rules       *ingestrules.Registry
+	logger      *logrus.Logger
+}
+
+// creates a new IngestRulesHandler; rules are cached for cacheTTL between reloads
+func NewIngestRulesHandler(ruleStorage *storage.IngestRuleStorage, state storage.StateStore, cacheTTL time.Duration, logger *logrus.Logger) *IngestRulesHandler {
+	return &IngestRulesHandler{
+		ruleStorage: ruleStorage,
+		state:       state,
+		rules:       ingestrules.NewRegistry(ruleStorage.GetActiveRules, cacheTTL, logger),
+		logger:      logger,
+	}
+}
+
+// Filter applies the user's ingest rules and returns the entries that should be queued
+func (h *IngestRulesHandler) Filter(userID int, entries []*models.LogEntry) []*models.LogEntry {
+	kept, stats := h.rules.Get(userID).Apply(entries)
+	metrics.Reject(metrics.RejectDropped, len(entries)-len(kept))
+	if len(stats) == 0 {
+		return kept
+	}
+
+	matched := make(map[int]int64, len(stats))
+	dropped := make(map[int]int64, len(stats))
+	for ruleID, s := range stats {
+		matched[ruleID] = s.Matched
+		dropped[ruleID] = s.Dropped
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := h.state.IncrIngestRuleStats(ctx, userID, matched, dropped); err != nil {
+		h.logger.WithError(err).Warn("Failed to count ingest rule stats")
+	}
+
+	return kept
+}
+
+// GetRules handles GET /api/v1/ingest-rules
+func (h *IngestRulesHandler) GetRules(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	rules, err := h.ruleStorage.GetUserRules(userID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get ingest rules")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get ingest rules",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	matched, dropped, err := h.state.GetIngestRuleStats(ctx, userID)
+	if err != nil {
+		// Counters are informational, still return the rules
+		h.logger.WithError(err).Warn("Failed to get ingest rule stats")
+	}
+
+	if rules == nil {
+		rules = []*models.IngestRule{}
+	}
+	for _, rule := range rules {
+		rule.Matched = matched[rule.ID]
+		rule.Dropped = dropped[rule.ID]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rules": rules,
+		"count": len(rules),
+	})
+}
+
+// CreateRule handles POST /api/v1/ingest-rules
+func (h *IngestRulesHandler) CreateRule(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	req, ok := h.bindRuleRequest(c)
+	if !ok {
+		return
+	}
+
+	rule := req.ToRule(userID)
+	if err := h.ruleStorage.CreateRule(rule); err != nil {
+		h.logger.WithError(err).Error("Failed to create ingest rule")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create ingest rule",
+		})
+		return
+	}
+	h.rules.Invalidate(userID)
+
+	h.logger.WithFields(logrus.Fields{
+		"user_id": userID,
+		"rule_id": rule.ID,
+		"action":  rule.Action,
+	}).Info("Ingest rule created")
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateRule handles PUT /api/v1/ingest-rules/:id
+func (h *IngestRulesHandler) UpdateRule(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid rule ID",
+		})
+		return
+	}
+
+	req, ok := h.bindRuleRequest(c)
+	if !ok {
+		return
+	}
+
+	rule := req.ToRule(userID)
+	rule.ID = id
+	if err := h.ruleStorage.UpdateRule(rule); err != nil {
+		h.logger.WithError(err).Warn("Failed to update ingest rule")
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Ingest rule not found",
+		})
+		return
+	}
+	h.rules.Invalidate(userID)
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteRule handles DELETE /api/v1/ingest-rules/:id
+func (h *IngestRulesHandler) DeleteRule(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid rule ID",
+		})
+		return
+	}
+
+	if err := h.ruleStorage.DeleteRule(id, userID); err != nil {
+		h.logger.WithError(err).Warn("Failed to delete ingest rule")
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Ingest rule not found",
+		})
+		return
+	}
+	h.rules.Invalidate(userID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := h.state.ResetIngestRuleStats(ctx, userID, id); err != nil {
+		h.logger.WithError(err).Warn("Failed to reset ingest rule stats")
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Ingest rule deleted successfully",
+	})
+}
+
+func (h *IngestRulesHandler) bindRuleRequest(c *gin.Context) (*models.CreateIngestRuleRequest, bool) {
+	var req models.CreateIngestRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+
+	return &req, true
+}
@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/config"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/metrics"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// how long effective limits are cached in Redis
+const limitsCacheTTL = time.Minute
+
+type LimitsHandler struct {
+	limitsStorage *storage.LimitsStorage
+	state         storage.StateStore
+	config        *config.Manager
+	logger        *logrus.Logger
+}
+
+// creates a new LimitsHandler; cfg provides the default limits for users without an override, reloaded on SIGHUP
+func NewLimitsHandler(limitsStorage *storage.LimitsStorage, state storage.StateStore, cfg *config.Manager, logger *logrus.Logger) *LimitsHandler {
+	return &LimitsHandler{
+		limitsStorage: limitsStorage,
+		state:         state,
+		config:        cfg,
+		logger:        logger,
+	}
+}
+
+// returns the configured default limits for a user
+func (h *LimitsHandler) defaultLimits(userID int) *models.IngestLimits {
+	cfg := h.config.Get()
+	return &models.IngestLimits{
+		UserID:             userID,
+		EventsPerSecond:    int64(cfg.RateLimitEventsPerSecond),
+		BytesPerSecond:     int64(cfg.RateLimitBytesPerSecond),
+		KeyEventsPerSecond: int64(cfg.RateLimitKeyEventsPerSecond),
+		KeyBytesPerSecond:  int64(cfg.RateLimitKeyBytesPerSecond),
+		DailyEventQuota:    int64(cfg.DailyEventQuota),
+		DailyByteQuota:     int64(cfg.DailyByteQuota),
+		IsDefault:          true,
+	}
+}
+
+// EffectiveLimits returns the user's override if one exists, otherwise the defaults
+func (h *LimitsHandler) EffectiveLimits(ctx context.Context, userID int) (*models.IngestLimits, error) {
+	if cached, err := h.state.GetCachedIngestLimits(ctx, userID); err == nil && cached != nil {
+		return cached, nil
+	}
+
+	limits, err := h.limitsStorage.GetIngestLimits(userID)
+	if err != nil {
+		return nil, err
+	}
+	if limits == nil {
+		limits = h.defaultLimits(userID)
+	}
+
+	if err := h.state.CacheIngestLimits(ctx, limits, limitsCacheTTL); err != nil {
+		h.logger.WithError(err).Warn("Failed to cache ingest limits")
+	}
+
+	return limits, nil
+}
+
+// Allow charges a request of the given size against the user's limits; apiKey may be empty
+func (h *LimitsHandler) Allow(ctx context.Context, userID int, apiKey string, events, bytes int64) (*storage.RateLimitResult, error) {
+	limits, err := h.EffectiveLimits(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	result, err := h.state.TakeIngestTokens(ctx, limits, apiKey, events, bytes)
+	if err == nil && !result.Allowed {
+		metrics.Reject(metrics.RejectRateLimited, int(events))
+	}
+	return result, err
+}
+
+// CheckIngest charges a request against the caller's limits, writing the X-RateLimit-* headers,
+// and responds with 429 when a limit is exceeded. It returns false if the request must stop.
+func (h *LimitsHandler) CheckIngest(c *gin.Context, userID int, entries []*models.LogEntry) bool {
+	var bytes int64
+	for _, entry := range entries {
+		bytes += entry.Size()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	result, err := h.Allow(ctx, userID, c.GetString("api_key"), int64(len(entries)), bytes)
+	if err != nil {
+		// Fail open so a Redis hiccup doesn't reject every ingest request
+		h.logger.WithError(err).Warn("Rate limit check failed, allowing request")
+		return true
+	}
+
+	retryAfter := SetRateLimitHeaders(c, result)
+	if result.Allowed {
+		return true
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"user_id": userID,
+		"reason":  result.Reason,
+		"events":  len(entries),
+		"bytes":   bytes,
+	}).Warn("Ingestion rate limit exceeded")
+
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Rate limit exceeded",
+		"limit":       result.Reason,
+		"retry_after": retryAfter,
+	})
+	return false
+}
+
+// SetRateLimitHeaders writes the X-RateLimit-* headers, plus Retry-After when the request was limited,
+// and returns the Retry-After value in seconds
+func SetRateLimitHeaders(c *gin.Context, result *storage.RateLimitResult) int64 {
+	if result.Limit > 0 {
+		c.Header("X-RateLimit-Limit", strconv.FormatInt(result.Limit, 10))
+		if result.Remaining >= 0 {
+			c.Header("X-RateLimit-Remaining", strconv.FormatInt(result.Remaining, 10))
+		}
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(int64(math.Ceil(result.Reset.Seconds())), 10))
+	}
+
+	if result.Allowed {
+		return 0
+	}
+
+	retryAfter := int64(math.Ceil(result.RetryAfter.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+	return retryAfter
+}
+
+// GetUsage handles GET /api/v1/account/usage
+func (h *LimitsHandler) GetUsage(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	limits, err := h.EffectiveLimits(ctx, userID.(int))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get ingest limits")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get usage",
+		})
+		return
+	}
+
+	usage, err := h.state.GetIngestUsage(ctx, limits)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get ingest usage")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get usage",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
+// GetUserLimits handles GET /api/v1/admin/users/:id/limits
+func (h *LimitsHandler) GetUserLimits(c *gin.Context) {
+	targetID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	limits, err := h.EffectiveLimits(ctx, targetID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get ingest limits")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get limits",
+		})
+		return
+	}
+
+	usage, err := h.state.GetIngestUsage(ctx, limits)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get ingest usage")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get limits",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
+// UpdateUserLimits handles PUT /api/v1/admin/users/:id/limits
+func (h *LimitsHandler) UpdateUserLimits(c *gin.Context) {
+	targetID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID",
+		})
+		return
+	}
+
+	var req models.UpdateIngestLimitsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	limits := req.ToLimits(targetID)
+	if err := h.limitsStorage.UpsertIngestLimits(limits); err != nil {
+		h.logger.WithError(err).Error("Failed to save ingest limits")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to save limits",
+		})
+		return
+	}
+
+	h.invalidate(targetID)
+
+	h.logger.WithFields(logrus.Fields{
+		"admin_id": c.GetInt("user_id"),
+		"user_id":  targetID,
+	}).Info("Ingest limits updated")
+
+	c.JSON(http.StatusOK, limits)
+}
+
+// ResetUserLimits handles DELETE /api/v1/admin/users/:id/limits, reverting the user to the defaults
+func (h *LimitsHandler) ResetUserLimits(c *gin.Context) {
+	targetID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID",
+		})
+		return
+	}
+
+	if err := h.limitsStorage.DeleteIngestLimits(targetID); err != nil {
+		h.logger.WithError(err).Error("Failed to delete ingest limits")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to reset limits",
+		})
+		return
+	}
+
+	h.invalidate(targetID)
+
+	c.JSON(http.StatusOK, h.defaultLimits(targetID))
+}
+
+// drops cached limits so the change applies to the next request
+func (h *LimitsHandler) invalidate(userID int) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := h.state.InvalidateIngestLimits(ctx, userID); err != nil {
+		h.logger.WithError(err).WithField("user_id", userID).Warn("Failed to invalidate cached limits")
+	}
+}
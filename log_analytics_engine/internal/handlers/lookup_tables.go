@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/enrich"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+type LookupTableHandler struct {
+	tableStorage *storage.LookupTableStorage
+	logger       *logrus.Logger
+}
+
+func NewLookupTableHandler(tableStorage *storage.LookupTableStorage, logger *logrus.Logger) *LookupTableHandler {
+	return &LookupTableHandler{
+		tableStorage: tableStorage,
+		logger:       logger,
+	}
+}
+
+// GetTables handles GET /api/v1/lookup-tables
+func (h *LookupTableHandler) GetTables(c *gin.Context) {
+	tables, err := h.tableStorage.GetUserTables(c.GetInt("user_id"))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get lookup tables")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get lookup tables",
+		})
+		return
+	}
+	if tables == nil {
+		tables = []*models.LookupTable{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tables": tables,
+		"count":  len(tables),
+	})
+}
+
+// GetTable handles GET /api/v1/lookup-tables/:name, returning up to ?limit= rows (default 100)
+func (h *LookupTableHandler) GetTable(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 0 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "limit must be between 0 and 1000",
+		})
+		return
+	}
+
+	table, err := h.tableStorage.GetTable(c.GetInt("user_id"), c.Param("name"))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get lookup table")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get lookup table",
+		})
+		return
+	}
+	if table == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Lookup table not found",
+		})
+		return
+	}
+
+	rows, err := h.tableStorage.GetTableRows(table.ID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get lookup table rows")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get lookup table",
+		})
+		return
+	}
+
+	keys := make([]string, 0, len(rows))
+	for key := range rows {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	preview := make([]map[string]string, 0, len(keys))
+	for _, key := range keys {
+		row := map[string]string{table.KeyColumn: key}
+		for column, value := range rows[key] {
+			row[column] = value
+		}
+		preview = append(preview, row)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"table": table,
+		"rows":  preview,
+	})
+}
+
+// PutTable handles PUT /api/v1/lookup-tables/:name with a CSV body.
+// ?match_field= names the log field to join on; ?key_column= the CSV column holding
+// its values (defaults to the first column, and match_field defaults to the key column)
+func (h *LookupTableHandler) PutTable(c *gin.Context) {
+	userID := c.GetInt("user_id")
+	name := c.Param("name")
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, models.MaxLookupTableBytes)
+	table, err := enrich.ParseCSV(body, c.Query("key_column"))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": "Lookup table exceeds the size limit",
+				"limit": models.MaxLookupTableBytes,
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid CSV",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	table.UserID = userID
+	table.Name = name
+	table.MatchField = c.DefaultQuery("match_field", table.KeyColumn)
+	if err := models.ValidateLookupTable(table.Name, table.MatchField); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.tableStorage.UpsertTable(table); err != nil {
+		h.logger.WithError(err).Error("Failed to save lookup table")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to save lookup table",
+		})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"user_id": userID,
+		"table":   table.Name,
+		"rows":    table.RowCount,
+	}).Info("Lookup table saved")
+
+	// Processors pick up the new rows within one rules cache TTL
+	c.JSON(http.StatusOK, table)
+}
+
+// DeleteTable handles DELETE /api/v1/lookup-tables/:name
+func (h *LookupTableHandler) DeleteTable(c *gin.Context) {
+	if err := h.tableStorage.DeleteTable(c.GetInt("user_id"), c.Param("name")); err != nil {
+		h.logger.WithError(err).Warn("Failed to delete lookup table")
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Lookup table not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Lookup table deleted successfully",
+	})
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/pipeline"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+type PipelineHandler struct {
+	pipelineStorage *storage.PipelineStorage
+	logger          *logrus.Logger
+}
+
+func NewPipelineHandler(pipelineStorage *storage.PipelineStorage, logger *logrus.Logger) *PipelineHandler {
+	return &PipelineHandler{
+		pipelineStorage: pipelineStorage,
+		logger:          logger,
+	}
+}
+
+// result of running a pipeline against one sample line
+type pipelineTestResult struct {
+	Input  string                 `json:"input"`
+	Output *models.LogEntry       `json:"output"`
+	Stages []pipeline.StageResult `json:"stages"`
+}
+
+// CreatePipeline handles POST /api/v1/pipelines
+func (h *PipelineHandler) CreatePipeline(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	req, ok := h.bindPipelineRequest(c)
+	if !ok {
+		return
+	}
+
+	p := req.ToPipeline(userID)
+	if err := h.pipelineStorage.CreatePipeline(p); err != nil {
+		h.logger.WithError(err).Error("Failed to create pipeline")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create pipeline",
+		})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"user_id":     userID,
+		"pipeline_id": p.ID,
+	}).Info("Pipeline created")
+
+	c.JSON(http.StatusCreated, p)
+}
+
+// GetPipelines handles GET /api/v1/pipelines
+func (h *PipelineHandler) GetPipelines(c *gin.Context) {
+	pipelines, err := h.pipelineStorage.GetUserPipelines(c.GetInt("user_id"))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get pipelines")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get pipelines",
+		})
+		return
+	}
+
+	if pipelines == nil {
+		pipelines = []*models.Pipeline{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"pipelines": pipelines,
+		"count":     len(pipelines),
+	})
+}
+
+// GetPipeline handles GET /api/v1/pipelines/:id
+func (h *PipelineHandler) GetPipeline(c *gin.Context) {
+	p, ok := h.findPipeline(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// UpdatePipeline handles PUT /api/v1/pipelines/:id
+func (h *PipelineHandler) UpdatePipeline(c *gin.Context) {
+	existing, ok := h.findPipeline(c)
+	if !ok {
+		return
+	}
+
+	req, ok := h.bindPipelineRequest(c)
+	if !ok {
+		return
+	}
+
+	p := req.ToPipeline(existing.UserID)
+	p.ID = existing.ID
+	p.CreatedAt = existing.CreatedAt
+	if err := h.pipelineStorage.UpdatePipeline(p); err != nil {
+		h.logger.WithError(err).Error("Failed to update pipeline")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update pipeline",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
+
+// DeletePipeline handles DELETE /api/v1/pipelines/:id
+func (h *PipelineHandler) DeletePipeline(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid pipeline ID",
+		})
+		return
+	}
+
+	if err := h.pipelineStorage.DeletePipeline(id, c.GetInt("user_id")); err != nil {
+		h.logger.WithError(err).Warn("Failed to delete pipeline")
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Pipeline not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Pipeline deleted successfully",
+	})
+}
+
+// TestPipeline handles POST /api/v1/pipelines/test, running a saved or unsaved
+// pipeline against sample lines without storing anything. Samples are joined first
+// when the pipeline has multiline rules.
+func (h *PipelineHandler) TestPipeline(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	var req models.TestPipelineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	config := &models.Pipeline{UserID: userID, Stages: req.Stages, Multiline: req.Multiline}
+	if req.PipelineID != 0 {
+		saved, err := h.pipelineStorage.GetPipeline(req.PipelineID, userID)
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to get pipeline")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to get pipeline",
+			})
+			return
+		}
+		if saved == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Pipeline not found",
+			})
+			return
+		}
+		config = saved
+	}
+
+	compiled, err := pipeline.Compile(config)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid pipeline",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	entries := make([]*models.LogEntry, 0, len(req.Samples))
+	for _, sample := range req.Samples {
+		entries = append(entries, &models.LogEntry{
+			Timestamp: time.Now(),
+			Source:    req.Source,
+			Level:     "INFO",
+			Message:   sample,
+			Service:   req.Service,
+			UserID:    userID,
+		})
+	}
+	if compiled.Multiline != nil {
+		entries = compiled.Multiline.JoinEntries(entries)
+	}
+
+	results := make([]pipelineTestResult, 0, len(entries))
+	for _, entry := range entries {
+		input := entry.Message
+		stages := compiled.Run(entry)
+		results = append(results, pipelineTestResult{
+			Input:  input,
+			Output: entry,
+			Stages: stages,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+		"count":   len(results),
+	})
+}
+
+// GetGrokPatterns handles GET /api/v1/pipelines/grok-patterns
+func (h *PipelineHandler) GetGrokPatterns(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"patterns": pipeline.GrokPatternNames(),
+	})
+}
+
+// binds and validates a create/update request, compiling it so invalid patterns are rejected before saving
+func (h *PipelineHandler) bindPipelineRequest(c *gin.Context) (*models.CreatePipelineRequest, bool) {
+	var req models.CreatePipelineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+
+	if _, err := pipeline.Compile(&models.Pipeline{Stages: req.Stages, Multiline: req.Multiline}); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid pipeline",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+
+	return &req, true
+}
+
+// loads the pipeline named by the :id parameter, responding with an error if it can't be found
+func (h *PipelineHandler) findPipeline(c *gin.Context) (*models.Pipeline, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid pipeline ID",
+		})
+		return nil, false
+	}
+
+	p, err := h.pipelineStorage.GetPipeline(id, c.GetInt("user_id"))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get pipeline")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get pipeline",
+		})
+		return nil, false
+	}
+	if p == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Pipeline not found",
+		})
+		return nil, false
+	}
+
+	return p, true
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
@@ -11,11 +12,11 @@ import (
 )
 
 type QueryHandler struct {
-	storage *storage.PostgresStorage
+	storage storage.LogStore
 	logger  *logrus.Logger
 }
 
-func NewQueryHandler(storage *storage.PostgresStorage, logger *logrus.Logger) *QueryHandler {
+func NewQueryHandler(storage storage.LogStore, logger *logrus.Logger) *QueryHandler {
 	return &QueryHandler{
 		storage: storage,
 		logger:  logger,
@@ -52,11 +53,10 @@ func (h *QueryHandler) QueryLogs(c *gin.Context) {
 		return
 	}
 
-	// Convert query to SQL
-	whereClause, args := req.ToSQL(userID.(int))
+	query := req.ToLogQuery(userID.(int))
 
 	// Get total count
-	totalCount, err := h.storage.CountLogs(userID.(int), whereClause, args)
+	totalCount, err := h.storage.CountLogs(query)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to count logs")
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -65,8 +65,21 @@ func (h *QueryHandler) QueryLogs(c *gin.Context) {
 		return
 	}
 
-	// Execute query
-	logs, err := h.storage.QueryLogs(userID.(int), whereClause, args, req.SortBy, req.SortOrder, req.Limit, req.Offset)
+	// Execute query; searches also return how each log matched
+	var logs []*models.LogEntry
+	var matches []models.SearchMatch
+	if query.Search != nil {
+		logs, matches, err = h.storage.SearchLogs(query)
+	} else {
+		logs, err = h.storage.QueryLogs(query)
+	}
+	if errors.Is(err, storage.ErrRelevanceUnsupported) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to query logs")
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -82,6 +95,7 @@ func (h *QueryHandler) QueryLogs(c *gin.Context) {
 		"level":       req.Level,
 		"source":      req.Source,
 		"service":     req.Service,
+		"search":      req.Search,
 	}).Info("Query executed successfully")
 
 	response := models.QueryResponse{
@@ -90,6 +104,7 @@ func (h *QueryHandler) QueryLogs(c *gin.Context) {
 		Limit:      req.Limit,
 		Offset:     req.Offset,
 		ExecutedAt: time.Now(),
+		Matches:    matches,
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -125,11 +140,8 @@ func (h *QueryHandler) DeleteLogs(c *gin.Context) {
 		return
 	}
 
-	// Convert query to SQL
-	whereClause, args := req.ToSQL(userID.(int))
-
 	// Delete logs matching the query
-	deletedCount, err := h.storage.DeleteLogs(userID.(int), whereClause, args)
+	deletedCount, err := h.storage.DeleteLogs(req.ToLogQuery(userID.(int)))
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to delete logs")
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -152,3 +164,50 @@ func (h *QueryHandler) DeleteLogs(c *gin.Context) {
 		"deleted_at":    time.Now(),
 	})
 }
+
+// AggregateLogs handles POST /api/v1/logs/aggregate
+func (h *QueryHandler) AggregateLogs(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+
+	var req models.AggregateRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithError(err).Warn("Invalid JSON in aggregate request")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid JSON format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		h.logger.WithError(err).Warn("Aggregate validation failed")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	buckets, err := h.storage.AggregateLogs(req.ToAggregateQuery(userID.(int)))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to aggregate logs")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to execute query",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"buckets":     buckets,
+		"group_by":    req.GroupBy,
+		"interval":    req.Interval,
+		"executed_at": time.Now(),
+	})
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/redact"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+type RedactionHandler struct {
+	redactionStorage *storage.RedactionStorage
+	state            storage.StateStore
+	defaults         []string // detectors applied to every user
+	logger           *logrus.Logger
+}
+
+func NewRedactionHandler(redactionStorage *storage.RedactionStorage, state storage.StateStore, defaults []string, logger *logrus.Logger) *RedactionHandler {
+	return &RedactionHandler{
+		redactionStorage: redactionStorage,
+		state:            state,
+		defaults:         defaults,
+		logger:           logger,
+	}
+}
+
+// hit counter of a detector applied to every user
+type defaultRedaction struct {
+	Detector string `json:"detector"`
+	Action   string `json:"action"`
+	Hits     int64  `json:"hits"`
+}
+
+// GetRules handles GET /api/v1/redaction-rules
+func (h *RedactionHandler) GetRules(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	rules, err := h.redactionStorage.GetUserRules(userID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get redaction rules")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get redaction rules",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	hits, err := h.state.GetRedactionHits(ctx, userID)
+	if err != nil {
+		// Counters are informational, still return the rules
+		h.logger.WithError(err).Warn("Failed to get redaction hits")
+	}
+
+	if rules == nil {
+		rules = []*models.RedactionRule{}
+	}
+	for _, rule := range rules {
+		rule.Hits = hits[redact.RuleKey(rule.ID)]
+	}
+
+	defaults := make([]defaultRedaction, 0, len(h.defaults))
+	for _, detector := range h.defaults {
+		defaults = append(defaults, defaultRedaction{
+			Detector: detector,
+			Action:   "mask",
+			Hits:     hits[redact.DefaultRuleKey(detector)],
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rules":     rules,
+		"count":     len(rules),
+		"defaults":  defaults,
+		"detectors": redact.DetectorNames(),
+	})
+}
+
+// CreateRule handles POST /api/v1/redaction-rules
+func (h *RedactionHandler) CreateRule(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	req, ok := h.bindRuleRequest(c)
+	if !ok {
+		return
+	}
+
+	rule := req.ToRule(userID)
+	if err := h.redactionStorage.CreateRule(rule); err != nil {
+		h.logger.WithError(err).Error("Failed to create redaction rule")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create redaction rule",
+		})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"user_id":  userID,
+		"rule_id":  rule.ID,
+		"detector": rule.Detector,
+	}).Info("Redaction rule created")
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateRule handles PUT /api/v1/redaction-rules/:id
+func (h *RedactionHandler) UpdateRule(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid rule ID",
+		})
+		return
+	}
+
+	req, ok := h.bindRuleRequest(c)
+	if !ok {
+		return
+	}
+
+	rule := req.ToRule(c.GetInt("user_id"))
+	rule.ID = id
+	if err := h.redactionStorage.UpdateRule(rule); err != nil {
+		h.logger.WithError(err).Warn("Failed to update redaction rule")
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Redaction rule not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteRule handles DELETE /api/v1/redaction-rules/:id
+func (h *RedactionHandler) DeleteRule(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid rule ID",
+		})
+		return
+	}
+
+	if err := h.redactionStorage.DeleteRule(id, userID); err != nil {
+		h.logger.WithError(err).Warn("Failed to delete redaction rule")
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Redaction rule not found",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := h.state.ResetRedactionHits(ctx, userID, redact.RuleKey(id)); err != nil {
+		h.logger.WithError(err).Warn("Failed to reset redaction hits")
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Redaction rule deleted successfully",
+	})
+}
+
+func (h *RedactionHandler) bindRuleRequest(c *gin.Context) (*models.CreateRedactionRuleRequest, bool) {
+	var req models.CreateRedactionRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+
+	return &req, true
+}
@@ -0,0 +1,137 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/queue"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/storage"
+)
+
+// the dependency checks both services share
+
+func PostgresCheck(pg *storage.PostgresStorage) Check {
+	return Check{
+		Name:     "postgres",
+		Critical: true,
+		Run: func(ctx context.Context) (any, error) {
+			stats := pg.GetDB().Stats()
+			details := map[string]int{
+				"open_connections": stats.OpenConnections,
+				"in_use":           stats.InUse,
+				"idle":             stats.Idle,
+				"max_open":         stats.MaxOpenConnections,
+			}
+			return details, pg.Ping(ctx)
+		},
+	}
+}
+
+// checks the store holding logs: the partitions when it is Postgres (whose connection PostgresCheck covers),
+// otherwise that the store answers
+func LogStoreCheck(logs storage.LogStore) Check {
+	if pg, ok := logs.(*storage.PostgresStorage); ok {
+		return PartitionCheck(pg)
+	}
+	return Check{
+		Name:     "log_store",
+		Critical: true,
+		Run: func(ctx context.Context) (any, error) {
+			return nil, logs.Ping(ctx)
+		},
+	}
+}
+
+// checks the shared state: the Redis connection when it is Redis, otherwise that the in-process store answers
+func StateCheck(state storage.StateStore) Check {
+	if r, ok := state.(*storage.RedisClient); ok {
+		return RedisCheck(r)
+	}
+	return Check{
+		Name:     "state",
+		Critical: true,
+		Run: func(ctx context.Context) (any, error) {
+			return nil, state.Ping(ctx)
+		},
+	}
+}
+
+func RedisCheck(r *storage.RedisClient) Check {
+	return Check{
+		Name:     "redis",
+		Critical: true,
+		Run: func(ctx context.Context) (any, error) {
+			return nil, r.Ping(ctx)
+		},
+	}
+}
+
+// fails when the consumer group is further behind the queue than the thresholds (0 disables one)
+func StreamLagCheck(q queue.Queue, group string, maxLag, maxPending int64) Check {
+	return Check{
+		Name:     "stream_lag",
+		Critical: true,
+		Run: func(ctx context.Context) (any, error) {
+			info, err := q.Info(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			g := info.Group(group)
+			if g == nil {
+				// The group is created by the first processor, so its absence isn't an ingest problem
+				return map[string]any{"group": group, "stream_length": info.Length}, nil
+			}
+			details := map[string]any{
+				"group":         g.Name,
+				"lag":           g.Lag,
+				"pending":       g.Pending,
+				"stream_length": info.Length,
+			}
+			if maxLag > 0 && g.Lag > maxLag {
+				return details, fmt.Errorf("consumer group lag %d exceeds %d", g.Lag, maxLag)
+			}
+			if maxPending > 0 && g.Pending > maxPending {
+				return details, fmt.Errorf("pending messages %d exceed %d", g.Pending, maxPending)
+			}
+			return details, nil
+		},
+	}
+}
+
+// fails when no partition of logs accepts rows timestamped now, which would make every insert fail
+func PartitionCheck(pg *storage.PostgresStorage) Check {
+	return Check{
+		Name:     "partitions",
+		Critical: true,
+		Run: func(ctx context.Context) (any, error) {
+			covered, err := pg.PartitionCoversNow(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if !covered {
+				return nil, fmt.Errorf("no logs partition covers %s", time.Now().UTC().Format("2006-01"))
+			}
+			return nil, nil
+		},
+	}
+}
+
+// reports the processors with a recent heartbeat; not critical, since the stream buffers logs meanwhile
+func ProcessorsCheck(state storage.StateStore, maxAge time.Duration) Check {
+	return Check{
+		Name: "processors",
+		Run: func(ctx context.Context) (any, error) {
+			processors, err := state.LiveProcessors(ctx, maxAge)
+			if err != nil {
+				return nil, err
+			}
+			details := map[string]any{"alive": len(processors), "processors": processors}
+			if len(processors) == 0 {
+				return details, fmt.Errorf("no processor heartbeat in the last %s", maxAge)
+			}
+			return details, nil
+		},
+	}
+}
@@ -0,0 +1,137 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/*
+this file serves the liveness and readiness probes shared by the ingester and processor
+
+/livez only reports that the process is serving requests, so orchestrators restart it when it hangs
+/readyz runs every dependency check concurrently and fails with 503 when a critical one fails;
+non-critical failures are reported but leave the service ready
+*/
+
+const (
+	StatusOK       = "ok"
+	StatusFailing  = "failing"
+	StatusDegraded = "degraded" // only non-critical checks failed
+)
+
+// Check is one dependency probed by /readyz
+type Check struct {
+	Name     string
+	Critical bool // a failure makes the service not ready
+	// returns optional details to report, and an error when the dependency is unhealthy
+	Run func(ctx context.Context) (any, error)
+}
+
+type CheckResult struct {
+	Status    string  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+	Details   any     `json:"details,omitempty"`
+}
+
+type Report struct {
+	Status    string                 `json:"status"`
+	Service   string                 `json:"service"`
+	Timestamp time.Time              `json:"timestamp"`
+	Checks    map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type Checker struct {
+	service string
+	timeout time.Duration // per check
+	checks  []Check
+	started time.Time
+}
+
+func NewChecker(service string, timeout time.Duration, checks ...Check) *Checker {
+	return &Checker{service: service, timeout: timeout, checks: checks, started: time.Now()}
+}
+
+// runs every check concurrently, each with its own timeout
+func (h *Checker) Ready(ctx context.Context) Report {
+	report := Report{
+		Status:    StatusOK,
+		Service:   h.service,
+		Timestamp: time.Now(),
+		Checks:    make(map[string]CheckResult, len(h.checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range h.checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, h.timeout)
+			defer cancel()
+
+			start := time.Now()
+			details, err := check.Run(checkCtx)
+			result := CheckResult{
+				Status:    StatusOK,
+				Critical:  check.Critical,
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+				Details:   details,
+			}
+			if err != nil {
+				result.Status = StatusFailing
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[check.Name] = result
+			if err != nil {
+				if check.Critical {
+					report.Status = StatusFailing
+				} else if report.Status == StatusOK {
+					report.Status = StatusDegraded
+				}
+			}
+		}(check)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// LiveHandler serves /livez
+func (h *Checker) LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"status":         StatusOK,
+			"service":        h.service,
+			"timestamp":      time.Now(),
+			"uptime_seconds": int64(time.Since(h.started).Seconds()),
+		})
+	})
+}
+
+// ReadyHandler serves /readyz: 200 when ready (including degraded), 503 otherwise
+func (h *Checker) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := h.Ready(r.Context())
+		statusCode := http.StatusOK
+		if report.Status == StatusFailing {
+			statusCode = http.StatusServiceUnavailable
+		}
+		writeJSON(w, statusCode, report)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,61 @@
+package ingester
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// request headers browsers may send to the API
+const corsAllowedHeaders = "Content-Type, Content-Encoding, Authorization, Idempotency-Key, X-Partial-Success"
+
+// answers CORS preflights and allows the origins in CORS_ALLOWED_ORIGINS; the CORS settings are reloaded on SIGHUP
+func (s *Service) corsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := s.config.Get()
+		preflight := c.Request.Method == http.MethodOptions
+
+		if origin := c.GetHeader("Origin"); origin != "" {
+			if allowed := allowedOrigin(cfg.CORSAllowedOrigins, origin); allowed != "" {
+				c.Header("Access-Control-Allow-Origin", allowed)
+				if cfg.CORSAllowCredentials {
+					c.Header("Access-Control-Allow-Credentials", "true")
+				}
+
+				if preflight {
+					c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+					c.Header("Access-Control-Allow-Headers", corsAllowedHeaders)
+					if cfg.CORSMaxAge > 0 {
+						c.Header("Access-Control-Max-Age", strconv.Itoa(int(cfg.CORSMaxAge.Seconds())))
+					}
+				} else if len(cfg.CORSExposedHeaders) > 0 {
+					c.Header("Access-Control-Expose-Headers", strings.Join(cfg.CORSExposedHeaders, ", "))
+				}
+			}
+			// Responses differ per origin, so caches must not share them
+			c.Writer.Header().Add("Vary", "Origin")
+		}
+
+		if preflight {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// returns the Access-Control-Allow-Origin value for origin, or "" when it isn't allowed
+func allowedOrigin(allowed []string, origin string) string {
+	for _, candidate := range allowed {
+		if candidate == "*" {
+			return "*"
+		}
+		if strings.EqualFold(candidate, origin) {
+			return origin
+		}
+	}
+	return ""
+}
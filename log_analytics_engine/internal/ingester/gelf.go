@@ -0,0 +1,156 @@
+package ingester
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/gelf"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/metrics"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// largest GELF HTTP body we accept before decompression
+const maxGELFBodySize = 1 << 20
+
+// Ingest a GELF message over HTTP (requires API key)
+func (s *Service) IngestGELF(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		s.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxGELFBodySize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to read request body",
+			"details": err.Error(),
+		})
+		return
+	}
+	if len(body) > maxGELFBodySize {
+		metrics.Reject(metrics.RejectTooLarge, 1)
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": fmt.Sprintf("GELF message too large (max %d bytes)", maxGELFBodySize),
+		})
+		return
+	}
+
+	msg, err := gelf.Decode(body)
+	if err != nil {
+		s.logger.WithError(err).Warn("Invalid GELF message")
+		metrics.Reject(metrics.RejectInvalidJSON, 1)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid GELF message",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	logEntry, err := s.gelfLogEntry(userID.(int), msg)
+	if err != nil {
+		s.logger.WithError(err).Warn("GELF validation failed")
+		metrics.Reject(metrics.RejectInvalid, 1)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+		return
+	}
+	assignEventID(c, logEntry, 0)
+
+	if len(s.ingestRulesHandler.Filter(userID.(int), []*models.LogEntry{logEntry})) == 0 {
+		c.Status(http.StatusAccepted)
+		return
+	}
+
+	if !s.limitsHandler.CheckIngest(c, userID.(int), []*models.LogEntry{logEntry}) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.Get().PublishTimeout)
+	defer cancel()
+
+	if err := s.queue.PublishLog(ctx, logEntry); err != nil {
+		s.logger.WithError(err).Error("Failed to publish GELF log to Redis")
+		metrics.Reject(metrics.RejectPublishFailed, 1)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to queue log for processing",
+		})
+		return
+	}
+
+	metrics.LogsAccepted.WithLabelValues("gelf").Inc()
+
+	// Graylog HTTP inputs answer with 202 and an empty body
+	c.Status(http.StatusAccepted)
+}
+
+// handles a message from the UDP listener, which authenticates with the _api_key additional field
+func (s *Service) handleGELFDatagram(ctx context.Context, msg *gelf.Message) error {
+	apiKey := msg.APIKey()
+	if apiKey == "" {
+		return fmt.Errorf("GELF message has no %s field", gelf.APIKeyField)
+	}
+
+	authCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	userID, err := s.authHandler.AuthenticateAPIKey(authCtx, apiKey)
+	if err != nil {
+		return fmt.Errorf("invalid API key: %w", err)
+	}
+
+	logEntry, err := s.gelfLogEntry(userID, msg)
+	if err != nil {
+		metrics.Reject(metrics.RejectInvalid, 1)
+		return err
+	}
+
+	if len(s.ingestRulesHandler.Filter(userID, []*models.LogEntry{logEntry})) == 0 {
+		return nil
+	}
+
+	pubCtx, pubCancel := context.WithTimeout(ctx, s.config.Get().PublishTimeout)
+	defer pubCancel()
+
+	result, err := s.limitsHandler.Allow(pubCtx, userID, apiKey, 1, logEntry.Size())
+	if err != nil {
+		s.logger.WithError(err).Warn("Rate limit check failed, allowing request")
+	} else if !result.Allowed {
+		// UDP has no way to signal back pressure, so the message is dropped
+		return fmt.Errorf("rate limit exceeded (%s)", result.Reason)
+	}
+
+	if err := s.queue.PublishLog(pubCtx, logEntry); err != nil {
+		metrics.Reject(metrics.RejectPublishFailed, 1)
+		return fmt.Errorf("failed to queue log for processing: %w", err)
+	}
+
+	metrics.LogsAccepted.WithLabelValues("gelf_udp").Inc()
+
+	s.logger.WithFields(logrus.Fields{
+		"user_id": userID,
+		"source":  logEntry.Source,
+		"level":   logEntry.Level,
+	}).Debug("GELF log queued successfully")
+
+	return nil
+}
+
+// validates a GELF message and converts it to a log entry owned by userID
+func (s *Service) gelfLogEntry(userID int, msg *gelf.Message) (*models.LogEntry, error) {
+	req := msg.ToIngestRequest()
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	return s.newLogEntry(req, userID)
+}
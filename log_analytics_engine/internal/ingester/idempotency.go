@@ -0,0 +1,125 @@
+package ingester
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// how long a key stays reserved while its request is in flight; short so a crashed request doesn't block retries
+const idempotencyPendingTTL = time.Minute
+
+// captures the response body so it can be replayed for retries with the same Idempotency-Key
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+	return w.ResponseWriter.WriteString(data)
+}
+
+// replays the stored response when a request is retried with an Idempotency-Key seen within the TTL
+func (s *Service) idempotencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > 255 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Idempotency-Key cannot exceed 255 characters",
+			})
+			c.Abort()
+			return
+		}
+
+		userID := c.GetInt("user_id")
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		existing, err := s.state.ReserveIdempotencyKey(ctx, userID, key, idempotencyPendingTTL)
+		if err != nil {
+			// Fail open: the processor still deduplicates on the event IDs derived from the key
+			s.logger.WithError(err).Warn("Failed to reserve idempotency key")
+			c.Set("idempotency_key", key)
+			c.Next()
+			return
+		}
+
+		if existing != nil {
+			if existing.State == storage.IdempotencyPending {
+				c.JSON(http.StatusConflict, gin.H{
+					"error": "A request with this Idempotency-Key is still being processed",
+				})
+				c.Abort()
+				return
+			}
+
+			s.logger.WithField("user_id", userID).Debug("Replaying response for Idempotency-Key")
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(existing.StatusCode, "application/json; charset=utf-8", []byte(existing.Body))
+			c.Abort()
+			return
+		}
+
+		c.Set("idempotency_key", key)
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		c.Next()
+
+		storeCtx, storeCancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer storeCancel()
+
+		// Only successful responses are remembered, failed requests can be retried with the same key
+		status := recorder.Status()
+		if status >= 200 && status < 300 {
+			record := storage.IdempotencyRecord{StatusCode: status, Body: recorder.body.String()}
+			if err := s.state.CompleteIdempotencyKey(storeCtx, userID, key, record, s.config.Get().IdempotencyTTL); err != nil {
+				s.logger.WithError(err).Warn("Failed to store idempotent response")
+			}
+			return
+		}
+
+		if err := s.state.ReleaseIdempotencyKey(storeCtx, userID, key); err != nil {
+			s.logger.WithError(err).WithFields(logrus.Fields{
+				"user_id": userID,
+				"status":  status,
+			}).Warn("Failed to release idempotency key")
+		}
+	}
+}
+
+// gives entries without an event_id one derived from the request's Idempotency-Key and their position,
+// so retried requests map to the same event IDs and the processor drops the duplicates. The log store only
+// remembers event IDs for IdempotencyTTL, like the key itself, so a key reused after that is stored again
+func assignEventID(c *gin.Context, entry *models.LogEntry, index int) {
+	if entry.EventID != "" {
+		return
+	}
+	key := c.GetString("idempotency_key")
+	if key == "" {
+		return
+	}
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d", key, index)))
+	entry.EventID = "idem-" + hex.EncodeToString(sum[:])
+}
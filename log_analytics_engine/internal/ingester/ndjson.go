@@ -0,0 +1,298 @@
+package ingester
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/handlers"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/metrics"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ndjsonMaxLineSize    = 1 << 20 // longest single NDJSON line we accept
+	maxReportedRejects   = 1000    // cap on line numbers returned in the response
+	zstdMaxDecoderMemory = 64 << 20
+)
+
+// decompresses gzip/zstd request bodies and enforces the configured max body size
+func (s *Service) decodeBodyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := c.Request.Body
+
+		switch strings.ToLower(strings.TrimSpace(c.GetHeader("Content-Encoding"))) {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			gz, err := gzip.NewReader(body)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "Invalid gzip body",
+					"details": err.Error(),
+				})
+				c.Abort()
+				return
+			}
+			body = gz
+		case "zstd":
+			zr, err := zstd.NewReader(body, zstd.WithDecoderMaxMemory(zstdMaxDecoderMemory))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "Invalid zstd body",
+					"details": err.Error(),
+				})
+				c.Abort()
+				return
+			}
+			body = zr.IOReadCloser()
+		default:
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"error": "Unsupported Content-Encoding (use gzip or zstd)",
+			})
+			c.Abort()
+			return
+		}
+
+		// The limit applies to the decompressed stream so small compressed bodies can't expand without bound
+		c.Request.Body = http.MaxBytesReader(c.Writer, body, int64(s.config.Get().MaxBodyBytes))
+		c.Request.Header.Del("Content-Encoding")
+		c.Request.ContentLength = -1
+
+		c.Next()
+	}
+}
+
+// returned by the NDJSON chunk flush when the caller is over its ingestion limits
+var errRateLimited = errors.New("rate limit exceeded")
+
+// reports whether the request body is newline-delimited JSON
+func isNDJSON(c *gin.Context) bool {
+	switch c.ContentType() {
+	case "application/x-ndjson", "application/ndjson":
+		return true
+	}
+	return false
+}
+
+// reports whether err was caused by the body exceeding the max body size
+func isBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+// Ingest a streaming NDJSON body: one IngestRequest per line, validated and published in chunks
+func (s *Service) IngestNDJSON(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		s.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+
+	cfg := s.config.Get()
+	reader := bufio.NewReaderSize(c.Request.Body, 64*1024)
+	chunk := make([]*models.LogEntry, 0, cfg.NDJSONChunkSize)
+	accepted := 0
+	rejected := 0
+	rejectedLines := []int{}
+	var ingestErrors []models.IngestError
+	rawLines := make(map[int]string)
+
+	reject := func(line int, raw []byte, category, reason string) {
+		rejected++
+		metrics.Reject(category, 1)
+		if len(rejectedLines) < maxReportedRejects {
+			rejectedLines = append(rejectedLines, line)
+			ingestErrors = append(ingestErrors, models.IngestError{Index: line, Error: reason})
+			rawLines[line] = string(raw)
+		}
+	}
+	defer func() {
+		s.quarantineRejected(userID.(int), "ndjson", ingestErrors, func(line int) string {
+			return rawLines[line]
+		})
+	}()
+
+	dropped := 0
+	var limited *storage.RateLimitResult
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+
+		// Drop and sample rules run first so discarded logs don't count against the user's limits
+		kept := s.ingestRulesHandler.Filter(userID.(int), chunk)
+		if len(kept) == 0 {
+			dropped += len(chunk)
+			chunk = chunk[:0]
+			return nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.PublishTimeout)
+		defer cancel()
+
+		var bytes int64
+		for _, entry := range kept {
+			bytes += entry.Size()
+		}
+		result, err := s.limitsHandler.Allow(ctx, userID.(int), c.GetString("api_key"), int64(len(kept)), bytes)
+		if err != nil {
+			s.logger.WithError(err).Warn("Rate limit check failed, allowing request")
+		} else if !result.Allowed {
+			limited = result
+			return errRateLimited
+		}
+
+		if err := s.queue.PublishLogs(ctx, kept); err != nil {
+			metrics.Reject(metrics.RejectPublishFailed, len(kept))
+			return err
+		}
+		metrics.LogsAccepted.WithLabelValues("ndjson").Add(float64(len(kept)))
+		accepted += len(kept)
+		dropped += len(chunk) - len(kept)
+		chunk = chunk[:0]
+		return nil
+	}
+
+	lineNumber := 0
+	for {
+		line, tooLong, err := readNDJSONLine(reader)
+		if err != io.EOF || len(line) > 0 || tooLong {
+			lineNumber++
+		}
+
+		if tooLong {
+			reject(lineNumber, nil, metrics.RejectTooLarge, fmt.Sprintf("line exceeds %d bytes", ndjsonMaxLineSize))
+		} else if len(bytes.TrimSpace(line)) > 0 {
+			var req models.IngestRequest
+			if jsonErr := json.Unmarshal(line, &req); jsonErr != nil {
+				reject(lineNumber, line, metrics.RejectInvalidJSON, fmt.Sprintf("invalid JSON: %s", jsonErr.Error()))
+			} else if validErr := req.Validate(); validErr != nil {
+				reject(lineNumber, line, metrics.RejectInvalid, validErr.Error())
+			} else if entry, entryErr := s.newLogEntry(&req, userID.(int)); entryErr != nil {
+				reject(lineNumber, line, metrics.RejectTimestamp, entryErr.Error())
+			} else {
+				assignEventID(c, entry, lineNumber)
+				chunk = append(chunk, entry)
+			}
+		}
+
+		if len(chunk) >= cfg.NDJSONChunkSize {
+			if pubErr := flush(); pubErr != nil {
+				s.ndjsonPublishFailed(c, pubErr, limited, accepted, rejected, rejectedLines)
+				return
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if isBodyTooLarge(err) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+					"error":          fmt.Sprintf("Request body too large (max %d bytes)", cfg.MaxBodyBytes),
+					"accepted":       accepted,
+					"rejected":       rejected,
+					"rejected_lines": rejectedLines,
+				})
+				return
+			}
+			s.logger.WithError(err).Warn("Failed to read NDJSON body")
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":          "Failed to read request body",
+				"details":        err.Error(),
+				"accepted":       accepted,
+				"rejected":       rejected,
+				"rejected_lines": rejectedLines,
+			})
+			return
+		}
+	}
+
+	if err := flush(); err != nil {
+		s.ndjsonPublishFailed(c, err, limited, accepted, rejected, rejectedLines)
+		return
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"user_id":  userID,
+		"accepted": accepted,
+		"dropped":  dropped,
+		"rejected": rejected,
+	}).Info("NDJSON logs queued")
+
+	statusCode := http.StatusAccepted
+	if accepted == 0 && dropped == 0 && rejected > 0 {
+		statusCode = http.StatusBadRequest
+	}
+
+	c.JSON(statusCode, gin.H{
+		"status":         "queued",
+		"accepted":       accepted,
+		"dropped":        dropped,
+		"rejected":       rejected,
+		"rejected_lines": rejectedLines,
+		"timestamp":      time.Now(),
+	})
+}
+
+// responds after a Redis publish failure, reporting how much of the stream was already queued
+func (s *Service) ndjsonPublishFailed(c *gin.Context, err error, limited *storage.RateLimitResult, accepted, rejected int, rejectedLines []int) {
+	if errors.Is(err, errRateLimited) {
+		retryAfter := handlers.SetRateLimitHeaders(c, limited)
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":          "Rate limit exceeded",
+			"limit":          limited.Reason,
+			"retry_after":    retryAfter,
+			"accepted":       accepted,
+			"rejected":       rejected,
+			"rejected_lines": rejectedLines,
+		})
+		return
+	}
+
+	s.logger.WithError(err).Error("Failed to publish NDJSON logs to Redis")
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error":          "Failed to queue logs for processing",
+		"accepted":       accepted,
+		"rejected":       rejected,
+		"rejected_lines": rejectedLines,
+	})
+}
+
+// reads one line; lines longer than ndjsonMaxLineSize are discarded and reported as tooLong
+func readNDJSONLine(reader *bufio.Reader) ([]byte, bool, error) {
+	var line []byte
+	tooLong := false
+
+	for {
+		fragment, err := reader.ReadSlice('\n')
+		if !tooLong {
+			if len(line)+len(fragment) > ndjsonMaxLineSize {
+				tooLong = true
+				line = nil
+			} else {
+				line = append(line, fragment...)
+			}
+		}
+
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		return bytes.TrimRight(line, "\r\n"), tooLong, err
+	}
+}
@@ -0,0 +1,131 @@
+package ingester
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+)
+
+// reports whether the client opted into partial-success batch semantics via ?partial=true or X-Partial-Success
+func wantsPartialSuccess(c *gin.Context) bool {
+	for _, value := range []string{c.Query("partial"), c.GetHeader("X-Partial-Success")} {
+		if enabled, err := strconv.ParseBool(strings.TrimSpace(value)); err == nil && enabled {
+			return true
+		}
+	}
+	return false
+}
+
+// keeps rejected raw entries in the user's quarantine when quarantining is enabled. The payloads
+// are redacted with the user's rules first; if those can't be loaded only the errors are kept
+func (s *Service) quarantineRejected(userID int, origin string, ingestErrors []models.IngestError, raw func(index int) string) {
+	if !s.config.Get().Quarantine || len(ingestErrors) == 0 {
+		return
+	}
+
+	keepPayloads := true
+	redacted := func(index int) string {
+		if !keepPayloads {
+			return ""
+		}
+		payload, err := s.redactors.ApplyText(userID, raw(index))
+		if err != nil {
+			s.logger.WithError(err).WithField("user_id", userID).Warn("Quarantining rejected logs without their payloads")
+			keepPayloads = false
+			return ""
+		}
+		return payload
+	}
+
+	now := time.Now()
+	entries := make([]models.QuarantinedLog, len(ingestErrors))
+	for i, ingestErr := range ingestErrors {
+		entries[i] = models.QuarantinedLog{
+			Raw:        redacted(ingestErr.Index),
+			Error:      ingestErr.Error,
+			Index:      ingestErr.Index,
+			Origin:     origin,
+			ReceivedAt: now,
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Quarantine is best effort, a failure here must not fail the ingest request
+	if err := s.state.QuarantineLogs(ctx, userID, entries); err != nil {
+		s.logger.WithError(err).Warn("Failed to quarantine rejected logs")
+	}
+}
+
+// Get rejected entries kept in the quarantine for the authenticated user
+func (s *Service) GetQuarantine(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 || parsed > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "limit must be between 1 and 1000",
+			})
+			return
+		}
+		limit = parsed
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	entries, err := s.state.GetQuarantinedLogs(ctx, userID.(int), limit)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get quarantined logs")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get quarantined logs",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"quarantined": entries,
+		"count":       len(entries),
+		"enabled":     s.config.Get().Quarantine,
+	})
+}
+
+// Remove all quarantined entries for the authenticated user
+func (s *Service) ClearQuarantine(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := s.state.ClearQuarantine(ctx, userID.(int)); err != nil {
+		s.logger.WithError(err).Error("Failed to clear quarantine")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to clear quarantine",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Quarantine cleared successfully",
+	})
+}
@@ -0,0 +1,711 @@
+package ingester
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/app"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/auth"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/config"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/gelf"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/handlers"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/health"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/metrics"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/queue"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/redact"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/selflog"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/storage"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/tlsconfig"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/pkg/client"
+	"github.com/sirupsen/logrus"
+)
+
+type Service struct {
+	storage            *storage.PostgresStorage
+	logs               storage.LogStore
+	state              storage.StateStore
+	queue              queue.Queue      // where accepted logs are published for the processors
+	redactors          *redact.Registry // redacts rejected payloads before they are quarantined
+	authStorage        *storage.AuthStorage
+	authHandler        *handlers.AuthHandler
+	queryHandler       *handlers.QueryHandler
+	limitsHandler      *handlers.LimitsHandler
+	pipelineHandler    *handlers.PipelineHandler
+	redactionHandler   *handlers.RedactionHandler
+	ingestRulesHandler *handlers.IngestRulesHandler
+	lookupTableHandler *handlers.LookupTableHandler
+	clientCertHandler  *handlers.ClientCertHandler
+	jwtService         *auth.JWTService
+	logForwarder       *client.Client      // nil unless LOG_FORWARD_URL is set
+	selfLogHook        *selflog.TenantHook // nil unless SELF_LOG_TENANT is set
+	health             *health.Checker
+	logger             *logrus.Logger
+	config             *config.Manager // settings marked reloadable change on SIGHUP
+}
+
+// creates the ingester over shared resources, which the caller closes after the service
+func New(configs *config.Manager, res *app.Resources) (*Service, error) {
+	cfg := configs.Get()
+	logger := logrus.New()
+
+	// Set log level
+	level, err := logrus.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		level = logrus.InfoLevel
+	}
+	logger.SetLevel(level)
+
+	logForwarder, err := selflog.Forward(logger, cfg, "log-ingestion")
+	if err != nil {
+		return nil, err
+	}
+
+	pgStorage := res.Postgres
+	state := res.State
+
+	// Create auth storage
+	authStorage := storage.NewAuthStorage(pgStorage.GetDB())
+
+	selfLogHook, err := selflog.ForwardToTenant(logger, cfg, "log-ingestion", res.Queue, authStorage)
+	if err != nil {
+		return nil, err
+	}
+
+	redactors, err := redact.NewRegistry(storage.NewRedactionStorage(pgStorage.GetDB()).GetActiveRules,
+		cfg.RedactDefaultDetectors, cfg.RedactHashKey, cfg.RulesCacheTTL, logger)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redaction defaults: %w", err)
+	}
+
+	// Create JWT service
+	jwtService := auth.NewJWTService(cfg.JWTSecret, cfg.JWTIssuer)
+
+	// Create auth handler
+	authHandler := handlers.NewAuthHandler(authStorage, state, jwtService, logger)
+
+	// Create query handler
+	queryHandler := handlers.NewQueryHandler(res.Logs, logger)
+
+	// Create rate limit handler
+	limitsHandler := handlers.NewLimitsHandler(storage.NewLimitsStorage(pgStorage.GetDB()), state, configs, logger)
+
+	healthChecker := health.NewChecker("log-ingestion", 2*time.Second,
+		health.PostgresCheck(pgStorage),
+		health.StateCheck(state),
+		health.StreamLagCheck(res.Queue, cfg.ConsumerGroup, int64(cfg.ReadyMaxStreamLag), int64(cfg.ReadyMaxPending)),
+		health.LogStoreCheck(res.Logs),
+		health.ProcessorsCheck(state, storage.ProcessorHeartbeatMaxAge),
+	)
+
+	return &Service{
+		storage:            pgStorage,
+		logs:               res.Logs,
+		state:              state,
+		queue:              res.Queue,
+		redactors:          redactors,
+		authStorage:        authStorage,
+		authHandler:        authHandler,
+		queryHandler:       queryHandler,
+		limitsHandler:      limitsHandler,
+		pipelineHandler:    handlers.NewPipelineHandler(storage.NewPipelineStorage(pgStorage.GetDB()), logger),
+		ingestRulesHandler: handlers.NewIngestRulesHandler(storage.NewIngestRuleStorage(pgStorage.GetDB()), state, cfg.RulesCacheTTL, logger),
+		lookupTableHandler: handlers.NewLookupTableHandler(storage.NewLookupTableStorage(pgStorage.GetDB()), logger),
+		clientCertHandler:  handlers.NewClientCertHandler(storage.NewClientCertStorage(pgStorage.GetDB()), state, logger),
+		redactionHandler:   handlers.NewRedactionHandler(storage.NewRedactionStorage(pgStorage.GetDB()), state, cfg.RedactDefaultDetectors, logger),
+		jwtService:         jwtService,
+		logForwarder:       logForwarder,
+		selfLogHook:        selfLogHook,
+		health:             healthChecker,
+		logger:             logger,
+		config:             configs,
+	}, nil
+}
+
+// returns the service's logger, which forwards its entries when self-logging is configured
+func (s *Service) Logger() *logrus.Logger {
+	return s.logger
+}
+
+// flushes the service's own logs; the shared resources are closed by their owner
+func (s *Service) Close() error {
+	if s.selfLogHook != nil {
+		// While the queue it publishes to is still open
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		s.selfLogHook.Close(ctx)
+		cancel()
+	}
+	if s.logForwarder != nil {
+		// Last, so errors from closing the rest are forwarded too
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		s.logForwarder.Close(ctx)
+		cancel()
+	}
+	return nil
+}
+
+// Health check endpoint
+func (s *Service) HealthCheck(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Check Redis connection
+	redisHealthy := true
+	if err := s.state.Ping(ctx); err != nil {
+		redisHealthy = false
+		s.logger.WithError(err).Warn("Redis health check failed")
+	}
+
+	status := "healthy"
+	statusCode := http.StatusOK
+	if !redisHealthy {
+		status = "degraded"
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	c.JSON(statusCode, gin.H{
+		"status":        status,
+		"timestamp":     time.Now(),
+		"service":       "log-ingestion",
+		"redis_healthy": redisHealthy,
+	})
+}
+
+// Ingest a single log entry (now requires API key)
+func (s *Service) IngestLog(c *gin.Context) {
+	// Newline-delimited bodies are streamed instead of bound as one document
+	if isNDJSON(c) {
+		s.IngestNDJSON(c)
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		s.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+
+	var req models.IngestRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if isBodyTooLarge(err) {
+			metrics.Reject(metrics.RejectTooLarge, 1)
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": fmt.Sprintf("Request body too large (max %d bytes)", s.config.Get().MaxBodyBytes),
+			})
+			return
+		}
+		s.logger.WithError(err).Warn("Invalid JSON in request")
+		metrics.Reject(metrics.RejectInvalidJSON, 1)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid JSON format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		s.logger.WithError(err).Warn("Validation failed")
+		metrics.Reject(metrics.RejectInvalid, 1)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// Convert to log entry
+	logEntry, err := s.newLogEntry(&req, userID.(int))
+	if err != nil {
+		s.logger.WithError(err).Warn("Validation failed")
+		metrics.Reject(metrics.RejectTimestamp, 1)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+		return
+	}
+	assignEventID(c, logEntry, 0)
+
+	// Drop and sample rules run first so discarded logs don't count against the user's limits
+	if len(s.ingestRulesHandler.Filter(userID.(int), []*models.LogEntry{logEntry})) == 0 {
+		c.JSON(http.StatusAccepted, gin.H{
+			"status":    "dropped",
+			"timestamp": logEntry.Timestamp,
+			"message":   "Log accepted and dropped by an ingest rule",
+		})
+		return
+	}
+
+	if !s.limitsHandler.CheckIngest(c, userID.(int), []*models.LogEntry{logEntry}) {
+		return
+	}
+
+	// Publish to Redis Stream instead of direct database insert
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.Get().PublishTimeout)
+	defer cancel()
+
+	if err := s.queue.PublishLog(ctx, logEntry); err != nil {
+		s.logger.WithError(err).Error("Failed to publish log to Redis")
+		metrics.Reject(metrics.RejectPublishFailed, 1)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to queue log for processing",
+		})
+		return
+	}
+
+	metrics.LogsAccepted.WithLabelValues("ingest").Inc()
+
+	s.logger.WithFields(logrus.Fields{
+		"user_id": userID,
+		"source":  logEntry.Source,
+		"level":   logEntry.Level,
+		"service": logEntry.Service,
+	}).Info("Log queued successfully")
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":    "queued",
+		"timestamp": logEntry.Timestamp,
+		"message":   "Log accepted and queued for processing",
+	})
+}
+
+// converts a validated request to a log entry owned by userID, applying the timestamp skew policy
+func (s *Service) newLogEntry(req *models.IngestRequest, userID int) (*models.LogEntry, error) {
+	entry := req.ToLogEntry()
+	entry.UserID = userID
+	if err := s.config.Get().TimestampPolicyConfig().Apply(entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Ingest multiple log entries (now requires API key)
+func (s *Service) IngestBatch(c *gin.Context) {
+	// Newline-delimited bodies are streamed instead of bound as one document
+	if isNDJSON(c) {
+		s.IngestNDJSON(c)
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		s.logger.Error("User ID not found in context")
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+
+	var req models.RawBatchIngestRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if isBodyTooLarge(err) {
+			metrics.Reject(metrics.RejectTooLarge, 1)
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": fmt.Sprintf("Request body too large (max %d bytes)", s.config.Get().MaxBodyBytes),
+			})
+			return
+		}
+		s.logger.WithError(err).Warn("Invalid JSON in batch request")
+		metrics.Reject(metrics.RejectInvalidJSON, 1)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid JSON format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if len(req.Logs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "No logs provided in batch",
+		})
+		return
+	}
+
+	if maxBatch := s.config.Get().MaxBatchSize; len(req.Logs) > maxBatch {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Batch size too large (max %d logs)", maxBatch),
+		})
+		return
+	}
+
+	partial := wantsPartialSuccess(c)
+
+	var logEntries []*models.LogEntry
+	var ingestErrors []models.IngestError
+
+	for i, rawLog := range req.Logs {
+		var logReq models.IngestRequest
+		if err := json.Unmarshal(rawLog, &logReq); err != nil {
+			metrics.Reject(metrics.RejectInvalidJSON, 1)
+			ingestErrors = append(ingestErrors, models.IngestError{Index: i, Error: fmt.Sprintf("invalid JSON: %s", err.Error())})
+			continue
+		}
+		if err := logReq.Validate(); err != nil {
+			metrics.Reject(metrics.RejectInvalid, 1)
+			ingestErrors = append(ingestErrors, models.IngestError{Index: i, Error: err.Error()})
+			continue
+		}
+		entry, err := s.newLogEntry(&logReq, userID.(int))
+		if err != nil {
+			metrics.Reject(metrics.RejectTimestamp, 1)
+			ingestErrors = append(ingestErrors, models.IngestError{Index: i, Error: err.Error()})
+			continue
+		}
+		assignEventID(c, entry, i)
+		logEntries = append(logEntries, entry)
+	}
+
+	if len(ingestErrors) > 0 {
+		s.quarantineRejected(userID.(int), "batch", ingestErrors, func(index int) string {
+			return string(req.Logs[index])
+		})
+	}
+
+	if len(ingestErrors) > 0 && !partial {
+		metrics.Reject(metrics.RejectBatchRejected, len(logEntries))
+		validationErrors := make([]string, len(ingestErrors))
+		for i, ingestErr := range ingestErrors {
+			validationErrors[i] = fmt.Sprintf("Log %d: %s", ingestErr.Index, ingestErr.Error)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "Validation failed for some logs",
+			"validation_errors": validationErrors,
+		})
+		return
+	}
+
+	if len(logEntries) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":    "Validation failed for all logs",
+			"accepted": 0,
+			"rejected": len(ingestErrors),
+			"errors":   ingestErrors,
+		})
+		return
+	}
+
+	// Drop and sample rules run first so discarded logs don't count against the user's limits
+	valid := len(logEntries)
+	logEntries = s.ingestRulesHandler.Filter(userID.(int), logEntries)
+	dropped := valid - len(logEntries)
+
+	if len(logEntries) > 0 {
+		if !s.limitsHandler.CheckIngest(c, userID.(int), logEntries) {
+			return
+		}
+
+		// Publish batch to Redis Stream
+		ctx, cancel := context.WithTimeout(context.Background(), s.config.Get().PublishTimeout)
+		defer cancel()
+
+		if err := s.queue.PublishLogs(ctx, logEntries); err != nil {
+			s.logger.WithError(err).Error("Failed to publish batch logs to Redis")
+			metrics.Reject(metrics.RejectPublishFailed, len(logEntries))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to queue logs for processing",
+			})
+			return
+		}
+	}
+
+	metrics.LogsAccepted.WithLabelValues("batch").Add(float64(len(logEntries)))
+
+	s.logger.WithFields(logrus.Fields{
+		"user_id":  userID,
+		"count":    len(logEntries),
+		"dropped":  dropped,
+		"rejected": len(ingestErrors),
+	}).Info("Batch logs queued successfully")
+
+	if len(ingestErrors) > 0 {
+		// Some entries were queued and some were not: report each rejected index
+		c.JSON(http.StatusMultiStatus, gin.H{
+			"status":      "partial",
+			"logs_queued": len(logEntries),
+			"accepted":    valid,
+			"dropped":     dropped,
+			"rejected":    len(ingestErrors),
+			"errors":      ingestErrors,
+			"timestamp":   time.Now(),
+			"message":     "Valid logs accepted and queued for processing, invalid logs rejected",
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":      "queued",
+		"logs_queued": len(logEntries),
+		"dropped":     dropped,
+		"timestamp":   time.Now(),
+		"message":     "Logs accepted and queued for processing",
+	})
+}
+
+// Get recent logs for the authenticated user
+func (s *Service) GetRecentLogs(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+
+	logs, err := s.logs.QueryLogs(&models.LogQuery{
+		UserID:    userID.(int),
+		SortBy:    models.FieldTimestamp,
+		SortOrder: "DESC",
+		Limit:     50,
+	})
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to retrieve logs")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to retrieve logs",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"logs":  logs,
+		"count": len(logs),
+	})
+}
+
+func (s *Service) GetStreamStatus(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	info, err := s.queue.Info(ctx)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get stream info")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get stream status",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// runs a log query against the reserved tenant holding the services' own logs
+func (s *Service) QuerySystemLogs(c *gin.Context) {
+	if s.selfLogHook == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "System tenant is not configured (set SELF_LOG_TENANT)",
+		})
+		return
+	}
+
+	// The query handler scopes results to the user in the context
+	c.Set("user_id", s.selfLogHook.UserID())
+	s.queryHandler.QueryLogs(c)
+}
+
+func setupRouter(service *Service) *gin.Engine {
+	if service.config.Get().IsProduction() {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	router := gin.Default()
+	router.Use(metrics.GinMiddleware())
+
+	// CORS middleware
+	router.Use(service.corsMiddleware())
+
+	// Prometheus scrape endpoint and probes
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+	router.GET("/livez", gin.WrapH(service.health.LiveHandler()))
+	router.GET("/readyz", gin.WrapH(service.health.ReadyHandler()))
+
+	// Public routes
+	api := router.Group("/api/v1")
+	{
+		api.GET("/health", service.HealthCheck)
+		api.POST("/auth/register", service.authHandler.Register)
+		api.POST("/auth/login", service.authHandler.Login)
+	}
+
+	// Protected routes (JWT)
+	protected := router.Group("/api/v1")
+	protected.Use(service.authHandler.JWTAuthMiddleware())
+	{
+		protected.POST("/api-keys", service.authHandler.CreateAPIKey)
+		protected.GET("/api-keys", service.authHandler.GetAPIKeys)
+		protected.DELETE("/api-keys/:id", service.authHandler.DeleteAPIKey)
+		protected.GET("/client-certs", service.clientCertHandler.GetCertificates)
+		protected.POST("/client-certs", service.clientCertHandler.CreateCertificate)
+		protected.DELETE("/client-certs/:id", service.clientCertHandler.DeleteCertificate)
+		protected.GET("/stream/status", service.GetStreamStatus)
+		protected.GET("/account/usage", service.limitsHandler.GetUsage)
+
+		protected.GET("/pipelines", service.pipelineHandler.GetPipelines)
+		protected.POST("/pipelines", service.pipelineHandler.CreatePipeline)
+		protected.POST("/pipelines/test", service.pipelineHandler.TestPipeline)
+		protected.GET("/pipelines/grok-patterns", service.pipelineHandler.GetGrokPatterns)
+		protected.GET("/pipelines/:id", service.pipelineHandler.GetPipeline)
+		protected.PUT("/pipelines/:id", service.pipelineHandler.UpdatePipeline)
+		protected.DELETE("/pipelines/:id", service.pipelineHandler.DeletePipeline)
+
+		protected.GET("/redaction-rules", service.redactionHandler.GetRules)
+		protected.POST("/redaction-rules", service.redactionHandler.CreateRule)
+		protected.PUT("/redaction-rules/:id", service.redactionHandler.UpdateRule)
+		protected.DELETE("/redaction-rules/:id", service.redactionHandler.DeleteRule)
+
+		protected.GET("/ingest-rules", service.ingestRulesHandler.GetRules)
+		protected.POST("/ingest-rules", service.ingestRulesHandler.CreateRule)
+		protected.PUT("/ingest-rules/:id", service.ingestRulesHandler.UpdateRule)
+		protected.DELETE("/ingest-rules/:id", service.ingestRulesHandler.DeleteRule)
+
+		protected.GET("/lookup-tables", service.lookupTableHandler.GetTables)
+		protected.GET("/lookup-tables/:name", service.lookupTableHandler.GetTable)
+		protected.PUT("/lookup-tables/:name", service.lookupTableHandler.PutTable)
+		protected.DELETE("/lookup-tables/:name", service.lookupTableHandler.DeleteTable)
+	}
+
+	// Admin routes (JWT of a user flagged as admin)
+	admin := router.Group("/api/v1/admin")
+	admin.Use(service.authHandler.JWTAuthMiddleware(), service.authHandler.AdminMiddleware())
+	{
+		admin.GET("/users/:id/limits", service.limitsHandler.GetUserLimits)
+		admin.PUT("/users/:id/limits", service.limitsHandler.UpdateUserLimits)
+		admin.DELETE("/users/:id/limits", service.limitsHandler.ResetUserLimits)
+		admin.POST("/system/logs/query", service.QuerySystemLogs)
+	}
+
+	// Log query routes (JWT or API key)
+	logsQuery := router.Group("/api/v1/logs")
+	logsQuery.Use(service.authHandler.JWTOrAPIKeyAuthMiddleware())
+	{
+		logsQuery.GET("/recent", service.GetRecentLogs)
+		logsQuery.POST("/query", service.queryHandler.QueryLogs)
+		logsQuery.POST("/delete", service.queryHandler.DeleteLogs)
+		logsQuery.POST("/aggregate", service.queryHandler.AggregateLogs)
+		logsQuery.GET("/quarantine", service.GetQuarantine)
+		logsQuery.DELETE("/quarantine", service.ClearQuarantine)
+	}
+
+	// Log ingestion routes (API key, or a registered client certificate over mutual TLS)
+	logsIngest := router.Group("/api/v1/logs")
+	logsIngest.Use(service.clientCertHandler.ClientCertOrAPIKeyAuthMiddleware(service.authHandler.APIKeyAuthMiddleware()))
+	logsIngest.Use(service.decodeBodyMiddleware())
+	logsIngest.Use(service.idempotencyMiddleware())
+	{
+		logsIngest.POST("/ingest", service.IngestLog)
+		logsIngest.POST("/batch", service.IngestBatch)
+		logsIngest.POST("/gelf", service.IngestGELF)
+	}
+
+	// Serve static files (React app) as fallback for unmatched routes
+	router.NoRoute(gin.WrapH(http.FileServer(http.Dir("./static"))))
+
+	return router
+}
+
+// serves the API until ctx is cancelled, then waits for in-flight requests so every accepted log is published
+func (s *Service) Run(ctx context.Context) error {
+	cfg := s.config.Get()
+	router := setupRouter(s)
+
+	// Context for background listeners, cancelled once the server has shut down rather than with ctx,
+	// so the GELF listener and workers keep running while in-flight requests drain
+	listenCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+
+	// Record the service's own metrics into system_metrics
+	if cfg.SystemMetricsInterval > 0 {
+		recorder := metrics.NewRecorder(storage.NewSystemMetricsStorage(s.storage.GetDB()), "log-ingestion",
+			cfg.SystemMetricsInterval, cfg.SystemMetricsRetention, s.logger)
+		go recorder.Run(listenCtx)
+	}
+
+	go queue.SampleMetrics(listenCtx, s.queue, 15*time.Second, s.logger)
+
+	// Per-request settings are read from the manager on each request; the log level is applied here
+	s.config.OnReload(func(next *config.Config) {
+		if level, err := logrus.ParseLevel(next.LogLevel); err == nil {
+			s.logger.SetLevel(level)
+		}
+	})
+
+	srv := &http.Server{
+		Addr:              ":" + cfg.ServerPort,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		ReadTimeout:       cfg.HTTPReadTimeout,
+		WriteTimeout:      cfg.HTTPWriteTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+	}
+
+	// Serve HTTPS when a certificate is configured, picking up renewed certificates as they're written
+	if cfg.TLSEnabled() {
+		certs, err := tlsconfig.NewCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		if srv.TLSConfig, err = tlsconfig.ServerConfig(certs, cfg.TLSClientCAFile); err != nil {
+			return fmt.Errorf("failed to configure TLS: %w", err)
+		}
+		go certs.Watch(listenCtx, time.Minute, s.logger)
+	}
+
+	// Start the GELF UDP listener if configured
+	gelfStopped := make(chan struct{})
+	if cfg.GELFUDPAddr != "" {
+		gelfServer := gelf.NewUDPServer(cfg.GELFUDPAddr, s.handleGELFDatagram, s.logger)
+		go func() {
+			defer close(gelfStopped)
+			if err := gelfServer.ListenAndServe(listenCtx); err != nil && err != context.Canceled {
+				s.logger.WithError(err).Error("GELF UDP listener stopped with error")
+			}
+		}()
+	} else {
+		close(gelfStopped)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		var err error
+		if srv.TLSConfig != nil {
+			s.logger.WithField("mutual_tls", cfg.TLSClientCAFile != "").Infof("Starting ingestion service on port %s (HTTPS)", cfg.ServerPort)
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			s.logger.Infof("Starting ingestion service on port %s", cfg.ServerPort)
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			serveErr <- err
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+	case err := <-serveErr:
+		cancel()
+		<-gelfStopped
+		return fmt.Errorf("failed to start server: %w", err)
+	}
+
+	s.logger.Info("Shutting down ingestion service...")
+
+	// Stop accepting connections and wait for in-flight requests, so every accepted log is published
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer shutdownCancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		s.logger.WithError(err).Warn("Timed out waiting for in-flight requests")
+	}
+
+	// Then stop the GELF listener and background workers; Close flushes forwarded logs
+	cancel()
+	<-gelfStopped
+	s.logger.Info("Ingestion service stopped")
+	return nil
+}
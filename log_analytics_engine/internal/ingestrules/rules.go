@@ -0,0 +1,201 @@
+package ingestrules
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/cache"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+/*
+This package evaluates per-user ingest rules before logs are queued:
+rules run in priority order; rewrite rules change the entry and evaluation continues
+the first matching drop or sample rule decides whether the entry is kept
+sampled entries carry a sample_rate field so counts can be re-weighted (count / sample_rate)
+*/
+
+// SampleRateField is the field recording the fraction of similar logs that were kept
+const SampleRateField = "sample_rate"
+
+// rule is a compiled ingest rule
+type rule struct {
+	config       *models.IngestRule
+	levels       map[string]bool
+	sources      map[string]bool
+	services     map[string]bool
+	messageRegex *regexp.Regexp
+}
+
+// Rules is a user's compiled rule set
+type Rules struct {
+	rules []rule
+}
+
+// RuleStats counts what one rule did during a call to Apply
+type RuleStats struct {
+	Matched int64
+	Dropped int64
+}
+
+// compiles rules, which must already be in priority order
+func Compile(configs []*models.IngestRule) (*Rules, error) {
+	r := &Rules{}
+	for _, config := range configs {
+		compiled := rule{
+			config:   config,
+			levels:   toSet(config.Match.Levels),
+			sources:  toSet(config.Match.Sources),
+			services: toSet(config.Match.Services),
+		}
+		if config.Match.MessageRegex != "" {
+			re, err := regexp.Compile(config.Match.MessageRegex)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: invalid message_regex: %w", config.ID, err)
+			}
+			compiled.messageRegex = re
+		}
+		r.rules = append(r.rules, compiled)
+	}
+	return r, nil
+}
+
+func toSet(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
+
+func (r *rule) matches(entry *models.LogEntry) bool {
+	if r.levels != nil && !r.levels[entry.Level] {
+		return false
+	}
+	if r.sources != nil && !r.sources[entry.Source] {
+		return false
+	}
+	if r.services != nil && !r.services[entry.Service] {
+		return false
+	}
+	for key, value := range r.config.Match.Fields {
+		if entry.Fields[key] != value {
+			return false
+		}
+	}
+	if r.messageRegex != nil && !r.messageRegex.MatchString(entry.Message) {
+		return false
+	}
+	return true
+}
+
+// Apply evaluates the rules against each entry and returns the entries to keep,
+// plus per-rule counters keyed by rule ID
+func (r *Rules) Apply(entries []*models.LogEntry) ([]*models.LogEntry, map[int]*RuleStats) {
+	if len(r.rules) == 0 {
+		return entries, nil
+	}
+
+	stats := make(map[int]*RuleStats)
+	kept := make([]*models.LogEntry, 0, len(entries))
+
+	for _, entry := range entries {
+		if r.keep(entry, stats) {
+			kept = append(kept, entry)
+		}
+	}
+	return kept, stats
+}
+
+// runs the rules against one entry and reports whether it is kept
+func (r *Rules) keep(entry *models.LogEntry, stats map[int]*RuleStats) bool {
+	for i := range r.rules {
+		rl := &r.rules[i]
+		if !rl.matches(entry) {
+			continue
+		}
+
+		s := stats[rl.config.ID]
+		if s == nil {
+			s = &RuleStats{}
+			stats[rl.config.ID] = s
+		}
+		s.Matched++
+
+		switch rl.config.Action {
+		case "rewrite":
+			if rl.config.SetLevel != "" {
+				entry.Level = rl.config.SetLevel
+			}
+			if rl.config.SetService != "" {
+				entry.Service = rl.config.SetService
+			}
+		case "drop":
+			s.Dropped++
+			return false
+		case "sample":
+			if rand.Float64() >= rl.config.SampleRate {
+				s.Dropped++
+				return false
+			}
+			markSampled(entry, rl.config.SampleRate)
+			return true
+		}
+	}
+	return true
+}
+
+// records the sample rate on a kept entry, combining it with any rate the client already applied
+func markSampled(entry *models.LogEntry, rate float64) {
+	if entry.Fields == nil {
+		entry.Fields = make(map[string]string)
+	}
+	if existing, err := strconv.ParseFloat(entry.Fields[SampleRateField], 64); err == nil && existing > 0 && existing <= 1 {
+		rate *= existing
+	}
+	entry.Fields[SampleRateField] = strconv.FormatFloat(rate, 'g', -1, 64)
+}
+
+// Registry caches each user's compiled rules for the ingester
+type Registry struct {
+	cache  *cache.PerUser[*Rules]
+	logger *logrus.Logger
+}
+
+// creates a registry; load returns a user's active rules in priority order
+func NewRegistry(load func(userID int) ([]*models.IngestRule, error), ttl time.Duration, logger *logrus.Logger) *Registry {
+	return &Registry{
+		logger: logger,
+		cache: cache.NewPerUser(ttl, func(userID int) (*Rules, error) {
+			configs, err := load(userID)
+			if err != nil {
+				return nil, err
+			}
+			return Compile(configs)
+		}),
+	}
+}
+
+// Get returns the user's rules; if they can't be loaded every entry is kept
+func (r *Registry) Get(userID int) *Rules {
+	rules, err := r.cache.Get(userID)
+	if err != nil {
+		r.logger.WithError(err).WithField("user_id", userID).Warn("Failed to load ingest rules")
+	}
+	if rules == nil {
+		return &Rules{}
+	}
+	return rules
+}
+
+// Invalidate drops a user's cached rules after they change
+func (r *Registry) Invalidate(userID int) {
+	r.cache.Invalidate(userID)
+}
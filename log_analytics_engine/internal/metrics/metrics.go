@@ -0,0 +1,156 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+/*
+this file defines the services' own operational metrics
+
+each binary updates the ones that apply to it and serves them in the Prometheus format on /metrics,
+and a Recorder periodically copies them into system_metrics
+*/
+
+// reasons logs are counted in LogsRejected
+const (
+	RejectTooLarge      = "too_large"      // body or line over the size limit
+	RejectInvalidJSON   = "invalid_json"   // not parseable
+	RejectInvalid       = "invalid"        // failed validation
+	RejectTimestamp     = "timestamp"      // outside the timestamp skew window
+	RejectBatchRejected = "batch_rejected" // valid, but in a batch rejected for its invalid entries
+	RejectDropped       = "dropped"        // discarded by an ingest rule
+	RejectRateLimited   = "rate_limited"   // over a rate limit or quota
+	RejectPublishFailed = "publish_failed" // couldn't be queued onto the stream
+)
+
+// Registry holds every LogBuilder metric
+var Registry = prometheus.NewRegistry()
+
+var (
+	// requests served by the HTTP API, per route pattern, method and status code
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "logbuilder_http_requests_total",
+		Help: "HTTP requests handled, by route, method and status.",
+	}, []string{"handler", "method", "status"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "logbuilder_http_request_duration_seconds",
+		Help:    "HTTP request latency, by route, method and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"handler", "method", "status"})
+
+	// logs accepted by each ingest endpoint: ingest, batch, ndjson, gelf, gelf_udp
+	LogsAccepted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "logbuilder_logs_accepted_total",
+		Help: "Logs accepted for processing, by ingest endpoint.",
+	}, []string{"endpoint"})
+
+	LogsRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "logbuilder_logs_rejected_total",
+		Help: "Logs not accepted for processing, by reason.",
+	}, []string{"reason"})
+
+	// chunked GELF UDP messages discarded incomplete: expired, or full when the reassembly buffer is at its limit
+	GELFChunkedDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "logbuilder_gelf_chunked_dropped_total",
+		Help: "Chunked GELF messages discarded before they were complete, by reason.",
+	}, []string{"reason"})
+
+	// api key lookups answered from the Redis cache (hit) or the database (miss)
+	APIKeyCache = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "logbuilder_api_key_cache_total",
+		Help: "API key lookups by cache result.",
+	}, []string{"result"})
+
+	// publishes of one log (single) or a pipelined batch (batch)
+	PublishDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "logbuilder_redis_publish_duration_seconds",
+		Help:    "Latency of publishing logs to the Redis stream.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation"})
+
+	// logs published onto the stream by the ingester
+	LogsIngested = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "logbuilder_logs_ingested_total",
+		Help: "Logs queued onto the Redis stream.",
+	})
+
+	// logs stored by the processor
+	LogsProcessed = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "logbuilder_logs_processed_total",
+		Help: "Logs stored in PostgreSQL.",
+	})
+
+	// messages returned by each stream read
+	ProcessorBatchSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "logbuilder_processor_batch_size",
+		Help:    "Messages per read from the Redis stream.",
+		Buckets: []float64{1, 2, 5, 10, 20, 50, 100},
+	})
+
+	InsertDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "logbuilder_insert_duration_seconds",
+		Help:    "Latency of storing a single log in PostgreSQL.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	})
+
+	// failures by where they happened: publish, decode, insert, ack
+	Errors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "logbuilder_errors_total",
+		Help: "Errors while moving logs through the system, by stage.",
+	}, []string{"stage"})
+
+	StreamLength = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "logbuilder_stream_length",
+		Help: "Entries in the logs:incoming stream.",
+	})
+
+	// entries not yet delivered to the consumer group
+	StreamLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "logbuilder_stream_lag",
+		Help: "Stream entries not yet read by the consumer group.",
+	}, []string{"group"})
+
+	// entries delivered but not yet acknowledged
+	StreamPending = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "logbuilder_stream_pending",
+		Help: "Stream entries read by the consumer group but not acknowledged.",
+	}, []string{"group"})
+
+	// pending entries taken over after ClaimIdle, i.e. retried after a failure or a dead processor
+	MessagesClaimed = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "logbuilder_stream_claimed_total",
+		Help: "Pending stream entries claimed again for processing.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPRequestDuration,
+		LogsAccepted,
+		LogsRejected,
+		GELFChunkedDropped,
+		APIKeyCache,
+		PublishDuration,
+		ProcessorBatchSize,
+		LogsIngested,
+		LogsProcessed,
+		InsertDuration,
+		Errors,
+		StreamLength,
+		StreamLag,
+		StreamPending,
+		MessagesClaimed,
+	)
+}
+
+// counts logs that were not accepted
+func Reject(reason string, count int) {
+	if count > 0 {
+		LogsRejected.WithLabelValues(reason).Add(float64(count))
+	}
+}
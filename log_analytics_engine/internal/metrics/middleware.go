@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// counts and times requests per route pattern rather than raw path, so IDs don't create new series
+func GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		handler := c.FullPath()
+		if handler == "" {
+			handler = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		HTTPRequests.WithLabelValues(handler, c.Request.Method, status).Inc()
+		HTTPRequestDuration.WithLabelValues(handler, c.Request.Method, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler serves the registry in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// where recorded samples are written; implemented by storage.SystemMetricsStorage
+type Store interface {
+	InsertMetrics(metrics []models.SystemMetric) error
+	DeleteMetricsBefore(cutoff time.Time) (int64, error)
+}
+
+// Recorder periodically snapshots the registry into system_metrics.
+// Counters are written as totals plus a per-second rate over the interval, and
+// histograms as _count, _sum and the average of the observations in the interval
+type Recorder struct {
+	gatherer  prometheus.Gatherer
+	store     Store
+	service   string // added to every sample's labels
+	interval  time.Duration
+	retention time.Duration // 0 keeps samples forever
+	logger    *logrus.Logger
+
+	// previous cumulative values by series, for rates and interval averages
+	previous     map[string]float64
+	previousTime time.Time
+}
+
+func NewRecorder(store Store, service string, interval, retention time.Duration, logger *logrus.Logger) *Recorder {
+	return &Recorder{
+		gatherer:  Registry,
+		store:     store,
+		service:   service,
+		interval:  interval,
+		retention: retention,
+		logger:    logger,
+		previous:  make(map[string]float64),
+	}
+}
+
+// records a snapshot every interval until ctx is cancelled, pruning old samples about hourly
+func (r *Recorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	lastPrune := time.Time{}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := r.record(now); err != nil {
+				r.logger.WithError(err).Warn("Failed to record system metrics")
+			}
+			if r.retention > 0 && now.Sub(lastPrune) >= time.Hour {
+				lastPrune = now
+				if _, err := r.store.DeleteMetricsBefore(now.Add(-r.retention)); err != nil {
+					r.logger.WithError(err).Warn("Failed to prune system metrics")
+				}
+			}
+		}
+	}
+}
+
+func (r *Recorder) record(now time.Time) error {
+	families, err := r.gatherer.Gather()
+	if err != nil {
+		return err
+	}
+
+	elapsed := now.Sub(r.previousTime).Seconds()
+	if r.previousTime.IsZero() {
+		elapsed = 0
+	}
+
+	var samples []models.SystemMetric
+	add := func(name, metricType string, value float64, labels map[string]string) {
+		samples = append(samples, models.SystemMetric{Name: name, Value: value, Type: metricType, Labels: labels, Timestamp: now})
+	}
+	// increase of a cumulative series since the previous snapshot, false on the first one
+	delta := func(key string, value float64) (float64, bool) {
+		prev, seen := r.previous[key]
+		r.previous[key] = value
+		if !seen || elapsed <= 0 || value < prev {
+			return 0, false
+		}
+		return value - prev, true
+	}
+
+	for _, family := range families {
+		name := family.GetName()
+		if !strings.HasPrefix(name, "logbuilder_") {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{"service": r.service}
+			for _, pair := range metric.GetLabel() {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+			key := seriesKey(name, metric.GetLabel())
+
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				value := metric.GetCounter().GetValue()
+				add(name, models.MetricCounter, value, labels)
+				if increase, ok := delta(key, value); ok {
+					add(strings.TrimSuffix(name, "_total")+"_per_second", models.MetricRate, increase/elapsed, labels)
+				}
+			case dto.MetricType_GAUGE:
+				add(name, models.MetricGauge, metric.GetGauge().GetValue(), labels)
+			case dto.MetricType_HISTOGRAM:
+				histogram := metric.GetHistogram()
+				count, sum := float64(histogram.GetSampleCount()), histogram.GetSampleSum()
+				add(name+"_count", models.MetricHistogram, count, labels)
+				add(name+"_sum", models.MetricHistogram, sum, labels)
+
+				countIncrease, countOK := delta(key+"_count", count)
+				sumIncrease, sumOK := delta(key+"_sum", sum)
+				if countOK && sumOK && countIncrease > 0 {
+					add(name+"_avg", models.MetricHistogram, sumIncrease/countIncrease, labels)
+				}
+			}
+		}
+	}
+
+	r.previousTime = now
+	return r.store.InsertMetrics(samples)
+}
+
+// identifies a series by name and label values, which Gather returns sorted by label name
+func seriesKey(name string, labels []*dto.LabelPair) string {
+	var b strings.Builder
+	b.WriteString(name)
+	for _, pair := range labels {
+		b.WriteString("|")
+		b.WriteString(pair.GetName())
+		b.WriteString("=")
+		b.WriteString(pair.GetValue())
+	}
+	return b.String()
+}
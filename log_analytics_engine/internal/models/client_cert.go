@@ -0,0 +1,42 @@
+package models
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// client certificate registered by a user; agents presenting it over mutual TLS
+// may ingest logs as that user without an API key
+type ClientCertificate struct {
+	ID          int        `json:"id" db:"id"`
+	UserID      int        `json:"user_id" db:"user_id"`
+	Name        string     `json:"name" db:"name"`
+	Fingerprint string     `json:"fingerprint" db:"fingerprint"` // hex SHA-256 of the DER certificate
+	Subject     string     `json:"subject" db:"subject"`
+	NotAfter    time.Time  `json:"not_after" db:"not_after"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt  *time.Time `json:"last_used_at" db:"last_used_at"`
+	IsActive    bool       `json:"is_active" db:"is_active"`
+}
+
+// registers the certificate presented on the request's mutual TLS connection, which proves the caller
+// holds its key; certificates are public, so one pasted into a request body would prove nothing
+type CreateClientCertificateRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+func (r *CreateClientCertificateRequest) Validate() error {
+	if len(r.Name) < 1 || len(r.Name) > 100 {
+		return fmt.Errorf("certificate name must be between 1 and 100 characters")
+	}
+	return nil
+}
+
+// returns the fingerprint client certificates are registered and looked up by
+func CertificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
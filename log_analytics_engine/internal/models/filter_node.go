@@ -0,0 +1,194 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+)
+
+/*
+This file defines the JSON form of filter trees in QueryRequest.Where, for example
+    {"or": [
+        {"and": [{"field": "level", "op": "eq", "value": "ERROR"}, {"field": "service", "value": "api"}]},
+        {"field": "level", "value": "FATAL"},
+        {"not": {"field": "fields.region", "op": "in", "values": ["us-east-1", "us-west-2"]}}
+    ]}
+A node is either and/or over child nodes, not over one node, or a comparison of a field: a log column or
+"fields.<key>" for a custom field. Comparisons default to eq and compile to Filter nodes, the same tree
+the flat QueryRequest fields build. Like SQL, a comparison on a custom field a log doesn't have is never
+true, even under not
+*/
+
+// limits on a filter tree, keeping the compiled SQL small
+const (
+	maxFilterDepth  = 10
+	maxFilterNodes  = 100
+	maxFilterValues = 100 // values of one in/not_in comparison
+)
+
+var fieldKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.\-]{1,100}$`)
+
+// FilterNode is one node of a filter tree in a query request
+type FilterNode struct {
+	And []*FilterNode `json:"and,omitempty"`
+	Or  []*FilterNode `json:"or,omitempty"`
+	Not *FilterNode   `json:"not,omitempty"`
+
+	Field  string        `json:"field,omitempty"`  // log column or fields.<key>
+	Op     string        `json:"op,omitempty"`     // comparison operator, default eq
+	Value  interface{}   `json:"value,omitempty"`  // for single-value operators
+	Values []interface{} `json:"values,omitempty"` // for in and not_in
+}
+
+// ToFilter checks the tree and converts it to a Filter, normalizing levels and parsing times
+func (n *FilterNode) ToFilter() (*Filter, error) {
+	nodes := 0
+	return n.compile("where", 0, &nodes)
+}
+
+func (n *FilterNode) compile(path string, depth int, nodes *int) (*Filter, error) {
+	if n == nil {
+		return nil, fmt.Errorf("%s: empty filter", path)
+	}
+	if depth > maxFilterDepth {
+		return nil, fmt.Errorf("%s: filters can be nested at most %d deep", path, maxFilterDepth)
+	}
+	if *nodes++; *nodes > maxFilterNodes {
+		return nil, fmt.Errorf("filter can have at most %d conditions", maxFilterNodes)
+	}
+
+	kinds := 0
+	for _, set := range []bool{n.And != nil, n.Or != nil, n.Not != nil, n.Field != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return nil, fmt.Errorf("%s: a filter needs exactly one of and, or, not or field", path)
+	}
+
+	children := func(op string, list []*FilterNode) (*Filter, error) {
+		if len(list) == 0 {
+			return nil, fmt.Errorf("%s.%s: needs at least one condition", path, op)
+		}
+		filters := make([]*Filter, len(list))
+		for i, child := range list {
+			filter, err := child.compile(fmt.Sprintf("%s.%s[%d]", path, op, i), depth+1, nodes)
+			if err != nil {
+				return nil, err
+			}
+			filters[i] = filter
+		}
+		return combine(op, filters), nil
+	}
+
+	switch {
+	case n.And != nil:
+		return children(FilterAnd, n.And)
+	case n.Or != nil:
+		return children(FilterOr, n.Or)
+	case n.Not != nil:
+		child, err := n.Not.compile(path+".not", depth+1, nodes)
+		if err != nil {
+			return nil, err
+		}
+		return Not(child), nil
+	}
+
+	filter, err := n.comparison()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := ValidateFilter(filter); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return filter, nil
+}
+
+// converts a leaf to a Filter, converting its values to the field's type
+func (n *FilterNode) comparison() (*Filter, error) {
+	op := strings.ToLower(n.Op)
+	if op == "" {
+		op = FilterEq
+	}
+
+	var raw []interface{}
+	if op == FilterIn || op == FilterNotIn {
+		if n.Value != nil || len(n.Values) == 0 {
+			return nil, fmt.Errorf("%s on %s needs values", op, n.Field)
+		}
+		if len(n.Values) > maxFilterValues {
+			return nil, fmt.Errorf("%s on %s can have at most %d values", op, n.Field, maxFilterValues)
+		}
+		raw = n.Values
+	} else {
+		if n.Value == nil || n.Values != nil {
+			return nil, fmt.Errorf("%s on %s needs a single value", op, n.Field)
+		}
+		raw = []interface{}{n.Value}
+	}
+
+	field := strings.ToLower(n.Field)
+	if strings.HasPrefix(field, FieldKeyPrefix) {
+		// Custom field keys are case-sensitive
+		field = FieldKeyPrefix + n.Field[len(FieldKeyPrefix):]
+	}
+	values := make([]interface{}, len(raw))
+	for i, value := range raw {
+		converted, err := filterValue(field, value)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = converted
+	}
+
+	// Level ranges compare severity, like min_level and max_level
+	if field == FieldLevel && (op == FilterGte || op == FilterLte) {
+		return Compare(FieldSeverityNumber, op, SeverityNumber(values[0].(string))), nil
+	}
+	return Compare(field, op, values...), nil
+}
+
+// converts a JSON value to the type stored in field
+func filterValue(field string, value interface{}) (interface{}, error) {
+	switch field {
+	case FieldSeverityNumber:
+		number, ok := value.(float64)
+		if !ok || number != math.Trunc(number) {
+			return nil, fmt.Errorf("%s values must be whole numbers", field)
+		}
+		return int(number), nil
+	case FieldTimestamp, FieldReceivedAt:
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s values must be RFC 3339 times", field)
+		}
+		t, err := time.Parse(time.RFC3339Nano, text)
+		if err != nil {
+			return nil, fmt.Errorf("%s values must be RFC 3339 times: %w", field, err)
+		}
+		return t, nil
+	}
+
+	text, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s values must be strings", field)
+	}
+	if field == FieldLevel {
+		return normalizeLevelParam("log level", text)
+	}
+	return text, nil
+}
+
+// FieldKey returns the filter field testing a custom field
+func FieldKey(key string) string {
+	return FieldKeyPrefix + key
+}
+
+// IsFieldKey reports whether field names a valid custom field key, returning the key
+func IsFieldKey(field string) (string, bool) {
+	key, ok := strings.CutPrefix(field, FieldKeyPrefix)
+	return key, ok && fieldKeyPattern.MatchString(key)
+}
@@ -0,0 +1,180 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// renders a Filter compactly: comparisons as field op values and operators as (op children...)
+func renderFilter(f *Filter) string {
+	if f == nil {
+		return "<nil>"
+	}
+	if f.Field != "" {
+		return fmt.Sprintf("%s %s %v", f.Field, f.Op, f.Values)
+	}
+	children := make([]string, len(f.Children))
+	for i, child := range f.Children {
+		children[i] = renderFilter(child)
+	}
+	return "(" + f.Op + " " + strings.Join(children, ", ") + ")"
+}
+
+func parseWhere(t *testing.T, where string) *FilterNode {
+	t.Helper()
+	var node FilterNode
+	if err := json.Unmarshal([]byte(where), &node); err != nil {
+		t.Fatalf("invalid test JSON %s: %v", where, err)
+	}
+	return &node
+}
+
+// nests a comparison under depth "not"s
+func nestedWhere(depth int) string {
+	return strings.Repeat(`{"not": `, depth) + `{"field": "source", "value": "web"}` + strings.Repeat("}", depth)
+}
+
+func TestFilterNodeToFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		where string
+		want  string
+	}{
+		{"comparison defaults to eq", `{"field": "source", "value": "web"}`, "source eq [web]"},
+		{"levels are normalized", `{"field": "level", "value": "warning"}`, "level eq [WARN]"},
+		{"level ranges compare severity", `{"field": "level", "op": "gte", "value": "error"}`, "severity_number gte [17]"},
+		{"custom field keys keep their case", `{"field": "Fields.Region", "op": "contains", "value": "east"}`, "fields.Region contains [east]"},
+		{"in", `{"field": "service", "op": "IN", "values": ["api", "billing"]}`, "service in [api billing]"},
+		{"single child and is dropped", `{"and": [{"field": "source", "value": "web"}]}`, "source eq [web]"},
+		{
+			"nested",
+			`{"or": [
+				{"and": [{"field": "level", "value": "ERROR"}, {"field": "service", "value": "api"}]},
+				{"field": "level", "value": "FATAL"},
+				{"not": {"field": "fields.region", "op": "in", "values": ["us-east-1", "us-west-2"]}}
+			]}`,
+			"(or (and level eq [ERROR], service eq [api]), level eq [FATAL], (not fields.region in [us-east-1 us-west-2]))",
+		},
+		{"deepest allowed", nestedWhere(maxFilterDepth), strings.Repeat("(not ", maxFilterDepth) + "source eq [web]" + strings.Repeat(")", maxFilterDepth)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := parseWhere(t, tt.where).ToFilter()
+			if err != nil {
+				t.Fatalf("ToFilter failed: %v", err)
+			}
+			if got := renderFilter(filter); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+			if err := ValidateFilter(And(filter, Compare(FieldLevel, FilterEq, "INFO"))); err != nil {
+				t.Errorf("ValidateFilter rejected the tree joined with a flat condition: %v", err)
+			}
+		})
+	}
+}
+
+func TestFilterNodeToFilterErrors(t *testing.T) {
+	many := make([]string, maxFilterNodes)
+	for i := range many {
+		many[i] = `{"field": "source", "value": "web"}`
+	}
+	manyValues := make([]string, maxFilterValues+1)
+	for i := range manyValues {
+		manyValues[i] = `"v"`
+	}
+
+	tests := []struct {
+		name  string
+		where string
+		want  string
+	}{
+		{"too deep", nestedWhere(maxFilterDepth + 1), "nested at most 10 deep"},
+		{"empty and", `{"and": []}`, "where.and: needs at least one condition"},
+		{"empty or nested", `{"not": {"or": []}}`, "where.not.or: needs at least one condition"},
+		{"empty node", `{}`, "needs exactly one of"},
+		{"two kinds", `{"field": "source", "value": "web", "and": [{"field": "level", "value": "INFO"}]}`, "needs exactly one of"},
+		{"null child", `{"and": [{"field": "source", "value": "web"}, null]}`, "where.and[1]: empty filter"},
+		{"too many conditions", `{"and": [` + strings.Join(many, ", ") + `]}`, "at most 100 conditions"},
+		{"too many values", `{"field": "source", "op": "in", "values": [` + strings.Join(manyValues, ", ") + `]}`, "at most 100 values"},
+		{"in without values", `{"field": "source", "op": "in", "value": "web"}`, "needs values"},
+		{"eq with values", `{"field": "source", "values": ["web"]}`, "needs a single value"},
+		{"unknown field", `{"or": [{"field": "level", "value": "INFO"}, {"field": "password", "value": "x"}]}`, "where.or[1]: unknown filter field"},
+		{"unknown operator", `{"field": "source", "op": "like", "value": "w%"}`, "unknown filter operator"},
+		{"invalid pattern deep in the tree", `{"and": [{"not": {"field": "message", "op": "regex", "value": "\\bx"}}]}`, `where.and[0].not: regex filter on message: pattern escape \b`},
+		{"invalid level", `{"field": "level", "value": "LOUD"}`, "where:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := parseWhere(t, tt.where).ToFilter()
+			if err == nil {
+				t.Fatalf("ToFilter = %s, want an error containing %q", renderFilter(filter), tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateFilterTrees(t *testing.T) {
+	leaf := func() *Filter { return Compare(FieldSource, FilterEq, "web") }
+	nested := func(depth int) *Filter {
+		filter := leaf()
+		for i := 0; i < depth; i++ {
+			filter = Not(filter)
+		}
+		return filter
+	}
+
+	tests := []struct {
+		name   string
+		filter *Filter
+		want   string // empty when the filter is valid
+	}{
+		{"nil", nil, ""},
+		{"nested and, or and not", And(Or(leaf(), Not(And(leaf(), leaf()))), leaf()), ""},
+		{"deepest allowed", nested(maxFilterTreeDepth), ""},
+		{"too deep", nested(maxFilterTreeDepth + 1), "nested more than 11 deep"},
+		{"too deep under and", And(leaf(), nested(maxFilterTreeDepth)), "nested more than 11 deep"},
+		{"empty and", &Filter{Op: FilterAnd}, "and filter needs at least one condition"},
+		{"empty or nested", And(leaf(), &Filter{Op: FilterOr}), "or filter needs at least one condition"},
+		{"not without a child", &Filter{Op: FilterNot}, "not filter needs exactly one condition"},
+		{"not with two children", &Filter{Op: FilterNot, Children: []*Filter{leaf(), leaf()}}, "not filter needs exactly one condition"},
+		{"invalid leaf deep in the tree", Or(leaf(), Not(And(leaf(), Compare("password", FilterEq, "x")))), `unknown filter field "password"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateFilter(tt.filter)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("ValidateFilter = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ValidateFilter = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryRequestJoinsWhereWithFlatConditions(t *testing.T) {
+	req := QueryRequest{
+		Source: "web",
+		Where:  parseWhere(t, `{"or": [{"field": "level", "value": "ERROR"}, {"field": "fields.status", "op": "starts_with", "value": "5"}]}`),
+	}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	want := "(and source eq [web], (or level eq [ERROR], fields.status starts_with [5]))"
+	if got := renderFilter(req.Filter()); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	req = QueryRequest{Where: parseWhere(t, `{"and": []}`)}
+	if err := req.Validate(); err == nil {
+		t.Error("Validate accepted an empty and")
+	}
+}
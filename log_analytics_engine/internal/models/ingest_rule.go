@@ -0,0 +1,120 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+var validIngestRuleActions = map[string]bool{
+	"drop":    true, // discard matching logs
+	"sample":  true, // keep a random SampleRate fraction of matching logs
+	"rewrite": true, // change the level and/or service, then keep evaluating rules
+}
+
+// per-user rule evaluated at ingest, before logs are queued for processing
+type IngestRule struct {
+	ID         int             `json:"id" db:"id"`
+	UserID     int             `json:"user_id" db:"user_id"`
+	Name       string          `json:"name" db:"name"`
+	Priority   int             `json:"priority" db:"priority"` // lower runs first
+	Match      IngestRuleMatch `json:"match" db:"match"`
+	Action     string          `json:"action" db:"action"`
+	SampleRate float64         `json:"sample_rate,omitempty" db:"sample_rate"` // fraction kept, for sample
+	SetLevel   string          `json:"set_level,omitempty" db:"set_level"`     // for rewrite
+	SetService string          `json:"set_service,omitempty" db:"set_service"` // for rewrite
+	IsActive   bool            `json:"is_active" db:"is_active"`
+	Matched    int64           `json:"matched"` // logs the rule matched, read from Redis
+	Dropped    int64           `json:"dropped"` // logs the rule discarded, read from Redis
+	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// conditions a log must meet for a rule to apply; empty conditions match everything
+// and all non-empty conditions must hold
+type IngestRuleMatch struct {
+	Levels       []string          `json:"levels,omitempty"`
+	Sources      []string          `json:"sources,omitempty"`
+	Services     []string          `json:"services,omitempty"`
+	MessageRegex string            `json:"message_regex,omitempty"`
+	Fields       map[string]string `json:"fields,omitempty"` // exact field values
+}
+
+type CreateIngestRuleRequest struct {
+	Name       string          `json:"name" binding:"required"`
+	Priority   int             `json:"priority,omitempty"`
+	Match      IngestRuleMatch `json:"match"`
+	Action     string          `json:"action" binding:"required"`
+	SampleRate float64         `json:"sample_rate,omitempty"`
+	SetLevel   string          `json:"set_level,omitempty"`
+	SetService string          `json:"set_service,omitempty"`
+	IsActive   *bool           `json:"is_active,omitempty"`
+}
+
+func (r *CreateIngestRuleRequest) Validate() error {
+	if len(r.Name) < 1 || len(r.Name) > 100 {
+		return fmt.Errorf("rule name must be between 1 and 100 characters")
+	}
+	if !validIngestRuleActions[r.Action] {
+		return fmt.Errorf("invalid action %q (must be drop, sample, or rewrite)", r.Action)
+	}
+
+	for i, level := range r.Match.Levels {
+		normalized, err := normalizeLevelParam("log level in match", level)
+		if err != nil {
+			return err
+		}
+		r.Match.Levels[i] = normalized
+	}
+	if len(r.Match.MessageRegex) > 1000 {
+		return fmt.Errorf("message_regex cannot exceed 1000 characters")
+	}
+	if r.Match.MessageRegex != "" {
+		if _, err := regexp.Compile(r.Match.MessageRegex); err != nil {
+			return fmt.Errorf("invalid message_regex: %w", err)
+		}
+	}
+
+	switch r.Action {
+	case "sample":
+		if r.SampleRate <= 0 || r.SampleRate >= 1 {
+			return fmt.Errorf("sample_rate must be between 0 and 1 (exclusive)")
+		}
+	case "rewrite":
+		if r.SetLevel == "" && r.SetService == "" {
+			return fmt.Errorf("rewrite requires set_level or set_service")
+		}
+		if r.SetLevel != "" {
+			level, err := normalizeLevelParam("set_level", r.SetLevel)
+			if err != nil {
+				return err
+			}
+			r.SetLevel = level
+		}
+	}
+	return nil
+}
+
+// converts the request to a rule owned by userID
+func (r *CreateIngestRuleRequest) ToRule(userID int) *IngestRule {
+	isActive := true
+	if r.IsActive != nil {
+		isActive = *r.IsActive
+	}
+	rule := &IngestRule{
+		UserID:   userID,
+		Name:     r.Name,
+		Priority: r.Priority,
+		Match:    r.Match,
+		Action:   r.Action,
+		IsActive: isActive,
+	}
+	switch r.Action {
+	case "sample":
+		rule.SampleRate = r.SampleRate
+	case "rewrite":
+		rule.SetLevel = r.SetLevel
+		rule.SetService = r.SetService
+	}
+	return rule
+}
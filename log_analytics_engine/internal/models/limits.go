@@ -0,0 +1,79 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// ingestion limits for a user; a value of 0 means unlimited
+type IngestLimits struct {
+	UserID             int       `json:"user_id" db:"user_id"`
+	EventsPerSecond    int64     `json:"events_per_second" db:"events_per_second"`
+	BytesPerSecond     int64     `json:"bytes_per_second" db:"bytes_per_second"`
+	KeyEventsPerSecond int64     `json:"key_events_per_second" db:"key_events_per_second"` // per API key
+	KeyBytesPerSecond  int64     `json:"key_bytes_per_second" db:"key_bytes_per_second"`   // per API key
+	DailyEventQuota    int64     `json:"daily_event_quota" db:"daily_event_quota"`
+	DailyByteQuota     int64     `json:"daily_byte_quota" db:"daily_byte_quota"`
+	IsDefault          bool      `json:"is_default"` // true when no per-user override exists
+	UpdatedAt          time.Time `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+// admin request to override a user's ingestion limits
+type UpdateIngestLimitsRequest struct {
+	EventsPerSecond    int64 `json:"events_per_second"`
+	BytesPerSecond     int64 `json:"bytes_per_second"`
+	KeyEventsPerSecond int64 `json:"key_events_per_second"`
+	KeyBytesPerSecond  int64 `json:"key_bytes_per_second"`
+	DailyEventQuota    int64 `json:"daily_event_quota"`
+	DailyByteQuota     int64 `json:"daily_byte_quota"`
+}
+
+// current ingestion usage shown in the user's account view
+type IngestUsage struct {
+	Limits          *IngestLimits `json:"limits"`
+	EventsToday     int64         `json:"events_today"`
+	BytesToday      int64         `json:"bytes_today"`
+	QuotaResetsAt   time.Time     `json:"quota_resets_at"`
+	EventsAvailable int64         `json:"events_available"` // tokens left in the per-user events bucket
+	RejectedToday   int64         `json:"rejected_today"`
+}
+
+func (r *UpdateIngestLimitsRequest) Validate() error {
+	values := map[string]int64{
+		"events_per_second":     r.EventsPerSecond,
+		"bytes_per_second":      r.BytesPerSecond,
+		"key_events_per_second": r.KeyEventsPerSecond,
+		"key_bytes_per_second":  r.KeyBytesPerSecond,
+		"daily_event_quota":     r.DailyEventQuota,
+		"daily_byte_quota":      r.DailyByteQuota,
+	}
+	for name, value := range values {
+		if value < 0 {
+			return fmt.Errorf("%s cannot be negative (use 0 for unlimited)", name)
+		}
+	}
+	return nil
+}
+
+// converts the request to limits for the given user
+func (r *UpdateIngestLimitsRequest) ToLimits(userID int) *IngestLimits {
+	return &IngestLimits{
+		UserID:             userID,
+		EventsPerSecond:    r.EventsPerSecond,
+		BytesPerSecond:     r.BytesPerSecond,
+		KeyEventsPerSecond: r.KeyEventsPerSecond,
+		KeyBytesPerSecond:  r.KeyBytesPerSecond,
+		DailyEventQuota:    r.DailyEventQuota,
+		DailyByteQuota:     r.DailyByteQuota,
+		UpdatedAt:          time.Now(),
+	}
+}
+
+// approximate stored size of a log entry, used for byte rate limits and quotas
+func (l *LogEntry) Size() int64 {
+	size := len(l.Source) + len(l.Level) + len(l.Message) + len(l.Service) + len(l.RawMessage)
+	for key, value := range l.Fields {
+		size += len(key) + len(value)
+	}
+	return int64(size)
+}
@@ -1,9 +1,13 @@
 package models
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/timestamp"
 )
 
 /*
@@ -15,26 +19,64 @@ converts an incoming log to an actual log entry
 
 // single log entry
 type LogEntry struct {
-	ID         int64             `json:"id" db:"id"`
-	Timestamp  time.Time         `json:"timestamp" db:"timestamp"`
-	Source     string            `json:"source" db:"source"`
-	Level      string            `json:"level" db:"level"`
-	Message    string            `json:"message" db:"message"`
-	Service    string            `json:"service" db:"service"`
-	Fields     map[string]string `json:"fields,omitempty" db:"fields"`
-	RawMessage string            `json:"raw_message,omitempty" db:"raw_message"`
-	CreatedAt  time.Time         `json:"created_at" db:"created_at"`
-	UserID     int               `json:"user_id" db:"user_id"` // NEW FIELD
+	ID             int64             `json:"id" db:"id"`
+	Timestamp      time.Time         `json:"timestamp" db:"timestamp"`
+	Source         string            `json:"source" db:"source"`
+	Level          string            `json:"level" db:"level"`
+	SeverityNumber int               `json:"severity_number" db:"severity_number"` // ordinal of Level, see severity.go
+	Message        string            `json:"message" db:"message"`
+	Service        string            `json:"service" db:"service"`
+	Fields         map[string]string `json:"fields,omitempty" db:"fields"`
+	RawMessage     string            `json:"raw_message,omitempty" db:"raw_message"`
+	CreatedAt      time.Time         `json:"created_at" db:"created_at"`
+	ReceivedAt     time.Time         `json:"received_at" db:"received_at"` // when the ingester accepted the log
+	UserID         int               `json:"user_id" db:"user_id"`         // NEW FIELD
+	EventID        string            `json:"event_id,omitempty" db:"event_id"`
 }
 
 // incoming log data
 type IngestRequest struct {
 	Timestamp *time.Time        `json:"timestamp,omitempty"`
 	Source    string            `json:"source" binding:"required"`
-	Level     string            `json:"level" binding:"required"`
+	Level     string            `json:"level"` // level name or alias; may be left out if a severity number is sent
 	Message   string            `json:"message" binding:"required"`
 	Service   string            `json:"service,omitempty"`
 	Fields    map[string]string `json:"fields,omitempty"`
+	EventID   string            `json:"event_id,omitempty"` // client-supplied ID used to deduplicate retries
+
+	// Numeric severities, used when level is empty: an OTel severity number (1-24), or failing that a
+	// syslog severity (0-7). They are separate because the two scales run in opposite directions
+	SeverityNumber *int `json:"severity_number,omitempty"`
+	SyslogSeverity *int `json:"syslog_severity,omitempty"`
+
+	rawTimestamp string // timestamp as sent by the client, parsed by Validate
+}
+
+// UnmarshalJSON accepts the timestamp as a string in any of timestamp.DefaultFormats
+// (RFC3339, Apache, syslog, ...) or as an epoch number in seconds, millis, micros or nanos
+func (req *IngestRequest) UnmarshalJSON(data []byte) error {
+	type plain IngestRequest
+	aux := struct {
+		*plain
+		Timestamp json.RawMessage `json:"timestamp,omitempty"`
+	}{plain: (*plain)(req)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	req.Timestamp = nil
+	req.rawTimestamp = ""
+	raw := bytes.TrimSpace(aux.Timestamp)
+	switch {
+	case len(raw) == 0 || string(raw) == "null":
+	case raw[0] == '"':
+		if err := json.Unmarshal(raw, &req.rawTimestamp); err != nil {
+			return err
+		}
+	default:
+		req.rawTimestamp = string(raw)
+	}
+	return nil
 }
 
 // multiple logs at once
@@ -42,25 +84,66 @@ type BatchIngestRequest struct {
 	Logs []IngestRequest `json:"logs" binding:"required"`
 }
 
+// batch where each log is kept as raw JSON so entries can be validated and rejected individually
+type RawBatchIngestRequest struct {
+	Logs []json.RawMessage `json:"logs" binding:"required"`
+}
+
+// failure for a single entry of a partially accepted batch
+type IngestError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// rejected entry kept so users can inspect what was not ingested
+type QuarantinedLog struct {
+	Raw        string    `json:"raw"` // redacted like stored logs, so may not be valid JSON; empty if it couldn't be redacted
+	Error      string    `json:"error"`
+	Index      int       `json:"index"`  // batch index, or line number for NDJSON
+	Origin     string    `json:"origin"` // batch or ndjson
+	ReceivedAt time.Time `json:"received_at"`
+}
+
 // checks if the log entry has required fields
 func (req *IngestRequest) Validate() error {
 	if req.Source == "" {
 		return fmt.Errorf("source is required")
 	}
-	if req.Level == "" {
-		return fmt.Errorf("level is required")
-	}
 	if req.Message == "" {
 		return fmt.Errorf("message is required")
 	}
+	if len(req.EventID) > 255 {
+		return fmt.Errorf("event_id cannot exceed 255 characters")
+	}
 
-	// Validate log level
-	validLevels := map[string]bool{
-		"DEBUG": true, "INFO": true, "WARN": true,
-		"ERROR": true, "FATAL": true,
+	if req.rawTimestamp != "" {
+		parsed, err := timestamp.Parse(req.rawTimestamp, timestamp.DefaultFormats, time.UTC)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp: %q is not a recognized format", req.rawTimestamp)
+		}
+		req.Timestamp = &parsed
 	}
-	if !validLevels[strings.ToUpper(req.Level)] {
-		return fmt.Errorf("invalid log level: %s", req.Level)
+
+	// Validate log level, accepting aliases and numeric severities
+	switch {
+	case req.Level != "":
+		level, err := normalizeLevelParam("log level", req.Level)
+		if err != nil {
+			return err
+		}
+		req.Level = level
+	case req.SeverityNumber != nil:
+		if !IsOTelSeverity(*req.SeverityNumber) {
+			return fmt.Errorf("invalid severity_number: %d (must be an OTel severity number from 1 to 24)", *req.SeverityNumber)
+		}
+		req.Level = OTelLevel(*req.SeverityNumber)
+	case req.SyslogSeverity != nil:
+		if !IsSyslogSeverity(*req.SyslogSeverity) {
+			return fmt.Errorf("invalid syslog_severity: %d (must be from 0 to 7)", *req.SyslogSeverity)
+		}
+		req.Level = SyslogLevel(*req.SyslogSeverity)
+	default:
+		return fmt.Errorf("level is required")
 	}
 
 	return nil
@@ -68,19 +151,28 @@ func (req *IngestRequest) Validate() error {
 
 // converts IngestRequest to LogEntry
 func (req *IngestRequest) ToLogEntry() *LogEntry {
-	timestamp := time.Now()
+	now := time.Now()
+	ts := now
 	if req.Timestamp != nil {
-		timestamp = *req.Timestamp
+		ts = *req.Timestamp
+	}
+
+	level, ok := NormalizeLevel(req.Level)
+	if !ok {
+		level = strings.ToUpper(req.Level)
 	}
 
 	return &LogEntry{
-		Timestamp:  timestamp,
-		Source:     req.Source,
-		Level:      strings.ToUpper(req.Level),
-		Message:    req.Message,
-		Service:    req.Service,
-		Fields:     req.Fields,
-		RawMessage: "", // Will be filled when we add parsing
-		CreatedAt:  time.Now(),
+		Timestamp:      ts,
+		Source:         req.Source,
+		Level:          level,
+		SeverityNumber: SeverityNumber(level),
+		Message:        req.Message,
+		Service:        req.Service,
+		Fields:         req.Fields,
+		RawMessage:     "", // Filled by the processor when a parsing pipeline runs
+		CreatedAt:      now,
+		ReceivedAt:     now,
+		EventID:        req.EventID,
 	}
 }
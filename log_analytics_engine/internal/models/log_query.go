@@ -0,0 +1,220 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+This file defines the structured queries log stores execute:
+a predicate tree over log columns, scoped to one user, with sorting and pagination
+each store compiles it to its own SQL, so no SQL text crosses the storage boundary
+*/
+
+// boolean operators combining child filters
+const (
+	FilterAnd = "and"
+	FilterOr  = "or"
+	FilterNot = "not"
+)
+
+// comparison operators testing a column
+const (
+	FilterEq          = "eq"
+	FilterNe          = "ne"
+	FilterIn          = "in"
+	FilterNotIn       = "not_in"
+	FilterGte         = "gte"
+	FilterLte         = "lte"
+	FilterContains    = "contains"     // case-insensitive substring
+	FilterNotContains = "not_contains" // case-insensitive substring
+	FilterStartsWith  = "starts_with"  // case-sensitive prefix
+	FilterRegex       = "regex"        // case-sensitive regular expression, checked by ValidatePattern
+	FilterNotRegex    = "not_regex"    // case-sensitive regular expression
+	FilterIRegex      = "iregex"       // case-insensitive regular expression
+	FilterNotIRegex   = "not_iregex"   // case-insensitive regular expression
+)
+
+// log columns a filter can test
+const (
+	FieldLevel          = "level"
+	FieldSeverityNumber = "severity_number"
+	FieldSource         = "source"
+	FieldService        = "service"
+	FieldMessage        = "message"
+	FieldTimestamp      = "timestamp"
+	FieldReceivedAt     = "received_at"
+
+	FieldKeyPrefix = "fields." // followed by a custom field key, see FieldKey
+)
+
+// text columns, which contains, starts_with and regex filters can test, along with custom fields
+var TextFields = []string{FieldLevel, FieldSource, FieldService, FieldMessage}
+
+// columns logs can be sorted by
+var SortColumns = []string{FieldTimestamp, FieldReceivedAt, FieldSeverityNumber, FieldSource, FieldService}
+
+// sorts searched logs best match first; log stores that can't rank refuse it
+const SortRelevance = "relevance"
+
+// columns aggregations can group by
+var GroupColumns = []string{FieldLevel, FieldSource, FieldService}
+
+// Filter is a node of a predicate tree: a boolean operator over Children,
+// or a comparison of Field against Values
+type Filter struct {
+	Op       string
+	Field    string
+	Values   []interface{} // strings, ints or times depending on Field
+	Children []*Filter
+}
+
+// Compare returns a comparison of a column against one or more values
+func Compare(field, op string, values ...interface{}) *Filter {
+	return &Filter{Op: op, Field: field, Values: values}
+}
+
+// And returns a filter matching logs that match every non-nil child, or nil if there are none
+func And(children ...*Filter) *Filter {
+	return combine(FilterAnd, children)
+}
+
+// Or returns a filter matching logs that match any non-nil child, or nil if there are none
+func Or(children ...*Filter) *Filter {
+	return combine(FilterOr, children)
+}
+
+// Not returns a filter matching logs that don't match child
+func Not(child *Filter) *Filter {
+	return &Filter{Op: FilterNot, Children: []*Filter{child}}
+}
+
+func combine(op string, children []*Filter) *Filter {
+	var kept []*Filter
+	for _, child := range children {
+		if child != nil {
+			kept = append(kept, child)
+		}
+	}
+	switch len(kept) {
+	case 0:
+		return nil
+	case 1:
+		return kept[0]
+	}
+	return &Filter{Op: op, Children: kept}
+}
+
+// LogQuery selects one user's logs; the sort and page only apply to QueryLogs and SearchLogs
+type LogQuery struct {
+	UserID    int
+	Filter    *Filter      // nil matches all of the user's logs
+	Search    *SearchQuery // full-text search over messages, ANDed with Filter
+	SortBy    string       // one of SortColumns or SortRelevance; default relevance for searches where the store ranks them, otherwise timestamp
+	SortOrder string       // ASC or DESC, default DESC
+	Limit     int          // 0 means no limit
+	Offset    int
+}
+
+// AggregateQuery counts the logs matching a query per group and/or time bucket
+type AggregateQuery struct {
+	LogQuery
+	GroupBy  []string      // columns from GroupColumns
+	Interval time.Duration // width of timestamp buckets, 0 for no time bucketing
+}
+
+// AggregateBucket is one row of an aggregation
+type AggregateBucket struct {
+	Time  *time.Time        `json:"time,omitempty"`  // start of the bucket when an interval was given
+	Group map[string]string `json:"group,omitempty"` // GroupBy column -> value
+	Count int64             `json:"count"`
+}
+
+// deepest Filter tree accepted: a Where tree under the AND that joins it with the flat conditions
+const maxFilterTreeDepth = maxFilterDepth + 1
+
+// ValidateFilter checks that every node of a filter tree uses a known operator and column
+func ValidateFilter(f *Filter) error {
+	return validateFilter(f, 0)
+}
+
+func validateFilter(f *Filter, depth int) error {
+	if f == nil {
+		return nil
+	}
+	if depth > maxFilterTreeDepth {
+		return fmt.Errorf("filter is nested more than %d deep", maxFilterTreeDepth)
+	}
+	switch f.Op {
+	case FilterAnd, FilterOr:
+		if len(f.Children) == 0 {
+			return fmt.Errorf("%s filter needs at least one condition", f.Op)
+		}
+	case FilterNot:
+		if len(f.Children) != 1 {
+			return fmt.Errorf("not filter needs exactly one condition")
+		}
+	case FilterEq, FilterNe, FilterGte, FilterLte:
+		if len(f.Values) != 1 {
+			return fmt.Errorf("%s filter on %s needs exactly one value", f.Op, f.Field)
+		}
+	case FilterContains, FilterNotContains, FilterStartsWith, FilterRegex, FilterNotRegex, FilterIRegex, FilterNotIRegex:
+		if len(f.Values) != 1 {
+			return fmt.Errorf("%s filter on %s needs exactly one value", f.Op, f.Field)
+		}
+		value, ok := f.Values[0].(string)
+		_, isKey := IsFieldKey(f.Field)
+		if !ok || !(containsString(TextFields, f.Field) || isKey) {
+			return fmt.Errorf("%s filter needs a text value and one of %s or a custom field", f.Op, strings.Join(TextFields, ", "))
+		}
+		if f.Op != FilterContains && f.Op != FilterNotContains && f.Op != FilterStartsWith {
+			if err := ValidatePattern(value); err != nil {
+				return fmt.Errorf("%s filter on %s: %w", f.Op, f.Field, err)
+			}
+		}
+	case FilterIn, FilterNotIn:
+		if len(f.Values) == 0 {
+			return fmt.Errorf("%s filter on %s needs at least one value", f.Op, f.Field)
+		}
+	default:
+		return fmt.Errorf("unknown filter operator %q", f.Op)
+	}
+
+	if len(f.Children) > 0 {
+		for _, child := range f.Children {
+			if err := validateFilter(child, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch f.Field {
+	case FieldLevel, FieldSeverityNumber, FieldSource, FieldService, FieldMessage, FieldTimestamp, FieldReceivedAt:
+		return nil
+	}
+	if _, ok := IsFieldKey(f.Field); ok {
+		return nil
+	}
+	return fmt.Errorf("unknown filter field %q (custom fields are fields.<key>, with letters, digits, _, . and -)", f.Field)
+}
+
+// ValidateGroupBy checks aggregation group columns
+func ValidateGroupBy(columns []string) error {
+	for _, column := range columns {
+		if !containsString(GroupColumns, column) {
+			return fmt.Errorf("invalid group_by field: %s (must be %s)", column, strings.Join(GroupColumns, ", "))
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
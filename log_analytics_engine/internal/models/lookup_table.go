@@ -0,0 +1,41 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// limits on uploaded lookup tables
+const (
+	MaxLookupTableBytes = 10 << 20
+	MaxLookupTableRows  = 100000
+)
+
+var lookupTableName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,100}$`)
+
+// per-user CSV table joined onto logs in the processor: when a log's MatchField
+// equals a row's key, the row's other columns are added as fields
+type LookupTable struct {
+	ID         int                          `json:"id" db:"id"`
+	UserID     int                          `json:"user_id" db:"user_id"`
+	Name       string                       `json:"name" db:"name"`
+	MatchField string                       `json:"match_field" db:"match_field"` // log field holding the key, e.g. customer_id
+	KeyColumn  string                       `json:"key_column" db:"key_column"`   // CSV column holding the key
+	Columns    []string                     `json:"columns" db:"columns"`         // columns added to matching logs
+	RowCount   int                          `json:"row_count" db:"row_count"`
+	Rows       map[string]map[string]string `json:"-" db:"rows"` // key -> column -> value
+	CreatedAt  time.Time                    `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time                    `json:"updated_at" db:"updated_at"`
+}
+
+// checks a table's name and join settings
+func ValidateLookupTable(name, matchField string) error {
+	if !lookupTableName.MatchString(name) {
+		return fmt.Errorf("table name must be 1-100 letters, digits, '_' or '-'")
+	}
+	if matchField == "" || len(matchField) > 255 {
+		return fmt.Errorf("match_field must be between 1 and 255 characters")
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"strings"
+)
+
+/*
+This file checks regular expressions used in queries. The database runs a pattern against every candidate
+row, so patterns are limited in size, and only the syntax Postgres and Go (which the SQLite store uses)
+read the same way is accepted: escapes like \b mean a word boundary in Go but a backspace in Postgres
+*/
+
+// limits on query patterns
+const (
+	maxPatternLength       = 256
+	maxPatternRepeat       = 100  // largest {n,m} bound
+	maxPatternNesting      = 3    // repetitions inside repeated groups, such as ((a+)b)*
+	maxPatternInstructions = 1000 // compiled size, which grows with counted repetitions
+)
+
+// escapes whose meaning differs between the engines
+const unsupportedEscapes = "bBzZpPQECyYmM"
+
+// ValidatePattern checks that a regular expression is supported and cheap enough to run per row
+func ValidatePattern(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("pattern must not be empty")
+	}
+	if len(pattern) > maxPatternLength {
+		return fmt.Errorf("pattern must be at most %d characters", maxPatternLength)
+	}
+
+	for i := 0; i < len(pattern)-1; i++ {
+		switch {
+		case pattern[i] == '\\':
+			if strings.IndexByte(unsupportedEscapes, pattern[i+1]) >= 0 {
+				return fmt.Errorf("pattern escape \\%c isn't supported", pattern[i+1])
+			}
+			i++
+		case pattern[i] == '(' && pattern[i+1] == '?':
+			// Postgres only reads flags at the very start, and the shared flags are just i
+			if !strings.HasPrefix(pattern[i:], "(?:") && !(i == 0 && strings.HasPrefix(pattern, "(?i)")) {
+				return fmt.Errorf("pattern groups starting with (? other than (?: and a leading (?i) aren't supported")
+			}
+		}
+	}
+
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return fmt.Errorf("invalid pattern: %w", err)
+	}
+	if err := checkPattern(re, 0); err != nil {
+		return err
+	}
+	prog, err := syntax.Compile(re.Simplify())
+	if err != nil {
+		return fmt.Errorf("invalid pattern: %w", err)
+	}
+	if len(prog.Inst) > maxPatternInstructions {
+		return fmt.Errorf("pattern is too complex")
+	}
+	return nil
+}
+
+// checks repetition bounds and nesting below re
+func checkPattern(re *syntax.Regexp, depth int) error {
+	switch re.Op {
+	case syntax.OpCapture:
+		if re.Name != "" {
+			return fmt.Errorf("named groups aren't supported in patterns")
+		}
+	case syntax.OpRepeat:
+		if re.Min > maxPatternRepeat || re.Max > maxPatternRepeat {
+			return fmt.Errorf("pattern repetition counts must be at most %d", maxPatternRepeat)
+		}
+		fallthrough
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest:
+		depth++
+		if depth > maxPatternNesting {
+			return fmt.Errorf("pattern nests repetitions more than %d deep", maxPatternNesting)
+		}
+	}
+	for _, sub := range re.Sub {
+		if err := checkPattern(sub, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidatePatternAccepts(t *testing.T) {
+	for _, pattern := range []string{
+		"timeout",
+		"^payments-",
+		`connection (refused|reset)$`,
+		`(?i)^error`,
+		`(?:ab)+c`,
+		`\d{3}-\d{4}`,
+		`[a-z_]+\.go:\d+`,
+		`\w+@\w+\.com`,
+		`\(literal parens\)`,
+		`a{1,100}`,
+		`((a+)b)*`,
+		strings.Repeat("a", maxPatternLength),
+	} {
+		if err := ValidatePattern(pattern); err != nil {
+			t.Errorf("ValidatePattern(%q) = %v, want nil", pattern, err)
+		}
+	}
+}
+
+func TestValidatePatternRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		want    string
+	}{
+		{"empty", "", "must not be empty"},
+		{"too long", strings.Repeat("a", maxPatternLength+1), "at most 256 characters"},
+		{"invalid syntax", "(unclosed", "invalid pattern"},
+		{"word boundary", `\bword\b`, `\b isn't supported`},
+		{"non-boundary", `a\B`, `\B isn't supported`},
+		{"end of text", `end\z`, `\z isn't supported`},
+		{"unicode class", `\pL+`, `\p isn't supported`},
+		{"quoted literal", `\Qa.b\E`, `\Q isn't supported`},
+		{"escaped backslash before b is fine, b alone isn't", `\\\b`, `\b isn't supported`},
+		{"flags after the start", `a(?i)b`, "aren't supported"},
+		{"other leading flags", `(?s).*`, "aren't supported"},
+		{"flag group", `(?i:abc)`, "aren't supported"},
+		{"lookahead", `a(?=b)`, "aren't supported"},
+		{"named group", `(?P<name>a)`, "aren't supported"},
+		{"large repeat", `a{101}`, "at most 100"},
+		{"large repeat bound", `a{1,1000}`, "at most 100"},
+		{"deep nesting", `(((a+)+)+)+`, "more than 3 deep"},
+		{"too complex", strings.Repeat(`[a-z]{100}`, 11), "too complex"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePattern(tt.pattern)
+			if err == nil {
+				t.Fatalf("ValidatePattern(%q) = nil, want an error containing %q", tt.pattern, tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscapedBoundaryIsLiteral(t *testing.T) {
+	// \\b is a backslash followed by b in both engines
+	if err := ValidatePattern(`C:\\bin`); err != nil {
+		t.Errorf("ValidatePattern = %v, want nil", err)
+	}
+}
+
+func TestFilterValidatesPatterns(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  *Filter
+		wantErr bool
+	}{
+		{"regex", Compare(FieldMessage, FilterRegex, "^pay"), false},
+		{"iregex on a custom field", Compare("fields.region", FilterIRegex, "^EU-"), false},
+		{"not_regex", Compare(FieldSource, FilterNotRegex, "^web$"), false},
+		{"not_iregex", Compare(FieldService, FilterNotIRegex, "api"), false},
+		{"unsupported pattern", Compare(FieldMessage, FilterRegex, `\bpay`), true},
+		{"invalid pattern", Compare(FieldMessage, FilterNotIRegex, "(pay"), true},
+		{"regex on a number", Compare(FieldSeverityNumber, FilterRegex, "1"), true},
+		{"regex without a value", Compare(FieldMessage, FilterRegex), true},
+		{"regex with a number", Compare(FieldMessage, FilterRegex, 1), true},
+		{"starts_with", Compare(FieldMessage, FilterStartsWith, "Payment"), false},
+		{"starts_with isn't a pattern", Compare(FieldMessage, FilterStartsWith, `(\b`), false},
+		{"starts_with on a timestamp", Compare(FieldTimestamp, FilterStartsWith, "2024"), true},
+		{"starts_with with two values", Compare(FieldSource, FilterStartsWith, "a", "b"), true},
+		{"nested invalid pattern", And(Compare(FieldLevel, FilterEq, "INFO"), Not(Compare(FieldMessage, FilterRegex, "a{1000}"))), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateFilter(tt.filter)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateFilter() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestQueryRequestValidatesPatterns(t *testing.T) {
+	tests := []struct {
+		name string
+		req  QueryRequest
+		want string
+	}{
+		{"message_regex", QueryRequest{MessageRegex: `\bx`}, "message_regex"},
+		{"source_not_regex", QueryRequest{SourceNotRegex: "(x"}, "source_not_regex"},
+		{"service_regex", QueryRequest{ServiceRegex: "a{200}"}, "service_regex"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want+":") {
+				t.Errorf("Validate() = %v, want an error about %s", err, tt.want)
+			}
+		})
+	}
+
+	req := QueryRequest{MessageStartsWith: "Pay", ServiceRegex: "^payments-", RegexIgnoreCase: true}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("Validate() = %v, want nil", err)
+	}
+	filter := req.Filter()
+	if filter == nil || filter.Op != FilterAnd || len(filter.Children) != 2 {
+		t.Fatalf("Filter() = %+v, want an and of two conditions", filter)
+	}
+	if got := filter.Children[0]; got.Field != FieldMessage || got.Op != FilterStartsWith || got.Values[0] != "Pay" {
+		t.Errorf("first condition = %+v, want message starts_with Pay", got)
+	}
+	if got := filter.Children[1]; got.Field != FieldService || got.Op != FilterIRegex || got.Values[0] != "^payments-" {
+		t.Errorf("second condition = %+v, want service iregex ^payments-", got)
+	}
+}
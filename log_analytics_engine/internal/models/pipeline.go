@@ -0,0 +1,196 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// stage types a parsing pipeline can contain
+var validStageTypes = map[string]bool{
+	"grok":      true,
+	"regex":     true,
+	"json":      true,
+	"logfmt":    true,
+	"kv":        true,
+	"timestamp": true,
+	"level":     true,
+}
+
+// parsing pipeline a user attaches to a source and/or service
+type Pipeline struct {
+	ID        int              `json:"id" db:"id"`
+	UserID    int              `json:"user_id" db:"user_id"`
+	Name      string           `json:"name" db:"name"`
+	Source    string           `json:"source,omitempty" db:"source"`   // empty matches every source
+	Service   string           `json:"service,omitempty" db:"service"` // empty matches every service
+	Priority  int              `json:"priority" db:"priority"`         // lower runs first, the first matching pipeline wins
+	Stages    []PipelineStage  `json:"stages" db:"stages"`
+	Multiline *MultilineConfig `json:"multiline,omitempty" db:"multiline"` // joins continuation lines before the stages run
+	IsActive  bool             `json:"is_active" db:"is_active"`
+	CreatedAt time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at" db:"updated_at"`
+}
+
+// single ordered step of a pipeline
+type PipelineStage struct {
+	Type string `json:"type"` // grok, regex, json, logfmt, kv, timestamp or level
+
+	// Input to read: "message" (default) or the name of a field in Fields
+	Field string `json:"field,omitempty"`
+
+	// grok and regex
+	Pattern  string            `json:"pattern,omitempty"`
+	Patterns map[string]string `json:"patterns,omitempty"` // extra grok pattern definitions
+
+	// json, logfmt and kv: prefix added to extracted field names
+	Prefix string `json:"prefix,omitempty"`
+
+	// json: key whose value replaces Message, e.g. "msg"
+	MessageKey string `json:"message_key,omitempty"`
+
+	// kv: separator between pairs (default whitespace) and between key and value (default "=")
+	PairSeparator  string `json:"pair_separator,omitempty"`
+	ValueSeparator string `json:"value_separator,omitempty"`
+
+	// timestamp: layouts to try, Go layouts or names like RFC3339, UNIX_MS, HTTPDATE
+	Formats  []string `json:"formats,omitempty"`
+	Timezone string   `json:"timezone,omitempty"`
+}
+
+// rules for merging continuation lines, such as stack traces, into the preceding event
+type MultilineConfig struct {
+	StartPattern        string `json:"start_pattern,omitempty"`        // a line matching this starts a new event
+	ContinuationPattern string `json:"continuation_pattern,omitempty"` // a line matching this belongs to the previous event
+	MaxWaitMs           int    `json:"max_wait_ms,omitempty"`          // flush a buffered event after this long without new lines
+	MaxLines            int    `json:"max_lines,omitempty"`            // flush once an event has this many lines
+}
+
+// defaults and limits for multiline joining
+const (
+	DefaultMultilineMaxWait  = 2000
+	MaxMultilineMaxWait      = 60000
+	DefaultMultilineMaxLines = 500
+	MaxMultilineMaxLines     = 5000
+)
+
+type CreatePipelineRequest struct {
+	Name      string           `json:"name" binding:"required"`
+	Source    string           `json:"source,omitempty"`
+	Service   string           `json:"service,omitempty"`
+	Priority  int              `json:"priority,omitempty"`
+	Stages    []PipelineStage  `json:"stages"`
+	Multiline *MultilineConfig `json:"multiline,omitempty"`
+	IsActive  *bool            `json:"is_active,omitempty"`
+}
+
+// request to run a pipeline against sample lines without saving it
+type TestPipelineRequest struct {
+	PipelineID int              `json:"pipeline_id,omitempty"` // test a saved pipeline instead of Stages
+	Stages     []PipelineStage  `json:"stages,omitempty"`
+	Multiline  *MultilineConfig `json:"multiline,omitempty"`
+	Samples    []string         `json:"samples" binding:"required"`
+	Source     string           `json:"source,omitempty"`
+	Service    string           `json:"service,omitempty"`
+}
+
+func (r *CreatePipelineRequest) Validate() error {
+	if len(r.Name) < 1 || len(r.Name) > 100 {
+		return fmt.Errorf("pipeline name must be between 1 and 100 characters")
+	}
+	if err := r.Multiline.Validate(); err != nil {
+		return err
+	}
+	if len(r.Stages) == 0 && r.Multiline != nil {
+		return nil
+	}
+	return validateStages(r.Stages)
+}
+
+func (r *TestPipelineRequest) Validate() error {
+	if len(r.Samples) == 0 {
+		return fmt.Errorf("at least one sample is required")
+	}
+	if len(r.Samples) > 100 {
+		return fmt.Errorf("cannot test more than 100 samples at once")
+	}
+	if r.PipelineID != 0 {
+		return nil
+	}
+	if err := r.Multiline.Validate(); err != nil {
+		return err
+	}
+	if len(r.Stages) == 0 && r.Multiline != nil {
+		return nil
+	}
+	return validateStages(r.Stages)
+}
+
+// checks the multiline rules and fills in defaults; a nil config is valid
+func (m *MultilineConfig) Validate() error {
+	if m == nil {
+		return nil
+	}
+	if m.StartPattern == "" && m.ContinuationPattern == "" {
+		return fmt.Errorf("multiline requires a start_pattern or a continuation_pattern")
+	}
+	if m.MaxWaitMs < 0 || m.MaxWaitMs > MaxMultilineMaxWait {
+		return fmt.Errorf("multiline max_wait_ms must be between 0 and %d", MaxMultilineMaxWait)
+	}
+	if m.MaxLines < 0 || m.MaxLines > MaxMultilineMaxLines {
+		return fmt.Errorf("multiline max_lines must be between 0 and %d", MaxMultilineMaxLines)
+	}
+	if m.MaxWaitMs == 0 {
+		m.MaxWaitMs = DefaultMultilineMaxWait
+	}
+	if m.MaxLines == 0 {
+		m.MaxLines = DefaultMultilineMaxLines
+	}
+	return nil
+}
+
+// converts the request to a Pipeline owned by userID
+func (r *CreatePipelineRequest) ToPipeline(userID int) *Pipeline {
+	isActive := true
+	if r.IsActive != nil {
+		isActive = *r.IsActive
+	}
+	return &Pipeline{
+		UserID:    userID,
+		Name:      r.Name,
+		Source:    r.Source,
+		Service:   r.Service,
+		Priority:  r.Priority,
+		Stages:    r.Stages,
+		Multiline: r.Multiline,
+		IsActive:  isActive,
+	}
+}
+
+// reports whether the pipeline applies to a log entry
+func (p *Pipeline) Matches(entry *LogEntry) bool {
+	if p.Source != "" && p.Source != entry.Source {
+		return false
+	}
+	if p.Service != "" && p.Service != entry.Service {
+		return false
+	}
+	return true
+}
+
+func validateStages(stages []PipelineStage) error {
+	if len(stages) == 0 {
+		return fmt.Errorf("at least one stage is required")
+	}
+	if len(stages) > 20 {
+		return fmt.Errorf("a pipeline cannot have more than 20 stages")
+	}
+	for i, stage := range stages {
+		if !validStageTypes[stage.Type] {
+			return fmt.Errorf("stage %d: invalid type %q (must be grok, regex, json, logfmt, kv, timestamp, or level)", i, stage.Type)
+		}
+		if (stage.Type == "grok" || stage.Type == "regex") && stage.Pattern == "" {
+			return fmt.Errorf("stage %d: %s stage requires a pattern", i, stage.Type)
+		}
+	}
+	return nil
+}
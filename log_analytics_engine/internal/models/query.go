@@ -9,7 +9,7 @@ import (
 // QueryRequest represents a query for logs with SQL-like filters
 type QueryRequest struct {
 	// WHERE clause filters - Single value
-	Level   string `json:"level,omitempty"`   // Filter by log level (DEBUG, INFO, WARN, ERROR, FATAL)
+	Level   string `json:"level,omitempty"`   // Filter by log level (TRACE, DEBUG, INFO, WARN, ERROR, FATAL), or a comparison like ">= WARN"
 	Source  string `json:"source,omitempty"`  // Filter by source
 	Service string `json:"service,omitempty"` // Filter by service
 	Message string `json:"message,omitempty"` // Search in message (substring match - CONTAINS)
@@ -19,6 +19,10 @@ type QueryRequest struct {
 	Sources  []string `json:"sources,omitempty"`  // Filter by multiple sources
 	Services []string `json:"services,omitempty"` // Filter by multiple services
 
+	// Severity range filters, compared on severity_number
+	MinLevel string `json:"min_level,omitempty"` // Logs at or above this level
+	MaxLevel string `json:"max_level,omitempty"` // Logs at or below this level
+
 	// Exclusion filters (NOT)
 	ExcludeLevel   string   `json:"exclude_level,omitempty"`   // Exclude a log level
 	ExcludeLevels  []string `json:"exclude_levels,omitempty"`  // Exclude multiple log levels
@@ -29,10 +33,36 @@ type QueryRequest struct {
 	MessageContains    string `json:"message_contains,omitempty"`     // Message contains text (case-insensitive)
 	MessageNotContains string `json:"message_not_contains,omitempty"` // Message does not contain text
 
+	// Exact, prefix and regular expression matches, all case-sensitive unless regex_ignore_case is set
+	// (source and service already match exactly). Patterns are POSIX-style regular expressions
+	MessageEquals     string `json:"message_equals,omitempty"`
+	MessageStartsWith string `json:"message_starts_with,omitempty"`
+	MessageRegex      string `json:"message_regex,omitempty"`
+	MessageNotRegex   string `json:"message_not_regex,omitempty"`
+	SourceStartsWith  string `json:"source_starts_with,omitempty"`
+	SourceRegex       string `json:"source_regex,omitempty"`
+	SourceNotRegex    string `json:"source_not_regex,omitempty"`
+	ServiceStartsWith string `json:"service_starts_with,omitempty"`
+	ServiceRegex      string `json:"service_regex,omitempty"` // e.g. "^payments-"
+	ServiceNotRegex   string `json:"service_not_regex,omitempty"`
+	RegexIgnoreCase   bool   `json:"regex_ignore_case,omitempty"` // match the regex filters case-insensitively
+
+	// Nested and/or/not conditions over any field, including fields.<key> for custom fields; ANDed
+	// with the filters above, which are shorthand for the same conditions (see FilterNode)
+	Where *FilterNode `json:"where,omitempty"`
+
+	// Full-text search over messages: words, "quoted phrases", prefix*, AND/OR/NOT, -word and parentheses.
+	// Matches whole words, unlike the substring filters above, and ranks results by relevance
+	Search string `json:"search,omitempty"`
+
 	// Time range filters
 	StartTime *time.Time `json:"start_time,omitempty"` // Filter logs after this time
 	EndTime   *time.Time `json:"end_time,omitempty"`   // Filter logs before this time
 
+	// Arrival time filters, on when the ingester received the logs rather than their timestamp
+	ReceivedAfter  *time.Time `json:"received_after,omitempty"`
+	ReceivedBefore *time.Time `json:"received_before,omitempty"`
+
 	// Time range helpers (alternative to start_time/end_time)
 	LastMinutes int `json:"last_minutes,omitempty"` // Logs from last N minutes
 	LastHours   int `json:"last_hours,omitempty"`   // Logs from last N hours
@@ -43,8 +73,11 @@ type QueryRequest struct {
 	Offset int `json:"offset,omitempty"` // Skip N results
 
 	// Sorting
-	SortBy    string `json:"sort_by,omitempty"`    // Field to sort by (default: timestamp)
-	SortOrder string `json:"sort_order,omitempty"` // ASC or DESC (default: DESC)
+	SortBy    string `json:"sort_by,omitempty"`    // Field to sort by (default: relevance with a search where the log store ranks, otherwise timestamp)
+	SortOrder string `json:"sort_order,omitempty"` // ASC or DESC (default: DESC); relevance is always best first
+
+	search *SearchQuery
+	where  *Filter
 }
 
 // QueryResponse contains the query results
@@ -54,45 +87,56 @@ type QueryResponse struct {
 	Limit      int         `json:"limit"`
 	Offset     int         `json:"offset"`
 	ExecutedAt time.Time   `json:"executed_at"`
+
+	// With a search, how each returned log matched, in the same order as Logs
+	Matches []SearchMatch `json:"matches,omitempty"`
 }
 
 // Validate checks if the query parameters are valid
 func (q *QueryRequest) Validate() error {
-	validLevels := map[string]bool{
-		"DEBUG": true, "INFO": true, "WARN": true,
-		"ERROR": true, "FATAL": true,
-	}
+	var err error
 
-	// Validate log level if provided
+	// Validate log level if provided; a comparison operator turns it into a severity range
 	if q.Level != "" {
-		if !validLevels[strings.ToUpper(q.Level)] {
-			return fmt.Errorf("invalid log level: %s (must be DEBUG, INFO, WARN, ERROR, or FATAL)", q.Level)
+		if err := q.parseLevel(); err != nil {
+			return err
 		}
-		q.Level = strings.ToUpper(q.Level)
 	}
 
 	// Validate multiple levels
 	for i, level := range q.Levels {
-		if !validLevels[strings.ToUpper(level)] {
-			return fmt.Errorf("invalid log level in levels array: %s", level)
+		if q.Levels[i], err = normalizeLevelParam("log level in levels array", level); err != nil {
+			return err
+		}
+	}
+
+	// Validate severity range
+	if q.MinLevel != "" {
+		if q.MinLevel, err = normalizeLevelParam("min_level", q.MinLevel); err != nil {
+			return err
+		}
+	}
+	if q.MaxLevel != "" {
+		if q.MaxLevel, err = normalizeLevelParam("max_level", q.MaxLevel); err != nil {
+			return err
 		}
-		q.Levels[i] = strings.ToUpper(level)
+	}
+	if q.MinLevel != "" && q.MaxLevel != "" && SeverityNumber(q.MinLevel) > SeverityNumber(q.MaxLevel) {
+		return fmt.Errorf("min_level must not be above max_level")
 	}
 
 	// Validate exclude level
 	if q.ExcludeLevel != "" {
-		if !validLevels[strings.ToUpper(q.ExcludeLevel)] {
-			return fmt.Errorf("invalid exclude_level: %s", q.ExcludeLevel)
+		if q.ExcludeLevel, err = normalizeLevelParam("exclude_level", q.ExcludeLevel); err != nil {
+			return err
 		}
-		q.ExcludeLevel = strings.ToUpper(q.ExcludeLevel)
 	}
 
 	// Validate exclude levels
 	for i, level := range q.ExcludeLevels {
-		if !validLevels[strings.ToUpper(level)] {
-			return fmt.Errorf("invalid log level in exclude_levels: %s", level)
+		if q.ExcludeLevels[i], err = normalizeLevelParam("log level in exclude_levels", level); err != nil {
+			return err
 		}
-		q.ExcludeLevels[i] = strings.ToUpper(level)
 	}
 
 	// Process time range helpers
@@ -117,11 +161,44 @@ func (q *QueryRequest) Validate() error {
 		}
 	}
 
+	if q.ReceivedAfter != nil && q.ReceivedBefore != nil {
+		if q.ReceivedAfter.After(*q.ReceivedBefore) {
+			return fmt.Errorf("received_after must be before received_before")
+		}
+	}
+
 	// Handle backward compatibility: if Message is set but MessageContains is not, use Message
 	if q.Message != "" && q.MessageContains == "" {
 		q.MessageContains = q.Message
 	}
 
+	// Validate regular expressions
+	for _, pattern := range []struct{ param, value string }{
+		{"message_regex", q.MessageRegex},
+		{"message_not_regex", q.MessageNotRegex},
+		{"source_regex", q.SourceRegex},
+		{"source_not_regex", q.SourceNotRegex},
+		{"service_regex", q.ServiceRegex},
+		{"service_not_regex", q.ServiceNotRegex},
+	} {
+		if pattern.value == "" {
+			continue
+		}
+		if err := ValidatePattern(pattern.value); err != nil {
+			return fmt.Errorf("%s: %w", pattern.param, err)
+		}
+	}
+
+	if q.Where != nil {
+		if q.where, err = q.Where.ToFilter(); err != nil {
+			return err
+		}
+	}
+
+	if q.search, err = ParseSearch(q.Search); err != nil {
+		return err
+	}
+
 	// Set default limit
 	if q.Limit <= 0 {
 		q.Limit = 100
@@ -135,18 +212,25 @@ func (q *QueryRequest) Validate() error {
 		return fmt.Errorf("offset cannot be negative")
 	}
 
-	// Validate sort field
-	if q.SortBy == "" {
+	// Validate sort field; searches are left unset so the log store picks relevance when it can rank them
+	if q.SortBy == "" && q.search == nil {
 		q.SortBy = "timestamp"
 	}
 	validSortFields := map[string]bool{
-		"timestamp": true,
-		"level":     true,
-		"source":    true,
-		"service":   true,
-	}
-	if !validSortFields[strings.ToLower(q.SortBy)] {
-		return fmt.Errorf("invalid sort_by field: %s (must be timestamp, level, source, or service)", q.SortBy)
+		SortRelevance:     q.search != nil,
+		"timestamp":       true,
+		"level":           true,
+		"severity_number": true,
+		"received_at":     true,
+		"source":          true,
+		"service":         true,
+	}
+	q.SortBy = strings.ToLower(q.SortBy)
+	if q.SortBy != "" && !validSortFields[q.SortBy] {
+		if q.SortBy == SortRelevance {
+			return fmt.Errorf("sort_by relevance requires a search")
+		}
+		return fmt.Errorf("invalid sort_by field: %s (must be timestamp, received_at, level, severity_number, source, service, or relevance)", q.SortBy)
 	}
 
 	// Validate sort order
@@ -161,126 +245,223 @@ func (q *QueryRequest) Validate() error {
 	return nil
 }
 
-// ToSQL converts the query to SQL WHERE clauses
-func (q *QueryRequest) ToSQL(userID int) (string, []interface{}) {
-	var conditions []string
-	var args []interface{}
-	argIndex := 1
+// normalizes Level; a comparison like ">= WARN" or "<ERROR" becomes MinLevel/MaxLevel
+func (q *QueryRequest) parseLevel() error {
+	value := strings.TrimSpace(q.Level)
+	op := "="
+	for _, candidate := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(value, candidate) {
+			op = candidate
+			value = strings.TrimSpace(value[len(op):])
+			break
+		}
+	}
+
+	level, err := normalizeLevelParam("log level", value)
+	if err != nil {
+		return err
+	}
+	q.Level = ""
+
+	// Strict comparisons move to the neighbouring canonical level
+	index := 0
+	for i, l := range Levels {
+		if l == level {
+			index = i
+		}
+	}
+	switch op {
+	case "=":
+		q.Level = level
+	case ">=":
+		q.MinLevel = level
+	case "<=":
+		q.MaxLevel = level
+	case ">":
+		if index == len(Levels)-1 {
+			return fmt.Errorf("no level is above %s", level)
+		}
+		q.MinLevel = Levels[index+1]
+	case "<":
+		if index == 0 {
+			return fmt.Errorf("no level is below %s", level)
+		}
+		q.MaxLevel = Levels[index-1]
+	}
+	return nil
+}
+
+// SortColumn returns the column to order by; levels sort by severity rather than alphabetically
+func (q *QueryRequest) SortColumn() string {
+	if q.SortBy == "level" {
+		return "severity_number"
+	}
+	return q.SortBy
+}
+
+// ToLogQuery converts the request to a structured query over the user's logs
+func (q *QueryRequest) ToLogQuery(userID int) *LogQuery {
+	return &LogQuery{
+		UserID:    userID,
+		Filter:    q.Filter(),
+		Search:    q.search,
+		SortBy:    q.SortColumn(),
+		SortOrder: q.SortOrder,
+		Limit:     q.Limit,
+		Offset:    q.Offset,
+	}
+}
 
-	// Always filter by user_id
-	conditions = append(conditions, fmt.Sprintf("user_id = $%d", argIndex))
-	args = append(args, userID)
-	argIndex++
+// Filter combines the request's flat conditions and Where tree with AND, returning nil when there are none
+func (q *QueryRequest) Filter() *Filter {
+	var conditions []*Filter
+	add := func(field, op string, values ...interface{}) {
+		conditions = append(conditions, Compare(field, op, values...))
+	}
 
 	// Single value filters
 	if q.Level != "" {
-		conditions = append(conditions, fmt.Sprintf("level = $%d", argIndex))
-		args = append(args, q.Level)
-		argIndex++
+		add(FieldLevel, FilterEq, q.Level)
 	}
-
 	if q.Source != "" {
-		conditions = append(conditions, fmt.Sprintf("source = $%d", argIndex))
-		args = append(args, q.Source)
-		argIndex++
+		add(FieldSource, FilterEq, q.Source)
 	}
-
 	if q.Service != "" {
-		conditions = append(conditions, fmt.Sprintf("service = $%d", argIndex))
-		args = append(args, q.Service)
-		argIndex++
+		add(FieldService, FilterEq, q.Service)
 	}
 
 	// Multi-value filters (IN operator)
 	if len(q.Levels) > 0 {
-		placeholders := make([]string, len(q.Levels))
-		for i, level := range q.Levels {
-			placeholders[i] = fmt.Sprintf("$%d", argIndex)
-			args = append(args, level)
-			argIndex++
-		}
-		conditions = append(conditions, fmt.Sprintf("level IN (%s)", strings.Join(placeholders, ", ")))
+		add(FieldLevel, FilterIn, stringValues(q.Levels)...)
 	}
-
 	if len(q.Sources) > 0 {
-		placeholders := make([]string, len(q.Sources))
-		for i, source := range q.Sources {
-			placeholders[i] = fmt.Sprintf("$%d", argIndex)
-			args = append(args, source)
-			argIndex++
-		}
-		conditions = append(conditions, fmt.Sprintf("source IN (%s)", strings.Join(placeholders, ", ")))
+		add(FieldSource, FilterIn, stringValues(q.Sources)...)
 	}
-
 	if len(q.Services) > 0 {
-		placeholders := make([]string, len(q.Services))
-		for i, service := range q.Services {
-			placeholders[i] = fmt.Sprintf("$%d", argIndex)
-			args = append(args, service)
-			argIndex++
-		}
-		conditions = append(conditions, fmt.Sprintf("service IN (%s)", strings.Join(placeholders, ", ")))
+		add(FieldService, FilterIn, stringValues(q.Services)...)
+	}
+
+	// Severity range filters
+	if q.MinLevel != "" {
+		add(FieldSeverityNumber, FilterGte, SeverityNumber(q.MinLevel))
+	}
+	if q.MaxLevel != "" {
+		add(FieldSeverityNumber, FilterLte, SeverityNumber(q.MaxLevel))
 	}
 
 	// Exclusion filters (NOT)
 	if q.ExcludeLevel != "" {
-		conditions = append(conditions, fmt.Sprintf("level != $%d", argIndex))
-		args = append(args, q.ExcludeLevel)
-		argIndex++
+		add(FieldLevel, FilterNe, q.ExcludeLevel)
 	}
-
 	if len(q.ExcludeLevels) > 0 {
-		placeholders := make([]string, len(q.ExcludeLevels))
-		for i, level := range q.ExcludeLevels {
-			placeholders[i] = fmt.Sprintf("$%d", argIndex)
-			args = append(args, level)
-			argIndex++
-		}
-		conditions = append(conditions, fmt.Sprintf("level NOT IN (%s)", strings.Join(placeholders, ", ")))
+		add(FieldLevel, FilterNotIn, stringValues(q.ExcludeLevels)...)
 	}
-
 	if q.ExcludeSource != "" {
-		conditions = append(conditions, fmt.Sprintf("source != $%d", argIndex))
-		args = append(args, q.ExcludeSource)
-		argIndex++
+		add(FieldSource, FilterNe, q.ExcludeSource)
 	}
-
 	if len(q.ExcludeSources) > 0 {
-		placeholders := make([]string, len(q.ExcludeSources))
-		for i, source := range q.ExcludeSources {
-			placeholders[i] = fmt.Sprintf("$%d", argIndex)
-			args = append(args, source)
-			argIndex++
-		}
-		conditions = append(conditions, fmt.Sprintf("source NOT IN (%s)", strings.Join(placeholders, ", ")))
+		add(FieldSource, FilterNotIn, stringValues(q.ExcludeSources)...)
 	}
 
 	// Text search operators
 	if q.MessageContains != "" {
-		conditions = append(conditions, fmt.Sprintf("message ILIKE $%d", argIndex))
-		args = append(args, "%"+q.MessageContains+"%")
-		argIndex++
+		add(FieldMessage, FilterContains, q.MessageContains)
 	}
-
 	if q.MessageNotContains != "" {
-		conditions = append(conditions, fmt.Sprintf("message NOT ILIKE $%d", argIndex))
-		args = append(args, "%"+q.MessageNotContains+"%")
-		argIndex++
+		add(FieldMessage, FilterNotContains, q.MessageNotContains)
+	}
+
+	// Exact, prefix and regular expression matches
+	regex, notRegex := FilterRegex, FilterNotRegex
+	if q.RegexIgnoreCase {
+		regex, notRegex = FilterIRegex, FilterNotIRegex
+	}
+	for _, match := range []struct{ field, op, value string }{
+		{FieldMessage, FilterEq, q.MessageEquals},
+		{FieldMessage, FilterStartsWith, q.MessageStartsWith},
+		{FieldMessage, regex, q.MessageRegex},
+		{FieldMessage, notRegex, q.MessageNotRegex},
+		{FieldSource, FilterStartsWith, q.SourceStartsWith},
+		{FieldSource, regex, q.SourceRegex},
+		{FieldSource, notRegex, q.SourceNotRegex},
+		{FieldService, FilterStartsWith, q.ServiceStartsWith},
+		{FieldService, regex, q.ServiceRegex},
+		{FieldService, notRegex, q.ServiceNotRegex},
+	} {
+		if match.value != "" {
+			add(match.field, match.op, match.value)
+		}
 	}
 
 	// Time range filters
 	if q.StartTime != nil {
-		conditions = append(conditions, fmt.Sprintf("timestamp >= $%d", argIndex))
-		args = append(args, q.StartTime)
-		argIndex++
+		add(FieldTimestamp, FilterGte, *q.StartTime)
 	}
-
 	if q.EndTime != nil {
-		conditions = append(conditions, fmt.Sprintf("timestamp <= $%d", argIndex))
-		args = append(args, q.EndTime)
-		argIndex++
+		add(FieldTimestamp, FilterLte, *q.EndTime)
+	}
+	if q.ReceivedAfter != nil {
+		add(FieldReceivedAt, FilterGte, *q.ReceivedAfter)
+	}
+	if q.ReceivedBefore != nil {
+		add(FieldReceivedAt, FilterLte, *q.ReceivedBefore)
 	}
 
-	whereClause := strings.Join(conditions, " AND ")
-	return whereClause, args
+	// Nested conditions, compiled by Validate
+	if q.where != nil {
+		conditions = append(conditions, q.where)
+	}
+
+	return And(conditions...)
+}
+
+func stringValues(list []string) []interface{} {
+	values := make([]interface{}, len(list))
+	for i, value := range list {
+		values[i] = value
+	}
+	return values
+}
+
+// AggregateRequest counts the logs matching the query's filters per group and/or time bucket
+type AggregateRequest struct {
+	QueryRequest
+	GroupBy  []string `json:"group_by,omitempty"` // level, source and/or service
+	Interval string   `json:"interval,omitempty"` // bucket width such as "5m" or "1h"
+
+	interval time.Duration
+}
+
+// Validate checks the filters, group columns and interval
+func (r *AggregateRequest) Validate() error {
+	if err := r.QueryRequest.Validate(); err != nil {
+		return err
+	}
+	for i, column := range r.GroupBy {
+		r.GroupBy[i] = strings.ToLower(column)
+	}
+	if err := ValidateGroupBy(r.GroupBy); err != nil {
+		return err
+	}
+
+	if r.Interval != "" {
+		interval, err := time.ParseDuration(r.Interval)
+		if err != nil || interval < time.Second {
+			return fmt.Errorf("interval must be a duration of at least 1s, such as 5m or 1h")
+		}
+		r.interval = interval
+	}
+	if len(r.GroupBy) == 0 && r.interval == 0 {
+		return fmt.Errorf("group_by or interval is required")
+	}
+	return nil
+}
+
+// ToAggregateQuery converts the request to a structured aggregation over the user's logs
+func (r *AggregateRequest) ToAggregateQuery(userID int) *AggregateQuery {
+	return &AggregateQuery{
+		LogQuery: *r.ToLogQuery(userID),
+		GroupBy:  r.GroupBy,
+		Interval: r.interval,
+	}
 }
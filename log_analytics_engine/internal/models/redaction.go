@@ -0,0 +1,96 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// built-in detectors a redaction rule can use; "custom" uses the rule's own pattern
+var validDetectors = map[string]bool{
+	"email":        true,
+	"credit_card":  true,
+	"jwt":          true,
+	"bearer_token": true,
+	"aws_key":      true,
+	"lak_key":      true,
+	"ipv4":         true,
+	"ipv6":         true,
+	"custom":       true,
+}
+
+var validRedactionActions = map[string]bool{
+	"mask": true, // replace the match with [REDACTED:<name>]
+	"hash": true, // replace the match with a hash so equal values can still be correlated
+	"drop": true, // remove the match from the message, or the whole field
+}
+
+// rule for removing PII and secrets from logs before they are stored
+type RedactionRule struct {
+	ID        int       `json:"id" db:"id"`
+	UserID    int       `json:"user_id" db:"user_id"`
+	Name      string    `json:"name" db:"name"`
+	Detector  string    `json:"detector" db:"detector"`
+	Pattern   string    `json:"pattern,omitempty" db:"pattern"` // only for the custom detector
+	Action    string    `json:"action" db:"action"`
+	IsActive  bool      `json:"is_active" db:"is_active"`
+	Hits      int64     `json:"hits"` // matches redacted so far, read from Redis
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type CreateRedactionRuleRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Detector string `json:"detector" binding:"required"`
+	Pattern  string `json:"pattern,omitempty"`
+	Action   string `json:"action,omitempty"` // defaults to mask
+	IsActive *bool  `json:"is_active,omitempty"`
+}
+
+func (r *CreateRedactionRuleRequest) Validate() error {
+	if len(r.Name) < 1 || len(r.Name) > 100 {
+		return fmt.Errorf("rule name must be between 1 and 100 characters")
+	}
+	if !validDetectors[r.Detector] {
+		return fmt.Errorf("invalid detector %q (must be email, credit_card, jwt, bearer_token, aws_key, lak_key, ipv4, ipv6, or custom)", r.Detector)
+	}
+	if r.Action == "" {
+		r.Action = "mask"
+	}
+	if !validRedactionActions[r.Action] {
+		return fmt.Errorf("invalid action %q (must be mask, hash, or drop)", r.Action)
+	}
+
+	if r.Detector != "custom" {
+		if r.Pattern != "" {
+			return fmt.Errorf("pattern is only allowed with the custom detector")
+		}
+		return nil
+	}
+	if r.Pattern == "" {
+		return fmt.Errorf("custom detector requires a pattern")
+	}
+	if len(r.Pattern) > 1000 {
+		return fmt.Errorf("pattern cannot exceed 1000 characters")
+	}
+	if _, err := regexp.Compile(r.Pattern); err != nil {
+		return fmt.Errorf("invalid pattern: %w", err)
+	}
+	return nil
+}
+
+// converts the request to a rule owned by userID
+func (r *CreateRedactionRuleRequest) ToRule(userID int) *RedactionRule {
+	isActive := true
+	if r.IsActive != nil {
+		isActive = *r.IsActive
+	}
+	return &RedactionRule{
+		UserID:   userID,
+		Name:     r.Name,
+		Detector: r.Detector,
+		Pattern:  r.Pattern,
+		Action:   r.Action,
+		IsActive: isActive,
+	}
+}
@@ -0,0 +1,271 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+/*
+This file parses full-text searches over log messages, such as
+    timeout "connection refused" -retry conn*
+    (payment OR billing) AND NOT test
+Words are ANDed by default; OR, AND and NOT are operators only in upper case, "-" negates a word,
+a trailing "*" matches words starting with it and double quotes match the words as a phrase.
+The parsed tree is compiled by each log store, so no query syntax reaches the database unchecked
+*/
+
+// search node kinds
+const (
+	SearchTerm   = "term"
+	SearchPhrase = "phrase"
+	SearchAnd    = "and"
+	SearchOr     = "or"
+	SearchNot    = "not"
+)
+
+// limits on a search, keeping the compiled queries cheap
+const (
+	maxSearchLength = 1000
+	maxSearchWords  = 32
+	maxSearchDepth  = 10
+)
+
+// SearchQuery is a node of a parsed full-text search
+type SearchQuery struct {
+	Op       string
+	Words    []string // the term's single word, or the phrase's words in order
+	Prefix   bool     // the term also matches longer words starting with it
+	Children []*SearchQuery
+}
+
+// SearchMatch describes how a log matched QueryRequest.Search
+type SearchMatch struct {
+	LogID   int64   `json:"log_id"`
+	Rank    float64 `json:"rank"`    // relevance, higher is better; 0 when the log store can't rank
+	Snippet string  `json:"snippet"` // HTML-escaped excerpt of the message with matches wrapped in <mark></mark>
+}
+
+// Terms returns the words a matching message contains, for highlighting; negated words are left out
+func (q *SearchQuery) Terms() []*SearchQuery {
+	switch q.Op {
+	case SearchTerm, SearchPhrase:
+		return []*SearchQuery{q}
+	case SearchNot:
+		return nil
+	}
+	var terms []*SearchQuery
+	for _, child := range q.Children {
+		terms = append(terms, child.Terms()...)
+	}
+	return terms
+}
+
+// ParseSearch parses a full-text search, returning nil for an empty one
+func ParseSearch(input string) (*SearchQuery, error) {
+	if len(input) > maxSearchLength {
+		return nil, fmt.Errorf("search must be at most %d characters", maxSearchLength)
+	}
+	tokens, err := tokenizeSearch(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	p := &searchParser{tokens: tokens}
+	query, err := p.or(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in search", p.tokens[p.pos].text)
+	}
+	if p.words > maxSearchWords {
+		return nil, fmt.Errorf("search must have at most %d words", maxSearchWords)
+	}
+	return query, nil
+}
+
+type searchToken struct {
+	text   string
+	phrase bool // text was double-quoted
+}
+
+func tokenizeSearch(input string) ([]searchToken, error) {
+	var tokens []searchToken
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, searchToken{text: string(r)})
+			i++
+		case r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated phrase in search")
+			}
+			tokens = append(tokens, searchToken{text: string(runes[i+1 : end]), phrase: true})
+			i = end + 1
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune(`()"`, runes[end]) {
+				end++
+			}
+			tokens = append(tokens, searchToken{text: string(runes[i:end])})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+type searchParser struct {
+	tokens []searchToken
+	pos    int
+	words  int
+}
+
+func (p *searchParser) peek() (searchToken, bool) {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos], true
+	}
+	return searchToken{}, false
+}
+
+func (p *searchParser) isOperator(name string) bool {
+	token, ok := p.peek()
+	return ok && !token.phrase && token.text == name
+}
+
+func (p *searchParser) or(depth int) (*SearchQuery, error) {
+	if depth > maxSearchDepth {
+		return nil, fmt.Errorf("search is nested more than %d levels deep", maxSearchDepth)
+	}
+	children := []*SearchQuery{}
+	for {
+		child, err := p.and(depth)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+		if !p.isOperator("OR") {
+			break
+		}
+		p.pos++
+	}
+	return searchNode(SearchOr, children), nil
+}
+
+func (p *searchParser) and(depth int) (*SearchQuery, error) {
+	children := []*SearchQuery{}
+	for {
+		child, err := p.unary(depth)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+
+		if p.isOperator("AND") {
+			p.pos++
+			continue
+		}
+		// Adjacent words are ANDed, up to an OR or the end of a group
+		token, ok := p.peek()
+		if !ok || (!token.phrase && (token.text == "OR" || token.text == ")")) {
+			break
+		}
+	}
+	return searchNode(SearchAnd, children), nil
+}
+
+func (p *searchParser) unary(depth int) (*SearchQuery, error) {
+	token, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("search ends with an operator")
+	}
+
+	switch {
+	case token.phrase:
+		p.pos++
+		return p.phrase(token.text)
+	case token.text == "NOT":
+		p.pos++
+		child, err := p.unary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &SearchQuery{Op: SearchNot, Children: []*SearchQuery{child}}, nil
+	case token.text == "(":
+		p.pos++
+		group, err := p.or(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if !p.isOperator(")") {
+			return nil, fmt.Errorf("missing ) in search")
+		}
+		p.pos++
+		return group, nil
+	case token.text == ")" || token.text == "AND" || token.text == "OR":
+		return nil, fmt.Errorf("unexpected %q in search", token.text)
+	}
+
+	p.pos++
+	word := token.text
+	negate := strings.HasPrefix(word, "-") && len(word) > 1
+	if negate {
+		word = word[1:]
+	}
+	prefix := strings.HasSuffix(word, "*")
+	if prefix {
+		word = strings.TrimRight(word, "*")
+	}
+	if err := p.checkWord(word); err != nil {
+		return nil, err
+	}
+
+	term := &SearchQuery{Op: SearchTerm, Words: []string{word}, Prefix: prefix}
+	if negate {
+		return &SearchQuery{Op: SearchNot, Children: []*SearchQuery{term}}, nil
+	}
+	return term, nil
+}
+
+func (p *searchParser) phrase(text string) (*SearchQuery, error) {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return nil, fmt.Errorf("empty phrase in search")
+	}
+	for _, word := range words {
+		if err := p.checkWord(word); err != nil {
+			return nil, err
+		}
+	}
+	if len(words) == 1 {
+		return &SearchQuery{Op: SearchTerm, Words: words}, nil
+	}
+	return &SearchQuery{Op: SearchPhrase, Words: words}, nil
+}
+
+// counts a word, rejecting ones the databases would drop as punctuation
+func (p *searchParser) checkWord(word string) error {
+	p.words++
+	if strings.IndexFunc(word, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+		return fmt.Errorf("search word %q has no letters or digits", word)
+	}
+	return nil
+}
+
+func searchNode(op string, children []*SearchQuery) *SearchQuery {
+	if len(children) == 1 {
+		return children[0]
+	}
+	return &SearchQuery{Op: op, Children: children}
+}
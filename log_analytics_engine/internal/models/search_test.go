@@ -0,0 +1,166 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+// renders a parsed search compactly: terms as words (prefixes with a trailing *), phrases quoted and
+// operators as (op children...)
+func renderSearch(q *SearchQuery) string {
+	switch q.Op {
+	case SearchTerm:
+		if q.Prefix {
+			return q.Words[0] + "*"
+		}
+		return q.Words[0]
+	case SearchPhrase:
+		return `"` + strings.Join(q.Words, " ") + `"`
+	}
+	children := make([]string, len(q.Children))
+	for i, child := range q.Children {
+		children[i] = renderSearch(child)
+	}
+	return "(" + q.Op + " " + strings.Join(children, " ") + ")"
+}
+
+func TestParseSearch(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"timeout", "timeout"},
+		{"  timeout   refused ", "(and timeout refused)"},
+		{"timeout AND refused", "(and timeout refused)"},
+		{"timeout OR refused", "(or timeout refused)"},
+		{"a b OR c", "(or (and a b) c)"},
+		{"a OR b c", "(or a (and b c))"},
+		{"(a OR b) c", "(and (or a b) c)"},
+		{"NOT test", "(not test)"},
+		{"payment -test", "(and payment (not test))"},
+		{"conn*", "conn*"},
+		{"conn** -retr*", "(and conn* (not retr*))"},
+		{`"connection refused"`, `"connection refused"`},
+		{`"  connection   refused  "`, `"connection refused"`},
+		{`"single"`, "single"},
+		{`timeout "connection refused" -retry conn*`, `(and timeout "connection refused" (not retry) conn*)`},
+		{"(payment OR billing) AND NOT test", "(and (or payment billing) (not test))"},
+		{"NOT NOT a", "(not (not a))"},
+		// operators are only upper case, and quoting turns them into words
+		{"a or b", "(and a or b)"},
+		{`"OR" "AND"`, "(and OR AND)"},
+		{"café über", "(and café über)"},
+		{"user_id=42", "user_id=42"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			q, err := ParseSearch(tt.input)
+			if err != nil {
+				t.Fatalf("ParseSearch(%q) failed: %v", tt.input, err)
+			}
+			if got := renderSearch(q); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSearchEmpty(t *testing.T) {
+	for _, input := range []string{"", "   ", "\t\n"} {
+		q, err := ParseSearch(input)
+		if q != nil || err != nil {
+			t.Errorf("ParseSearch(%q) = %v, %v, want nil, nil", input, q, err)
+		}
+	}
+}
+
+func TestParseSearchErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`"connection refused`, "unterminated phrase"},
+		{`""`, "empty phrase"},
+		{"(a OR b", "missing )"},
+		{"a OR b)", `unexpected ")"`},
+		{"()", `unexpected ")"`},
+		{"OR a", `unexpected "OR"`},
+		{"a AND", "ends with an operator"},
+		{"a OR", "ends with an operator"},
+		{"NOT", "ends with an operator"},
+		{"a AND OR b", `unexpected "OR"`},
+		{"***", "has no letters or digits"},
+		{"-", "has no letters or digits"},
+		{`"-- --"`, "has no letters or digits"},
+		{strings.Repeat("(", maxSearchDepth+1) + "a" + strings.Repeat(")", maxSearchDepth+1), "nested more than"},
+		{strings.Repeat("a ", maxSearchWords+1), "at most 32 words"},
+		{strings.Repeat("a", maxSearchLength+1), "at most 1000 characters"},
+	}
+	for _, tt := range tests {
+		name := tt.input
+		if len(name) > 40 {
+			name = name[:40]
+		}
+		t.Run(name, func(t *testing.T) {
+			q, err := ParseSearch(tt.input)
+			if err == nil {
+				t.Fatalf("ParseSearch(%q) = %s, want an error containing %q", tt.input, renderSearch(q), tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSearchAllowsLimits(t *testing.T) {
+	nested := strings.Repeat("(", maxSearchDepth) + "a" + strings.Repeat(")", maxSearchDepth)
+	words := strings.TrimSpace(strings.Repeat("a ", maxSearchWords))
+	for _, input := range []string{nested, words} {
+		if _, err := ParseSearch(input); err != nil {
+			t.Errorf("ParseSearch(%q) failed: %v", input, err)
+		}
+	}
+}
+
+func TestSearchTerms(t *testing.T) {
+	q, err := ParseSearch(`(payment OR "card declined") -test NOT (retry OR job*) conn*`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, term := range q.Terms() {
+		got = append(got, renderSearch(term))
+	}
+	if want := []string{"payment", `"card declined"`, "conn*"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got terms %q, want %q", got, want)
+	}
+}
+
+func TestQueryRequestSearchSort(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     QueryRequest
+		want    string
+		wantErr bool
+	}{
+		{"search leaves the sort to the log store", QueryRequest{Search: "error"}, "", false},
+		{"search sorted by relevance", QueryRequest{Search: "error", SortBy: "Relevance"}, SortRelevance, false},
+		{"search sorted by a column", QueryRequest{Search: "error", SortBy: "level"}, "severity_number", false},
+		{"no search sorts by timestamp", QueryRequest{}, "timestamp", false},
+		{"relevance needs a search", QueryRequest{SortBy: SortRelevance}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if got := tt.req.ToLogQuery(1).SortBy; got != tt.want {
+					t.Errorf("SortBy = %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+This file is the single source of truth for log levels:
+canonical levels and their ordinal severity numbers
+normalizes aliases (warning, err, critical, ...) and OTel severity numbers and texts
+syslog severities 0-7 overlap OTel severity numbers 1-7 with the opposite order (0 is the most
+severe in syslog, 1 the least in OTel), so a bare number below 8 is rejected as a level; clients
+send it as severity_number (always OTel) or syslog_severity instead, and the GELF adapter maps
+its syslog levels itself
+*/
+
+// severity numbers follow the OpenTelemetry ranges, using the first number of each range
+const (
+	SeverityTrace = 1
+	SeverityDebug = 5
+	SeverityInfo  = 9
+	SeverityWarn  = 13
+	SeverityError = 17
+	SeverityFatal = 21
+)
+
+// canonical levels from least to most severe
+var Levels = []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+var severityNumbers = map[string]int{
+	"TRACE": SeverityTrace,
+	"DEBUG": SeverityDebug,
+	"INFO":  SeverityInfo,
+	"WARN":  SeverityWarn,
+	"ERROR": SeverityError,
+	"FATAL": SeverityFatal,
+}
+
+// spellings used by common shippers and logging libraries
+var levelAliases = map[string]string{
+	"TRACE":         "TRACE",
+	"FINEST":        "TRACE",
+	"VERBOSE":       "TRACE",
+	"DEBUG":         "DEBUG",
+	"DBG":           "DEBUG",
+	"FINE":          "DEBUG",
+	"FINER":         "DEBUG",
+	"INFO":          "INFO",
+	"INF":           "INFO",
+	"INFORMATION":   "INFO",
+	"INFORMATIONAL": "INFO",
+	"NOTICE":        "INFO",
+	"WARN":          "WARN",
+	"WRN":           "WARN",
+	"WARNING":       "WARN",
+	"ERROR":         "ERROR",
+	"ERR":           "ERROR",
+	"FATAL":         "FATAL",
+	"FTL":           "FATAL",
+	"CRITICAL":      "FATAL",
+	"CRIT":          "FATAL",
+	"PANIC":         "FATAL",
+	"SEVERE":        "FATAL",
+	"ALERT":         "FATAL",
+	"EMERG":         "FATAL",
+	"EMERGENCY":     "FATAL",
+}
+
+// NormalizeLevel maps a level alias or number onto a canonical level.
+// Numbers 8-24 are OTel severity numbers; 0-7 could be syslog or OTel, so they
+// aren't accepted. OTel severity texts such as WARN2 or ERROR4 are also accepted
+func NormalizeLevel(value string) (string, bool) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if level, ok := levelAliases[value]; ok {
+		return level, true
+	}
+
+	if n, err := strconv.Atoi(value); err == nil {
+		if n >= 8 && n <= 24 {
+			return OTelLevel(n), true
+		}
+		return "", false
+	}
+
+	// OTel severity texts carry a 2-4 suffix within a range, e.g. INFO3
+	if len(value) > 1 {
+		suffix := value[len(value)-1]
+		if suffix >= '2' && suffix <= '4' {
+			if _, ok := severityNumbers[value[:len(value)-1]]; ok {
+				return value[:len(value)-1], true
+			}
+		}
+	}
+	return "", false
+}
+
+// SeverityNumber returns the ordinal of a canonical level, or 0 if it isn't one
+func SeverityNumber(level string) int {
+	return severityNumbers[level]
+}
+
+// reports whether n is a syslog severity
+func IsSyslogSeverity(n int) bool {
+	return n >= 0 && n <= 7
+}
+
+// reports whether n is an OTel severity number
+func IsOTelSeverity(n int) bool {
+	return n >= 1 && n <= 24
+}
+
+// maps a syslog severity (0-7) onto a canonical level
+func SyslogLevel(severity int) string {
+	switch {
+	case severity <= 2: // emergency, alert, critical
+		return "FATAL"
+	case severity == 3:
+		return "ERROR"
+	case severity == 4:
+		return "WARN"
+	case severity <= 6: // notice, informational
+		return "INFO"
+	default:
+		return "DEBUG"
+	}
+}
+
+// maps an OTel severity number (1-24) onto a canonical level
+func OTelLevel(number int) string {
+	switch {
+	case number >= SeverityFatal:
+		return "FATAL"
+	case number >= SeverityError:
+		return "ERROR"
+	case number >= SeverityWarn:
+		return "WARN"
+	case number >= SeverityInfo:
+		return "INFO"
+	case number >= SeverityDebug:
+		return "DEBUG"
+	default:
+		return "TRACE"
+	}
+}
+
+// normalizes a level taken from a request, naming the offending field on error
+func normalizeLevelParam(name, value string) (string, error) {
+	level, ok := NormalizeLevel(value)
+	if !ok {
+		return "", fmt.Errorf("invalid %s: %s (must be one of %s, an alias, or an OTel severity number from 8 to 24)", name, value, strings.Join(Levels, ", "))
+	}
+	return level, nil
+}
@@ -0,0 +1,107 @@
+package models
+
+import "testing"
+
+func TestNormalizeLevel(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string // empty when the value is rejected
+	}{
+		{"INFO", "INFO"},
+		{"  warn ", "WARN"},
+		{"Warning", "WARN"},
+		{"err", "ERROR"},
+		{"critical", "FATAL"},
+		{"emerg", "FATAL"},
+		{"notice", "INFO"},
+		{"finest", "TRACE"},
+		{"dbg", "DEBUG"},
+
+		// bare numbers below 8 could be syslog or OTel, so they're refused
+		{"0", ""},
+		{"3", ""},
+		{"7", ""},
+		{"-1", ""},
+		// OTel severity numbers, at each range boundary
+		{"8", "DEBUG"},
+		{"9", "INFO"},
+		{"12", "INFO"},
+		{"13", "WARN"},
+		{"16", "WARN"},
+		{"17", "ERROR"},
+		{"20", "ERROR"},
+		{"21", "FATAL"},
+		{"24", "FATAL"},
+		{"25", ""},
+
+		// OTel severity texts with a 2-4 suffix
+		{"WARN2", "WARN"},
+		{"error4", "ERROR"},
+		{"TRACE3", "TRACE"},
+		{"WARN1", ""},
+		{"WARN5", ""},
+		{"WARNING2", ""},
+		{"2", ""},
+
+		{"", ""},
+		{"LOUD", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := NormalizeLevel(tt.value)
+			if ok != (tt.want != "") || got != tt.want {
+				t.Errorf("NormalizeLevel(%q) = %q, %v, want %q", tt.value, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyslogLevel(t *testing.T) {
+	want := []string{"FATAL", "FATAL", "FATAL", "ERROR", "WARN", "INFO", "INFO", "DEBUG"}
+	for severity, level := range want {
+		if got := SyslogLevel(severity); got != level {
+			t.Errorf("SyslogLevel(%d) = %s, want %s", severity, got, level)
+		}
+		if !IsSyslogSeverity(severity) {
+			t.Errorf("IsSyslogSeverity(%d) = false", severity)
+		}
+	}
+	for _, n := range []int{-1, 8} {
+		if IsSyslogSeverity(n) {
+			t.Errorf("IsSyslogSeverity(%d) = true", n)
+		}
+	}
+}
+
+func TestOTelLevel(t *testing.T) {
+	tests := []struct {
+		number int
+		want   string
+	}{
+		{1, "TRACE"}, {4, "TRACE"},
+		{5, "DEBUG"}, {8, "DEBUG"},
+		{9, "INFO"}, {12, "INFO"},
+		{13, "WARN"}, {16, "WARN"},
+		{17, "ERROR"}, {20, "ERROR"},
+		{21, "FATAL"}, {24, "FATAL"},
+	}
+	for _, tt := range tests {
+		if got := OTelLevel(tt.number); got != tt.want {
+			t.Errorf("OTelLevel(%d) = %s, want %s", tt.number, got, tt.want)
+		}
+		if !IsOTelSeverity(tt.number) {
+			t.Errorf("IsOTelSeverity(%d) = false", tt.number)
+		}
+	}
+	for _, n := range []int{0, 25} {
+		if IsOTelSeverity(n) {
+			t.Errorf("IsOTelSeverity(%d) = true", n)
+		}
+	}
+	// Each canonical level's severity number maps back onto it
+	for _, level := range Levels {
+		if got := OTelLevel(SeverityNumber(level)); got != level {
+			t.Errorf("OTelLevel(SeverityNumber(%s)) = %s", level, got)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// metric types stored in system_metrics
+const (
+	MetricCounter   = "counter"   // cumulative total since the service started
+	MetricGauge     = "gauge"     // current value
+	MetricRate      = "rate"      // per-second increase of a counter over the last interval
+	MetricHistogram = "histogram" // _count, _sum and _avg series of a latency histogram
+)
+
+// a sample of one of the services' own operational metrics
+type SystemMetric struct {
+	Name      string            `json:"metric_name" db:"metric_name"`
+	Value     float64           `json:"metric_value" db:"metric_value"`
+	Type      string            `json:"metric_type" db:"metric_type"`
+	Labels    map[string]string `json:"labels" db:"labels"`
+	Timestamp time.Time         `json:"timestamp" db:"timestamp"`
+}
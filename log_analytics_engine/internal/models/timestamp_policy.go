@@ -0,0 +1,73 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// what the ingester does with a client timestamp outside the skew window
+const (
+	TimestampAccept = "accept" // store it as sent, the default
+	TimestampClamp  = "clamp"  // replace it with the receive time, keeping the original in OriginalTimestampField
+	TimestampReject = "reject" // reject the log
+)
+
+// OriginalTimestampField is the custom field holding the timestamp the client sent, in RFC 3339,
+// on every log whose timestamp the policy replaced. Logs the policy left alone don't have it
+const OriginalTimestampField = "original_timestamp"
+
+// skew window applied to client timestamps, relative to when the log was received
+type TimestampPolicy struct {
+	Mode      string
+	MaxPast   time.Duration // 0 means no limit
+	MaxFuture time.Duration // 0 means no limit
+}
+
+func (p TimestampPolicy) Validate() error {
+	switch p.Mode {
+	case TimestampAccept, TimestampClamp, TimestampReject:
+	default:
+		return fmt.Errorf("invalid timestamp policy %q (must be accept, clamp, or reject)", p.Mode)
+	}
+	if p.MaxPast < 0 || p.MaxFuture < 0 {
+		return fmt.Errorf("timestamp skew limits cannot be negative")
+	}
+	return nil
+}
+
+// Apply checks the entry's timestamp against the window around its ReceivedAt,
+// replacing it or returning an error depending on the mode
+func (p TimestampPolicy) Apply(entry *LogEntry) error {
+	if p.Mode == TimestampAccept {
+		return nil
+	}
+
+	tooOld := p.MaxPast > 0 && entry.Timestamp.Before(entry.ReceivedAt.Add(-p.MaxPast))
+	tooNew := p.MaxFuture > 0 && entry.Timestamp.After(entry.ReceivedAt.Add(p.MaxFuture))
+	if !tooOld && !tooNew {
+		return nil
+	}
+
+	if p.Mode == TimestampReject {
+		return fmt.Errorf("timestamp %s is outside the accepted window (%s in the past to %s in the future)",
+			entry.Timestamp.Format(time.RFC3339), p.MaxPast, p.MaxFuture)
+	}
+
+	setTimestamp(entry, entry.ReceivedAt)
+	return nil
+}
+
+// replaces the entry's timestamp, keeping the client's in OriginalTimestampField. Every change
+// the policy makes goes through here, so the original is never lost
+func setTimestamp(entry *LogEntry, t time.Time) {
+	if t.Equal(entry.Timestamp) {
+		return
+	}
+	if entry.Fields == nil {
+		entry.Fields = make(map[string]string)
+	}
+	if _, ok := entry.Fields[OriginalTimestampField]; !ok {
+		entry.Fields[OriginalTimestampField] = entry.Timestamp.Format(time.RFC3339Nano)
+	}
+	entry.Timestamp = t
+}
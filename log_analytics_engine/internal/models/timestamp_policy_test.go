@@ -0,0 +1,113 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimestampPolicyApply(t *testing.T) {
+	received := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	window := func(mode string) TimestampPolicy {
+		return TimestampPolicy{Mode: mode, MaxPast: time.Hour, MaxFuture: time.Minute}
+	}
+
+	tests := []struct {
+		name      string
+		policy    TimestampPolicy
+		timestamp time.Time
+		wantErr   bool
+		clamped   bool
+	}{
+		{"accept keeps an old timestamp", window(TimestampAccept), received.Add(-48 * time.Hour), false, false},
+		{"inside the window", window(TimestampReject), received.Add(-30 * time.Minute), false, false},
+		{"at the past limit", window(TimestampClamp), received.Add(-time.Hour), false, false},
+		{"at the future limit", window(TimestampClamp), received.Add(time.Minute), false, false},
+		{"reject too old", window(TimestampReject), received.Add(-time.Hour - time.Second), true, false},
+		{"reject too new", window(TimestampReject), received.Add(2 * time.Minute), true, false},
+		{"clamp too old", window(TimestampClamp), received.Add(-48 * time.Hour), false, true},
+		{"clamp too new", window(TimestampClamp), received.Add(2 * time.Minute), false, true},
+		{"no past limit", TimestampPolicy{Mode: TimestampReject, MaxFuture: time.Minute}, received.AddDate(-5, 0, 0), false, false},
+		{"no future limit", TimestampPolicy{Mode: TimestampReject, MaxPast: time.Hour}, received.AddDate(5, 0, 0), false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := &LogEntry{Timestamp: tt.timestamp, ReceivedAt: received, Fields: map[string]string{"region": "eu"}}
+			err := tt.policy.Apply(entry)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Apply() = %v, want error %v", err, tt.wantErr)
+			}
+			original, kept := entry.Fields[OriginalTimestampField]
+			if !tt.clamped {
+				if !entry.Timestamp.Equal(tt.timestamp) || kept {
+					t.Errorf("timestamp = %v with original %q, want it unchanged", entry.Timestamp, original)
+				}
+				return
+			}
+			if !entry.Timestamp.Equal(received) {
+				t.Errorf("timestamp = %v, want it clamped to %v", entry.Timestamp, received)
+			}
+			if original != tt.timestamp.Format(time.RFC3339Nano) {
+				t.Errorf("%s = %q, want %q", OriginalTimestampField, original, tt.timestamp.Format(time.RFC3339Nano))
+			}
+			if entry.Fields["region"] != "eu" {
+				t.Errorf("fields = %v, want the existing fields kept", entry.Fields)
+			}
+		})
+	}
+}
+
+func TestTimestampPolicyClampWithoutFields(t *testing.T) {
+	received := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	sent := received.Add(-48*time.Hour + 123*time.Millisecond)
+	entry := &LogEntry{Timestamp: sent, ReceivedAt: received}
+
+	if err := (TimestampPolicy{Mode: TimestampClamp, MaxPast: time.Hour}).Apply(entry); err != nil {
+		t.Fatal(err)
+	}
+	if got := entry.Fields[OriginalTimestampField]; got != "2024-03-13T12:00:00.123Z" {
+		t.Errorf("%s = %q", OriginalTimestampField, got)
+	}
+}
+
+func TestSetTimestampKeepsFirstOriginal(t *testing.T) {
+	sent := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	entry := &LogEntry{Timestamp: sent}
+
+	setTimestamp(entry, sent)
+	if entry.Fields != nil {
+		t.Errorf("fields = %v, want none when the timestamp is unchanged", entry.Fields)
+	}
+
+	setTimestamp(entry, sent.Add(time.Hour))
+	setTimestamp(entry, sent.Add(2*time.Hour))
+	if !entry.Timestamp.Equal(sent.Add(2*time.Hour)) || entry.Fields[OriginalTimestampField] != "2024-03-15T12:00:00Z" {
+		t.Errorf("timestamp = %v, original = %q, want the client's timestamp kept", entry.Timestamp, entry.Fields[OriginalTimestampField])
+	}
+}
+
+func TestTimestampPolicyValidate(t *testing.T) {
+	tests := []struct {
+		policy TimestampPolicy
+		want   string // empty when the policy is valid
+	}{
+		{TimestampPolicy{Mode: TimestampAccept}, ""},
+		{TimestampPolicy{Mode: TimestampClamp, MaxPast: time.Hour, MaxFuture: time.Minute}, ""},
+		{TimestampPolicy{Mode: "drop"}, "invalid timestamp policy"},
+		{TimestampPolicy{Mode: ""}, "invalid timestamp policy"},
+		{TimestampPolicy{Mode: TimestampReject, MaxPast: -time.Second}, "cannot be negative"},
+		{TimestampPolicy{Mode: TimestampReject, MaxFuture: -time.Second}, "cannot be negative"},
+	}
+	for _, tt := range tests {
+		err := tt.policy.Validate()
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("Validate(%+v) = %v, want nil", tt.policy, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Validate(%+v) = %v, want an error containing %q", tt.policy, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+/*
+This file implements a small grok compiler:
+%{NAME} expands to the named pattern, %{NAME:field} also captures it into field
+patterns are expanded recursively into a single RE2 regular expression
+*/
+
+// built-in grok patterns, a subset of the standard logstash library
+var grokPatterns = map[string]string{
+	"USERNAME":          `[a-zA-Z0-9._-]+`,
+	"USER":              `%{USERNAME}`,
+	"INT":               `(?:[+-]?(?:[0-9]+))`,
+	"BASE10NUM":         `(?:[+-]?(?:[0-9]+(?:\.[0-9]+)?|\.[0-9]+))`,
+	"NUMBER":            `(?:%{BASE10NUM})`,
+	"POSINT":            `\b(?:[1-9][0-9]*)\b`,
+	"NONNEGINT":         `\b(?:[0-9]+)\b`,
+	"WORD":              `\b\w+\b`,
+	"NOTSPACE":          `\S+`,
+	"SPACE":             `\s*`,
+	"DATA":              `.*?`,
+	"GREEDYDATA":        `.*`,
+	"QUOTEDSTRING":      `"(?:[^"\\]|\\.)*"`,
+	"UUID":              `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"IPV4":              `(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.){3}(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)`,
+	"IPV6":              `(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f]{0,4}`,
+	"IP":                `(?:%{IPV6}|%{IPV4})`,
+	"HOSTNAME":          `\b(?:[0-9A-Za-z][0-9A-Za-z-]{0,62})(?:\.(?:[0-9A-Za-z][0-9A-Za-z-]{0,62}))*\.?`,
+	"IPORHOST":          `(?:%{IP}|%{HOSTNAME})`,
+	"HOSTPORT":          `%{IPORHOST}:%{POSINT}`,
+	"PATH":              `(?:/[^\s]*)+`,
+	"URIPROTO":          `[A-Za-z][A-Za-z0-9+\-.]+`,
+	"URIPATH":           `(?:/[A-Za-z0-9$.+!*'(){},~:;=@#%&_\-]*)+`,
+	"URIPARAM":          `\?[A-Za-z0-9$.+!*'|(){},~@#%&/=:;_?\-\[\]<>]*`,
+	"URIPATHPARAM":      `%{URIPATH}(?:%{URIPARAM})?`,
+	"URI":               `%{URIPROTO}://\S+`,
+	"LOGLEVEL":          `(?i:trace|debug|notice|info(?:rmation)?|warn(?:ing)?|err(?:or)?|crit(?:ical)?|fatal|severe|alert|emerg(?:ency)?)`,
+	"YEAR":              `(?:\d\d){1,2}`,
+	"MONTHNUM":          `(?:0?[1-9]|1[0-2])`,
+	"MONTHDAY":          `(?:(?:0[1-9])|(?:[12][0-9])|(?:3[01])|[1-9])`,
+	"MONTH":             `\b(?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec)[a-z]*\b`,
+	"DAY":               `\b(?:Mon|Tue|Wed|Thu|Fri|Sat|Sun)[a-z]*\b`,
+	"HOUR":              `(?:2[0123]|[01]?[0-9])`,
+	"MINUTE":            `(?:[0-5][0-9])`,
+	"SECOND":            `(?:(?:[0-5]?[0-9]|60)(?:[:.,][0-9]+)?)`,
+	"TIME":              `%{HOUR}:%{MINUTE}(?::%{SECOND})?`,
+	"ISO8601_TIMEZONE":  `(?:Z|[+-]%{HOUR}(?::?%{MINUTE}))`,
+	"TIMESTAMP_ISO8601": `%{YEAR}-%{MONTHNUM}-%{MONTHDAY}[T ]%{HOUR}:?%{MINUTE}(?::?%{SECOND})?%{ISO8601_TIMEZONE}?`,
+	"HTTPDATE":          `%{MONTHDAY}/%{MONTH}/%{YEAR}:%{TIME} %{INT}`,
+	"SYSLOGTIMESTAMP":   `%{MONTH} +%{MONTHDAY} %{TIME}`,
+	"SYSLOGPROG":        `%{NOTSPACE:program}(?:\[%{POSINT:pid}\])?`,
+	"SYSLOGBASE":        `%{SYSLOGTIMESTAMP:syslog_timestamp} %{IPORHOST:logsource} %{SYSLOGPROG}:`,
+	"COMMONAPACHELOG":   `%{IPORHOST:client_ip} %{USER:ident} %{USER:auth} \[%{HTTPDATE:timestamp}\] "(?:%{WORD:method} %{NOTSPACE:request}(?: HTTP/%{NUMBER:http_version})?|%{DATA:raw_request})" %{NUMBER:status} (?:%{NUMBER:bytes}|-)`,
+	"COMBINEDAPACHELOG": `%{COMMONAPACHELOG} %{QUOTEDSTRING:referrer} %{QUOTEDSTRING:user_agent}`,
+}
+
+// matches %{NAME} and %{NAME:field}; a trailing :type is accepted and ignored since fields are strings
+var grokReference = regexp.MustCompile(`%\{(\w+)(?::([\w.@-]+))?(?::\w+)?\}`)
+
+// limits how deeply patterns may reference each other, which also stops cycles
+const maxGrokDepth = 16
+
+// compiled grok expression
+type grok struct {
+	re     *regexp.Regexp
+	fields map[string]string // regexp group name -> field name
+}
+
+// compiles a grok pattern; custom definitions take precedence over the built-ins
+func compileGrok(pattern string, custom map[string]string) (*grok, error) {
+	g := &grok{fields: make(map[string]string)}
+
+	expanded, err := g.expand(pattern, custom, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := regexp.Compile(expanded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid grok pattern: %w", err)
+	}
+	g.re = re
+
+	return g, nil
+}
+
+func (g *grok) expand(pattern string, custom map[string]string, depth int) (string, error) {
+	if depth > maxGrokDepth {
+		return "", fmt.Errorf("grok patterns nested more than %d levels deep", maxGrokDepth)
+	}
+
+	var expandErr error
+	expanded := grokReference.ReplaceAllStringFunc(pattern, func(ref string) string {
+		if expandErr != nil {
+			return ""
+		}
+		parts := grokReference.FindStringSubmatch(ref)
+		name, field := parts[1], parts[2]
+
+		definition, ok := custom[name]
+		if !ok {
+			definition, ok = grokPatterns[name]
+		}
+		if !ok {
+			expandErr = fmt.Errorf("unknown grok pattern %%{%s}", name)
+			return ""
+		}
+
+		inner, err := g.expand(definition, custom, depth+1)
+		if err != nil {
+			expandErr = err
+			return ""
+		}
+
+		if field == "" {
+			return "(?:" + inner + ")"
+		}
+
+		// Field names may contain characters RE2 doesn't allow in group names
+		group := fmt.Sprintf("g%d", len(g.fields))
+		g.fields[group] = field
+		return "(?P<" + group + ">" + inner + ")"
+	})
+	if expandErr != nil {
+		return "", expandErr
+	}
+
+	return expanded, nil
+}
+
+// returns the captured fields, or false if the input doesn't match
+func (g *grok) match(input string) (map[string]string, bool) {
+	match := g.re.FindStringSubmatch(input)
+	if match == nil {
+		return nil, false
+	}
+
+	captures := make(map[string]string)
+	for i, group := range g.re.SubexpNames() {
+		field, ok := g.fields[group]
+		if !ok || match[i] == "" {
+			continue
+		}
+		captures[field] = match[i]
+	}
+	return captures, true
+}
+
+// returns the names of the built-in grok patterns
+func GrokPatternNames() []string {
+	names := make([]string, 0, len(grokPatterns))
+	for name := range grokPatterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,92 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+)
+
+// Multiline holds the compiled rules for joining continuation lines into one event
+type Multiline struct {
+	start        *regexp.Regexp
+	continuation *regexp.Regexp
+	MaxWait      time.Duration
+	MaxLines     int
+}
+
+func compileMultiline(config *models.MultilineConfig) (*Multiline, error) {
+	m := &Multiline{
+		MaxWait:  time.Duration(config.MaxWaitMs) * time.Millisecond,
+		MaxLines: config.MaxLines,
+	}
+	if m.MaxWait <= 0 {
+		m.MaxWait = models.DefaultMultilineMaxWait * time.Millisecond
+	}
+	if m.MaxLines <= 0 {
+		m.MaxLines = models.DefaultMultilineMaxLines
+	}
+
+	var err error
+	if config.StartPattern != "" {
+		if m.start, err = regexp.Compile(config.StartPattern); err != nil {
+			return nil, fmt.Errorf("invalid multiline start_pattern: %w", err)
+		}
+	}
+	if config.ContinuationPattern != "" {
+		if m.continuation, err = regexp.Compile(config.ContinuationPattern); err != nil {
+			return nil, fmt.Errorf("invalid multiline continuation_pattern: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// IsContinuation reports whether a line belongs to the event before it. The continuation
+// pattern wins when both are set; with only a start pattern, every line not matching it continues.
+func (m *Multiline) IsContinuation(message string) bool {
+	if m.continuation != nil {
+		return m.continuation.MatchString(message)
+	}
+	return !m.start.MatchString(message)
+}
+
+// Join appends continuation lines to an event's message
+func Join(event *models.LogEntry, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	event.Message = event.Message + "\n" + strings.Join(lines, "\n")
+}
+
+// JoinEntries merges a sequence of entries in memory, as the processor does through Redis.
+// It is used by the pipeline test API.
+func (m *Multiline) JoinEntries(entries []*models.LogEntry) []*models.LogEntry {
+	var joined []*models.LogEntry
+	var current *models.LogEntry
+	var lines []string
+
+	flush := func() {
+		if current != nil {
+			Join(current, lines)
+			joined = append(joined, current)
+		}
+		current, lines = nil, nil
+	}
+
+	for _, entry := range entries {
+		if current != nil && m.IsContinuation(entry.Message) {
+			lines = append(lines, entry.Message)
+			if len(lines)+1 >= m.MaxLines {
+				flush()
+			}
+			continue
+		}
+		flush()
+		current = entry
+	}
+	flush()
+
+	return joined
+}
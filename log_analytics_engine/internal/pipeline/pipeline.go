@@ -0,0 +1,124 @@
+package pipeline
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/cache"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+/*
+This package runs server-side parsing pipelines:
+a pipeline is an ordered list of stages (grok, regex, json, logfmt, kv, timestamp, level)
+stages extract fields from the message and may rewrite its level, timestamp and message
+the original message is kept in RawMessage
+a pipeline may also join continuation lines (stack traces) into one event before its stages run
+*/
+
+// Pipeline is a compiled, ready to run pipeline
+type Pipeline struct {
+	Config    *models.Pipeline
+	Multiline *Multiline // nil unless the pipeline joins multiline events
+	stages    []Stage
+}
+
+// outcome of one stage, returned by the pipeline test API
+type StageResult struct {
+	Type    string `json:"type"`
+	Matched bool   `json:"matched"`
+	Error   string `json:"error,omitempty"`
+}
+
+// compiles every stage of a pipeline, failing on the first invalid one
+func Compile(config *models.Pipeline) (*Pipeline, error) {
+	p := &Pipeline{Config: config}
+	for i, stageConfig := range config.Stages {
+		stage, err := newStage(stageConfig)
+		if err != nil {
+			return nil, fmt.Errorf("stage %d (%s): %w", i, stageConfig.Type, err)
+		}
+		p.stages = append(p.stages, stage)
+	}
+
+	if config.Multiline != nil {
+		multiline, err := compileMultiline(config.Multiline)
+		if err != nil {
+			return nil, err
+		}
+		p.Multiline = multiline
+	}
+
+	return p, nil
+}
+
+// Run applies every stage in order. A stage that doesn't match or fails leaves the
+// entry as it was and the next stage still runs.
+func (p *Pipeline) Run(entry *models.LogEntry) []StageResult {
+	if entry.RawMessage == "" {
+		entry.RawMessage = entry.Message
+	}
+
+	results := make([]StageResult, len(p.stages))
+	for i, stage := range p.stages {
+		results[i].Type = p.Config.Stages[i].Type
+		matched, err := stage.Apply(entry)
+		results[i].Matched = matched
+		if err != nil {
+			results[i].Error = err.Error()
+		}
+	}
+	return results
+}
+
+// Registry caches each user's compiled pipelines for the processor
+type Registry struct {
+	cache  *cache.PerUser[[]*Pipeline]
+	logger *logrus.Logger
+}
+
+// creates a registry; load returns a user's active pipelines ordered by priority
+func NewRegistry(load func(userID int) ([]*models.Pipeline, error), ttl time.Duration, logger *logrus.Logger) *Registry {
+	r := &Registry{logger: logger}
+	r.cache = cache.NewPerUser(ttl, func(userID int) ([]*Pipeline, error) {
+		configs, err := load(userID)
+		if err != nil {
+			return nil, err
+		}
+
+		compiled := make([]*Pipeline, 0, len(configs))
+		for _, config := range configs {
+			p, err := Compile(config)
+			if err != nil {
+				// Pipelines are validated when saved, so this only happens if the stage code changed
+				logger.WithError(err).WithField("pipeline_id", config.ID).Warn("Skipping invalid pipeline")
+				continue
+			}
+			compiled = append(compiled, p)
+		}
+		return compiled, nil
+	})
+	return r
+}
+
+// Match returns the first of the user's pipelines that matches the entry's source and service,
+// or nil if none does
+func (r *Registry) Match(entry *models.LogEntry) *Pipeline {
+	pipelines, err := r.cache.Get(entry.UserID)
+	if err != nil {
+		r.logger.WithError(err).WithField("user_id", entry.UserID).Warn("Failed to load pipelines")
+	}
+
+	for _, p := range pipelines {
+		if p.Config.Matches(entry) {
+			return p
+		}
+	}
+	return nil
+}
+
+// Invalidate drops a user's cached pipelines
+func (r *Registry) Invalidate(userID int) {
+	r.cache.Invalidate(userID)
+}
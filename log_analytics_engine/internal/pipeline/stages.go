@@ -0,0 +1,360 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/timestamp"
+)
+
+// Stage is a compiled pipeline step
+type Stage interface {
+	// Apply parses the entry in place and reports whether the stage's input matched
+	Apply(entry *models.LogEntry) (bool, error)
+}
+
+// builds the stage described by cfg
+func newStage(cfg models.PipelineStage) (Stage, error) {
+	switch cfg.Type {
+	case "grok":
+		g, err := compileGrok(cfg.Pattern, cfg.Patterns)
+		if err != nil {
+			return nil, err
+		}
+		return &grokStage{field: cfg.Field, grok: g}, nil
+	case "regex":
+		re, err := regexp.Compile(cfg.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %w", err)
+		}
+		return &regexStage{field: cfg.Field, re: re}, nil
+	case "json":
+		return &jsonStage{field: cfg.Field, prefix: cfg.Prefix, messageKey: cfg.MessageKey}, nil
+	case "logfmt":
+		return &logfmtStage{field: cfg.Field, prefix: cfg.Prefix}, nil
+	case "kv":
+		valueSep := cfg.ValueSeparator
+		if valueSep == "" {
+			valueSep = "="
+		}
+		return &kvStage{field: cfg.Field, prefix: cfg.Prefix, pairSep: cfg.PairSeparator, valueSep: valueSep}, nil
+	case "timestamp":
+		return newTimestampStage(cfg)
+	case "level":
+		return &levelStage{field: cfg.Field}, nil
+	default:
+		return nil, fmt.Errorf("unknown stage type %q", cfg.Type)
+	}
+}
+
+// reads the stage input: the message by default, otherwise a field
+func input(entry *models.LogEntry, field string) (string, bool) {
+	if field == "" || field == "message" {
+		return entry.Message, entry.Message != ""
+	}
+	value, ok := entry.Fields[field]
+	return value, ok
+}
+
+// stores an extracted value; a capture named "message" replaces the message,
+// which stays available in RawMessage
+func setField(entry *models.LogEntry, name, value string) {
+	if name == "message" {
+		entry.Message = value
+		return
+	}
+	if entry.Fields == nil {
+		entry.Fields = make(map[string]string)
+	}
+	entry.Fields[name] = value
+}
+
+type grokStage struct {
+	field string
+	grok  *grok
+}
+
+func (s *grokStage) Apply(entry *models.LogEntry) (bool, error) {
+	value, ok := input(entry, s.field)
+	if !ok {
+		return false, nil
+	}
+	captures, matched := s.grok.match(value)
+	if !matched {
+		return false, nil
+	}
+	for name, capture := range captures {
+		setField(entry, name, capture)
+	}
+	return true, nil
+}
+
+// regex stage: every named capture group becomes a field
+type regexStage struct {
+	field string
+	re    *regexp.Regexp
+}
+
+func (s *regexStage) Apply(entry *models.LogEntry) (bool, error) {
+	value, ok := input(entry, s.field)
+	if !ok {
+		return false, nil
+	}
+	match := s.re.FindStringSubmatch(value)
+	if match == nil {
+		return false, nil
+	}
+	for i, name := range s.re.SubexpNames() {
+		if name != "" && match[i] != "" {
+			setField(entry, name, match[i])
+		}
+	}
+	return true, nil
+}
+
+// json stage: flattens a JSON object embedded in the message into dotted field names
+type jsonStage struct {
+	field      string
+	prefix     string
+	messageKey string
+}
+
+func (s *jsonStage) Apply(entry *models.LogEntry) (bool, error) {
+	value, ok := input(entry, s.field)
+	if !ok {
+		return false, nil
+	}
+
+	// Allow text before the object, e.g. "INFO request done {...}"
+	start := strings.IndexByte(value, '{')
+	if start < 0 {
+		return false, nil
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(value[start:]))
+	decoder.UseNumber()
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil {
+		return false, nil
+	}
+
+	flattened := make(map[string]string)
+	flatten("", object, flattened)
+
+	if s.messageKey != "" {
+		if message, ok := flattened[s.messageKey]; ok {
+			entry.Message = message
+			delete(flattened, s.messageKey)
+		}
+	}
+	for key, v := range flattened {
+		setField(entry, s.prefix+key, v)
+	}
+	return true, nil
+}
+
+// flattens nested objects into dotted keys; arrays are kept as JSON text
+func flatten(prefix string, value interface{}, out map[string]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, inner := range v {
+			name := key
+			if prefix != "" {
+				name = prefix + "." + key
+			}
+			flatten(name, inner, out)
+		}
+	case string:
+		out[prefix] = v
+	case nil:
+		out[prefix] = ""
+	case json.Number:
+		out[prefix] = v.String()
+	case bool:
+		out[prefix] = fmt.Sprint(v)
+	default:
+		encoded, _ := json.Marshal(v)
+		out[prefix] = string(encoded)
+	}
+}
+
+// logfmt stage: key=value pairs separated by spaces, values may be double quoted
+type logfmtStage struct {
+	field  string
+	prefix string
+}
+
+func (s *logfmtStage) Apply(entry *models.LogEntry) (bool, error) {
+	value, ok := input(entry, s.field)
+	if !ok {
+		return false, nil
+	}
+	pairs := parseLogfmt(value)
+	if len(pairs) == 0 {
+		return false, nil
+	}
+	for _, pair := range pairs {
+		setField(entry, s.prefix+pair[0], pair[1])
+	}
+	return true, nil
+}
+
+// parses logfmt, ignoring bare words that aren't part of a pair
+func parseLogfmt(line string) [][2]string {
+	var pairs [][2]string
+	i := 0
+	for i < len(line) {
+		for i < len(line) && line[i] == ' ' {
+			i++
+		}
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' {
+			i++
+		}
+		key := line[start:i]
+		if key == "" && i < len(line) {
+			i++ // stray '='
+			continue
+		}
+		if i >= len(line) || line[i] != '=' {
+			continue
+		}
+		i++ // skip '='
+
+		var value string
+		if i < len(line) && line[i] == '"' {
+			var b strings.Builder
+			i++
+			for i < len(line) && line[i] != '"' {
+				if line[i] == '\\' && i+1 < len(line) {
+					i++
+				}
+				b.WriteByte(line[i])
+				i++
+			}
+			i++ // skip closing quote
+			value = b.String()
+		} else {
+			start = i
+			for i < len(line) && line[i] != ' ' {
+				i++
+			}
+			value = line[start:i]
+		}
+		pairs = append(pairs, [2]string{key, value})
+	}
+	return pairs
+}
+
+// kv stage: pairs split on configurable separators, e.g. "a:1;b:2"
+type kvStage struct {
+	field    string
+	prefix   string
+	pairSep  string // empty splits on whitespace
+	valueSep string
+}
+
+func (s *kvStage) Apply(entry *models.LogEntry) (bool, error) {
+	value, ok := input(entry, s.field)
+	if !ok {
+		return false, nil
+	}
+
+	var pairs []string
+	if s.pairSep == "" {
+		pairs = strings.FieldsFunc(value, unicode.IsSpace)
+	} else {
+		pairs = strings.Split(value, s.pairSep)
+	}
+
+	matched := false
+	for _, pair := range pairs {
+		key, v, found := strings.Cut(strings.TrimSpace(pair), s.valueSep)
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			continue
+		}
+		setField(entry, s.prefix+key, strings.Trim(strings.TrimSpace(v), `"'`))
+		matched = true
+	}
+	return matched, nil
+}
+
+// timestamp stage: parses a field and replaces the entry timestamp
+type timestampStage struct {
+	field    string
+	formats  []string
+	location *time.Location
+}
+
+func newTimestampStage(cfg models.PipelineStage) (Stage, error) {
+	location := time.UTC
+	if cfg.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", cfg.Timezone, err)
+		}
+		location = loc
+	}
+
+	field := cfg.Field
+	if field == "" {
+		field = "timestamp"
+	}
+
+	formats := cfg.Formats
+	if len(formats) == 0 {
+		formats = timestamp.DefaultFormats
+	}
+
+	return &timestampStage{field: field, formats: formats, location: location}, nil
+}
+
+func (s *timestampStage) Apply(entry *models.LogEntry) (bool, error) {
+	value, ok := input(entry, s.field)
+	if !ok {
+		return false, nil
+	}
+	parsed, err := timestamp.Parse(value, s.formats, s.location)
+	if err != nil {
+		return false, err
+	}
+	entry.Timestamp = parsed
+	return true, nil
+}
+
+// level stage: normalizes a level field, or finds a level keyword in the message
+type levelStage struct {
+	field string
+}
+
+// first level-looking word in a message
+var levelKeyword = regexp.MustCompile(`(?i)\b(trace|debug|info|notice|warn|warning|error|err|critical|crit|fatal|panic|emerg|alert|severe)\b`)
+
+func (s *levelStage) Apply(entry *models.LogEntry) (bool, error) {
+	field := s.field
+	if field == "" {
+		if _, ok := entry.Fields["level"]; ok {
+			field = "level"
+		}
+	}
+
+	var value string
+	if field == "" || field == "message" {
+		value = levelKeyword.FindString(entry.Message)
+	} else {
+		value = entry.Fields[field]
+	}
+
+	level, ok := models.NormalizeLevel(value)
+	if !ok {
+		return false, nil
+	}
+	entry.Level = level
+	return true, nil
+}
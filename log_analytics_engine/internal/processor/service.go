@@ -0,0 +1,356 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/app"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/config"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/consumer"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/enrich"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/health"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/metrics"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/pipeline"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/queue"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/redact"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/selflog"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/storage"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/pkg/client"
+	"github.com/sirupsen/logrus"
+)
+
+// times a joined multiline event is stored before it is dropped
+const maxMultilineAttempts = 5
+
+type Service struct {
+	storage      *storage.PostgresStorage
+	logs         storage.LogStore
+	state        storage.StateStore
+	queue        queue.Queue // where the ingesters publish logs
+	pipelines    *pipeline.Registry
+	enricher     *enrich.Enricher
+	geoIP        *enrich.GeoIP // nil when GEOIP_DB_PATH is unset
+	redactors    *redact.Registry
+	logForwarder *client.Client      // nil unless LOG_FORWARD_URL is set
+	selfLogHook  *selflog.TenantHook // nil unless SELF_LOG_TENANT is set
+	health       *health.Checker
+	logger       *logrus.Logger
+	config       *config.Config
+}
+
+// creates a new processor service
+func New(cfg *config.Config, res *app.Resources) (*Service, error) {
+	logger := logrus.New()
+
+	// Set log level
+	level, err := logrus.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		level = logrus.InfoLevel
+	}
+	logger.SetLevel(level)
+
+	logForwarder, err := selflog.Forward(logger, cfg, "log-processor")
+	if err != nil {
+		return nil, err
+	}
+
+	pgStorage := res.Postgres
+	state := res.State
+
+	selfLogHook, err := selflog.ForwardToTenant(logger, cfg, "log-processor", res.Queue, storage.NewAuthStorage(pgStorage.GetDB()))
+	if err != nil {
+		return nil, err
+	}
+
+	pipelineStorage := storage.NewPipelineStorage(pgStorage.GetDB())
+	redactionStorage := storage.NewRedactionStorage(pgStorage.GetDB())
+
+	redactors, err := redact.NewRegistry(redactionStorage.GetActiveRules, cfg.RedactDefaultDetectors, cfg.RedactHashKey, cfg.RulesCacheTTL, logger)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redaction defaults: %w", err)
+	}
+
+	var geoIP *enrich.GeoIP
+	if cfg.GeoIPDBPath != "" {
+		if geoIP, err = enrich.OpenGeoIP(cfg.GeoIPDBPath); err != nil {
+			return nil, err
+		}
+	}
+	lookupTables := storage.NewLookupTableStorage(pgStorage.GetDB())
+
+	healthChecker := health.NewChecker("log-processor", 2*time.Second,
+		health.PostgresCheck(pgStorage),
+		health.StateCheck(state),
+		health.LogStoreCheck(res.Logs),
+	)
+
+	return &Service{
+		storage:      pgStorage,
+		logs:         res.Logs,
+		state:        state,
+		queue:        res.Queue,
+		pipelines:    pipeline.NewRegistry(pipelineStorage.GetActivePipelines, cfg.RulesCacheTTL, logger),
+		enricher:     enrich.NewEnricher(geoIP, cfg.EnrichIPField, cfg.EnrichUserAgentField, lookupTables, cfg.RulesCacheTTL, logger),
+		geoIP:        geoIP,
+		redactors:    redactors,
+		logForwarder: logForwarder,
+		selfLogHook:  selfLogHook,
+		health:       healthChecker,
+		logger:       logger,
+		config:       cfg,
+	}, nil
+}
+
+// returns the service's logger, which forwards its entries when self-logging is configured
+func (s *Service) Logger() *logrus.Logger {
+	return s.logger
+}
+
+// returns the checks behind the processor's /livez and /readyz
+func (s *Service) Health() *health.Checker {
+	return s.health
+}
+
+// flushes the service's own logs; the shared resources are closed by their owner
+func (s *Service) Close() error {
+	if s.selfLogHook != nil {
+		// While the queue it publishes to is still open
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		s.selfLogHook.Close(ctx)
+		cancel()
+	}
+	if s.geoIP != nil {
+		s.geoIP.Close()
+	}
+	if s.logForwarder != nil {
+		// Last, so errors from closing the rest are forwarded too
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		s.logForwarder.Close(ctx)
+		cancel()
+	}
+	return nil
+}
+
+// processLog handles a single log entry
+func (s *Service) processLog(log *models.LogEntry) error {
+	// Find the user's pipeline for this source/service, if any
+	p := s.pipelines.Match(log)
+	if p != nil && p.Multiline != nil {
+		return s.bufferMultiline(p, log)
+	}
+	return s.storeLog(p, log)
+}
+
+// runs the pipeline stages, if any, enriches and redacts the entry and stores it
+func (s *Service) storeLog(p *pipeline.Pipeline, log *models.LogEntry) error {
+	if p != nil {
+		p.Run(log)
+	}
+
+	// Enrich before redacting so lookups see the original IPs and IDs
+	s.enricher.Apply(log)
+
+	// Redact after parsing so values extracted into fields are covered too. Without the user's
+	// rules the log is left pending rather than stored unredacted
+	hits, err := s.redactors.Apply(log)
+	if err != nil {
+		metrics.Errors.WithLabelValues("redact").Inc()
+		return err
+	}
+	if len(hits) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		if err := s.state.IncrRedactionHits(ctx, log.UserID, hits); err != nil {
+			s.logger.WithError(err).Warn("Failed to count redaction hits")
+		}
+		cancel()
+	}
+
+	// Store in the log store
+	start := time.Now()
+	err = s.logs.InsertLog(log)
+	metrics.InsertDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		if errors.Is(err, storage.ErrDuplicateEvent) {
+			// A retry of an event we already stored, acknowledge it without inserting again
+			s.logger.WithFields(logrus.Fields{
+				"user_id":  log.UserID,
+				"event_id": log.EventID,
+			}).Debug("Skipped duplicate log event")
+			return nil
+		}
+		metrics.Errors.WithLabelValues("insert").Inc()
+		return fmt.Errorf("failed to store log in database: %w", err)
+	}
+	metrics.LogsProcessed.Inc()
+
+	s.logger.WithFields(logrus.Fields{
+		"log_id":  log.ID,
+		"user_id": log.UserID,
+		"level":   log.Level,
+		"source":  log.Source,
+	}).Debug("Log processed and stored")
+
+	return nil
+}
+
+// adds a line to its stream's buffered event in Redis, storing the previous event once it is complete
+func (s *Service) bufferMultiline(p *pipeline.Pipeline, log *models.LogEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	continuation := p.Multiline.IsContinuation(log.Message)
+	event, err := s.state.AppendMultiline(ctx, log, p.Config.ID, continuation, p.Multiline.MaxWait, p.Multiline.MaxLines)
+	if err != nil {
+		return err
+	}
+	if event != nil {
+		// The line itself is buffered, so only fail the message if the event couldn't be kept either
+		return s.storeMultilineEvent(ctx, p, event)
+	}
+	return nil
+}
+
+// joins and stores a buffered event; if storing fails the event is put back in Redis to be
+// retried, and an error is returned only when that fails too
+func (s *Service) storeMultilineEvent(ctx context.Context, p *pipeline.Pipeline, event *storage.MultilineEvent) error {
+	// Store a copy, since the pipeline and redactors change the entry in place
+	entry := *event.Entry
+	entry.Fields = make(map[string]string, len(event.Entry.Fields))
+	for k, v := range event.Entry.Fields {
+		entry.Fields[k] = v
+	}
+	pipeline.Join(&entry, event.Lines)
+
+	err := s.storeLog(p, &entry)
+	if err == nil {
+		return nil
+	}
+
+	event.Attempts++
+	fields := logrus.Fields{
+		"user_id":  event.Entry.UserID,
+		"lines":    len(event.Lines) + 1,
+		"attempts": event.Attempts,
+	}
+	if event.Attempts >= maxMultilineAttempts {
+		s.logger.WithError(err).WithFields(fields).Error("Dropping multiline event after repeated store failures")
+		return nil
+	}
+	if retryErr := s.state.RetryMultilineLater(ctx, event); retryErr != nil {
+		return fmt.Errorf("failed to store multiline event: %w (and to requeue it: %v)", err, retryErr)
+	}
+	s.logger.WithError(err).WithFields(fields).Warn("Failed to store multiline event, will retry")
+	return nil
+}
+
+// periodically stores buffered multiline events that waited longer than their max wait,
+// and retries events whose storing failed
+func (s *Service) flushMultiline(ctx context.Context) {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			retries, err := s.state.RetryMultiline(ctx, 100)
+			if err != nil && ctx.Err() == nil {
+				s.logger.WithError(err).Warn("Failed to read multiline retries")
+			}
+			expired, err := s.state.ExpireMultiline(ctx, 100)
+			if err != nil && ctx.Err() == nil {
+				s.logger.WithError(err).Warn("Failed to flush multiline events")
+			}
+			for _, event := range append(retries, expired...) {
+				if err := s.storeMultilineEvent(ctx, s.pipelines.Match(event.Entry), event); err != nil {
+					s.logger.WithError(err).WithField("user_id", event.Entry.UserID).Error("Lost multiline event")
+				}
+			}
+		}
+	}
+}
+
+// hourly deletes event_ids older than the dedup window, so the dedup table doesn't grow for ever
+func (s *Service) pruneEventIDs(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		pruneCtx, cancel := context.WithTimeout(ctx, time.Minute)
+		pruned, err := s.logs.PruneEventIDs(pruneCtx)
+		cancel()
+		if err != nil && ctx.Err() == nil {
+			s.logger.WithError(err).Warn("Failed to prune event IDs")
+		} else if pruned > 0 {
+			s.logger.WithField("count", pruned).Debug("Pruned expired event IDs")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tells the ingesters this processor is alive until ctx is cancelled
+func (s *Service) heartbeat(ctx context.Context, consumerName string) {
+	ticker := time.NewTicker(storage.ProcessorHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		beatCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		if err := s.state.ProcessorHeartbeat(beatCtx, consumerName, storage.ProcessorHeartbeatMaxAge); err != nil {
+			s.logger.WithError(err).Warn("Failed to send processor heartbeat")
+		}
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			// Stop counting as alive right away rather than when the heartbeat expires
+			removeCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			s.state.RemoveProcessorHeartbeat(removeCtx, consumerName)
+			cancel()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// begins processing logs from Redis Stream. Once ctx is cancelled the consumer stops reading,
+// finishes its current batch within ShutdownTimeout, acknowledges what was stored and returns;
+// unacknowledged messages and buffered multiline events stay in Redis for another processor
+func (s *Service) Start(ctx context.Context) error {
+	consumerGroup := s.config.ConsumerGroup
+	consumerName := fmt.Sprintf("processor-%d", os.Getpid())
+
+	s.logger.WithFields(logrus.Fields{
+		"consumer_group": consumerGroup,
+		"consumer_name":  consumerName,
+	}).Info("Starting log processor")
+
+	go s.flushMultiline(ctx)
+	go s.heartbeat(ctx, consumerName)
+	go s.pruneEventIDs(ctx)
+	go queue.SampleMetrics(ctx, s.queue, 15*time.Second, s.logger)
+	if s.config.SystemMetricsInterval > 0 {
+		recorder := metrics.NewRecorder(storage.NewSystemMetricsStorage(s.storage.GetDB()), "log-processor",
+			s.config.SystemMetricsInterval, s.config.SystemMetricsRetention, s.logger)
+		go recorder.Run(ctx)
+	}
+	if s.geoIP != nil {
+		go s.geoIP.Watch(ctx, time.Minute, s.logger)
+	}
+
+	// Start consuming from the queue
+	c := consumer.New(s.queue, consumerGroup, consumerName, s.processLog, s.logger)
+	c.BatchSize = s.config.ConsumerBatchSize
+	c.DrainTimeout = s.config.ShutdownTimeout
+	c.ClaimIdle = s.config.ClaimIdle
+	return c.Run(ctx)
+}
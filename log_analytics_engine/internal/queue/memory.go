@@ -0,0 +1,263 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/consumer"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/metrics"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+)
+
+/*
+this file is an in-process Queue with the same consumer-group semantics as a Redis stream: each group
+reads every message once, messages stay pending until acknowledged and idle pending messages can be
+claimed by another consumer. Nothing is persisted, so messages still queued when the process exits are lost
+*/
+
+// returned by Publish when MaxLen messages are waiting to be delivered
+var ErrFull = errors.New("queue is full")
+
+type Memory struct {
+	mu      sync.Mutex
+	name    string
+	maxLen  int
+	lastSeq int64
+	entries []memoryEntry // in publish order, until every group has read them
+	groups  map[string]*memoryGroup
+	arrived chan struct{} // closed and replaced whenever messages are published
+}
+
+type memoryEntry struct {
+	seq  int64
+	data string
+}
+
+type memoryGroup struct {
+	delivered int64 // seq of the last message delivered to the group
+	pending   map[int64]*pendingEntry
+	consumers map[string]struct{}
+}
+
+type pendingEntry struct {
+	data        string
+	consumer    string
+	deliveredAt time.Time
+}
+
+// creates an empty queue holding at most maxLen undelivered messages
+func NewMemory(name string, maxLen int) *Memory {
+	return &Memory{
+		name:    name,
+		maxLen:  maxLen,
+		groups:  make(map[string]*memoryGroup),
+		arrived: make(chan struct{}),
+	}
+}
+
+// publishes a log entry
+func (m *Memory) PublishLog(ctx context.Context, log *models.LogEntry) error {
+	start := time.Now()
+	err := m.publish([]*models.LogEntry{log})
+	metrics.PublishDuration.WithLabelValues("single").Observe(time.Since(start).Seconds())
+	return err
+}
+
+// publishes multiple log entries, all or none of them
+func (m *Memory) PublishLogs(ctx context.Context, logs []*models.LogEntry) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	start := time.Now()
+	err := m.publish(logs)
+	metrics.PublishDuration.WithLabelValues("batch").Observe(time.Since(start).Seconds())
+	return err
+}
+
+func (m *Memory) publish(logs []*models.LogEntry) error {
+	// Encoded like the Redis stream, so consumers can't see later changes to the entries
+	encoded := make([]string, len(logs))
+	for i, log := range logs {
+		logJSON, err := json.Marshal(log)
+		if err != nil {
+			metrics.Errors.WithLabelValues("publish").Inc()
+			return fmt.Errorf("failed to marshal log: %w", err)
+		}
+		encoded[i] = string(logJSON)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.entries)+len(encoded) > m.maxLen {
+		metrics.Errors.WithLabelValues("publish").Inc()
+		return ErrFull
+	}
+	for _, data := range encoded {
+		m.lastSeq++
+		m.entries = append(m.entries, memoryEntry{seq: m.lastSeq, data: data})
+	}
+	metrics.LogsIngested.Add(float64(len(encoded)))
+
+	close(m.arrived)
+	m.arrived = make(chan struct{})
+	return nil
+}
+
+// creates the consumer group if it doesn't exist yet; a new group starts at the oldest queued message
+func (m *Memory) EnsureGroup(ctx context.Context, group string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.groups[group]; !ok {
+		m.groups[group] = &memoryGroup{
+			pending:   make(map[int64]*pendingEntry),
+			consumers: make(map[string]struct{}),
+		}
+	}
+	return nil
+}
+
+// delivers up to count new messages to a consumer, waiting at most block for one to be published
+func (m *Memory) ReadGroup(ctx context.Context, group, consumerName string, count int, block time.Duration) ([]consumer.Message, error) {
+	timer := time.NewTimer(block)
+	defer timer.Stop()
+
+	for {
+		m.mu.Lock()
+		g, ok := m.groups[group]
+		if !ok {
+			m.mu.Unlock()
+			return nil, fmt.Errorf("no consumer group %q", group)
+		}
+		messages := m.deliver(g, consumerName, count)
+		arrived := m.arrived
+		m.mu.Unlock()
+
+		if len(messages) > 0 {
+			return messages, nil
+		}
+		select {
+		case <-arrived:
+		case <-timer.C:
+			return nil, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// moves up to count undelivered messages to the group's pending list; the caller holds m.mu
+func (m *Memory) deliver(g *memoryGroup, consumerName string, count int) []consumer.Message {
+	g.consumers[consumerName] = struct{}{}
+
+	first := sort.Search(len(m.entries), func(i int) bool { return m.entries[i].seq > g.delivered })
+	var messages []consumer.Message
+	now := time.Now()
+	for _, entry := range m.entries[first:] {
+		if len(messages) == count {
+			break
+		}
+		g.pending[entry.seq] = &pendingEntry{data: entry.data, consumer: consumerName, deliveredAt: now}
+		g.delivered = entry.seq
+		messages = append(messages, consumer.Message{ID: strconv.FormatInt(entry.seq, 10), Data: entry.data})
+	}
+	m.trim()
+	return messages
+}
+
+// drops messages every group has read; pending messages are kept by their group
+func (m *Memory) trim() {
+	if len(m.groups) == 0 {
+		return
+	}
+	oldest := m.lastSeq
+	for _, g := range m.groups {
+		if g.delivered < oldest {
+			oldest = g.delivered
+		}
+	}
+	read := sort.Search(len(m.entries), func(i int) bool { return m.entries[i].seq > oldest })
+	if read > 0 {
+		clear(m.entries[:read])
+		m.entries = m.entries[read:]
+	}
+}
+
+// acknowledges processed messages so they leave the group's pending list
+func (m *Memory) Ack(ctx context.Context, group string, ids ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.groups[group]
+	if !ok {
+		return fmt.Errorf("no consumer group %q", group)
+	}
+	for _, id := range ids {
+		seq, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			continue
+		}
+		delete(g.pending, seq)
+	}
+	return nil
+}
+
+// takes over up to count messages, oldest first, that have been pending for at least minIdle
+func (m *Memory) Claim(ctx context.Context, group, consumerName string, minIdle time.Duration, count int) ([]consumer.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.groups[group]
+	if !ok {
+		return nil, fmt.Errorf("no consumer group %q", group)
+	}
+	g.consumers[consumerName] = struct{}{}
+
+	now := time.Now()
+	var idle []int64
+	for seq, entry := range g.pending {
+		if now.Sub(entry.deliveredAt) >= minIdle {
+			idle = append(idle, seq)
+		}
+	}
+	sort.Slice(idle, func(i, j int) bool { return idle[i] < idle[j] })
+	if len(idle) > count {
+		idle = idle[:count]
+	}
+
+	messages := make([]consumer.Message, len(idle))
+	for i, seq := range idle {
+		entry := g.pending[seq]
+		entry.consumer = consumerName
+		entry.deliveredAt = now
+		messages[i] = consumer.Message{ID: strconv.FormatInt(seq, 10), Data: entry.data}
+	}
+	return messages, nil
+}
+
+// returns the number of queued messages and each group's progress
+func (m *Memory) Info(ctx context.Context) (*Info, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	info := &Info{Name: m.name, Length: int64(len(m.entries)), Groups: []GroupInfo{}}
+	for name, g := range m.groups {
+		unread := len(m.entries) - sort.Search(len(m.entries), func(i int) bool { return m.entries[i].seq > g.delivered })
+		info.Groups = append(info.Groups, GroupInfo{
+			Name:            name,
+			Consumers:       int64(len(g.consumers)),
+			Pending:         int64(len(g.pending)),
+			Lag:             int64(unread),
+			LastDeliveredID: strconv.FormatInt(g.delivered, 10),
+		})
+	}
+	sort.Slice(info.Groups, func(i, j int) bool { return info.Groups[i].Name < info.Groups[j].Name })
+	return info, nil
+}
@@ -0,0 +1,77 @@
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/consumer"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/metrics"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+/*
+this file defines Queue, which carries accepted logs from the ingesters to the processors.
+storage.RedisClient implements it over a Redis stream that separate ingester and processor processes share;
+Memory implements it within one process, for the combined logbuilder binary
+*/
+
+// Queue publishes logs and delivers them to consumer groups, keeping each message pending until it is acknowledged
+type Queue interface {
+	consumer.Stream
+	PublishLog(ctx context.Context, log *models.LogEntry) error
+	PublishLogs(ctx context.Context, logs []*models.LogEntry) error
+	Info(ctx context.Context) (*Info, error)
+}
+
+// Info describes the queue and how far each consumer group has got through it
+type Info struct {
+	Name   string      `json:"stream_name"`
+	Length int64       `json:"stream_length"`
+	Groups []GroupInfo `json:"groups"`
+}
+
+type GroupInfo struct {
+	Name            string `json:"name"`
+	Consumers       int64  `json:"consumers"`
+	Pending         int64  `json:"pending"` // delivered but not acknowledged
+	Lag             int64  `json:"lag"`     // not yet delivered
+	LastDeliveredID string `json:"last_delivered_id"`
+}
+
+// returns the named group, or nil if no consumer has created it yet
+func (i *Info) Group(name string) *GroupInfo {
+	for k := range i.Groups {
+		if i.Groups[k].Name == name {
+			return &i.Groups[k]
+		}
+	}
+	return nil
+}
+
+// periodically samples the queue's length and each consumer group's lag into the metrics
+func SampleMetrics(ctx context.Context, q Queue, interval time.Duration, logger *logrus.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := q.Info(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.WithError(err).Warn("Failed to sample stream metrics")
+				}
+				continue
+			}
+
+			metrics.StreamLength.Set(float64(info.Length))
+			for _, group := range info.Groups {
+				metrics.StreamLag.WithLabelValues(group.Name).Set(float64(group.Lag))
+				metrics.StreamPending.WithLabelValues(group.Name).Set(float64(group.Pending))
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package redact
+
+import (
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// detector finds one kind of sensitive value; locate, when set, returns the part of a regex match to
+// redact, or ok false when the match isn't real (e.g. no card number in it passes the Luhn check)
+type detector struct {
+	re     *regexp.Regexp
+	locate func(match string) (start, end int, ok bool)
+}
+
+// a locate for detectors whose matches are redacted whole when valid accepts them
+func whole(valid func(match string) bool) func(match string) (int, int, bool) {
+	return func(match string) (int, int, bool) {
+		return 0, len(match), valid(match)
+	}
+}
+
+var detectors = map[string]detector{
+	"email": {
+		re: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	},
+	"credit_card": {
+		// Greedy, so a match can take in digits next to the card; locateCardNumber finds the card in it
+		re:     regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		locate: locateCardNumber,
+	},
+	"jwt": {
+		re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]*`),
+	},
+	"bearer_token": {
+		re: regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/-]+=*`),
+	},
+	"aws_key": {
+		re: regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[A-Z0-9]{16}\b|(?i:aws.{0,20}?secret.{0,20}?[:=]\s*["']?)[A-Za-z0-9/+]{40}`),
+	},
+	"lak_key": {
+		re: regexp.MustCompile(`\blak_[0-9a-f]{64}\b`),
+	},
+	"ipv4": {
+		re:     regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`),
+		locate: whole(func(match string) bool { return net.ParseIP(match) != nil }),
+	},
+	"ipv6": {
+		re:     regexp.MustCompile(`(?i)(?:[0-9a-f]{0,4}:){2,7}[0-9a-f]{0,4}`),
+		locate: whole(isIPv6),
+	},
+}
+
+// returns the names of the built-in detectors
+func DetectorNames() []string {
+	names := make([]string, 0, len(detectors))
+	for name := range detectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// reports whether a match is an IPv6 address with at least three groups, or groups on both sides of ::,
+// so C++ and Rust paths like std::io::Error aren't taken for addresses such as d:: or ::e
+func isIPv6(match string) bool {
+	if strings.Count(match, ":") < 2 || net.ParseIP(match) == nil {
+		return false
+	}
+	groups := 0
+	for _, group := range strings.Split(match, ":") {
+		if group != "" {
+			groups++
+		}
+	}
+	if groups >= 3 {
+		return true
+	}
+	left, right, _ := strings.Cut(match, "::")
+	return left != "" && right != ""
+}
+
+// returns the card number in a credit_card match: the shortest run of whole digit groups (split by
+// spaces or dashes) that is 13-19 digits and passes the Luhn check, the leftmost of equal ones. Runs
+// start and end at group edges, so "12 4111111111111111" and "4111 1111 1111 1111 123" yield the card
+// without the digits beside it
+func locateCardNumber(match string) (start, end int, ok bool) {
+	// byte offsets where each group starts and ends, and the digits before each group
+	var starts, ends, before []int
+	digits := 0
+	for i := 0; i < len(match); i++ {
+		if match[i] < '0' || match[i] > '9' {
+			continue
+		}
+		if i == 0 || match[i-1] < '0' || match[i-1] > '9' {
+			starts = append(starts, i)
+			before = append(before, digits)
+		}
+		if i == len(match)-1 || match[i+1] < '0' || match[i+1] > '9' {
+			ends = append(ends, i+1)
+		}
+		digits++
+	}
+
+	bestDigits := 0
+	for first := range starts {
+		for last := first; last < len(ends); last++ {
+			count := before[last] - before[first] + (ends[last] - starts[last])
+			if count > 19 || (bestDigits > 0 && count >= bestDigits) {
+				break
+			}
+			if count >= 13 && isCardNumber(match[starts[first]:ends[last]]) {
+				start, end, bestDigits = starts[first], ends[last], count
+				break
+			}
+		}
+	}
+	return start, end, bestDigits > 0
+}
+
+// reports whether a match is 13-19 digits passing the Luhn checksum
+func isCardNumber(match string) bool {
+	digits := make([]int, 0, len(match))
+	for _, r := range match {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, int(r-'0'))
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := digits[i]
+		if (len(digits)-1-i)%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
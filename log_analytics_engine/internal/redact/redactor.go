@@ -0,0 +1,211 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/cache"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+/*
+This package removes PII and secrets from log entries before they are stored:
+rules pair a built-in detector (or a custom regex) with an action: mask, hash or drop
+rules apply to the message, the raw message and every field value
+only per-rule hit counts are reported, never the matched values
+hashes are HMAC-SHA256 keyed with REDACT_HASH_KEY over the user ID and the value, so they can't be
+reversed by hashing guesses, and one user's hashes can't be matched against another's
+*/
+
+// rule is a compiled redaction rule
+type rule struct {
+	key    string // identifies the rule in hit counters
+	name   string
+	action string
+	detector
+}
+
+// Redactor applies an ordered set of rules
+type Redactor struct {
+	rules   []rule
+	hashKey []byte
+}
+
+// Hits counts matches per rule key
+type Hits map[string]int64
+
+// RuleKey returns the hit counter key for a user rule
+func RuleKey(ruleID int) string {
+	return strconv.Itoa(ruleID)
+}
+
+// DefaultRuleKey returns the hit counter key for a detector applied to every user
+func DefaultRuleKey(detector string) string {
+	return "default:" + detector
+}
+
+// compiles a user's rules followed by the detectors applied to everyone; hashKey keys the hash action
+func Compile(rules []*models.RedactionRule, defaults []string, hashKey string) (*Redactor, error) {
+	r := &Redactor{hashKey: []byte(hashKey)}
+	for _, config := range rules {
+		d, ok := detectors[config.Detector]
+		if config.Detector == "custom" {
+			re, err := regexp.Compile(config.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: invalid pattern: %w", config.ID, err)
+			}
+			d, ok = detector{re: re}, true
+		}
+		if !ok {
+			return nil, fmt.Errorf("rule %d: unknown detector %q", config.ID, config.Detector)
+		}
+		r.rules = append(r.rules, rule{key: RuleKey(config.ID), name: config.Name, action: config.Action, detector: d})
+	}
+
+	for _, name := range defaults {
+		d, ok := detectors[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown default detector %q", name)
+		}
+		r.rules = append(r.rules, rule{key: DefaultRuleKey(name), name: name, action: "mask", detector: d})
+	}
+
+	return r, nil
+}
+
+// Apply redacts the entry in place and returns how many values each rule replaced
+func (r *Redactor) Apply(entry *models.LogEntry) Hits {
+	if len(r.rules) == 0 {
+		return nil
+	}
+
+	hits := make(Hits)
+
+	entry.Message, _ = r.redact(entry.Message, entry.UserID, hits)
+	if entry.RawMessage != "" {
+		// RawMessage repeats the message, so don't count its matches twice
+		entry.RawMessage, _ = r.redact(entry.RawMessage, entry.UserID, nil)
+	}
+
+	for key, value := range entry.Fields {
+		redacted, dropped := r.redact(value, entry.UserID, hits)
+		if dropped {
+			delete(entry.Fields, key)
+			continue
+		}
+		entry.Fields[key] = redacted
+	}
+
+	return hits
+}
+
+// ApplyText redacts a user's text that isn't part of an entry, such as a rejected payload, without counting hits
+func (r *Redactor) ApplyText(userID int, text string) string {
+	text, _ = r.redact(text, userID, nil)
+	return text
+}
+
+// redacts one value; dropped reports whether a drop rule matched it
+func (r *Redactor) redact(value string, userID int, hits Hits) (string, bool) {
+	dropped := false
+	for _, rl := range r.rules {
+		count := 0
+		value = rl.re.ReplaceAllStringFunc(value, func(match string) string {
+			start, end := 0, len(match)
+			if rl.locate != nil {
+				var ok bool
+				if start, end, ok = rl.locate(match); !ok {
+					return match
+				}
+			}
+			count++
+			return match[:start] + r.replacement(rl, match[start:end], userID) + match[end:]
+		})
+		if count == 0 {
+			continue
+		}
+		if hits != nil {
+			hits[rl.key] += int64(count)
+		}
+		if rl.action == "drop" {
+			dropped = true
+		}
+	}
+	return value, dropped
+}
+
+// returns what a sensitive value is replaced with under the rule's action
+func (r *Redactor) replacement(rl rule, sensitive string, userID int) string {
+	switch rl.action {
+	case "hash":
+		mac := hmac.New(sha256.New, r.hashKey)
+		mac.Write([]byte(strconv.Itoa(userID) + ":" + sensitive))
+		return "[HASH:" + hex.EncodeToString(mac.Sum(nil)[:8]) + "]"
+	case "drop":
+		return ""
+	default:
+		return "[REDACTED:" + rl.name + "]"
+	}
+}
+
+// Registry caches each user's compiled redactor for the processor
+type Registry struct {
+	cache  *cache.PerUser[*Redactor]
+	logger *logrus.Logger
+}
+
+// creates a registry; load returns a user's active rules, defaults are applied to every user and
+// hashKey keys the hash action
+func NewRegistry(load func(userID int) ([]*models.RedactionRule, error), defaults []string, hashKey string, ttl time.Duration, logger *logrus.Logger) (*Registry, error) {
+	if _, err := Compile(nil, defaults, hashKey); err != nil {
+		return nil, err
+	}
+
+	return &Registry{
+		logger: logger,
+		cache: cache.NewPerUser(ttl, func(userID int) (*Redactor, error) {
+			rules, err := load(userID)
+			if err != nil {
+				return nil, err
+			}
+			return Compile(rules, defaults, hashKey)
+		}),
+	}, nil
+}
+
+// returns the user's redactor. If a reload fails the rules cached before are used; with nothing
+// cached the error is returned, since redacting with only the defaults could store the user's secrets
+func (r *Registry) get(userID int) (*Redactor, error) {
+	redactor, err := r.cache.Get(userID)
+	if err != nil {
+		if redactor == nil {
+			return nil, fmt.Errorf("failed to load redaction rules: %w", err)
+		}
+		r.logger.WithError(err).WithField("user_id", userID).Warn("Failed to reload redaction rules, using cached rules")
+	}
+	return redactor, nil
+}
+
+// Apply redacts the entry with its user's rules
+func (r *Registry) Apply(entry *models.LogEntry) (Hits, error) {
+	redactor, err := r.get(entry.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return redactor.Apply(entry), nil
+}
+
+// ApplyText redacts text with the user's rules
+func (r *Registry) ApplyText(userID int, text string) (string, error) {
+	redactor, err := r.get(userID)
+	if err != nil {
+		return "", err
+	}
+	return redactor.ApplyText(userID, text), nil
+}
@@ -0,0 +1,160 @@
+package redact
+
+import (
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+func compileRules(t *testing.T, hashKey string, rules ...*models.RedactionRule) *Redactor {
+	t.Helper()
+	r, err := Compile(rules, nil, hashKey)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	return r
+}
+
+var hashPattern = regexp.MustCompile(`^card \[HASH:[0-9a-f]{16}\] charged$`)
+
+func TestActions(t *testing.T) {
+	const text = "card 4111 1111 1111 1111 charged"
+	rule := func(action string) *models.RedactionRule {
+		return &models.RedactionRule{ID: 1, Name: "cards", Detector: "credit_card", Action: action}
+	}
+
+	if got := compileRules(t, "key", rule("mask")).ApplyText(1, text); got != "card [REDACTED:cards] charged" {
+		t.Errorf("mask: got %q", got)
+	}
+	if got := compileRules(t, "key", rule("drop")).ApplyText(1, text); got != "card  charged" {
+		t.Errorf("drop: got %q", got)
+	}
+
+	hashed := compileRules(t, "key", rule("hash")).ApplyText(1, text)
+	if !hashPattern.MatchString(hashed) {
+		t.Fatalf("hash: got %q, want an HMAC prefix", hashed)
+	}
+	// Hashes depend only on the digits found, the user and the key
+	if again := compileRules(t, "key", rule("hash")).ApplyText(1, "card 4111 1111 1111 1111 charged"); again != hashed {
+		t.Errorf("hash isn't stable: %q then %q", hashed, again)
+	}
+	if other := compileRules(t, "key", rule("hash")).ApplyText(2, text); other == hashed {
+		t.Error("two users got the same hash for one value")
+	}
+	if other := compileRules(t, "other-key", rule("hash")).ApplyText(1, text); other == hashed {
+		t.Error("two hash keys gave the same hash")
+	}
+	// Digits next to the card aren't part of what is hashed
+	if beside := compileRules(t, "key", rule("hash")).ApplyText(1, "card 4111 1111 1111 1111 123"); beside != strings.TrimSuffix(hashed, " charged")+" 123" {
+		t.Errorf("hash with trailing digits: got %q", beside)
+	}
+}
+
+func TestApply(t *testing.T) {
+	r := compileRules(t, "key",
+		&models.RedactionRule{ID: 1, Name: "emails", Detector: "email", Action: "mask"},
+		&models.RedactionRule{ID: 2, Name: "tokens", Detector: "custom", Pattern: `tok_[a-z0-9]+`, Action: "drop"},
+	)
+	entry := &models.LogEntry{
+		UserID:     1,
+		Message:    "mail a@example.com and b@example.com",
+		RawMessage: "raw mail a@example.com",
+		Fields:     map[string]string{"token": "tok_abc123", "owner": "c@example.com", "region": "eu"},
+	}
+
+	hits := r.Apply(entry)
+
+	if entry.Message != "mail [REDACTED:emails] and [REDACTED:emails]" {
+		t.Errorf("message = %q", entry.Message)
+	}
+	if entry.RawMessage != "raw mail [REDACTED:emails]" {
+		t.Errorf("raw message = %q", entry.RawMessage)
+	}
+	if _, ok := entry.Fields["token"]; ok {
+		t.Error("field matched by a drop rule was kept")
+	}
+	if entry.Fields["owner"] != "[REDACTED:emails]" || entry.Fields["region"] != "eu" {
+		t.Errorf("fields = %v", entry.Fields)
+	}
+	// The raw message repeats the message, so its matches aren't counted
+	if hits[RuleKey(1)] != 3 || hits[RuleKey(2)] != 1 || len(hits) != 2 {
+		t.Errorf("hits = %v, want 3 emails and 1 token", hits)
+	}
+}
+
+func TestDefaultsRunAfterUserRules(t *testing.T) {
+	r, err := Compile([]*models.RedactionRule{{ID: 5, Name: "mine", Detector: "ipv4", Action: "hash"}}, []string{"ipv4", "email"}, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := &models.LogEntry{UserID: 1, Message: "from 10.0.0.1 by x@example.com"}
+	hits := r.Apply(entry)
+
+	if !strings.HasPrefix(entry.Message, "from [HASH:") || !strings.HasSuffix(entry.Message, " by [REDACTED:email]") {
+		t.Errorf("message = %q, want the user's hash rule to win over the ipv4 default", entry.Message)
+	}
+	if hits[RuleKey(5)] != 1 || hits[DefaultRuleKey("email")] != 1 || hits[DefaultRuleKey("ipv4")] != 0 {
+		t.Errorf("hits = %v", hits)
+	}
+}
+
+func TestApplyWithoutRules(t *testing.T) {
+	r := compileRules(t, "key")
+	entry := &models.LogEntry{Message: "a@example.com"}
+	if hits := r.Apply(entry); hits != nil || entry.Message != "a@example.com" {
+		t.Errorf("got %v and %q, want the entry unchanged", hits, entry.Message)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	if _, err := Compile([]*models.RedactionRule{{ID: 1, Detector: "custom", Pattern: "(unclosed"}}, nil, "key"); err == nil {
+		t.Error("Compile accepted an invalid custom pattern")
+	}
+	if _, err := Compile([]*models.RedactionRule{{ID: 1, Detector: "ssn"}}, nil, "key"); err == nil {
+		t.Error("Compile accepted an unknown detector")
+	}
+	if _, err := Compile(nil, []string{"ssn"}, "key"); err == nil {
+		t.Error("Compile accepted an unknown default detector")
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	fail := false
+	load := func(userID int) ([]*models.RedactionRule, error) {
+		if fail {
+			return nil, errors.New("database unavailable")
+		}
+		return []*models.RedactionRule{{ID: 1, Name: "emails", Detector: "email", Action: "mask"}}, nil
+	}
+	registry, err := NewRegistry(load, nil, "key", time.Nanosecond, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if text, err := registry.ApplyText(1, "to a@example.com"); err != nil || text != "to [REDACTED:emails]" {
+		t.Fatalf("ApplyText = %q, %v", text, err)
+	}
+
+	// A failed reload keeps using the rules loaded before, but a user with nothing cached gets the error
+	fail = true
+	time.Sleep(time.Millisecond)
+	if text, err := registry.ApplyText(1, "to a@example.com"); err != nil || text != "to [REDACTED:emails]" {
+		t.Errorf("ApplyText with stale rules = %q, %v", text, err)
+	}
+	if _, err := registry.Apply(&models.LogEntry{UserID: 2, Message: "to a@example.com"}); err == nil {
+		t.Error("Apply succeeded without any rules loaded for the user")
+	}
+
+	if _, err := NewRegistry(load, []string{"ssn"}, "key", time.Minute, logger); err == nil {
+		t.Error("NewRegistry accepted an unknown default detector")
+	}
+}
@@ -0,0 +1,47 @@
+package selflog
+
+import (
+	"fmt"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/config"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/pkg/client"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/pkg/client/logrusadapter"
+	"github.com/sirupsen/logrus"
+)
+
+/*
+this file lets the ingester and processor ship their own logs to a LogBuilder server
+
+when LOG_FORWARD_URL is set, entries at LOG_FORWARD_LEVEL and above are sent there with LOG_FORWARD_API_KEY
+the level defaults to warn, since forwarding to this same ingester logs a line for every batch it receives
+*/
+
+// adds a forwarding hook to logger; the returned client is nil when forwarding is disabled
+// and otherwise has to be closed on shutdown to send what is still buffered
+func Forward(logger *logrus.Logger, cfg *config.Config, service string) (*client.Client, error) {
+	if cfg.LogForwardURL == "" {
+		return nil, nil
+	}
+
+	level, err := logrus.ParseLevel(cfg.LogForwardLevel)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOG_FORWARD_LEVEL: %w", err)
+	}
+
+	c, err := client.New(client.Config{
+		BaseURL: cfg.LogForwardURL,
+		APIKey:  cfg.LogForwardAPIKey,
+		Source:  "logbuilder",
+		Service: service,
+		OnError: func(err error) {
+			// The standard logger has no hook, so delivery problems can't feed back into the client
+			logrus.WithError(err).WithField("service", service).Warn("Failed to forward own logs")
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log forwarding client: %w", err)
+	}
+
+	logger.AddHook(logrusadapter.NewHook(c, level))
+	return c, nil
+}
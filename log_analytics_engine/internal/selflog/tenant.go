@@ -0,0 +1,183 @@
+package selflog
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/config"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/queue"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/storage"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/pkg/client"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/pkg/client/logrusadapter"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	tenantQueueSize     = 1000
+	tenantBatchSize     = 100
+	tenantFlushInterval = time.Second
+)
+
+// TenantHook stores a service's own log entries under the reserved tenant by publishing them
+// straight onto the stream, so they are processed and queryable like any other user's logs
+type TenantHook struct {
+	stream  queue.Queue
+	userID  int
+	service string
+	levels  []logrus.Level
+
+	queue     chan *models.LogEntry
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// adds a hook storing logger's entries under SELF_LOG_TENANT; returns nil when it is unset.
+// The hook has to be closed on shutdown to publish what is still queued
+func ForwardToTenant(logger *logrus.Logger, cfg *config.Config, service string, stream queue.Queue, authStorage *storage.AuthStorage) (*TenantHook, error) {
+	if cfg.SelfLogTenant == "" {
+		return nil, nil
+	}
+
+	level, err := logrus.ParseLevel(cfg.LogForwardLevel)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOG_FORWARD_LEVEL: %w", err)
+	}
+
+	userID, err := authStorage.EnsureSystemUser(cfg.SelfLogTenant)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SELF_LOG_TENANT: %w", err)
+	}
+
+	h := &TenantHook{
+		stream:  stream,
+		userID:  userID,
+		service: service,
+		levels:  levelsFrom(level),
+		queue:   make(chan *models.LogEntry, tenantQueueSize),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go h.run()
+
+	logger.AddHook(h)
+	return h, nil
+}
+
+// logrus levels count down in severity, so these are minLevel and everything more severe
+func levelsFrom(minLevel logrus.Level) []logrus.Level {
+	var levels []logrus.Level
+	for _, level := range logrus.AllLevels {
+		if level <= minLevel {
+			levels = append(levels, level)
+		}
+	}
+	return levels
+}
+
+// ID of the reserved tenant
+func (h *TenantHook) UserID() int {
+	return h.userID
+}
+
+func (h *TenantHook) Levels() []logrus.Level {
+	return h.levels
+}
+
+func (h *TenantHook) Fire(entry *logrus.Entry) error {
+	// An entry about one of the tenant's own logs (a failed insert, say) would feed back into itself
+	if userID, ok := entry.Data["user_id"]; ok && fmt.Sprint(userID) == strconv.Itoa(h.userID) {
+		return nil
+	}
+
+	now := time.Now()
+	level := logrusadapter.Level(entry.Level)
+	log := &models.LogEntry{
+		Timestamp:      entry.Time,
+		Source:         "logbuilder",
+		Level:          level,
+		SeverityNumber: models.SeverityNumber(level),
+		Message:        entry.Message,
+		Service:        h.service,
+		Fields:         make(map[string]string, len(entry.Data)),
+		CreatedAt:      now,
+		ReceivedAt:     now,
+		UserID:         h.userID,
+	}
+	for k, v := range entry.Data {
+		client.AddField(log.Fields, k, v)
+	}
+
+	// The process exits after fatal entries, so those are published right away
+	if entry.Level <= logrus.FatalLevel {
+		h.publish([]*models.LogEntry{log})
+		return nil
+	}
+
+	select {
+	case h.queue <- log:
+	default:
+		// Dropping is better than blocking the caller on its own logging
+	}
+	return nil
+}
+
+// stops the background publisher once everything queued so far was published
+func (h *TenantHook) Close(ctx context.Context) {
+	h.closeOnce.Do(func() { close(h.stop) })
+	select {
+	case <-h.done:
+	case <-ctx.Done():
+	}
+}
+
+func (h *TenantHook) run() {
+	defer close(h.done)
+
+	ticker := time.NewTicker(tenantFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*models.LogEntry, 0, tenantBatchSize)
+	send := func() {
+		if len(batch) > 0 {
+			h.publish(batch)
+			batch = make([]*models.LogEntry, 0, tenantBatchSize)
+		}
+	}
+
+	for {
+		select {
+		case log := <-h.queue:
+			batch = append(batch, log)
+			if len(batch) >= tenantBatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case <-h.stop:
+			for {
+				select {
+				case log := <-h.queue:
+					batch = append(batch, log)
+				default:
+					send()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (h *TenantHook) publish(logs []*models.LogEntry) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := h.stream.PublishLogs(ctx, logs); err != nil {
+		// The standard logger has no hook, so this can't feed back into the queue
+		logrus.WithError(err).WithField("service", h.service).Warn("Failed to publish own logs to the system tenant")
+	}
+}
@@ -45,6 +45,31 @@ func (s *AuthStorage) CreateUser(user *models.User) error {
 	return nil
 }
 
+// returns the ID of the reserved tenant for the services' own logs, creating it on first use.
+// It has no usable password, and a regular account that already took the username is refused
+func (s *AuthStorage) EnsureSystemUser(username string) (int, error) {
+	email := username + "@system.logbuilder.internal"
+
+	_, err := s.db.Exec(`
+        INSERT INTO users (username, email, password_hash, created_at, updated_at)
+        VALUES ($1, $2, '!', NOW(), NOW())
+        ON CONFLICT DO NOTHING
+    `, username, email)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create system user: %w", err)
+	}
+
+	var id int
+	err = s.db.QueryRow(`SELECT id FROM users WHERE username = $1 AND email = $2`, username, email).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("username %q is already used by a regular account", username)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get system user: %w", err)
+	}
+	return id, nil
+}
+
 func (s *AuthStorage) GetUserByUsername(username string) (*models.User, error) {
 	query := `
         SELECT id, username, email, password_hash, created_at, updated_at, is_active
@@ -129,6 +154,19 @@ func (s *AuthStorage) GetUserByID(id int) (*models.User, error) {
 	return user, nil
 }
 
+// reports whether the user may use the admin API
+func (s *AuthStorage) IsAdmin(userID int) (bool, error) {
+	var isAdmin bool
+	err := s.db.QueryRow(`SELECT is_admin FROM users WHERE id = $1 AND is_active = true`, userID).Scan(&isAdmin)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check admin status: %w", err)
+	}
+	return isAdmin, nil
+}
+
 // API Key management
 func (s *AuthStorage) CreateAPIKey(userID int, name string) (*models.APIKey, error) {
 	// Generate a secure API key
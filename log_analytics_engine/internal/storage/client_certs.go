@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+)
+
+// returned when a certificate is already registered, by any user
+var ErrCertificateExists = errors.New("certificate is already registered")
+
+type ClientCertStorage struct {
+	db *sql.DB
+}
+
+func NewClientCertStorage(db *sql.DB) *ClientCertStorage {
+	return &ClientCertStorage{db: db}
+}
+
+// registers a certificate for cert.UserID
+func (s *ClientCertStorage) CreateCertificate(cert *models.ClientCertificate) error {
+	query := `
+        INSERT INTO client_certificates (user_id, name, fingerprint, subject, not_after, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id
+    `
+
+	cert.CreatedAt = time.Now()
+	cert.IsActive = true
+	err := s.db.QueryRow(query, cert.UserID, cert.Name, cert.Fingerprint, cert.Subject, cert.NotAfter, cert.CreatedAt).Scan(&cert.ID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrCertificateExists
+		}
+		return fmt.Errorf("failed to register certificate: %w", err)
+	}
+	return nil
+}
+
+// returns the user's certificates, newest first
+func (s *ClientCertStorage) GetUserCertificates(userID int) ([]*models.ClientCertificate, error) {
+	query := `
+        SELECT id, user_id, name, fingerprint, subject, not_after, created_at, last_used_at, is_active
+        FROM client_certificates
+        WHERE user_id = $1
+        ORDER BY created_at DESC
+    `
+
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get certificates: %w", err)
+	}
+	defer rows.Close()
+
+	var certs []*models.ClientCertificate
+	for rows.Next() {
+		cert := &models.ClientCertificate{}
+		if err := rows.Scan(&cert.ID, &cert.UserID, &cert.Name, &cert.Fingerprint, &cert.Subject,
+			&cert.NotAfter, &cert.CreatedAt, &cert.LastUsedAt, &cert.IsActive); err != nil {
+			return nil, fmt.Errorf("failed to scan certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	return certs, rows.Err()
+}
+
+// deletes one of the user's certificates, returning its fingerprint so cached lookups can be dropped
+func (s *ClientCertStorage) DeleteCertificate(certID int, userID int) (string, error) {
+	var fingerprint string
+	err := s.db.QueryRow(`DELETE FROM client_certificates WHERE id = $1 AND user_id = $2 RETURNING fingerprint`, certID, userID).Scan(&fingerprint)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("certificate not found or not owned by user")
+		}
+		return "", fmt.Errorf("failed to delete certificate: %w", err)
+	}
+	return fingerprint, nil
+}
+
+// returns the active user owning the certificate with the given fingerprint
+func (s *ClientCertStorage) ValidateFingerprint(fingerprint string) (int, error) {
+	query := `
+        SELECT u.id
+        FROM users u
+        JOIN client_certificates c ON u.id = c.user_id
+        WHERE c.fingerprint = $1 AND c.is_active = true AND u.is_active = true
+    `
+
+	var userID int
+	if err := s.db.QueryRow(query, fingerprint).Scan(&userID); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("certificate is not registered")
+		}
+		return 0, fmt.Errorf("failed to validate certificate: %w", err)
+	}
+
+	// Update last_used_at timestamp
+	go s.updateLastUsed(fingerprint)
+
+	return userID, nil
+}
+
+func (s *ClientCertStorage) updateLastUsed(fingerprint string) {
+	query := `UPDATE client_certificates SET last_used_at = $1 WHERE fingerprint = $2`
+	s.db.Exec(query, time.Now(), fingerprint)
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// sorted set of processor consumer names scored by the unix time of their last heartbeat
+const processorHeartbeatsKey = "processors:heartbeats"
+
+// how often processors send a heartbeat, and how long one counts as alive
+const (
+	ProcessorHeartbeatInterval = 10 * time.Second
+	ProcessorHeartbeatMaxAge   = 30 * time.Second
+)
+
+// a processor that sent a heartbeat recently
+type ProcessorHeartbeat struct {
+	Name     string    `json:"name"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// records that the named processor is alive, forgetting processors silent for longer than maxAge
+func (r *RedisClient) ProcessorHeartbeat(ctx context.Context, name string, maxAge time.Duration) error {
+	now := time.Now()
+	pipe := r.client.Pipeline()
+	pipe.ZAdd(ctx, processorHeartbeatsKey, redis.Z{Score: float64(now.Unix()), Member: name})
+	pipe.ZRemRangeByScore(ctx, processorHeartbeatsKey, "-inf", "("+strconv.FormatInt(now.Add(-maxAge).Unix(), 10))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to record heartbeat: %w", err)
+	}
+	return nil
+}
+
+// stops reporting the named processor, for a clean shutdown
+func (r *RedisClient) RemoveProcessorHeartbeat(ctx context.Context, name string) error {
+	return r.client.ZRem(ctx, processorHeartbeatsKey, name).Err()
+}
+
+// returns the processors that sent a heartbeat within maxAge
+func (r *RedisClient) LiveProcessors(ctx context.Context, maxAge time.Duration) ([]ProcessorHeartbeat, error) {
+	min := strconv.FormatInt(time.Now().Add(-maxAge).Unix(), 10)
+	members, err := r.client.ZRangeByScoreWithScores(ctx, processorHeartbeatsKey, &redis.ZRangeBy{Min: min, Max: "+inf"}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get processor heartbeats: %w", err)
+	}
+
+	processors := make([]ProcessorHeartbeat, 0, len(members))
+	for _, member := range members {
+		name, _ := member.Member.(string)
+		processors = append(processors, ProcessorHeartbeat{Name: name, LastSeen: time.Unix(int64(member.Score), 0)})
+	}
+	return processors, nil
+}
+
+// checks the Redis connection
+func (r *RedisClient) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
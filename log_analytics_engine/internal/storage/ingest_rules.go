@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+)
+
+type IngestRuleStorage struct {
+	db *sql.DB
+}
+
+func NewIngestRuleStorage(db *sql.DB) *IngestRuleStorage {
+	return &IngestRuleStorage{db: db}
+}
+
+const ingestRuleColumns = `id, user_id, name, priority, match, action, sample_rate, set_level, set_service, is_active, created_at, updated_at`
+
+func (s *IngestRuleStorage) CreateRule(rule *models.IngestRule) error {
+	match, err := json.Marshal(rule.Match)
+	if err != nil {
+		return fmt.Errorf("failed to marshal match: %w", err)
+	}
+
+	query := `
+        INSERT INTO ingest_rules (user_id, name, priority, match, action, sample_rate, set_level, set_service, is_active, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+        RETURNING id
+    `
+
+	now := time.Now()
+	err = s.db.QueryRow(
+		query,
+		rule.UserID,
+		rule.Name,
+		rule.Priority,
+		match,
+		rule.Action,
+		rule.SampleRate,
+		rule.SetLevel,
+		rule.SetService,
+		rule.IsActive,
+		now,
+		now,
+	).Scan(&rule.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create ingest rule: %w", err)
+	}
+
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+	return nil
+}
+
+// returns all of the user's rules in evaluation order
+func (s *IngestRuleStorage) GetUserRules(userID int) ([]*models.IngestRule, error) {
+	return s.listRules(`SELECT `+ingestRuleColumns+` FROM ingest_rules WHERE user_id = $1 ORDER BY priority, id`, userID)
+}
+
+// returns the user's active rules in evaluation order
+func (s *IngestRuleStorage) GetActiveRules(userID int) ([]*models.IngestRule, error) {
+	return s.listRules(`SELECT `+ingestRuleColumns+` FROM ingest_rules WHERE user_id = $1 AND is_active = true ORDER BY priority, id`, userID)
+}
+
+func (s *IngestRuleStorage) listRules(query string, userID int) ([]*models.IngestRule, error) {
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ingest rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []*models.IngestRule
+	for rows.Next() {
+		rule := &models.IngestRule{}
+		var match []byte
+		err := rows.Scan(
+			&rule.ID,
+			&rule.UserID,
+			&rule.Name,
+			&rule.Priority,
+			&match,
+			&rule.Action,
+			&rule.SampleRate,
+			&rule.SetLevel,
+			&rule.SetService,
+			&rule.IsActive,
+			&rule.CreatedAt,
+			&rule.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan ingest rule: %w", err)
+		}
+		if err := json.Unmarshal(match, &rule.Match); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal match: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+func (s *IngestRuleStorage) UpdateRule(rule *models.IngestRule) error {
+	match, err := json.Marshal(rule.Match)
+	if err != nil {
+		return fmt.Errorf("failed to marshal match: %w", err)
+	}
+
+	query := `
+        UPDATE ingest_rules
+        SET name = $1, priority = $2, match = $3, action = $4, sample_rate = $5, set_level = $6,
+            set_service = $7, is_active = $8, updated_at = $9
+        WHERE id = $10 AND user_id = $11
+        RETURNING created_at
+    `
+
+	rule.UpdatedAt = time.Now()
+	err = s.db.QueryRow(
+		query,
+		rule.Name,
+		rule.Priority,
+		match,
+		rule.Action,
+		rule.SampleRate,
+		rule.SetLevel,
+		rule.SetService,
+		rule.IsActive,
+		rule.UpdatedAt,
+		rule.ID,
+		rule.UserID,
+	).Scan(&rule.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("ingest rule not found or not owned by user")
+		}
+		return fmt.Errorf("failed to update ingest rule: %w", err)
+	}
+
+	return nil
+}
+
+func (s *IngestRuleStorage) DeleteRule(id, userID int) error {
+	result, err := s.db.Exec(`DELETE FROM ingest_rules WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete ingest rule: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("ingest rule not found or not owned by user")
+	}
+
+	return nil
+}
+
+// returns the Redis hash holding a user's ingest rule counters, with fields "<rule>:matched" and "<rule>:dropped"
+func ingestRuleStatsKey(userID int) string {
+	return "ingestrules:stats:" + strconv.Itoa(userID)
+}
+
+// IncrIngestRuleStats adds matched and dropped counts for each rule
+func (r *RedisClient) IncrIngestRuleStats(ctx context.Context, userID int, matched, dropped map[int]int64) error {
+	if len(matched) == 0 {
+		return nil
+	}
+
+	key := ingestRuleStatsKey(userID)
+	pipe := r.client.Pipeline()
+	for ruleID, count := range matched {
+		pipe.HIncrBy(ctx, key, fmt.Sprintf("%d:matched", ruleID), count)
+	}
+	for ruleID, count := range dropped {
+		if count > 0 {
+			pipe.HIncrBy(ctx, key, fmt.Sprintf("%d:dropped", ruleID), count)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to count ingest rule stats: %w", err)
+	}
+	return nil
+}
+
+// GetIngestRuleStats returns matched and dropped counts keyed by rule ID
+func (r *RedisClient) GetIngestRuleStats(ctx context.Context, userID int) (matched, dropped map[int]int64, err error) {
+	values, err := r.client.HGetAll(ctx, ingestRuleStatsKey(userID)).Result()
+	if err != nil && err != redis.Nil {
+		return nil, nil, fmt.Errorf("failed to get ingest rule stats: %w", err)
+	}
+
+	matched = make(map[int]int64)
+	dropped = make(map[int]int64)
+	for field, value := range values {
+		id, kind, ok := strings.Cut(field, ":")
+		if !ok {
+			continue
+		}
+		ruleID, err := strconv.Atoi(id)
+		if err != nil {
+			continue
+		}
+		count, _ := strconv.ParseInt(value, 10, 64)
+		switch kind {
+		case "matched":
+			matched[ruleID] = count
+		case "dropped":
+			dropped[ruleID] = count
+		}
+	}
+	return matched, dropped, nil
+}
+
+// ResetIngestRuleStats clears the counters of one rule
+func (r *RedisClient) ResetIngestRuleStats(ctx context.Context, userID, ruleID int) error {
+	key := ingestRuleStatsKey(userID)
+	if err := r.client.HDel(ctx, key, fmt.Sprintf("%d:matched", ruleID), fmt.Sprintf("%d:dropped", ruleID)).Err(); err != nil {
+		return fmt.Errorf("failed to reset ingest rule stats: %w", err)
+	}
+	return nil
+}
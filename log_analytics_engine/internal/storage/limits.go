@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+)
+
+type LimitsStorage struct {
+	db *sql.DB
+}
+
+func NewLimitsStorage(db *sql.DB) *LimitsStorage {
+	return &LimitsStorage{db: db}
+}
+
+// returns the per-user limit override, or nil if the user has none
+func (s *LimitsStorage) GetIngestLimits(userID int) (*models.IngestLimits, error) {
+	query := `
+        SELECT user_id, events_per_second, bytes_per_second, key_events_per_second, key_bytes_per_second,
+               daily_event_quota, daily_byte_quota, updated_at
+        FROM ingest_limits
+        WHERE user_id = $1
+    `
+
+	limits := &models.IngestLimits{}
+	err := s.db.QueryRow(query, userID).Scan(
+		&limits.UserID,
+		&limits.EventsPerSecond,
+		&limits.BytesPerSecond,
+		&limits.KeyEventsPerSecond,
+		&limits.KeyBytesPerSecond,
+		&limits.DailyEventQuota,
+		&limits.DailyByteQuota,
+		&limits.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get ingest limits: %w", err)
+	}
+
+	return limits, nil
+}
+
+// creates or replaces the limit override for a user
+func (s *LimitsStorage) UpsertIngestLimits(limits *models.IngestLimits) error {
+	query := `
+        INSERT INTO ingest_limits (user_id, events_per_second, bytes_per_second, key_events_per_second,
+                                   key_bytes_per_second, daily_event_quota, daily_byte_quota, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        ON CONFLICT (user_id) DO UPDATE SET
+            events_per_second = EXCLUDED.events_per_second,
+            bytes_per_second = EXCLUDED.bytes_per_second,
+            key_events_per_second = EXCLUDED.key_events_per_second,
+            key_bytes_per_second = EXCLUDED.key_bytes_per_second,
+            daily_event_quota = EXCLUDED.daily_event_quota,
+            daily_byte_quota = EXCLUDED.daily_byte_quota,
+            updated_at = EXCLUDED.updated_at
+    `
+
+	if limits.UpdatedAt.IsZero() {
+		limits.UpdatedAt = time.Now()
+	}
+
+	_, err := s.db.Exec(
+		query,
+		limits.UserID,
+		limits.EventsPerSecond,
+		limits.BytesPerSecond,
+		limits.KeyEventsPerSecond,
+		limits.KeyBytesPerSecond,
+		limits.DailyEventQuota,
+		limits.DailyByteQuota,
+		limits.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save ingest limits: %w", err)
+	}
+
+	return nil
+}
+
+// removes the limit override so the user falls back to the defaults
+func (s *LimitsStorage) DeleteIngestLimits(userID int) error {
+	_, err := s.db.Exec(`DELETE FROM ingest_limits WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete ingest limits: %w", err)
+	}
+	return nil
+}
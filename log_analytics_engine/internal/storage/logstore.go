@@ -0,0 +1,332 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+)
+
+/*
+this file defines LogStore, the log storage used by the handlers and the processor, and compiles its
+structured queries to parameterized SQL for the Postgres and SQLite implementations
+*/
+
+// LogStore stores logs and answers structured queries scoped to one user
+type LogStore interface {
+	InsertLog(log *models.LogEntry) error // returns ErrDuplicateEvent for a repeated event_id
+	InsertLogs(logs []*models.LogEntry) error
+	QueryLogs(q *models.LogQuery) ([]*models.LogEntry, error)
+	// like QueryLogs for a query with a Search, also returning how each log matched
+	SearchLogs(q *models.LogQuery) ([]*models.LogEntry, []models.SearchMatch, error)
+	CountLogs(q *models.LogQuery) (int, error)
+	DeleteLogs(q *models.LogQuery) (int, error)
+	AggregateLogs(q *models.AggregateQuery) ([]models.AggregateBucket, error)
+	// sets how long event_ids are remembered for deduplication, 0 for ever
+	SetDedupWindow(window time.Duration)
+	// deletes event_ids older than the dedup window, returning how many were removed
+	PruneEventIDs(ctx context.Context) (int64, error)
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// returned by SearchLogs when the query sorts by relevance and the log store can't rank matches
+var ErrRelevanceUnsupported = errors.New("sort_by relevance isn't supported by this log store")
+
+var (
+	_ LogStore = (*PostgresStorage)(nil)
+	_ LogStore = (*SQLiteStorage)(nil)
+)
+
+// returns the log store named by kind: "postgres" stores logs alongside everything else in pg,
+// "sqlite" opens an embedded database at sqlitePath
+func OpenLogStore(kind, sqlitePath string, pg *PostgresStorage) (LogStore, error) {
+	switch kind {
+	case "postgres":
+		return pg, nil
+	case "sqlite":
+		return NewSQLiteStorage(sqlitePath)
+	}
+	return nil, fmt.Errorf("unknown log store %q", kind)
+}
+
+// the SQL differences between log stores
+type sqlDialect struct {
+	placeholder func(n int) string                                   // n-th bind parameter, from 1
+	containsOp  string                                               // case-insensitive LIKE operator
+	timeArg     func(t time.Time) interface{}                        // bind value for a timestamp column
+	timeBucket  func(column, width string) string                    // unix seconds starting the width-second bucket holding column
+	search      func(b *queryBuilder, q *models.SearchQuery) string  // condition on message matching a full-text search
+	regex       func(column, pattern string, ignoreCase bool) string // condition on column matching the pattern placeholder
+	fieldKey    func(b *queryBuilder, key string) string             // text value of a custom field, NULL when unset
+}
+
+// compiles LogQuery filters, accumulating bind arguments
+type queryBuilder struct {
+	dialect sqlDialect
+	args    []interface{}
+}
+
+// adds a bind argument and returns its placeholder
+func (b *queryBuilder) arg(value interface{}) string {
+	b.args = append(b.args, value)
+	return b.dialect.placeholder(len(b.args))
+}
+
+// returns the WHERE condition selecting the query's logs
+func (b *queryBuilder) where(q *models.LogQuery) (string, error) {
+	if err := models.ValidateFilter(q.Filter); err != nil {
+		return "", err
+	}
+
+	condition := "user_id = " + b.arg(q.UserID)
+	if q.Filter != nil {
+		filter, err := b.filter(q.Filter)
+		if err != nil {
+			return "", err
+		}
+		condition += " AND " + filter
+	}
+	if q.Search != nil {
+		condition += " AND " + b.dialect.search(b, q.Search)
+	}
+	return condition, nil
+}
+
+func (b *queryBuilder) filter(f *models.Filter) (string, error) {
+	switch f.Op {
+	case models.FilterAnd, models.FilterOr:
+		parts := make([]string, len(f.Children))
+		for i, child := range f.Children {
+			part, err := b.filter(child)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = part
+		}
+		return "(" + strings.Join(parts, " "+strings.ToUpper(f.Op)+" ") + ")", nil
+	case models.FilterNot:
+		part, err := b.filter(f.Children[0])
+		if err != nil {
+			return "", err
+		}
+		return "NOT " + part, nil
+	}
+
+	column := f.Field
+	if key, ok := models.IsFieldKey(f.Field); ok {
+		column = b.dialect.fieldKey(b, key)
+	}
+	value := func(v interface{}) string {
+		if t, ok := v.(time.Time); ok && (column == models.FieldTimestamp || column == models.FieldReceivedAt) {
+			return b.arg(b.dialect.timeArg(t))
+		}
+		return b.arg(v)
+	}
+
+	switch f.Op {
+	case models.FilterEq:
+		return column + " = " + value(f.Values[0]), nil
+	case models.FilterNe:
+		return column + " != " + value(f.Values[0]), nil
+	case models.FilterGte:
+		return column + " >= " + value(f.Values[0]), nil
+	case models.FilterLte:
+		return column + " <= " + value(f.Values[0]), nil
+	case models.FilterIn, models.FilterNotIn:
+		placeholders := make([]string, len(f.Values))
+		for i, v := range f.Values {
+			placeholders[i] = value(v)
+		}
+		op := " IN "
+		if f.Op == models.FilterNotIn {
+			op = " NOT IN "
+		}
+		return column + op + "(" + strings.Join(placeholders, ", ") + ")", nil
+	case models.FilterContains, models.FilterNotContains:
+		op := " " + b.dialect.containsOp + " "
+		if f.Op == models.FilterNotContains {
+			op = " NOT " + b.dialect.containsOp + " "
+		}
+		return column + op + b.arg("%"+likeEscaper.Replace(fmt.Sprint(f.Values[0]))+"%") + ` ESCAPE '\'`, nil
+	case models.FilterStartsWith:
+		// Unlike LIKE, case-sensitive in both stores and free of wildcards to escape
+		prefix := b.arg(f.Values[0])
+		return fmt.Sprintf("substr(%s, 1, length(%s)) = %s", column, prefix, prefix), nil
+	case models.FilterRegex, models.FilterIRegex:
+		return b.dialect.regex(column, b.arg(f.Values[0]), f.Op == models.FilterIRegex), nil
+	case models.FilterNotRegex, models.FilterNotIRegex:
+		return "NOT " + b.dialect.regex(column, b.arg(f.Values[0]), f.Op == models.FilterNotIRegex), nil
+	}
+	return "", fmt.Errorf("unknown filter operator %q", f.Op)
+}
+
+// escapes LIKE wildcards so contains filters match them literally, with ESCAPE '\'
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// returns the ORDER BY clause for the query, defaulting to newest first; log stores that rank
+// searches order by relevance themselves
+func orderBy(q *models.LogQuery) (string, error) {
+	column := q.SortBy
+	if column == "" || column == models.SortRelevance {
+		column = models.FieldTimestamp
+	}
+	valid := false
+	for _, candidate := range models.SortColumns {
+		valid = valid || candidate == column
+	}
+	if !valid {
+		return "", fmt.Errorf("invalid sort column %q", column)
+	}
+
+	order := strings.ToUpper(q.SortOrder)
+	if order == "" || q.SortBy == models.SortRelevance {
+		order = "DESC"
+	}
+	if order != "ASC" && order != "DESC" {
+		return "", fmt.Errorf("invalid sort order %q", q.SortOrder)
+	}
+	// id breaks ties so pages don't overlap
+	return fmt.Sprintf("%s %s, id %s", column, order, order), nil
+}
+
+// builds the SELECT for an aggregation; the result columns are the bucket start (when an interval is set),
+// the group columns in order, then the count
+func (b *queryBuilder) aggregate(q *models.AggregateQuery) (string, error) {
+	if err := models.ValidateGroupBy(q.GroupBy); err != nil {
+		return "", err
+	}
+	where, err := b.where(&q.LogQuery)
+	if err != nil {
+		return "", err
+	}
+
+	var keys []string
+	if q.Interval > 0 {
+		seconds := int64(q.Interval / time.Second)
+		if seconds < 1 {
+			return "", fmt.Errorf("aggregation interval must be at least 1s")
+		}
+		keys = append(keys, b.dialect.timeBucket(models.FieldTimestamp, b.arg(seconds)))
+	}
+	for _, column := range q.GroupBy {
+		keys = append(keys, "COALESCE("+column+", '')")
+	}
+	if len(keys) == 0 {
+		return "", fmt.Errorf("aggregation needs group columns or an interval")
+	}
+
+	positions := make([]string, len(keys))
+	for i := range keys {
+		positions[i] = fmt.Sprint(i + 1)
+	}
+	order := strings.Join(positions, ", ")
+	if q.Interval == 0 {
+		order = fmt.Sprintf("%d DESC", len(keys)+1)
+	}
+
+	query := fmt.Sprintf(`SELECT %s, COUNT(*) FROM logs WHERE %s GROUP BY %s ORDER BY %s`,
+		strings.Join(keys, ", "), where, strings.Join(positions, ", "), order)
+	if q.Limit > 0 {
+		query += " LIMIT " + b.arg(q.Limit)
+	}
+	return query, nil
+}
+
+// reads aggregation rows produced by queryBuilder.aggregate
+func scanAggregateRows(rows *sql.Rows, q *models.AggregateQuery) ([]models.AggregateBucket, error) {
+	buckets := []models.AggregateBucket{}
+	for rows.Next() {
+		var bucketStart int64
+		groups := make([]string, len(q.GroupBy))
+		dest := make([]interface{}, 0, len(groups)+2)
+		if q.Interval > 0 {
+			dest = append(dest, &bucketStart)
+		}
+		for i := range groups {
+			dest = append(dest, &groups[i])
+		}
+		var bucket models.AggregateBucket
+		dest = append(dest, &bucket.Count)
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan aggregate row: %w", err)
+		}
+		if q.Interval > 0 {
+			start := time.Unix(bucketStart, 0).UTC()
+			bucket.Time = &start
+		}
+		if len(groups) > 0 {
+			bucket.Group = make(map[string]string, len(groups))
+			for i, column := range q.GroupBy {
+				bucket.Group[column] = groups[i]
+			}
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets, rows.Err()
+}
+
+// snippets longer than this many bytes are cut to a window around the first match
+const maxSnippetLength = 300
+
+// returns the message HTML-escaped with the search's words wrapped in <mark></mark>, for log stores
+// that can't highlight matches themselves
+func highlight(message string, q *models.SearchQuery) string {
+	var patterns []string
+	for _, term := range q.Terms() {
+		words := make([]string, len(term.Words))
+		for i, word := range term.Words {
+			words[i] = regexp.QuoteMeta(word)
+		}
+		pattern := strings.Join(words, `\s+`)
+		if term.Prefix {
+			pattern += `\w*`
+		}
+		patterns = append(patterns, pattern)
+	}
+	var spans [][]int
+	if len(patterns) > 0 {
+		spans = regexp.MustCompile(`(?i)`+strings.Join(patterns, "|")).FindAllStringIndex(message, -1)
+	}
+
+	start, end := 0, len(message)
+	if end > maxSnippetLength {
+		if len(spans) > 0 {
+			start = max(0, spans[0][0]-maxSnippetLength/3)
+		}
+		end = min(len(message), start+maxSnippetLength)
+		for start > 0 && !utf8.RuneStart(message[start]) {
+			start--
+		}
+		for end < len(message) && !utf8.RuneStart(message[end]) {
+			end--
+		}
+	}
+
+	var snippet strings.Builder
+	if start > 0 {
+		snippet.WriteString("…")
+	}
+	pos := start
+	for _, span := range spans {
+		if span[0] < pos || span[1] > end {
+			continue
+		}
+		snippet.WriteString(html.EscapeString(message[pos:span[0]]))
+		snippet.WriteString("<mark>" + html.EscapeString(message[span[0]:span[1]]) + "</mark>")
+		pos = span[1]
+	}
+	snippet.WriteString(html.EscapeString(message[pos:end]))
+	if end < len(message) {
+		snippet.WriteString("…")
+	}
+	return snippet.String()
+}
@@ -0,0 +1,678 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+)
+
+// base time of the test logs, which testLog offsets by whole minutes
+var testEpoch = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// opens an in-memory SQLite store, closed when the test ends
+func newTestStore(t *testing.T) *SQLiteStorage {
+	t.Helper()
+	store, err := NewSQLiteStorage(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open SQLite store: %v", err)
+	}
+	store.logger.SetOutput(io.Discard)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func testLog(userID int, minute int, level, source, service, message string) *models.LogEntry {
+	ts := testEpoch.Add(time.Duration(minute) * time.Minute)
+	return &models.LogEntry{
+		Timestamp:      ts,
+		Source:         source,
+		Level:          level,
+		SeverityNumber: models.SeverityNumber(level),
+		Message:        message,
+		Service:        service,
+		CreatedAt:      ts,
+		ReceivedAt:     ts,
+		UserID:         userID,
+	}
+}
+
+// the logs most tests query: five for user 1, one for user 2 that must never show up in user 1's results
+func seedLogs(t *testing.T, store LogStore) []*models.LogEntry {
+	t.Helper()
+	logs := []*models.LogEntry{
+		testLog(1, 0, "DEBUG", "web", "api", "cache warmed"),
+		testLog(1, 1, "INFO", "web", "api", "request served in 12ms"),
+		testLog(1, 2, "WARN", "worker", "billing", "retrying payment job"),
+		testLog(1, 3, "ERROR", "worker", "billing", "Payment declined: card expired"),
+		testLog(1, 4, "FATAL", "db", "", "out of disk space"),
+		testLog(2, 2, "ERROR", "web", "api", "other user's payment error"),
+	}
+	logs[1].Fields = map[string]string{"region": "us-east-1", "status": "200"}
+	logs[3].Fields = map[string]string{"region": "eu-west-1", "status": "402"}
+	if err := store.InsertLogs(logs); err != nil {
+		t.Fatalf("failed to insert logs: %v", err)
+	}
+	return logs
+}
+
+// returns the messages of logs, in order
+func messages(logs []*models.LogEntry) []string {
+	result := make([]string, len(logs))
+	for i, log := range logs {
+		result[i] = log.Message
+	}
+	return result
+}
+
+func equalStrings(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// runs a filter against the seeded logs of user 1 and checks the messages, oldest first
+func checkFilter(t *testing.T, store LogStore, filter *models.Filter, want ...string) {
+	t.Helper()
+	logs, err := store.QueryLogs(&models.LogQuery{UserID: 1, Filter: filter, SortOrder: "ASC"})
+	if err != nil {
+		t.Fatalf("QueryLogs failed: %v", err)
+	}
+	if got := messages(logs); !equalStrings(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestQueryLogsRoundTrip(t *testing.T) {
+	store := newTestStore(t)
+	seeded := seedLogs(t, store)
+
+	logs, err := store.QueryLogs(&models.LogQuery{UserID: 1, Filter: models.Compare(models.FieldLevel, models.FilterEq, "ERROR")})
+	if err != nil {
+		t.Fatalf("QueryLogs failed: %v", err)
+	}
+	if len(logs) != 1 {
+		t.Fatalf("got %d logs, want 1", len(logs))
+	}
+	got, want := logs[0], seeded[3]
+	if got.ID != want.ID || !got.Timestamp.Equal(want.Timestamp) || got.Level != want.Level ||
+		got.SeverityNumber != models.SeverityError || got.Source != want.Source || got.Service != want.Service ||
+		got.Message != want.Message || got.Fields["region"] != "eu-west-1" || got.UserID != 1 {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestQueryLogsFilters(t *testing.T) {
+	store := newTestStore(t)
+	seedLogs(t, store)
+
+	tests := []struct {
+		name   string
+		filter *models.Filter
+		want   []string
+	}{
+		{"all", nil, []string{"cache warmed", "request served in 12ms", "retrying payment job", "Payment declined: card expired", "out of disk space"}},
+		{"eq", models.Compare(models.FieldLevel, models.FilterEq, "WARN"), []string{"retrying payment job"}},
+		{"ne", models.Compare(models.FieldSource, models.FilterNe, "worker"), []string{"cache warmed", "request served in 12ms", "out of disk space"}},
+		{"in", models.Compare(models.FieldLevel, models.FilterIn, "DEBUG", "FATAL"), []string{"cache warmed", "out of disk space"}},
+		{"not in", models.Compare(models.FieldSource, models.FilterNotIn, "web", "worker"), []string{"out of disk space"}},
+		{"severity at least", models.Compare(models.FieldSeverityNumber, models.FilterGte, models.SeverityError), []string{"Payment declined: card expired", "out of disk space"}},
+		{"severity at most", models.Compare(models.FieldSeverityNumber, models.FilterLte, models.SeverityDebug), []string{"cache warmed"}},
+		{"time range", models.And(
+			models.Compare(models.FieldTimestamp, models.FilterGte, testEpoch.Add(time.Minute)),
+			models.Compare(models.FieldTimestamp, models.FilterLte, testEpoch.Add(2*time.Minute)),
+		), []string{"request served in 12ms", "retrying payment job"}},
+		{"contains ignores case", models.Compare(models.FieldMessage, models.FilterContains, "PAYMENT"), []string{"retrying payment job", "Payment declined: card expired"}},
+		{"not contains", models.Compare(models.FieldMessage, models.FilterNotContains, "payment"), []string{"cache warmed", "request served in 12ms", "out of disk space"}},
+		{"or", models.Or(
+			models.Compare(models.FieldLevel, models.FilterEq, "DEBUG"),
+			models.Compare(models.FieldService, models.FilterEq, "billing"),
+		), []string{"cache warmed", "retrying payment job", "Payment declined: card expired"}},
+		{"not", models.Not(models.Compare(models.FieldService, models.FilterEq, "api")), []string{"retrying payment job", "Payment declined: card expired", "out of disk space"}},
+		{"no match", models.Compare(models.FieldLevel, models.FilterEq, "TRACE"), nil},
+		{"starts with", models.Compare(models.FieldMessage, models.FilterStartsWith, "re"), []string{"request served in 12ms", "retrying payment job"}},
+		{"starts with is case-sensitive", models.Compare(models.FieldMessage, models.FilterStartsWith, "payment"), nil},
+		{"starts with the whole value", models.Compare(models.FieldSource, models.FilterStartsWith, "db"), []string{"out of disk space"}},
+		{"starts with longer than the value", models.Compare(models.FieldSource, models.FilterStartsWith, "dbx"), nil},
+		{"starts with a custom field", models.Compare("fields.region", models.FilterStartsWith, "eu-"), []string{"Payment declined: card expired"}},
+		{"regex", models.Compare(models.FieldMessage, models.FilterRegex, `^(cache|out) `), []string{"cache warmed", "out of disk space"}},
+		{"regex is case-sensitive", models.Compare(models.FieldMessage, models.FilterRegex, "^payment"), nil},
+		{"regex with a leading (?i)", models.Compare(models.FieldMessage, models.FilterRegex, "(?i)^payment"), []string{"Payment declined: card expired"}},
+		{"iregex", models.Compare(models.FieldMessage, models.FilterIRegex, "PAYMENT (JOB|DECLINED)"), []string{"retrying payment job", "Payment declined: card expired"}},
+		{"not regex", models.Compare(models.FieldMessage, models.FilterNotRegex, `\d+ms$|^P`), []string{"cache warmed", "retrying payment job", "out of disk space"}},
+		{"not iregex", models.Compare(models.FieldSource, models.FilterNotIRegex, "^W"), []string{"out of disk space"}},
+		{"regex on a custom field", models.Compare("fields.status", models.FilterRegex, `^4\d\d$`), []string{"Payment declined: card expired"}},
+		{"not regex skips logs without the field", models.Compare("fields.status", models.FilterNotRegex, "^4"), []string{"request served in 12ms"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkFilter(t, store, tt.filter, tt.want...)
+		})
+	}
+}
+
+func TestQueryLogsRejectsInvalidFilters(t *testing.T) {
+	store := newTestStore(t)
+
+	for _, filter := range []*models.Filter{
+		models.Compare("password", models.FilterEq, "x"),
+		models.Compare(models.FieldLevel, "like", "x"),
+		models.Compare(models.FieldLevel, models.FilterEq),
+		{Op: models.FilterAnd},
+		models.Compare(models.FieldTimestamp, models.FilterContains, "2024"),
+		models.Compare(models.FieldMessage, models.FilterRegex, `\bpayment`),
+		models.Compare(models.FieldMessage, models.FilterIRegex, "(payment"),
+		models.Compare(models.FieldSeverityNumber, models.FilterStartsWith, "1"),
+		models.And(models.Compare(models.FieldLevel, models.FilterEq, "INFO"), &models.Filter{Op: models.FilterOr}),
+		deepFilter(12),
+	} {
+		if _, err := store.QueryLogs(&models.LogQuery{UserID: 1, Filter: filter}); err == nil {
+			t.Errorf("QueryLogs accepted filter %+v", filter)
+		}
+	}
+}
+
+// nests a comparison under depth nots
+func deepFilter(depth int) *models.Filter {
+	filter := models.Compare(models.FieldSource, models.FilterEq, "web")
+	for i := 0; i < depth; i++ {
+		filter = models.Not(filter)
+	}
+	return filter
+}
+
+func TestQueryLogsSortAndPaging(t *testing.T) {
+	store := newTestStore(t)
+
+	// Every log has the same timestamp, so only the id tie-break keeps pages apart
+	var logs []*models.LogEntry
+	for i := 0; i < 7; i++ {
+		logs = append(logs, testLog(1, 0, "INFO", "web", "api", string(rune('a'+i))))
+	}
+	if err := store.InsertLogs(logs); err != nil {
+		t.Fatalf("failed to insert logs: %v", err)
+	}
+
+	for _, order := range []string{"ASC", "DESC"} {
+		var paged []string
+		for offset := 0; offset < len(logs); offset += 3 {
+			page, err := store.QueryLogs(&models.LogQuery{UserID: 1, SortOrder: order, Limit: 3, Offset: offset})
+			if err != nil {
+				t.Fatalf("QueryLogs failed: %v", err)
+			}
+			paged = append(paged, messages(page)...)
+		}
+
+		want := []string{"a", "b", "c", "d", "e", "f", "g"}
+		if order == "DESC" {
+			want = []string{"g", "f", "e", "d", "c", "b", "a"}
+		}
+		if !equalStrings(paged, want) {
+			t.Errorf("%s pages returned %q, want %q", order, paged, want)
+		}
+	}
+
+	if _, err := store.QueryLogs(&models.LogQuery{UserID: 1, SortBy: "message"}); err == nil {
+		t.Error("QueryLogs accepted an unknown sort column")
+	}
+	if _, err := store.QueryLogs(&models.LogQuery{UserID: 1, SortOrder: "sideways"}); err == nil {
+		t.Error("QueryLogs accepted an unknown sort order")
+	}
+}
+
+func TestQueryLogsSortColumns(t *testing.T) {
+	store := newTestStore(t)
+	seedLogs(t, store)
+
+	logs, err := store.QueryLogs(&models.LogQuery{UserID: 1, SortBy: models.FieldSeverityNumber, SortOrder: "DESC", Limit: 2})
+	if err != nil {
+		t.Fatalf("QueryLogs failed: %v", err)
+	}
+	if got, want := messages(logs), []string{"out of disk space", "Payment declined: card expired"}; !equalStrings(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	logs, err = store.QueryLogs(&models.LogQuery{UserID: 1, SortBy: models.FieldSource, SortOrder: "ASC", Limit: 1})
+	if err != nil {
+		t.Fatalf("QueryLogs failed: %v", err)
+	}
+	if got, want := messages(logs), []string{"out of disk space"}; !equalStrings(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCountLogs(t *testing.T) {
+	store := newTestStore(t)
+	seedLogs(t, store)
+
+	tests := []struct {
+		userID int
+		filter *models.Filter
+		want   int
+	}{
+		{1, nil, 5},
+		{2, nil, 1},
+		{3, nil, 0},
+		{1, models.Compare(models.FieldSource, models.FilterEq, "worker"), 2},
+		{2, models.Compare(models.FieldSource, models.FilterEq, "worker"), 0},
+	}
+	for _, tt := range tests {
+		count, err := store.CountLogs(&models.LogQuery{UserID: tt.userID, Filter: tt.filter, Limit: 1})
+		if err != nil {
+			t.Fatalf("CountLogs failed: %v", err)
+		}
+		if count != tt.want {
+			t.Errorf("user %d, filter %+v: counted %d, want %d", tt.userID, tt.filter, count, tt.want)
+		}
+	}
+}
+
+func TestDeleteLogs(t *testing.T) {
+	store := newTestStore(t)
+	seedLogs(t, store)
+
+	// User 2's api log matches too, but isn't theirs to delete
+	deleted, err := store.DeleteLogs(&models.LogQuery{UserID: 1, Filter: models.Compare(models.FieldService, models.FilterEq, "api")})
+	if err != nil {
+		t.Fatalf("DeleteLogs failed: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("deleted %d logs, want 2", deleted)
+	}
+
+	checkFilter(t, store, nil, "retrying payment job", "Payment declined: card expired", "out of disk space")
+	if count, _ := store.CountLogs(&models.LogQuery{UserID: 2}); count != 1 {
+		t.Errorf("user 2 has %d logs left, want 1", count)
+	}
+}
+
+func TestAggregateLogs(t *testing.T) {
+	store := newTestStore(t)
+	seedLogs(t, store)
+
+	buckets, err := store.AggregateLogs(&models.AggregateQuery{
+		LogQuery: models.LogQuery{UserID: 1},
+		GroupBy:  []string{models.FieldSource},
+	})
+	if err != nil {
+		t.Fatalf("AggregateLogs failed: %v", err)
+	}
+	// Largest group first
+	if len(buckets) != 3 || buckets[0].Count != 2 || buckets[0].Time != nil {
+		t.Fatalf("got %+v, want 3 groups led by a count of 2", buckets)
+	}
+	counts := map[string]int64{}
+	for _, bucket := range buckets {
+		counts[bucket.Group[models.FieldSource]] = bucket.Count
+	}
+	if counts["web"] != 2 || counts["worker"] != 2 || counts["db"] != 1 {
+		t.Errorf("got counts %v, want web 2, worker 2, db 1", counts)
+	}
+
+	// Empty services are grouped as ""
+	buckets, err = store.AggregateLogs(&models.AggregateQuery{
+		LogQuery: models.LogQuery{UserID: 1, Filter: models.Compare(models.FieldLevel, models.FilterEq, "FATAL")},
+		GroupBy:  []string{models.FieldService},
+	})
+	if err != nil {
+		t.Fatalf("AggregateLogs failed: %v", err)
+	}
+	if len(buckets) != 1 || buckets[0].Group[models.FieldService] != "" || buckets[0].Count != 1 {
+		t.Errorf("got %+v, want one empty service group", buckets)
+	}
+}
+
+func TestAggregateLogsByInterval(t *testing.T) {
+	store := newTestStore(t)
+	seedLogs(t, store)
+
+	buckets, err := store.AggregateLogs(&models.AggregateQuery{
+		LogQuery: models.LogQuery{UserID: 1},
+		GroupBy:  []string{models.FieldLevel},
+		Interval: 2 * time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("AggregateLogs failed: %v", err)
+	}
+
+	type key struct {
+		start time.Time
+		level string
+	}
+	got := map[key]int64{}
+	for _, bucket := range buckets {
+		if bucket.Time == nil {
+			t.Fatalf("bucket %+v has no time", bucket)
+		}
+		got[key{*bucket.Time, bucket.Group[models.FieldLevel]}] = bucket.Count
+	}
+	want := map[key]int64{
+		{testEpoch, "DEBUG"}:                      1,
+		{testEpoch, "INFO"}:                       1,
+		{testEpoch.Add(2 * time.Minute), "WARN"}:  1,
+		{testEpoch.Add(2 * time.Minute), "ERROR"}: 1,
+		{testEpoch.Add(4 * time.Minute), "FATAL"}: 1,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got buckets %v, want %v", got, want)
+	}
+	for k, count := range want {
+		if got[k] != count {
+			t.Errorf("bucket %v counted %d, want %d", k, got[k], count)
+		}
+	}
+
+	if _, err := store.AggregateLogs(&models.AggregateQuery{LogQuery: models.LogQuery{UserID: 1}}); err == nil {
+		t.Error("AggregateLogs accepted a query without groups or an interval")
+	}
+	if _, err := store.AggregateLogs(&models.AggregateQuery{LogQuery: models.LogQuery{UserID: 1}, GroupBy: []string{models.FieldMessage}}); err == nil {
+		t.Error("AggregateLogs accepted grouping by message")
+	}
+}
+
+func TestInsertLogDeduplicatesEventIDs(t *testing.T) {
+	store := newTestStore(t)
+
+	first := testLog(1, 0, "INFO", "web", "api", "first")
+	first.EventID = "evt-1"
+	if err := store.InsertLog(first); err != nil {
+		t.Fatalf("InsertLog failed: %v", err)
+	}
+
+	retry := testLog(1, 1, "INFO", "web", "api", "retry")
+	retry.EventID = "evt-1"
+	if err := store.InsertLog(retry); !errors.Is(err, ErrDuplicateEvent) {
+		t.Errorf("InsertLog of a repeated event_id returned %v, want ErrDuplicateEvent", err)
+	}
+
+	// Event IDs are per user
+	other := testLog(2, 0, "INFO", "web", "api", "other user")
+	other.EventID = "evt-1"
+	if err := store.InsertLog(other); err != nil {
+		t.Errorf("InsertLog of another user's event_id failed: %v", err)
+	}
+
+	// Batches skip repeats, within the batch and of stored events
+	batch := []*models.LogEntry{
+		testLog(1, 2, "INFO", "web", "api", "batch repeat"),
+		testLog(1, 3, "INFO", "web", "api", "batch new"),
+		testLog(1, 4, "INFO", "web", "api", "batch new again"),
+		testLog(1, 5, "INFO", "web", "api", "no event id"),
+	}
+	batch[0].EventID = "evt-1"
+	batch[1].EventID = "evt-2"
+	batch[2].EventID = "evt-2"
+	if err := store.InsertLogs(batch); err != nil {
+		t.Fatalf("InsertLogs failed: %v", err)
+	}
+
+	checkFilter(t, store, nil, "first", "batch new", "no event id")
+}
+
+func TestEventIDsExpireAfterDedupWindow(t *testing.T) {
+	store := newTestStore(t)
+	store.SetDedupWindow(time.Hour)
+
+	now := time.Now()
+	insert := func(receivedAt time.Time, message string) error {
+		log := testLog(1, 0, "INFO", "web", "api", message)
+		log.EventID = "evt-1"
+		log.ReceivedAt = receivedAt
+		return store.InsertLog(log)
+	}
+
+	if err := insert(now.Add(-2*time.Hour), "old"); err != nil {
+		t.Fatalf("InsertLog failed: %v", err)
+	}
+	if err := insert(now.Add(-90*time.Minute), "within window"); !errors.Is(err, ErrDuplicateEvent) {
+		t.Errorf("repeat within the window returned %v, want ErrDuplicateEvent", err)
+	}
+	if err := insert(now, "after window"); err != nil {
+		t.Errorf("repeat after the window returned %v, want it stored", err)
+	}
+
+	// The reclaimed ID counts from its new time, so nothing is old enough to prune
+	pruned, err := store.PruneEventIDs(context.Background())
+	if err != nil {
+		t.Fatalf("PruneEventIDs failed: %v", err)
+	}
+	if pruned != 0 {
+		t.Errorf("pruned %d event IDs, want 0", pruned)
+	}
+
+	stale := testLog(1, 0, "INFO", "web", "api", "stale")
+	stale.EventID = "evt-2"
+	stale.ReceivedAt = now.Add(-3 * time.Hour)
+	if err := store.InsertLog(stale); err != nil {
+		t.Fatalf("InsertLog failed: %v", err)
+	}
+	if pruned, err = store.PruneEventIDs(context.Background()); err != nil || pruned != 1 {
+		t.Errorf("PruneEventIDs returned %d, %v, want 1 pruned", pruned, err)
+	}
+
+	checkFilter(t, store, nil, "old", "after window", "stale")
+}
+
+func TestEventIDsWithoutDedupWindowNeverExpire(t *testing.T) {
+	store := newTestStore(t)
+
+	log := testLog(1, 0, "INFO", "web", "api", "first")
+	log.EventID = "evt-1"
+	log.ReceivedAt = time.Now().Add(-365 * 24 * time.Hour)
+	if err := store.InsertLog(log); err != nil {
+		t.Fatalf("InsertLog failed: %v", err)
+	}
+
+	retry := testLog(1, 0, "INFO", "web", "api", "retry")
+	retry.EventID = "evt-1"
+	retry.ReceivedAt = time.Now()
+	if err := store.InsertLog(retry); !errors.Is(err, ErrDuplicateEvent) {
+		t.Errorf("InsertLog returned %v, want ErrDuplicateEvent", err)
+	}
+	if pruned, err := store.PruneEventIDs(context.Background()); err != nil || pruned != 0 {
+		t.Errorf("PruneEventIDs returned %d, %v, want nothing pruned", pruned, err)
+	}
+}
+
+func TestSearchLogs(t *testing.T) {
+	store := newTestStore(t)
+	seedLogs(t, store)
+
+	search := func(input, sortBy string) ([]*models.LogEntry, []models.SearchMatch, error) {
+		t.Helper()
+		q, err := models.ParseSearch(input)
+		if err != nil {
+			t.Fatalf("ParseSearch(%q) failed: %v", input, err)
+		}
+		return store.SearchLogs(&models.LogQuery{UserID: 1, Search: q, SortBy: sortBy})
+	}
+
+	// Words match as case-insensitive substrings, newest first
+	logs, matches, err := search("payment", "")
+	if err != nil {
+		t.Fatalf("SearchLogs failed: %v", err)
+	}
+	if got, want := messages(logs), []string{"Payment declined: card expired", "retrying payment job"}; !equalStrings(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if len(matches) != 2 || matches[0].LogID != logs[0].ID || matches[0].Rank != 0 ||
+		matches[0].Snippet != "<mark>Payment</mark> declined: card expired" {
+		t.Errorf("got matches %+v, want unranked highlighted snippets", matches)
+	}
+
+	if logs, _, _ = search(`payment NOT "card expired"`, models.FieldTimestamp); !equalStrings(messages(logs), []string{"retrying payment job"}) {
+		t.Errorf("got %q, want only the retry", messages(logs))
+	}
+	if logs, _, _ = search("disk OR cache", models.FieldTimestamp); len(logs) != 2 {
+		t.Errorf("got %q, want the disk and cache logs", messages(logs))
+	}
+
+	if _, _, err := search("payment", models.SortRelevance); !errors.Is(err, ErrRelevanceUnsupported) {
+		t.Errorf("sorting by relevance returned %v, want ErrRelevanceUnsupported", err)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	long := strings.Repeat("é", 200) + " needle " + strings.Repeat("ü", 200)
+
+	tests := []struct {
+		name    string
+		message string
+		search  string
+		want    string
+	}{
+		{"word", "Connection refused by host", "refused", "Connection <mark>refused</mark> by host"},
+		{"case insensitive", "ERROR while saving", "error", "<mark>ERROR</mark> while saving"},
+		{"every match", "retry 1, retry 2", "retry", "<mark>retry</mark> 1, <mark>retry</mark> 2"},
+		{"prefix", "connecting to db", "conn*", "<mark>connecting</mark> to db"},
+		{"phrase across spaces", "connection   refused", `"connection refused"`, "<mark>connection   refused</mark>"},
+		{"negated words aren't marked", "timeout after retry", "timeout -retry", "<mark>timeout</mark> after retry"},
+		{"no match", "all good", "NOT error", "all good"},
+		{
+			"html is escaped",
+			`<script>alert("x")</script> & <b>error</b>`,
+			"error",
+			"&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; &amp; &lt;b&gt;<mark>error</mark>&lt;/b&gt;",
+		},
+		{"search syntax is literal in the pattern", "cost is $5.00 (total)", `"$5.00"`, "cost is <mark>$5.00</mark> (total)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := models.ParseSearch(tt.search)
+			if err != nil {
+				t.Fatalf("ParseSearch(%q) failed: %v", tt.search, err)
+			}
+			if got := highlight(tt.message, q); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("long messages are cut on rune boundaries around the first match", func(t *testing.T) {
+		q, _ := models.ParseSearch("needle")
+		got := highlight(long, q)
+		if !utf8.ValidString(got) {
+			t.Fatalf("snippet %q isn't valid UTF-8", got)
+		}
+		if !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") || !strings.Contains(got, " <mark>needle</mark> ") {
+			t.Errorf("got %q, want an excerpt around the match with ellipses on both sides", got)
+		}
+		if excerpt := strings.Trim(got, "…"); len(strings.ReplaceAll(strings.ReplaceAll(excerpt, "<mark>", ""), "</mark>", "")) > maxSnippetLength {
+			t.Errorf("excerpt is %d bytes, want at most %d", len(excerpt), maxSnippetLength)
+		}
+	})
+
+	t.Run("long messages without a match start at the beginning", func(t *testing.T) {
+		q, _ := models.ParseSearch("missing")
+		got := highlight(long, q)
+		if !utf8.ValidString(got) || strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") {
+			t.Errorf("got %q, want the valid start of the message followed by an ellipsis", got)
+		}
+	})
+}
+
+func TestContainsMatchesLikeWildcardsLiterally(t *testing.T) {
+	store := newTestStore(t)
+	logs := []*models.LogEntry{
+		testLog(1, 0, "INFO", "web", "api", "disk 100% full"),
+		testLog(1, 1, "INFO", "web", "api", "disk 1000 blocks"),
+		testLog(1, 2, "INFO", "web", "api", "user_id missing"),
+		testLog(1, 3, "INFO", "web", "api", "userXid missing"),
+		testLog(1, 4, "INFO", "web", "api", `path C:\temp\x`),
+		testLog(1, 5, "INFO", "web", "api", "path C:/temp/x"),
+	}
+	logs[0].Fields = map[string]string{"pct": "50%"}
+	logs[1].Fields = map[string]string{"pct": "500"}
+	if err := store.InsertLogs(logs); err != nil {
+		t.Fatalf("failed to insert logs: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter *models.Filter
+		want   []string
+	}{
+		{"percent", models.Compare(models.FieldMessage, models.FilterContains, "100%"), []string{"disk 100% full"}},
+		{"underscore", models.Compare(models.FieldMessage, models.FilterContains, "user_id"), []string{"user_id missing"}},
+		{"backslash", models.Compare(models.FieldMessage, models.FilterContains, `C:\temp`), []string{`path C:\temp\x`}},
+		{"escape sequence", models.Compare(models.FieldMessage, models.FilterContains, `\%`), nil},
+		{"not contains", models.Compare(models.FieldMessage, models.FilterNotContains, "_"), []string{"disk 100% full", "disk 1000 blocks", "userXid missing", `path C:\temp\x`, "path C:/temp/x"}},
+		{"custom field", models.Compare("fields.pct", models.FilterContains, "0%"), []string{"disk 100% full"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkFilter(t, store, tt.filter, tt.want...)
+		})
+	}
+
+	// Searches are LIKE matches on SQLite too
+	q, err := models.ParseSearch(`"user_id"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, _, err := store.SearchLogs(&models.LogQuery{UserID: 1, Search: q})
+	if err != nil {
+		t.Fatalf("SearchLogs failed: %v", err)
+	}
+	if got, want := messages(found), []string{"user_id missing"}; !equalStrings(got, want) {
+		t.Errorf("search got %q, want %q", got, want)
+	}
+}
+
+func TestQueryLogsWhereTrees(t *testing.T) {
+	store := newTestStore(t)
+	seedLogs(t, store)
+
+	tests := []struct {
+		name  string
+		where string
+		want  []string
+	}{
+		{
+			"or of and",
+			`{"or": [
+				{"and": [{"field": "level", "value": "ERROR"}, {"field": "service", "value": "billing"}]},
+				{"field": "source", "value": "db"}
+			]}`,
+			[]string{"Payment declined: card expired", "out of disk space"},
+		},
+		{"custom field", `{"field": "fields.region", "op": "starts_with", "value": "us-"}`, []string{"request served in 12ms"}},
+		{"custom field in", `{"field": "fields.status", "op": "in", "values": ["200", "500"]}`, []string{"request served in 12ms"}},
+		{
+			"not over a custom field skips logs without it",
+			`{"not": {"field": "fields.region", "value": "us-east-1"}}`,
+			[]string{"Payment declined: card expired"},
+		},
+		{
+			"level range and not",
+			`{"and": [{"field": "level", "op": "gte", "value": "warn"}, {"not": {"field": "message", "op": "iregex", "value": "^payment"}}]}`,
+			[]string{"retrying payment job", "out of disk space"},
+		},
+		{"deepest allowed", strings.Repeat(`{"not": `, 10) + `{"field": "source", "value": "web"}` + strings.Repeat("}", 10), []string{"cache warmed", "request served in 12ms"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var node models.FilterNode
+			if err := json.Unmarshal([]byte(tt.where), &node); err != nil {
+				t.Fatalf("invalid test JSON: %v", err)
+			}
+			filter, err := node.ToFilter()
+			if err != nil {
+				t.Fatalf("ToFilter failed: %v", err)
+			}
+			checkFilter(t, store, filter, tt.want...)
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+)
+
+type LookupTableStorage struct {
+	db *sql.DB
+}
+
+func NewLookupTableStorage(db *sql.DB) *LookupTableStorage {
+	return &LookupTableStorage{db: db}
+}
+
+const lookupTableColumns = `id, user_id, name, match_field, key_column, columns, row_count, created_at, updated_at`
+
+// creates the table, or replaces the contents of the user's table with the same name
+func (s *LookupTableStorage) UpsertTable(table *models.LookupTable) error {
+	columns, err := json.Marshal(table.Columns)
+	if err != nil {
+		return fmt.Errorf("failed to marshal columns: %w", err)
+	}
+	rows, err := json.Marshal(table.Rows)
+	if err != nil {
+		return fmt.Errorf("failed to marshal rows: %w", err)
+	}
+
+	query := `
+        INSERT INTO lookup_tables (user_id, name, match_field, key_column, columns, rows, row_count, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+        ON CONFLICT (user_id, name) DO UPDATE
+        SET match_field = EXCLUDED.match_field, key_column = EXCLUDED.key_column, columns = EXCLUDED.columns,
+            rows = EXCLUDED.rows, row_count = EXCLUDED.row_count, updated_at = EXCLUDED.updated_at
+        RETURNING id, created_at
+    `
+
+	table.UpdatedAt = time.Now()
+	err = s.db.QueryRow(
+		query,
+		table.UserID,
+		table.Name,
+		table.MatchField,
+		table.KeyColumn,
+		columns,
+		rows,
+		table.RowCount,
+		table.UpdatedAt,
+	).Scan(&table.ID, &table.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save lookup table: %w", err)
+	}
+	return nil
+}
+
+// returns the user's tables without their rows
+func (s *LookupTableStorage) GetUserTables(userID int) ([]*models.LookupTable, error) {
+	rows, err := s.db.Query(`SELECT `+lookupTableColumns+` FROM lookup_tables WHERE user_id = $1 ORDER BY name`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lookup tables: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []*models.LookupTable
+	for rows.Next() {
+		table, err := scanLookupTable(rows)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
+}
+
+// returns one of the user's tables without its rows, or nil if it doesn't exist
+func (s *LookupTableStorage) GetTable(userID int, name string) (*models.LookupTable, error) {
+	row := s.db.QueryRow(`SELECT `+lookupTableColumns+` FROM lookup_tables WHERE user_id = $1 AND name = $2`, userID, name)
+	table, err := scanLookupTable(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return table, err
+}
+
+func scanLookupTable(row rowScanner) (*models.LookupTable, error) {
+	table := &models.LookupTable{}
+	var columns []byte
+	err := row.Scan(
+		&table.ID,
+		&table.UserID,
+		&table.Name,
+		&table.MatchField,
+		&table.KeyColumn,
+		&columns,
+		&table.RowCount,
+		&table.CreatedAt,
+		&table.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan lookup table: %w", err)
+	}
+	if err := json.Unmarshal(columns, &table.Columns); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal columns: %w", err)
+	}
+	return table, nil
+}
+
+// returns a table's rows, keyed by the key column
+func (s *LookupTableStorage) GetTableRows(tableID int) (map[string]map[string]string, error) {
+	var data []byte
+	if err := s.db.QueryRow(`SELECT rows FROM lookup_tables WHERE id = $1`, tableID).Scan(&data); err != nil {
+		return nil, fmt.Errorf("failed to get lookup table rows: %w", err)
+	}
+
+	var rows map[string]map[string]string
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal lookup table rows: %w", err)
+	}
+	return rows, nil
+}
+
+func (s *LookupTableStorage) DeleteTable(userID int, name string) error {
+	result, err := s.db.Exec(`DELETE FROM lookup_tables WHERE user_id = $1 AND name = $2`, userID, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete lookup table: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("lookup table not found or not owned by user")
+	}
+	return nil
+}
@@ -0,0 +1,639 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+)
+
+/*
+this file is a StateStore kept in process, for the combined logbuilder binary with QUEUE_BACKEND=memory,
+where the ingester and processor share one process and Redis isn't needed. It follows the Redis
+implementation: the same TTLs, list caps, token buckets and multiline buffering. Nothing is persisted,
+so caches, quotas and buffered multiline events start empty when the process restarts
+*/
+
+// how often expired entries are swept, besides being ignored when read
+const memoryStateSweepInterval = time.Minute
+
+type MemoryState struct {
+	mu sync.Mutex
+
+	apiKeys     map[string]expiring[int]
+	clientCerts map[string]expiring[int]
+	limits      map[int]expiring[models.IngestLimits]
+	idempotency map[string]expiring[IdempotencyRecord]
+	quarantine  map[int]expiring[[]models.QuarantinedLog] // newest first
+
+	buckets  map[string]*tokenBucket
+	quotaDay string           // UTC day the quota counters belong to
+	quotas   map[string]int64 // "<user>:events", "<user>:bytes" and "<user>:rejected" for quotaDay
+
+	ruleStats     map[int]map[string]int64 // "<rule>:matched" and "<rule>:dropped" per user
+	redactionHits map[int]map[string]int64
+
+	multiline      map[string]*multilineBuffer
+	multilineRetry []*MultilineEvent
+	heartbeats     map[string]time.Time
+
+	lastSweep time.Time
+}
+
+// a value that is dropped after expires, or never when expires is zero
+type expiring[V any] struct {
+	value   V
+	expires time.Time
+}
+
+func (e expiring[V]) live(now time.Time) bool {
+	return e.expires.IsZero() || now.Before(e.expires)
+}
+
+func expiresAfter(now time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
+
+type tokenBucket struct {
+	tokens float64
+	ts     int64 // unix ms of the last charge
+}
+
+type multilineBuffer struct {
+	entry    *models.LogEntry
+	lines    []string
+	deadline time.Time // when the event is flushed by ExpireMultiline
+	expires  time.Time // when the buffer is forgotten, like the Redis key TTL
+}
+
+// creates an empty in-process state store
+func NewMemoryState() *MemoryState {
+	return &MemoryState{
+		apiKeys:       make(map[string]expiring[int]),
+		clientCerts:   make(map[string]expiring[int]),
+		limits:        make(map[int]expiring[models.IngestLimits]),
+		idempotency:   make(map[string]expiring[IdempotencyRecord]),
+		quarantine:    make(map[int]expiring[[]models.QuarantinedLog]),
+		buckets:       make(map[string]*tokenBucket),
+		quotas:        make(map[string]int64),
+		ruleStats:     make(map[int]map[string]int64),
+		redactionHits: make(map[int]map[string]int64),
+		multiline:     make(map[string]*multilineBuffer),
+		heartbeats:    make(map[string]time.Time),
+		lastSweep:     time.Now(),
+	}
+}
+
+// locks the store, sweeping expired entries at most once per memoryStateSweepInterval; returns the time
+// the operation runs at
+func (m *MemoryState) lock() time.Time {
+	m.mu.Lock()
+	now := time.Now()
+	if now.Sub(m.lastSweep) < memoryStateSweepInterval {
+		return now
+	}
+	m.lastSweep = now
+
+	sweep(m.apiKeys, now)
+	sweep(m.clientCerts, now)
+	sweep(m.limits, now)
+	sweep(m.idempotency, now)
+	sweep(m.quarantine, now)
+	for key, bucket := range m.buckets {
+		// A bucket idle this long has refilled, which is the same as having none
+		if now.UnixMilli()-bucket.ts > time.Hour.Milliseconds() {
+			delete(m.buckets, key)
+		}
+	}
+	for key, buffer := range m.multiline {
+		if now.After(buffer.expires) {
+			delete(m.multiline, key)
+		}
+	}
+	return now
+}
+
+func sweep[K comparable, V any](values map[K]expiring[V], now time.Time) {
+	for key, value := range values {
+		if !value.live(now) {
+			delete(values, key)
+		}
+	}
+}
+
+// CacheAPIKey stores the user owning an API key for ttl
+func (m *MemoryState) CacheAPIKey(ctx context.Context, apiKey string, userID int, ttl time.Duration) error {
+	now := m.lock()
+	defer m.mu.Unlock()
+	m.apiKeys[apiKey] = expiring[int]{value: userID, expires: expiresAfter(now, ttl)}
+	return nil
+}
+
+// GetCachedAPIKey returns the cached owner of an API key
+func (m *MemoryState) GetCachedAPIKey(ctx context.Context, apiKey string) (int, error) {
+	now := m.lock()
+	defer m.mu.Unlock()
+	cached, ok := m.apiKeys[apiKey]
+	if !ok || !cached.live(now) {
+		return 0, fmt.Errorf("API key not in cache")
+	}
+	return cached.value, nil
+}
+
+// InvalidateCachedAPIKey removes an API key from the cache
+func (m *MemoryState) InvalidateCachedAPIKey(ctx context.Context, apiKey string) error {
+	m.lock()
+	defer m.mu.Unlock()
+	delete(m.apiKeys, apiKey)
+	return nil
+}
+
+// CacheClientCert stores the user owning a client certificate fingerprint for ttl
+func (m *MemoryState) CacheClientCert(ctx context.Context, fingerprint string, userID int, ttl time.Duration) error {
+	now := m.lock()
+	defer m.mu.Unlock()
+	m.clientCerts[fingerprint] = expiring[int]{value: userID, expires: expiresAfter(now, ttl)}
+	return nil
+}
+
+// GetCachedClientCert returns the cached owner of a client certificate fingerprint
+func (m *MemoryState) GetCachedClientCert(ctx context.Context, fingerprint string) (int, error) {
+	now := m.lock()
+	defer m.mu.Unlock()
+	cached, ok := m.clientCerts[fingerprint]
+	if !ok || !cached.live(now) {
+		return 0, fmt.Errorf("client certificate not in cache")
+	}
+	return cached.value, nil
+}
+
+// InvalidateCachedClientCert removes a client certificate fingerprint from the cache
+func (m *MemoryState) InvalidateCachedClientCert(ctx context.Context, fingerprint string) error {
+	m.lock()
+	defer m.mu.Unlock()
+	delete(m.clientCerts, fingerprint)
+	return nil
+}
+
+// CacheIngestLimits caches a user's effective limits for ttl
+func (m *MemoryState) CacheIngestLimits(ctx context.Context, limits *models.IngestLimits, ttl time.Duration) error {
+	now := m.lock()
+	defer m.mu.Unlock()
+	m.limits[limits.UserID] = expiring[models.IngestLimits]{value: *limits, expires: expiresAfter(now, ttl)}
+	return nil
+}
+
+// GetCachedIngestLimits returns cached limits, or nil if they are not cached
+func (m *MemoryState) GetCachedIngestLimits(ctx context.Context, userID int) (*models.IngestLimits, error) {
+	now := m.lock()
+	defer m.mu.Unlock()
+	cached, ok := m.limits[userID]
+	if !ok || !cached.live(now) {
+		return nil, nil
+	}
+	limits := cached.value
+	return &limits, nil
+}
+
+// InvalidateIngestLimits drops cached limits after an admin changes them
+func (m *MemoryState) InvalidateIngestLimits(ctx context.Context, userID int) error {
+	m.lock()
+	defer m.mu.Unlock()
+	delete(m.limits, userID)
+	return nil
+}
+
+// starts the day's quota counters once the UTC day changes
+func (m *MemoryState) rollQuotaDay(day string) {
+	if m.quotaDay != day {
+		m.quotaDay = day
+		clear(m.quotas)
+	}
+}
+
+// TakeIngestTokens charges events and bytes against the user's and API key's buckets and daily quotas,
+// with the same rules as rateLimitScript
+func (m *MemoryState) TakeIngestTokens(ctx context.Context, limits *models.IngestLimits, apiKey string, events, bytes int64) (*RateLimitResult, error) {
+	now := m.lock()
+	defer m.mu.Unlock()
+
+	day, untilReset := quotaDay(now)
+	m.rollQuotaDay(day)
+	nowMs := now.UnixMilli()
+	keyID := apiKeyID(apiKey)
+
+	bucketKeys := []string{
+		fmt.Sprintf("user:%d:events", limits.UserID),
+		fmt.Sprintf("user:%d:bytes", limits.UserID),
+		fmt.Sprintf("key:%s:events", keyID),
+		fmt.Sprintf("key:%s:bytes", keyID),
+	}
+	rates := []int64{limits.EventsPerSecond, limits.BytesPerSecond, limits.KeyEventsPerSecond, limits.KeyBytesPerSecond}
+	if apiKey == "" {
+		rates[2], rates[3] = 0, 0
+	}
+	costs := []int64{events, bytes, events, bytes}
+	quotaKeys := []string{fmt.Sprintf("%d:events", limits.UserID), fmt.Sprintf("%d:bytes", limits.UserID)}
+	quotas := []int64{limits.DailyEventQuota, limits.DailyByteQuota}
+
+	reject := func(retryAfter time.Duration, check int, remaining int64) *RateLimitResult {
+		m.quotas[fmt.Sprintf("%d:rejected", limits.UserID)] += events
+		if check > 0 && check < len(bucketKeys) {
+			// Remaining only describes the user events bucket
+			remaining = -1
+		}
+		return &RateLimitResult{
+			Limit:      limits.EventsPerSecond,
+			Remaining:  remaining,
+			RetryAfter: retryAfter,
+			Reset:      retryAfter,
+			Reason:     rateLimitReasons[check],
+		}
+	}
+
+	tokens := make([]float64, len(bucketKeys))
+	for i, key := range bucketKeys {
+		rate := float64(rates[i])
+		if rate <= 0 {
+			continue
+		}
+		current := rate
+		if bucket, ok := m.buckets[key]; ok {
+			current = math.Min(rate, bucket.tokens+math.Max(0, float64(nowMs-bucket.ts))/1000*rate)
+		}
+		needed := math.Min(float64(costs[i]), rate)
+		if current < needed {
+			retryAfter := time.Duration(math.Ceil((needed-current)/rate*1000)) * time.Millisecond
+			return reject(retryAfter, i, int64(math.Floor(current))), nil
+		}
+		tokens[i] = current
+	}
+
+	for i, quota := range quotas {
+		if quota > 0 && m.quotas[quotaKeys[i]]+costs[i] > quota {
+			reset := time.Duration(int64(untilReset.Seconds())+1) * time.Second
+			return reject(reset, len(bucketKeys)+i, 0), nil
+		}
+	}
+
+	for i, key := range bucketKeys {
+		if rates[i] > 0 {
+			m.buckets[key] = &tokenBucket{tokens: tokens[i] - float64(costs[i]), ts: nowMs}
+		}
+	}
+	for i, key := range quotaKeys {
+		m.quotas[key] += costs[i]
+	}
+
+	remaining := int64(-1)
+	if rates[0] > 0 {
+		remaining = int64(math.Max(0, math.Floor(tokens[0]-float64(costs[0]))))
+	}
+	return &RateLimitResult{Allowed: true, Limit: limits.EventsPerSecond, Remaining: remaining, Reset: time.Second}, nil
+}
+
+// GetIngestUsage returns today's usage counters and the tokens left in the user's events bucket
+func (m *MemoryState) GetIngestUsage(ctx context.Context, limits *models.IngestLimits) (*models.IngestUsage, error) {
+	now := m.lock()
+	defer m.mu.Unlock()
+
+	day, untilReset := quotaDay(now)
+	m.rollQuotaDay(day)
+
+	usage := &models.IngestUsage{
+		Limits:          limits,
+		QuotaResetsAt:   now.Add(untilReset).Truncate(time.Second),
+		EventsAvailable: -1,
+		EventsToday:     m.quotas[fmt.Sprintf("%d:events", limits.UserID)],
+		BytesToday:      m.quotas[fmt.Sprintf("%d:bytes", limits.UserID)],
+		RejectedToday:   m.quotas[fmt.Sprintf("%d:rejected", limits.UserID)],
+	}
+	if limits.EventsPerSecond > 0 {
+		rate := float64(limits.EventsPerSecond)
+		available := rate
+		if bucket, ok := m.buckets[fmt.Sprintf("user:%d:events", limits.UserID)]; ok {
+			available = math.Max(0, math.Min(rate, bucket.tokens+float64(now.UnixMilli()-bucket.ts)/1000*rate))
+		}
+		usage.EventsAvailable = int64(available)
+	}
+	return usage, nil
+}
+
+// ReserveIdempotencyKey marks a key as pending; if the key was already seen it returns the existing record instead
+func (m *MemoryState) ReserveIdempotencyKey(ctx context.Context, userID int, key string, ttl time.Duration) (*IdempotencyRecord, error) {
+	now := m.lock()
+	defer m.mu.Unlock()
+
+	id := idempotencyRedisKey(userID, key)
+	if existing, ok := m.idempotency[id]; ok && existing.live(now) {
+		record := existing.value
+		return &record, nil
+	}
+	m.idempotency[id] = expiring[IdempotencyRecord]{value: IdempotencyRecord{State: IdempotencyPending}, expires: expiresAfter(now, ttl)}
+	return nil, nil
+}
+
+// CompleteIdempotencyKey stores the response for a key so retries can replay it within the TTL
+func (m *MemoryState) CompleteIdempotencyKey(ctx context.Context, userID int, key string, record IdempotencyRecord, ttl time.Duration) error {
+	now := m.lock()
+	defer m.mu.Unlock()
+	record.State = IdempotencyCompleted
+	m.idempotency[idempotencyRedisKey(userID, key)] = expiring[IdempotencyRecord]{value: record, expires: expiresAfter(now, ttl)}
+	return nil
+}
+
+// ReleaseIdempotencyKey forgets a key so a failed request can be retried with it
+func (m *MemoryState) ReleaseIdempotencyKey(ctx context.Context, userID int, key string) error {
+	m.lock()
+	defer m.mu.Unlock()
+	delete(m.idempotency, idempotencyRedisKey(userID, key))
+	return nil
+}
+
+// QuarantineLogs keeps rejected entries in a capped per-user list that expires after a week
+func (m *MemoryState) QuarantineLogs(ctx context.Context, userID int, entries []models.QuarantinedLog) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	now := m.lock()
+	defer m.mu.Unlock()
+
+	var kept []models.QuarantinedLog
+	if existing, ok := m.quarantine[userID]; ok && existing.live(now) {
+		kept = existing.value
+	}
+	// Newest first, like LPUSH
+	list := make([]models.QuarantinedLog, 0, len(entries)+len(kept))
+	for i := len(entries) - 1; i >= 0; i-- {
+		list = append(list, entries[i])
+	}
+	list = append(list, kept...)
+	if len(list) > 1000 {
+		list = list[:1000]
+	}
+	m.quarantine[userID] = expiring[[]models.QuarantinedLog]{value: list, expires: now.Add(7 * 24 * time.Hour)}
+	return nil
+}
+
+// GetQuarantinedLogs returns the most recent rejected entries for a user, newest first
+func (m *MemoryState) GetQuarantinedLogs(ctx context.Context, userID int, limit int) ([]models.QuarantinedLog, error) {
+	now := m.lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.quarantine[userID]
+	if !ok || !existing.live(now) {
+		return []models.QuarantinedLog{}, nil
+	}
+	list := existing.value
+	if limit > 0 && limit < len(list) {
+		list = list[:limit]
+	}
+	return append([]models.QuarantinedLog(nil), list...), nil
+}
+
+// ClearQuarantine removes all quarantined entries for a user
+func (m *MemoryState) ClearQuarantine(ctx context.Context, userID int) error {
+	m.lock()
+	defer m.mu.Unlock()
+	delete(m.quarantine, userID)
+	return nil
+}
+
+// adds counts to a user's counters, creating them as needed
+func incrCounters(counters map[int]map[string]int64, userID int, counts map[string]int64) {
+	user, ok := counters[userID]
+	if !ok {
+		user = make(map[string]int64)
+		counters[userID] = user
+	}
+	for field, count := range counts {
+		user[field] += count
+	}
+}
+
+// IncrIngestRuleStats adds to the matched and dropped counters of a user's rules
+func (m *MemoryState) IncrIngestRuleStats(ctx context.Context, userID int, matched, dropped map[int]int64) error {
+	if len(matched) == 0 {
+		return nil
+	}
+	m.lock()
+	defer m.mu.Unlock()
+
+	counts := make(map[string]int64, len(matched)+len(dropped))
+	for ruleID, count := range matched {
+		counts[fmt.Sprintf("%d:matched", ruleID)] = count
+	}
+	for ruleID, count := range dropped {
+		if count > 0 {
+			counts[fmt.Sprintf("%d:dropped", ruleID)] = count
+		}
+	}
+	incrCounters(m.ruleStats, userID, counts)
+	return nil
+}
+
+// GetIngestRuleStats returns matched and dropped counts keyed by rule ID
+func (m *MemoryState) GetIngestRuleStats(ctx context.Context, userID int) (matched, dropped map[int]int64, err error) {
+	m.lock()
+	defer m.mu.Unlock()
+
+	matched = make(map[int]int64)
+	dropped = make(map[int]int64)
+	for field, count := range m.ruleStats[userID] {
+		var ruleID int
+		var kind string
+		if _, err := fmt.Sscanf(field, "%d:%s", &ruleID, &kind); err != nil {
+			continue
+		}
+		switch kind {
+		case "matched":
+			matched[ruleID] = count
+		case "dropped":
+			dropped[ruleID] = count
+		}
+	}
+	return matched, dropped, nil
+}
+
+// ResetIngestRuleStats clears the counters of one rule
+func (m *MemoryState) ResetIngestRuleStats(ctx context.Context, userID, ruleID int) error {
+	m.lock()
+	defer m.mu.Unlock()
+	delete(m.ruleStats[userID], fmt.Sprintf("%d:matched", ruleID))
+	delete(m.ruleStats[userID], fmt.Sprintf("%d:dropped", ruleID))
+	return nil
+}
+
+// IncrRedactionHits adds per-rule hit counts for a user
+func (m *MemoryState) IncrRedactionHits(ctx context.Context, userID int, hits map[string]int64) error {
+	if len(hits) == 0 {
+		return nil
+	}
+	m.lock()
+	defer m.mu.Unlock()
+	incrCounters(m.redactionHits, userID, hits)
+	return nil
+}
+
+// GetRedactionHits returns a user's hit counters keyed by rule
+func (m *MemoryState) GetRedactionHits(ctx context.Context, userID int) (map[string]int64, error) {
+	m.lock()
+	defer m.mu.Unlock()
+	hits := make(map[string]int64, len(m.redactionHits[userID]))
+	for rule, count := range m.redactionHits[userID] {
+		hits[rule] = count
+	}
+	return hits, nil
+}
+
+// ResetRedactionHits clears the counter of one rule
+func (m *MemoryState) ResetRedactionHits(ctx context.Context, userID int, rule string) error {
+	m.lock()
+	defer m.mu.Unlock()
+	delete(m.redactionHits[userID], rule)
+	return nil
+}
+
+// copies an entry so buffered events don't share their fields with the caller
+func copyEntry(entry *models.LogEntry) *models.LogEntry {
+	copied := *entry
+	if entry.Fields != nil {
+		copied.Fields = make(map[string]string, len(entry.Fields))
+		for k, v := range entry.Fields {
+			copied.Fields[k] = v
+		}
+	}
+	return &copied
+}
+
+// AppendMultiline adds a line to the stream's buffered event, like multilineAppendScript. It returns the
+// event that is now complete, or nil if the line was buffered.
+func (m *MemoryState) AppendMultiline(ctx context.Context, entry *models.LogEntry, pipelineID int, continuation bool, maxWait time.Duration, maxLines int) (*MultilineEvent, error) {
+	now := m.lock()
+	defer m.mu.Unlock()
+
+	key := multilineKey(entry, pipelineID)
+	buffer, ok := m.multiline[key]
+	if ok && now.After(buffer.expires) {
+		buffer, ok = nil, false
+	}
+	deadline := now.Add(maxWait)
+	expires := now.Add(maxWait + time.Minute)
+
+	if continuation && ok {
+		buffer.lines = append(buffer.lines, entry.Message)
+		buffer.deadline = deadline
+		buffer.expires = expires
+		if len(buffer.lines)+1 >= maxLines {
+			delete(m.multiline, key)
+			return &MultilineEvent{Entry: buffer.entry, Lines: buffer.lines}, nil
+		}
+		return nil, nil
+	}
+
+	m.multiline[key] = &multilineBuffer{entry: copyEntry(entry), deadline: deadline, expires: expires}
+	if ok {
+		return &MultilineEvent{Entry: buffer.entry, Lines: buffer.lines}, nil
+	}
+	return nil, nil
+}
+
+// ExpireMultiline removes and returns up to limit buffered events whose max wait has passed
+func (m *MemoryState) ExpireMultiline(ctx context.Context, limit int) ([]*MultilineEvent, error) {
+	now := m.lock()
+	defer m.mu.Unlock()
+
+	var due []string
+	for key, buffer := range m.multiline {
+		if !buffer.deadline.After(now) {
+			due = append(due, key)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return m.multiline[due[i]].deadline.Before(m.multiline[due[j]].deadline) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	events := make([]*MultilineEvent, 0, len(due))
+	for _, key := range due {
+		buffer := m.multiline[key]
+		delete(m.multiline, key)
+		events = append(events, &MultilineEvent{Entry: buffer.entry, Lines: buffer.lines})
+	}
+	return events, nil
+}
+
+// RetryMultilineLater keeps a joined event whose storing failed, to be returned by RetryMultiline
+func (m *MemoryState) RetryMultilineLater(ctx context.Context, event *MultilineEvent) error {
+	m.lock()
+	defer m.mu.Unlock()
+	m.multilineRetry = append(m.multilineRetry, event)
+	return nil
+}
+
+// RetryMultiline removes and returns up to limit events kept by RetryMultilineLater, oldest first
+func (m *MemoryState) RetryMultiline(ctx context.Context, limit int) ([]*MultilineEvent, error) {
+	m.lock()
+	defer m.mu.Unlock()
+	n := min(limit, len(m.multilineRetry))
+	events := append([]*MultilineEvent(nil), m.multilineRetry[:n]...)
+	m.multilineRetry = m.multilineRetry[n:]
+	return events, nil
+}
+
+// records that the named processor is alive, forgetting processors silent for longer than maxAge
+func (m *MemoryState) ProcessorHeartbeat(ctx context.Context, name string, maxAge time.Duration) error {
+	now := m.lock()
+	defer m.mu.Unlock()
+	m.heartbeats[name] = now
+	for other, lastSeen := range m.heartbeats {
+		if lastSeen.Before(now.Add(-maxAge)) {
+			delete(m.heartbeats, other)
+		}
+	}
+	return nil
+}
+
+// stops reporting the named processor, for a clean shutdown
+func (m *MemoryState) RemoveProcessorHeartbeat(ctx context.Context, name string) error {
+	m.lock()
+	defer m.mu.Unlock()
+	delete(m.heartbeats, name)
+	return nil
+}
+
+// returns the processors that sent a heartbeat within maxAge, least recent first
+func (m *MemoryState) LiveProcessors(ctx context.Context, maxAge time.Duration) ([]ProcessorHeartbeat, error) {
+	now := m.lock()
+	defer m.mu.Unlock()
+
+	processors := []ProcessorHeartbeat{}
+	for name, lastSeen := range m.heartbeats {
+		if !lastSeen.Before(now.Add(-maxAge)) {
+			processors = append(processors, ProcessorHeartbeat{Name: name, LastSeen: lastSeen.Truncate(time.Second)})
+		}
+	}
+	sort.Slice(processors, func(i, j int) bool {
+		if !processors[i].LastSeen.Equal(processors[j].LastSeen) {
+			return processors[i].LastSeen.Before(processors[j].LastSeen)
+		}
+		return processors[i].Name < processors[j].Name
+	})
+	return processors, nil
+}
+
+// always succeeds; the state lives in this process
+func (m *MemoryState) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (m *MemoryState) Close() error {
+	return nil
+}
@@ -0,0 +1,241 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+)
+
+func TestMemoryStateCaches(t *testing.T) {
+	ctx := context.Background()
+	state := NewMemoryState()
+
+	if _, err := state.GetCachedAPIKey(ctx, "key"); err == nil {
+		t.Fatal("GetCachedAPIKey found a key that was never cached")
+	}
+	if err := state.CacheAPIKey(ctx, "key", 7, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if userID, err := state.GetCachedAPIKey(ctx, "key"); err != nil || userID != 7 {
+		t.Fatalf("GetCachedAPIKey = %d, %v, want 7", userID, err)
+	}
+	state.InvalidateCachedAPIKey(ctx, "key")
+	if _, err := state.GetCachedAPIKey(ctx, "key"); err == nil {
+		t.Error("GetCachedAPIKey found an invalidated key")
+	}
+
+	state.CacheClientCert(ctx, "fingerprint", 3, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if _, err := state.GetCachedClientCert(ctx, "fingerprint"); err == nil {
+		t.Error("GetCachedClientCert found an expired fingerprint")
+	}
+
+	if limits, err := state.GetCachedIngestLimits(ctx, 1); limits != nil || err != nil {
+		t.Fatalf("GetCachedIngestLimits = %v, %v, want nil, nil", limits, err)
+	}
+	limits := &models.IngestLimits{UserID: 1, EventsPerSecond: 10}
+	state.CacheIngestLimits(ctx, limits, time.Minute)
+	limits.EventsPerSecond = 20
+	if cached, _ := state.GetCachedIngestLimits(ctx, 1); cached == nil || cached.EventsPerSecond != 10 {
+		t.Errorf("GetCachedIngestLimits = %+v, want the limits as they were cached", cached)
+	}
+}
+
+func TestMemoryStateTakeIngestTokens(t *testing.T) {
+	ctx := context.Background()
+	state := NewMemoryState()
+	limits := &models.IngestLimits{UserID: 1, EventsPerSecond: 10, BytesPerSecond: 1000, DailyEventQuota: 15}
+
+	result, err := state.TakeIngestTokens(ctx, limits, "", 8, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Allowed || result.Remaining != 2 {
+		t.Fatalf("first take = %+v, want allowed with 2 remaining", result)
+	}
+
+	// Only about 2 events are left in the bucket
+	result, _ = state.TakeIngestTokens(ctx, limits, "", 5, 100)
+	if result.Allowed || result.Reason != rateLimitReasons[0] || result.RetryAfter <= 0 {
+		t.Fatalf("second take = %+v, want rejected by the user events rate", result)
+	}
+
+	// A batch larger than the rate only needs a full bucket, but the daily quota still counts all of it
+	time.Sleep(time.Second)
+	result, _ = state.TakeIngestTokens(ctx, limits, "", 12, 100)
+	if result.Allowed || result.Reason != rateLimitReasons[4] || result.Remaining != 0 {
+		t.Fatalf("third take = %+v, want rejected by the daily event quota", result)
+	}
+
+	usage, err := state.GetIngestUsage(ctx, limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.EventsToday != 8 || usage.BytesToday != 100 || usage.RejectedToday != 17 {
+		t.Errorf("usage = %d events, %d bytes, %d rejected, want 8, 100 and 17",
+			usage.EventsToday, usage.BytesToday, usage.RejectedToday)
+	}
+	if usage.EventsAvailable != 10 {
+		t.Errorf("EventsAvailable = %d, want the refilled bucket of 10", usage.EventsAvailable)
+	}
+}
+
+func TestMemoryStateTakeIngestTokensPerKey(t *testing.T) {
+	ctx := context.Background()
+	state := NewMemoryState()
+	limits := &models.IngestLimits{UserID: 1, KeyEventsPerSecond: 5}
+
+	if result, _ := state.TakeIngestTokens(ctx, limits, "a", 5, 0); !result.Allowed || result.Remaining != -1 {
+		t.Fatalf("take with key a = %+v, want allowed without a user events limit", result)
+	}
+	result, _ := state.TakeIngestTokens(ctx, limits, "a", 1, 0)
+	if result.Allowed || result.Reason != rateLimitReasons[2] || result.Remaining != -1 {
+		t.Errorf("second take with key a = %+v, want rejected by the key events rate", result)
+	}
+	if result, _ := state.TakeIngestTokens(ctx, limits, "b", 5, 0); !result.Allowed {
+		t.Errorf("take with key b = %+v, want allowed from its own bucket", result)
+	}
+	if result, _ := state.TakeIngestTokens(ctx, limits, "", 100, 0); !result.Allowed {
+		t.Errorf("take without a key = %+v, want allowed since key limits don't apply", result)
+	}
+}
+
+func TestMemoryStateIdempotencyKeys(t *testing.T) {
+	ctx := context.Background()
+	state := NewMemoryState()
+
+	if existing, _ := state.ReserveIdempotencyKey(ctx, 1, "k", time.Minute); existing != nil {
+		t.Fatalf("first reserve returned %+v, want nil", existing)
+	}
+	if existing, _ := state.ReserveIdempotencyKey(ctx, 1, "k", time.Minute); existing == nil || existing.State != IdempotencyPending {
+		t.Fatalf("second reserve returned %+v, want the pending record", existing)
+	}
+	if existing, _ := state.ReserveIdempotencyKey(ctx, 2, "k", time.Minute); existing != nil {
+		t.Errorf("reserve for another user returned %+v, want nil", existing)
+	}
+
+	state.CompleteIdempotencyKey(ctx, 1, "k", IdempotencyRecord{StatusCode: 202, Body: "{}"}, time.Minute)
+	existing, _ := state.ReserveIdempotencyKey(ctx, 1, "k", time.Minute)
+	if existing == nil || existing.State != IdempotencyCompleted || existing.StatusCode != 202 {
+		t.Fatalf("reserve after complete returned %+v, want the completed record", existing)
+	}
+
+	state.ReleaseIdempotencyKey(ctx, 1, "k")
+	if existing, _ := state.ReserveIdempotencyKey(ctx, 1, "k", time.Minute); existing != nil {
+		t.Errorf("reserve after release returned %+v, want nil", existing)
+	}
+}
+
+func TestMemoryStateQuarantine(t *testing.T) {
+	ctx := context.Background()
+	state := NewMemoryState()
+
+	var entries []models.QuarantinedLog
+	for i := 0; i < 1005; i++ {
+		entries = append(entries, models.QuarantinedLog{Raw: fmt.Sprint(i)})
+	}
+	state.QuarantineLogs(ctx, 1, entries[:5])
+	state.QuarantineLogs(ctx, 1, entries[5:])
+
+	all, _ := state.GetQuarantinedLogs(ctx, 1, 0)
+	if len(all) != 1000 || all[0].Raw != "1004" || all[999].Raw != "5" {
+		t.Fatalf("got %d entries from %s to %s, want the newest 1000 from 1004 to 5", len(all), all[0].Raw, all[len(all)-1].Raw)
+	}
+	if recent, _ := state.GetQuarantinedLogs(ctx, 1, 2); len(recent) != 2 || recent[1].Raw != "1003" {
+		t.Errorf("got %+v, want the two newest entries", recent)
+	}
+
+	state.ClearQuarantine(ctx, 1)
+	if cleared, _ := state.GetQuarantinedLogs(ctx, 1, 0); len(cleared) != 0 {
+		t.Errorf("got %d entries after clearing, want none", len(cleared))
+	}
+}
+
+func TestMemoryStateCounters(t *testing.T) {
+	ctx := context.Background()
+	state := NewMemoryState()
+
+	state.IncrIngestRuleStats(ctx, 1, map[int]int64{4: 2, 5: 1}, map[int]int64{4: 1})
+	state.IncrIngestRuleStats(ctx, 1, map[int]int64{4: 3}, nil)
+	matched, dropped, _ := state.GetIngestRuleStats(ctx, 1)
+	if matched[4] != 5 || matched[5] != 1 || dropped[4] != 1 || len(dropped) != 1 {
+		t.Fatalf("matched %v and dropped %v, want 4:5 5:1 and 4:1", matched, dropped)
+	}
+	state.ResetIngestRuleStats(ctx, 1, 4)
+	if matched, dropped, _ = state.GetIngestRuleStats(ctx, 1); matched[4] != 0 || dropped[4] != 0 || matched[5] != 1 {
+		t.Errorf("after reset matched %v and dropped %v, want only rule 5", matched, dropped)
+	}
+
+	state.IncrRedactionHits(ctx, 1, map[string]int64{"email": 2})
+	state.IncrRedactionHits(ctx, 1, map[string]int64{"email": 1, "rule:3": 4})
+	state.ResetRedactionHits(ctx, 1, "rule:3")
+	if hits, _ := state.GetRedactionHits(ctx, 1); len(hits) != 1 || hits["email"] != 3 {
+		t.Errorf("hits %v, want email:3", hits)
+	}
+}
+
+func TestMemoryStateMultiline(t *testing.T) {
+	ctx := context.Background()
+	state := NewMemoryState()
+	line := func(message string) *models.LogEntry {
+		return &models.LogEntry{UserID: 1, Source: "app", Message: message}
+	}
+
+	// A start line flushes the previous event
+	if event, _ := state.AppendMultiline(ctx, line("first"), 1, false, time.Minute, 10); event != nil {
+		t.Fatalf("first start returned %+v, want nil", event)
+	}
+	state.AppendMultiline(ctx, line("  at one"), 1, true, time.Minute, 10)
+	event, _ := state.AppendMultiline(ctx, line("second"), 1, false, time.Minute, 10)
+	if event == nil || event.Entry.Message != "first" || len(event.Lines) != 1 || event.Lines[0] != "  at one" {
+		t.Fatalf("second start returned %+v, want the first event with its continuation", event)
+	}
+
+	// Reaching maxLines flushes the event itself
+	state.AppendMultiline(ctx, line("  at two"), 1, true, time.Minute, 3)
+	event, _ = state.AppendMultiline(ctx, line("  at three"), 1, true, time.Minute, 3)
+	if event == nil || event.Entry.Message != "second" || len(event.Lines) != 2 {
+		t.Fatalf("continuation at maxLines returned %+v, want the second event with 2 lines", event)
+	}
+
+	// Events past their max wait are expired, oldest first
+	state.AppendMultiline(ctx, &models.LogEntry{UserID: 1, Source: "a", Message: "a"}, 1, false, 0, 10)
+	state.AppendMultiline(ctx, &models.LogEntry{UserID: 1, Source: "b", Message: "b"}, 1, false, time.Millisecond, 10)
+	state.AppendMultiline(ctx, &models.LogEntry{UserID: 1, Source: "c", Message: "c"}, 1, false, time.Hour, 10)
+	time.Sleep(5 * time.Millisecond)
+	expired, _ := state.ExpireMultiline(ctx, 100)
+	if len(expired) != 2 || expired[0].Entry.Message != "a" || expired[1].Entry.Message != "b" {
+		t.Fatalf("expired %d events, want a then b", len(expired))
+	}
+
+	state.RetryMultilineLater(ctx, &MultilineEvent{Entry: line("retry 1"), Attempts: 1})
+	state.RetryMultilineLater(ctx, &MultilineEvent{Entry: line("retry 2"), Attempts: 1})
+	retries, _ := state.RetryMultiline(ctx, 1)
+	if len(retries) != 1 || retries[0].Entry.Message != "retry 1" {
+		t.Fatalf("retried %+v, want retry 1 first", retries)
+	}
+	if retries, _ = state.RetryMultiline(ctx, 10); len(retries) != 1 || retries[0].Entry.Message != "retry 2" {
+		t.Errorf("retried %+v, want retry 2", retries)
+	}
+}
+
+func TestMemoryStateHeartbeats(t *testing.T) {
+	ctx := context.Background()
+	state := NewMemoryState()
+
+	state.ProcessorHeartbeat(ctx, "a", time.Minute)
+	state.ProcessorHeartbeat(ctx, "b", time.Minute)
+	state.RemoveProcessorHeartbeat(ctx, "a")
+
+	live, _ := state.LiveProcessors(ctx, time.Minute)
+	if len(live) != 1 || live[0].Name != "b" {
+		t.Fatalf("live processors %+v, want only b", live)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if live, _ = state.LiveProcessors(ctx, time.Millisecond); len(live) != 0 {
+		t.Errorf("live processors %+v, want none within 1ms", live)
+	}
+}
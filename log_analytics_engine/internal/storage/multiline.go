@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+)
+
+/*
+This file buffers partial multiline events in Redis so any processor can join them:
+{multiline}:<user>:<pipeline>:<stream> holds the first line of the event as JSON
+the same key with a :lines suffix holds the continuation lines
+{multiline}:deadlines is a sorted set of buffered events scored by when they must be flushed
+{multiline}:retry holds joined events whose storing failed, to be stored again
+The shared {multiline} hash tag keeps every key in one Redis Cluster slot, since the scripts touch
+an event's keys together with the deadlines; every key a script touches is passed in KEYS
+*/
+
+const (
+	multilineDeadlinesKey = "{multiline}:deadlines"
+	multilineRetryKey     = "{multiline}:retry"
+)
+
+// KEYS: event key, lines key, deadlines zset
+// ARGV: "start" or "cont", entry JSON, message, deadline in ms, max lines, key TTL in ms
+//
+// A continuation is appended to the buffered event; a start line (or a continuation with
+// nothing buffered) replaces the buffer and returns the previous event so it can be stored.
+var multilineAppendScript = redis.NewScript(`
+local function take()
+  local event = redis.call('GET', KEYS[1])
+  if not event then
+    return nil
+  end
+  local lines = redis.call('LRANGE', KEYS[2], 0, -1)
+  redis.call('DEL', KEYS[1], KEYS[2])
+  redis.call('ZREM', KEYS[3], KEYS[1])
+  return {event, lines}
+end
+
+if ARGV[1] == 'cont' and redis.call('EXISTS', KEYS[1]) == 1 then
+  local count = redis.call('RPUSH', KEYS[2], ARGV[3])
+  redis.call('PEXPIRE', KEYS[1], ARGV[6])
+  redis.call('PEXPIRE', KEYS[2], ARGV[6])
+  redis.call('ZADD', KEYS[3], ARGV[4], KEYS[1])
+  if count + 1 >= tonumber(ARGV[5]) then
+    local full = take()
+    return {'flush', full[1], full[2]}
+  end
+  return {'buffered'}
+end
+
+local previous = take()
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[6])
+redis.call('ZADD', KEYS[3], ARGV[4], KEYS[1])
+if previous then
+  return {'flush', previous[1], previous[2]}
+end
+return {'buffered'}
+`)
+
+// KEYS: deadlines zset, event key, lines key
+// ARGV: now in ms
+//
+// Removes and returns the event if its deadline has passed; only one processor gets each event.
+var multilineExpireScript = redis.NewScript(`
+local deadline = redis.call('ZSCORE', KEYS[1], KEYS[2])
+if not deadline or tonumber(deadline) > tonumber(ARGV[1]) then
+  return false
+end
+redis.call('ZREM', KEYS[1], KEYS[2])
+local event = redis.call('GET', KEYS[2])
+if not event then
+  return false
+end
+local lines = redis.call('LRANGE', KEYS[3], 0, -1)
+redis.call('DEL', KEYS[2], KEYS[3])
+return {event, lines}
+`)
+
+// MultilineEvent is a buffered event with the continuation lines collected for it
+type MultilineEvent struct {
+	Entry    *models.LogEntry `json:"entry"`
+	Lines    []string         `json:"lines,omitempty"`
+	Attempts int              `json:"attempts,omitempty"` // failed attempts to store it
+}
+
+// returns the key buffering events for one user, pipeline, source and service
+func multilineKey(entry *models.LogEntry, pipelineID int) string {
+	sum := sha256.Sum256([]byte(entry.Source + "\x00" + entry.Service))
+	return fmt.Sprintf("{multiline}:%d:%d:%s", entry.UserID, pipelineID, hex.EncodeToString(sum[:8]))
+}
+
+// AppendMultiline adds a line to the stream's buffered event. It returns the event that is now
+// complete, or nil if the line was buffered.
+func (r *RedisClient) AppendMultiline(ctx context.Context, entry *models.LogEntry, pipelineID int, continuation bool, maxWait time.Duration, maxLines int) (*MultilineEvent, error) {
+	key := multilineKey(entry, pipelineID)
+
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal log entry: %w", err)
+	}
+
+	mode := "start"
+	if continuation {
+		mode = "cont"
+	}
+
+	result, err := multilineAppendScript.Run(ctx, r.client,
+		[]string{key, key + ":lines", multilineDeadlinesKey},
+		mode,
+		encoded,
+		entry.Message,
+		time.Now().Add(maxWait).UnixMilli(),
+		maxLines,
+		(maxWait + time.Minute).Milliseconds(),
+	).Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to buffer multiline event: %w", err)
+	}
+
+	if len(result) < 3 || result[0] != "flush" {
+		return nil, nil
+	}
+	return decodeMultilineEvent(result[1], result[2])
+}
+
+// ExpireMultiline removes and returns up to limit buffered events whose max wait has passed
+func (r *RedisClient) ExpireMultiline(ctx context.Context, limit int) ([]*MultilineEvent, error) {
+	now := time.Now().UnixMilli()
+	keys, err := r.client.ZRangeByScore(ctx, multilineDeadlinesKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now, 10),
+		Count: int64(limit),
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired multiline events: %w", err)
+	}
+
+	events := make([]*MultilineEvent, 0, len(keys))
+	for _, key := range keys {
+		result, err := multilineExpireScript.Run(ctx, r.client,
+			[]string{multilineDeadlinesKey, key, key + ":lines"},
+			now,
+		).Slice()
+		if err == redis.Nil {
+			// Flushed by another processor, or appended to since it was listed
+			continue
+		}
+		if err != nil {
+			return events, fmt.Errorf("failed to expire multiline event: %w", err)
+		}
+		if len(result) != 2 {
+			continue
+		}
+		event, err := decodeMultilineEvent(result[0], result[1])
+		if err != nil {
+			r.logger.WithError(err).Error("Dropping unreadable multiline event")
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// RetryMultilineLater puts a joined event whose storing failed back in Redis, to be returned by
+// RetryMultiline
+func (r *RedisClient) RetryMultilineLater(ctx context.Context, event *MultilineEvent) error {
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal multiline event: %w", err)
+	}
+	if err := r.client.RPush(ctx, multilineRetryKey, encoded).Err(); err != nil {
+		return fmt.Errorf("failed to requeue multiline event: %w", err)
+	}
+	return nil
+}
+
+// RetryMultiline removes and returns up to limit events put back by RetryMultilineLater, oldest first
+func (r *RedisClient) RetryMultiline(ctx context.Context, limit int) ([]*MultilineEvent, error) {
+	encoded, err := r.client.LPopCount(ctx, multilineRetryKey, limit).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read multiline retries: %w", err)
+	}
+
+	events := make([]*MultilineEvent, 0, len(encoded))
+	for _, item := range encoded {
+		event := &MultilineEvent{}
+		if err := json.Unmarshal([]byte(item), event); err != nil || event.Entry == nil {
+			r.logger.WithError(err).Error("Dropping unreadable multiline retry")
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func decodeMultilineEvent(rawEntry, rawLines interface{}) (*MultilineEvent, error) {
+	encoded, ok := rawEntry.(string)
+	if !ok {
+		return nil, fmt.Errorf("unexpected multiline event type %T", rawEntry)
+	}
+
+	event := &MultilineEvent{Entry: &models.LogEntry{}}
+	if err := json.Unmarshal([]byte(encoded), event.Entry); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal multiline event: %w", err)
+	}
+
+	lines, _ := rawLines.([]interface{})
+	for _, line := range lines {
+		if s, ok := line.(string); ok {
+			event.Lines = append(event.Lines, s)
+		}
+	}
+	return event, nil
+}
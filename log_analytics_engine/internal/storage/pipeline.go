@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+)
+
+type PipelineStorage struct {
+	db *sql.DB
+}
+
+func NewPipelineStorage(db *sql.DB) *PipelineStorage {
+	return &PipelineStorage{db: db}
+}
+
+const pipelineColumns = `id, user_id, name, source, service, priority, stages, multiline, is_active, created_at, updated_at`
+
+func (s *PipelineStorage) CreatePipeline(pipeline *models.Pipeline) error {
+	stages, multiline, err := marshalPipeline(pipeline)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO pipelines (user_id, name, source, service, priority, stages, multiline, is_active, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+        RETURNING id
+    `
+
+	now := time.Now()
+	err = s.db.QueryRow(
+		query,
+		pipeline.UserID,
+		pipeline.Name,
+		pipeline.Source,
+		pipeline.Service,
+		pipeline.Priority,
+		stages,
+		multiline,
+		pipeline.IsActive,
+		now,
+		now,
+	).Scan(&pipeline.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create pipeline: %w", err)
+	}
+
+	pipeline.CreatedAt = now
+	pipeline.UpdatedAt = now
+	return nil
+}
+
+// returns a pipeline owned by the user, or nil if it doesn't exist
+func (s *PipelineStorage) GetPipeline(id, userID int) (*models.Pipeline, error) {
+	query := `SELECT ` + pipelineColumns + ` FROM pipelines WHERE id = $1 AND user_id = $2`
+
+	pipeline, err := scanPipeline(s.db.QueryRow(query, id, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get pipeline: %w", err)
+	}
+	return pipeline, nil
+}
+
+// returns the user's pipelines in the order they are tried
+func (s *PipelineStorage) GetUserPipelines(userID int) ([]*models.Pipeline, error) {
+	return s.listPipelines(`SELECT `+pipelineColumns+` FROM pipelines WHERE user_id = $1 ORDER BY priority, id`, userID)
+}
+
+// returns the user's active pipelines in the order they are tried
+func (s *PipelineStorage) GetActivePipelines(userID int) ([]*models.Pipeline, error) {
+	return s.listPipelines(`SELECT `+pipelineColumns+` FROM pipelines WHERE user_id = $1 AND is_active = true ORDER BY priority, id`, userID)
+}
+
+func (s *PipelineStorage) listPipelines(query string, userID int) ([]*models.Pipeline, error) {
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pipelines: %w", err)
+	}
+	defer rows.Close()
+
+	var pipelines []*models.Pipeline
+	for rows.Next() {
+		pipeline, err := scanPipeline(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan pipeline: %w", err)
+		}
+		pipelines = append(pipelines, pipeline)
+	}
+
+	return pipelines, rows.Err()
+}
+
+func (s *PipelineStorage) UpdatePipeline(pipeline *models.Pipeline) error {
+	stages, multiline, err := marshalPipeline(pipeline)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        UPDATE pipelines
+        SET name = $1, source = $2, service = $3, priority = $4, stages = $5, multiline = $6, is_active = $7, updated_at = $8
+        WHERE id = $9 AND user_id = $10
+    `
+
+	pipeline.UpdatedAt = time.Now()
+	result, err := s.db.Exec(
+		query,
+		pipeline.Name,
+		pipeline.Source,
+		pipeline.Service,
+		pipeline.Priority,
+		stages,
+		multiline,
+		pipeline.IsActive,
+		pipeline.UpdatedAt,
+		pipeline.ID,
+		pipeline.UserID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update pipeline: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("pipeline not found or not owned by user")
+	}
+
+	return nil
+}
+
+func (s *PipelineStorage) DeletePipeline(id, userID int) error {
+	result, err := s.db.Exec(`DELETE FROM pipelines WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete pipeline: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("pipeline not found or not owned by user")
+	}
+
+	return nil
+}
+
+// encodes the JSONB columns of a pipeline; multiline is NULL when not configured
+func marshalPipeline(pipeline *models.Pipeline) ([]byte, []byte, error) {
+	if pipeline.Stages == nil {
+		pipeline.Stages = []models.PipelineStage{}
+	}
+	stages, err := json.Marshal(pipeline.Stages)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal stages: %w", err)
+	}
+
+	var multiline []byte
+	if pipeline.Multiline != nil {
+		multiline, err = json.Marshal(pipeline.Multiline)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to marshal multiline config: %w", err)
+		}
+	}
+
+	return stages, multiline, nil
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPipeline(row rowScanner) (*models.Pipeline, error) {
+	pipeline := &models.Pipeline{}
+	var stages, multiline []byte
+
+	err := row.Scan(
+		&pipeline.ID,
+		&pipeline.UserID,
+		&pipeline.Name,
+		&pipeline.Source,
+		&pipeline.Service,
+		&pipeline.Priority,
+		&stages,
+		&multiline,
+		&pipeline.IsActive,
+		&pipeline.CreatedAt,
+		&pipeline.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(stages, &pipeline.Stages); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal stages: %w", err)
+	}
+	if multiline != nil {
+		if err := json.Unmarshal(multiline, &pipeline.Multiline); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal multiline config: %w", err)
+		}
+	}
+
+	return pipeline, nil
+}
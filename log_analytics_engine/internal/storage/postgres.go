@@ -1,9 +1,13 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/lib/pq"
@@ -12,12 +16,20 @@ import (
 )
 
 type PostgresStorage struct {
-	db     *sql.DB
-	logger *logrus.Logger
+	db          *sql.DB
+	logger      *logrus.Logger
+	dedupWindow atomic.Int64 // nanoseconds event_ids are remembered, 0 for ever; changes on reload
+}
+
+// connection pool limits for NewPostgresStorage
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
 }
 
 // function makes a connection to the postgres database
-func NewPostgresStorage(connectionString string) (*PostgresStorage, error) {
+func NewPostgresStorage(connectionString string, pool PoolConfig) (*PostgresStorage, error) {
 	db, err := sql.Open("postgres", connectionString)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
@@ -29,9 +41,9 @@ func NewPostgresStorage(connectionString string) (*PostgresStorage, error) {
 	}
 
 	// Configure connection pool
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
 
 	logger := logrus.New()
 
@@ -46,45 +58,185 @@ func (s *PostgresStorage) Close() error {
 	return s.db.Close()
 }
 
+// checks that a connection from the pool can reach the database
+func (s *PostgresStorage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// reports whether a partition of logs (or a default partition) accepts rows timestamped now
+func (s *PostgresStorage) PartitionCoversNow(ctx context.Context) (bool, error) {
+	query := `
+        SELECT EXISTS (
+            SELECT 1
+            FROM pg_inherits i
+            JOIN pg_class c ON c.oid = i.inhrelid
+            CROSS JOIN LATERAL (SELECT pg_get_expr(c.relpartbound, c.oid) AS bound) b
+            WHERE i.inhparent = 'logs'::regclass
+              AND (
+                  b.bound = 'DEFAULT'
+                  OR (
+                      NOW() >= (regexp_match(b.bound, 'FROM \(''([^'']+)''\)'))[1]::timestamptz
+                      AND NOW() < (regexp_match(b.bound, 'TO \(''([^'']+)''\)'))[1]::timestamptz
+                  )
+              )
+        )
+    `
+
+	var covered bool
+	if err := s.db.QueryRowContext(ctx, query).Scan(&covered); err != nil {
+		return false, fmt.Errorf("failed to check partitions: %w", err)
+	}
+	return covered, nil
+}
+
+// returned by InsertLog when the (user_id, event_id) pair was already stored
+var ErrDuplicateEvent = errors.New("duplicate event_id")
+
 // stores a single log entry in the database
 func (s *PostgresStorage) InsertLog(log *models.LogEntry) error {
 	query := `
-        INSERT INTO logs (timestamp, source, level, message, service, fields, raw_message, created_at, user_id)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        INSERT INTO logs (timestamp, source, level, severity_number, message, service, fields, raw_message, created_at, received_at, user_id, event_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
         RETURNING id
     `
 
+	args, err := insertArgs(log)
+	if err != nil {
+		return err
+	}
+
+	// Logs without an event_id skip the dedup table and the transaction
+	if log.EventID == "" {
+		if err := s.db.QueryRow(query, args...).Scan(&log.ID); err != nil {
+			s.logger.WithError(err).Error("Failed to insert log")
+			return fmt.Errorf("failed to insert log: %w", err)
+		}
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	claimed, err := s.claimEventID(tx, log)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return ErrDuplicateEvent
+	}
+
+	if err := tx.QueryRow(query, args...).Scan(&log.ID); err != nil {
+		s.logger.WithError(err).Error("Failed to insert log")
+		return fmt.Errorf("failed to insert log: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// sets how long event_ids are remembered; an event_id seen again after the window is stored as a new
+// event, so a reused Idempotency-Key isn't dropped for ever. 0 remembers them for ever
+func (s *PostgresStorage) SetDedupWindow(window time.Duration) {
+	s.dedupWindow.Store(int64(window))
+}
+
+// records the log's event_id for its user, returning false if it was already recorded within the dedup
+// window. Claims are timed by when the ingester received the log, so queueing delays don't shorten the window
+func (s *PostgresStorage) claimEventID(tx *sql.Tx, log *models.LogEntry) (bool, error) {
+	seenAt := log.ReceivedAt
+	if seenAt.IsZero() {
+		seenAt = time.Now()
+	}
+
+	query := `
+        INSERT INTO log_event_ids (user_id, event_id, created_at)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (user_id, event_id) DO NOTHING
+    `
+	args := []interface{}{log.UserID, log.EventID, seenAt}
+	if window := time.Duration(s.dedupWindow.Load()); window > 0 {
+		query = `
+            INSERT INTO log_event_ids (user_id, event_id, created_at)
+            VALUES ($1, $2, $3)
+            ON CONFLICT (user_id, event_id) DO UPDATE SET created_at = EXCLUDED.created_at
+            WHERE log_event_ids.created_at < EXCLUDED.created_at - make_interval(secs => $4)
+        `
+		args = append(args, window.Seconds())
+	}
+
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to record event_id: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check affected rows: %w", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// deletes event_ids older than the dedup window, returning how many were removed
+func (s *PostgresStorage) PruneEventIDs(ctx context.Context) (int64, error) {
+	window := time.Duration(s.dedupWindow.Load())
+	if window <= 0 {
+		return 0, nil
+	}
+	result, err := s.db.ExecContext(ctx, `DELETE FROM log_event_ids WHERE created_at < NOW() - make_interval(secs => $1)`,
+		window.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune event_ids: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// builds the INSERT arguments for a log entry, in column order
+func insertArgs(log *models.LogEntry) ([]interface{}, error) {
 	// Convert fields map to JSON
 	var fieldsJSON interface{}
-	var err error
-	if log.Fields != nil && len(log.Fields) > 0 {
-		fieldsJSON, err = json.Marshal(log.Fields)
+	if len(log.Fields) > 0 {
+		encoded, err := json.Marshal(log.Fields)
 		if err != nil {
-			return fmt.Errorf("failed to marshal fields: %w", err)
+			return nil, fmt.Errorf("failed to marshal fields: %w", err)
 		}
-	} else {
-		fieldsJSON = nil
+		fieldsJSON = encoded
+	}
+
+	// Pipelines and ingest rules may have changed the level since the entry was built
+	log.SeverityNumber = models.SeverityNumber(log.Level)
+
+	// Entries queued before received_at existed were created when they were received
+	receivedAt := log.ReceivedAt
+	if receivedAt.IsZero() {
+		receivedAt = log.CreatedAt
 	}
 
-	err = s.db.QueryRow(
-		query,
+	var eventID interface{}
+	if log.EventID != "" {
+		eventID = log.EventID
+	}
+
+	return []interface{}{
 		log.Timestamp,
 		log.Source,
 		log.Level,
+		log.SeverityNumber,
 		log.Message,
 		log.Service,
 		fieldsJSON,
 		log.RawMessage,
 		log.CreatedAt,
+		receivedAt,
 		log.UserID,
-	).Scan(&log.ID)
-
-	if err != nil {
-		s.logger.WithError(err).Error("Failed to insert log")
-		return fmt.Errorf("failed to insert log: %w", err)
-	}
-
-	return nil
+		eventID,
+	}, nil
 }
 
 // stores multiple log entries in a single transaction
@@ -100,8 +252,8 @@ func (s *PostgresStorage) InsertLogs(logs []*models.LogEntry) error {
 	defer tx.Rollback()
 
 	query := `
-        INSERT INTO logs (timestamp, source, level, message, service, fields, raw_message, created_at, user_id)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        INSERT INTO logs (timestamp, source, level, severity_number, message, service, fields, raw_message, created_at, received_at, user_id, event_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
     `
 
 	stmt, err := tx.Prepare(query)
@@ -110,29 +262,25 @@ func (s *PostgresStorage) InsertLogs(logs []*models.LogEntry) error {
 	}
 	defer stmt.Close()
 
+	duplicates := 0
 	for _, log := range logs {
-		var fieldsJSON interface{}
-		if log.Fields != nil && len(log.Fields) > 0 {
-			fieldsJSON, err = json.Marshal(log.Fields)
+		if log.EventID != "" {
+			claimed, err := s.claimEventID(tx, log)
 			if err != nil {
-				return fmt.Errorf("failed to marshal fields: %w", err)
+				return err
+			}
+			if !claimed {
+				duplicates++
+				continue
 			}
-		} else {
-			fieldsJSON = nil
 		}
 
-		_, err = stmt.Exec(
-			log.Timestamp,
-			log.Source,
-			log.Level,
-			log.Message,
-			log.Service,
-			fieldsJSON,
-			log.RawMessage,
-			log.CreatedAt,
-			log.UserID,
-		)
+		args, err := insertArgs(log)
 		if err != nil {
+			return err
+		}
+
+		if _, err = stmt.Exec(args...); err != nil {
 			s.logger.WithError(err).Error("Failed to execute insert")
 			return fmt.Errorf("failed to insert log: %w", err)
 		}
@@ -142,20 +290,59 @@ func (s *PostgresStorage) InsertLogs(logs []*models.LogEntry) error {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	s.logger.Infof("Successfully inserted %d logs", len(logs))
+	s.logger.Infof("Successfully inserted %d logs (%d duplicates skipped)", len(logs)-duplicates, duplicates)
 	return nil
 }
 
-// retrieves recent log entries (for testing)
-func (s *PostgresStorage) GetRecentLogs(limit int) ([]*models.LogEntry, error) {
-	query := `
-        SELECT id, timestamp, source, level, message, service, fields, raw_message, created_at
-        FROM logs
-        ORDER BY timestamp DESC
-        LIMIT $1
-    `
+// GetDB exposes the database connection for auth storage
+func (s *PostgresStorage) GetDB() *sql.DB {
+	return s.db
+}
+
+var postgresDialect = sqlDialect{
+	placeholder: func(n int) string { return fmt.Sprintf("$%d", n) },
+	containsOp:  "ILIKE",
+	timeArg:     func(t time.Time) interface{} { return t },
+	timeBucket: func(column, width string) string {
+		return fmt.Sprintf("(floor(extract(epoch FROM %s) / %s) * %s)::bigint", column, width, width)
+	},
+	search: func(b *queryBuilder, q *models.SearchQuery) string {
+		return fmt.Sprintf("message_tsv @@ to_tsquery('%s', %s)", searchConfig, b.arg(tsquery(q)))
+	},
+	regex: func(column, pattern string, ignoreCase bool) string {
+		if ignoreCase {
+			return column + " ~* " + pattern
+		}
+		return column + " ~ " + pattern
+	},
+	fieldKey: func(b *queryBuilder, key string) string {
+		return "(fields ->> " + b.arg(key) + "::text)"
+	},
+}
+
+const logColumns = `id, timestamp, source, level, severity_number, message, COALESCE(service, ''), fields, COALESCE(raw_message, ''), created_at, received_at, user_id, COALESCE(event_id, '')`
+
+// QueryLogs returns a page of the logs matching the query
+func (s *PostgresStorage) QueryLogs(q *models.LogQuery) ([]*models.LogEntry, error) {
+	b := &queryBuilder{dialect: postgresDialect}
+	where, err := b.where(q)
+	if err != nil {
+		return nil, err
+	}
+	order, err := orderBy(q)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM logs WHERE %s ORDER BY %s`, logColumns, where, order)
+	if q.Limit > 0 {
+		query += " LIMIT " + b.arg(q.Limit)
+	}
+	if q.Offset > 0 {
+		query += " OFFSET " + b.arg(q.Offset)
+	}
 
-	rows, err := s.db.Query(query, limit)
+	rows, err := s.db.Query(query, b.args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query logs: %w", err)
 	}
@@ -163,161 +350,151 @@ func (s *PostgresStorage) GetRecentLogs(limit int) ([]*models.LogEntry, error) {
 
 	var logs []*models.LogEntry
 	for rows.Next() {
-		log := &models.LogEntry{}
-		var fieldsJSON []byte
-
-		err := rows.Scan(
-			&log.ID,
-			&log.Timestamp,
-			&log.Source,
-			&log.Level,
-			&log.Message,
-			&log.Service,
-			&fieldsJSON,
-			&log.RawMessage,
-			&log.CreatedAt,
-		)
+		log, err := s.scanLog(rows)
 		if err != nil {
 			s.logger.WithError(err).Error("Failed to scan log row")
 			continue
 		}
-
-		// Unmarshal fields JSON
-		if len(fieldsJSON) > 0 {
-			err = json.Unmarshal(fieldsJSON, &log.Fields)
-			if err != nil {
-				s.logger.WithError(err).Error("Failed to unmarshal fields")
-			}
-		}
-
 		logs = append(logs, log)
 	}
 
-	return logs, nil
+	return logs, rows.Err()
 }
 
-// GetDB exposes the database connection for auth storage
-func (s *PostgresStorage) GetDB() *sql.DB {
-	return s.db
-}
+// text search configuration of the logs.message_tsv column; queries must use the same one
+const searchConfig = "simple"
 
-// GetRecentLogsByUser retrieves recent log entries for a specific user
-func (s *PostgresStorage) GetRecentLogsByUser(userID int, limit int) ([]*models.LogEntry, error) {
-	query := `
-        SELECT id, timestamp, source, level, message, service, fields, raw_message, created_at, user_id
-        FROM logs
-        WHERE user_id = $1
-        ORDER BY timestamp DESC
-        LIMIT $2
-    `
+// ts_headline options; the message is HTML-escaped before highlighting, so snippets are safe to render
+const headlineOptions = `StartSel=<mark>, StopSel=</mark>, MinWords=15, MaxWords=35, MaxFragments=2, FragmentDelimiter=" … "`
 
-	rows, err := s.db.Query(query, userID, limit)
+const postgresEscapedMessage = `replace(replace(replace(message, '&', '&amp;'), '<', '&lt;'), '>', '&gt;')`
+
+// SearchLogs returns a page of the logs matching the query's search, with their rank and highlighted snippets
+func (s *PostgresStorage) SearchLogs(q *models.LogQuery) ([]*models.LogEntry, []models.SearchMatch, error) {
+	if q.Search == nil {
+		return nil, nil, fmt.Errorf("search logs needs a search")
+	}
+	b := &queryBuilder{dialect: postgresDialect}
+	where, err := b.where(q)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query logs: %w", err)
+		return nil, nil, err
+	}
+	order := "rank DESC, id DESC"
+	if q.SortBy != "" && q.SortBy != models.SortRelevance {
+		if order, err = orderBy(q); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	search := fmt.Sprintf("to_tsquery('%s', %s)", searchConfig, b.arg(tsquery(q.Search)))
+	query := fmt.Sprintf(`SELECT %s, ts_rank_cd(message_tsv, %s) AS rank, ts_headline('%s', %s, %s, '%s')
+        FROM logs WHERE %s ORDER BY %s`,
+		logColumns, search, searchConfig, postgresEscapedMessage, search, headlineOptions, where, order)
+	if q.Limit > 0 {
+		query += " LIMIT " + b.arg(q.Limit)
+	}
+	if q.Offset > 0 {
+		query += " OFFSET " + b.arg(q.Offset)
+	}
+
+	rows, err := s.db.Query(query, b.args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to search logs: %w", err)
 	}
 	defer rows.Close()
 
 	var logs []*models.LogEntry
+	var matches []models.SearchMatch
 	for rows.Next() {
-		log := &models.LogEntry{}
-		var fieldsJSON []byte
-
-		err := rows.Scan(
-			&log.ID,
-			&log.Timestamp,
-			&log.Source,
-			&log.Level,
-			&log.Message,
-			&log.Service,
-			&fieldsJSON,
-			&log.RawMessage,
-			&log.CreatedAt,
-			&log.UserID,
-		)
+		var match models.SearchMatch
+		log, err := s.scanLog(rows, &match.Rank, &match.Snippet)
 		if err != nil {
 			s.logger.WithError(err).Error("Failed to scan log row")
 			continue
 		}
-
-		// Unmarshal fields JSON
-		if len(fieldsJSON) > 0 {
-			err = json.Unmarshal(fieldsJSON, &log.Fields)
-			if err != nil {
-				s.logger.WithError(err).Error("Failed to unmarshal fields")
-			}
-		}
-
+		match.LogID = log.ID
 		logs = append(logs, log)
+		matches = append(matches, match)
 	}
 
-	return logs, nil
+	return logs, matches, rows.Err()
 }
 
-// QueryLogs executes a filtered query on logs with pagination
-func (s *PostgresStorage) QueryLogs(userID int, whereClause string, args []interface{}, sortBy, sortOrder string, limit, offset int) ([]*models.LogEntry, error) {
-	query := fmt.Sprintf(`
-        SELECT id, timestamp, source, level, message, service, fields, raw_message, created_at, user_id
-        FROM logs
-        WHERE %s
-        ORDER BY %s %s
-        LIMIT $%d OFFSET $%d
-    `, whereClause, sortBy, sortOrder, len(args)+1, len(args)+2)
-
-	// Add limit and offset to args
-	args = append(args, limit, offset)
-
-	rows, err := s.db.Query(query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query logs: %w", err)
+// reads a row starting with logColumns, followed by any extra columns
+func (s *PostgresStorage) scanLog(rows *sql.Rows, extra ...interface{}) (*models.LogEntry, error) {
+	log := &models.LogEntry{}
+	var fieldsJSON []byte
+
+	dest := []interface{}{
+		&log.ID,
+		&log.Timestamp,
+		&log.Source,
+		&log.Level,
+		&log.SeverityNumber,
+		&log.Message,
+		&log.Service,
+		&fieldsJSON,
+		&log.RawMessage,
+		&log.CreatedAt,
+		&log.ReceivedAt,
+		&log.UserID,
+		&log.EventID,
+	}
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
 	}
-	defer rows.Close()
 
-	var logs []*models.LogEntry
-	for rows.Next() {
-		log := &models.LogEntry{}
-		var fieldsJSON []byte
-
-		err := rows.Scan(
-			&log.ID,
-			&log.Timestamp,
-			&log.Source,
-			&log.Level,
-			&log.Message,
-			&log.Service,
-			&fieldsJSON,
-			&log.RawMessage,
-			&log.CreatedAt,
-			&log.UserID,
-		)
-		if err != nil {
-			s.logger.WithError(err).Error("Failed to scan log row")
-			continue
+	// Unmarshal fields JSON
+	if len(fieldsJSON) > 0 {
+		if err := json.Unmarshal(fieldsJSON, &log.Fields); err != nil {
+			s.logger.WithError(err).Error("Failed to unmarshal fields")
 		}
+	}
+	return log, nil
+}
 
-		// Unmarshal fields JSON
-		if len(fieldsJSON) > 0 {
-			err = json.Unmarshal(fieldsJSON, &log.Fields)
-			if err != nil {
-				s.logger.WithError(err).Error("Failed to unmarshal fields")
-			}
+// compiles a search to tsquery syntax; words are quoted, so to_tsquery normalizes them like messages
+// but never reads them as operators
+func tsquery(q *models.SearchQuery) string {
+	switch q.Op {
+	case models.SearchAnd, models.SearchOr:
+		op := " & "
+		if q.Op == models.SearchOr {
+			op = " | "
 		}
-
-		logs = append(logs, log)
+		parts := make([]string, len(q.Children))
+		for i, child := range q.Children {
+			parts[i] = tsquery(child)
+		}
+		return "(" + strings.Join(parts, op) + ")"
+	case models.SearchNot:
+		return "!" + tsquery(q.Children[0])
 	}
 
-	return logs, nil
+	lexemes := make([]string, len(q.Words))
+	for i, word := range q.Words {
+		word = strings.ReplaceAll(word, `\`, `\\`)
+		lexemes[i] = "'" + strings.ReplaceAll(word, "'", "''") + "'"
+	}
+	if q.Prefix {
+		return lexemes[0] + ":*"
+	}
+	if len(lexemes) == 1 {
+		return lexemes[0]
+	}
+	return "(" + strings.Join(lexemes, " <-> ") + ")"
 }
 
 // CountLogs counts the total number of logs matching the query
-func (s *PostgresStorage) CountLogs(userID int, whereClause string, args []interface{}) (int, error) {
-	query := fmt.Sprintf(`
-        SELECT COUNT(*)
-        FROM logs
-        WHERE %s
-    `, whereClause)
+func (s *PostgresStorage) CountLogs(q *models.LogQuery) (int, error) {
+	b := &queryBuilder{dialect: postgresDialect}
+	where, err := b.where(q)
+	if err != nil {
+		return 0, err
+	}
 
 	var count int
-	err := s.db.QueryRow(query, args...).Scan(&count)
+	err = s.db.QueryRow(`SELECT COUNT(*) FROM logs WHERE `+where, b.args...).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count logs: %w", err)
 	}
@@ -326,13 +503,14 @@ func (s *PostgresStorage) CountLogs(userID int, whereClause string, args []inter
 }
 
 // DeleteLogs deletes logs matching the query
-func (s *PostgresStorage) DeleteLogs(userID int, whereClause string, args []interface{}) (int, error) {
-	query := fmt.Sprintf(`
-        DELETE FROM logs
-        WHERE %s
-    `, whereClause)
+func (s *PostgresStorage) DeleteLogs(q *models.LogQuery) (int, error) {
+	b := &queryBuilder{dialect: postgresDialect}
+	where, err := b.where(q)
+	if err != nil {
+		return 0, err
+	}
 
-	result, err := s.db.Exec(query, args...)
+	result, err := s.db.Exec(`DELETE FROM logs WHERE `+where, b.args...)
 	if err != nil {
 		return 0, fmt.Errorf("failed to delete logs: %w", err)
 	}
@@ -344,3 +522,20 @@ func (s *PostgresStorage) DeleteLogs(userID int, whereClause string, args []inte
 
 	return int(rowsAffected), nil
 }
+
+// AggregateLogs counts the logs matching the query per group and time bucket
+func (s *PostgresStorage) AggregateLogs(q *models.AggregateQuery) ([]models.AggregateBucket, error) {
+	b := &queryBuilder{dialect: postgresDialect}
+	query, err := b.aggregate(q)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate logs: %w", err)
+	}
+	defer rows.Close()
+
+	return scanAggregateRows(rows, q)
+}
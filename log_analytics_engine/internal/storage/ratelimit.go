@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+)
+
+/*
+This file implements ingestion rate limiting in Redis:
+token buckets per user and per API key for events/second and bytes/second
+daily event and byte quotas per user, reset at midnight UTC
+all checks run in one Lua script so a request is either charged to every bucket or to none
+*/
+
+// reasons returned by the rate limit script, indexed by the check that failed
+var rateLimitReasons = []string{
+	"user_events_per_second",
+	"user_bytes_per_second",
+	"key_events_per_second",
+	"key_bytes_per_second",
+	"daily_event_quota",
+	"daily_byte_quota",
+}
+
+// KEYS: 4 bucket hashes (user events, user bytes, key events, key bytes) then 2 quota counters (events, bytes)
+// ARGV: now_ms, events, bytes, 4 bucket rates, 2 quotas, seconds until the quotas reset
+//
+// Buckets hold at most one second of tokens. A request may overdraw a bucket as long as the bucket
+// holds enough tokens for min(cost, capacity), so batches larger than the per-second rate are still
+// accepted and the debt is paid back before the next request is allowed.
+var rateLimitScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local costs = {tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[2]), tonumber(ARGV[3])}
+local tokens = {}
+
+for i = 1, 4 do
+  local rate = tonumber(ARGV[3 + i])
+  if rate > 0 then
+    local state = redis.call('HMGET', KEYS[i], 'tokens', 'ts')
+    local current = tonumber(state[1])
+    local ts = tonumber(state[2])
+    if current == nil then
+      current = rate
+      ts = now
+    end
+    current = math.min(rate, current + (math.max(0, now - ts) / 1000.0) * rate)
+    local needed = math.min(costs[i], rate)
+    if current < needed then
+      return {0, math.ceil((needed - current) / rate * 1000), i, math.floor(current)}
+    end
+    tokens[i] = current
+  end
+end
+
+local reset = tonumber(ARGV[10])
+for i = 1, 2 do
+  local quota = tonumber(ARGV[7 + i])
+  if quota > 0 then
+    local used = tonumber(redis.call('GET', KEYS[4 + i]) or '0')
+    if used + costs[i] > quota then
+      return {0, reset * 1000, 4 + i, 0}
+    end
+  end
+end
+
+for i = 1, 4 do
+  local rate = tonumber(ARGV[3 + i])
+  if rate > 0 then
+    redis.call('HSET', KEYS[i], 'tokens', tokens[i] - costs[i], 'ts', now)
+    redis.call('PEXPIRE', KEYS[i], 60000 + math.ceil(math.max(0, costs[i] - tokens[i]) / rate * 1000))
+  end
+end
+
+for i = 1, 2 do
+  redis.call('INCRBY', KEYS[4 + i], costs[i])
+  redis.call('EXPIRE', KEYS[4 + i], reset + 86400)
+end
+
+local remaining = -1
+if tonumber(ARGV[4]) > 0 then
+  remaining = math.max(0, math.floor(tokens[1] - costs[1]))
+end
+return {1, 0, 0, remaining}
+`)
+
+// RateLimitResult is the outcome of charging a request against the user's limits
+type RateLimitResult struct {
+	Allowed    bool
+	RetryAfter time.Duration
+	Reason     string // which limit was exceeded, empty when allowed
+	Limit      int64  // events per second for the user, 0 when unlimited
+	Remaining  int64  // events left in the user's bucket, -1 when unlimited
+	Reset      time.Duration
+}
+
+// hashes an API key so it can be used in Redis keys without storing the secret
+func apiKeyID(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:8])
+}
+
+// returns the UTC day used for daily quotas and the time until it ends
+func quotaDay(now time.Time) (string, time.Duration) {
+	now = now.UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	return now.Format("20060102"), midnight.Sub(now)
+}
+
+// TakeIngestTokens charges events and bytes against the user's and API key's buckets and daily quotas
+func (r *RedisClient) TakeIngestTokens(ctx context.Context, limits *models.IngestLimits, apiKey string, events, bytes int64) (*RateLimitResult, error) {
+	now := time.Now()
+	day, untilReset := quotaDay(now)
+	keyID := apiKeyID(apiKey)
+
+	keys := []string{
+		fmt.Sprintf("ratelimit:user:%d:events", limits.UserID),
+		fmt.Sprintf("ratelimit:user:%d:bytes", limits.UserID),
+		fmt.Sprintf("ratelimit:key:%s:events", keyID),
+		fmt.Sprintf("ratelimit:key:%s:bytes", keyID),
+		fmt.Sprintf("quota:%d:%s:events", limits.UserID, day),
+		fmt.Sprintf("quota:%d:%s:bytes", limits.UserID, day),
+	}
+
+	keyEvents, keyBytes := limits.KeyEventsPerSecond, limits.KeyBytesPerSecond
+	if apiKey == "" {
+		keyEvents, keyBytes = 0, 0
+	}
+
+	values, err := rateLimitScript.Run(ctx, r.client, keys,
+		now.UnixMilli(),
+		events,
+		bytes,
+		limits.EventsPerSecond,
+		limits.BytesPerSecond,
+		keyEvents,
+		keyBytes,
+		limits.DailyEventQuota,
+		limits.DailyByteQuota,
+		int64(untilReset.Seconds())+1,
+	).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run rate limit script: %w", err)
+	}
+
+	result := &RateLimitResult{
+		Allowed:   values[0] == 1,
+		Limit:     limits.EventsPerSecond,
+		Remaining: values[3],
+		Reset:     time.Second,
+	}
+	if !result.Allowed {
+		result.RetryAfter = time.Duration(values[1]) * time.Millisecond
+		result.Reason = rateLimitReasons[values[2]-1]
+		result.Reset = result.RetryAfter
+		if values[2] <= 4 && values[2] != 1 {
+			// Remaining only describes the user events bucket
+			result.Remaining = -1
+		}
+		r.client.IncrBy(ctx, fmt.Sprintf("quota:%d:%s:rejected", limits.UserID, day), events)
+		r.client.Expire(ctx, fmt.Sprintf("quota:%d:%s:rejected", limits.UserID, day), untilReset+24*time.Hour)
+	}
+
+	return result, nil
+}
+
+// GetIngestUsage returns today's usage counters and the tokens left in the user's events bucket
+func (r *RedisClient) GetIngestUsage(ctx context.Context, limits *models.IngestLimits) (*models.IngestUsage, error) {
+	now := time.Now()
+	day, untilReset := quotaDay(now)
+
+	pipe := r.client.Pipeline()
+	eventsCmd := pipe.Get(ctx, fmt.Sprintf("quota:%d:%s:events", limits.UserID, day))
+	bytesCmd := pipe.Get(ctx, fmt.Sprintf("quota:%d:%s:bytes", limits.UserID, day))
+	rejectedCmd := pipe.Get(ctx, fmt.Sprintf("quota:%d:%s:rejected", limits.UserID, day))
+	bucketCmd := pipe.HMGet(ctx, fmt.Sprintf("ratelimit:user:%d:events", limits.UserID), "tokens", "ts")
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get ingest usage: %w", err)
+	}
+
+	usage := &models.IngestUsage{
+		Limits:          limits,
+		QuotaResetsAt:   now.Add(untilReset).Truncate(time.Second),
+		EventsAvailable: -1,
+	}
+	usage.EventsToday, _ = eventsCmd.Int64()
+	usage.BytesToday, _ = bytesCmd.Int64()
+	usage.RejectedToday, _ = rejectedCmd.Int64()
+
+	if limits.EventsPerSecond > 0 {
+		usage.EventsAvailable = limits.EventsPerSecond
+		state := bucketCmd.Val()
+		if len(state) == 2 && state[0] != nil && state[1] != nil {
+			tokens, _ := strconv.ParseFloat(fmt.Sprint(state[0]), 64)
+			ts, _ := strconv.ParseInt(fmt.Sprint(state[1]), 10, 64)
+			elapsed := float64(now.UnixMilli()-ts) / 1000.0
+			refilled := tokens + elapsed*float64(limits.EventsPerSecond)
+			if refilled > float64(limits.EventsPerSecond) {
+				refilled = float64(limits.EventsPerSecond)
+			}
+			if refilled < 0 {
+				refilled = 0
+			}
+			usage.EventsAvailable = int64(refilled)
+		}
+	}
+
+	return usage, nil
+}
+
+// CacheIngestLimits caches a user's effective limits so the ingest path doesn't hit Postgres on every request
+func (r *RedisClient) CacheIngestLimits(ctx context.Context, limits *models.IngestLimits, ttl time.Duration) error {
+	return r.setJSON(ctx, fmt.Sprintf("limits:%d", limits.UserID), limits, ttl)
+}
+
+// GetCachedIngestLimits returns cached limits, or nil if they are not cached
+func (r *RedisClient) GetCachedIngestLimits(ctx context.Context, userID int) (*models.IngestLimits, error) {
+	var limits models.IngestLimits
+	found, err := r.getJSON(ctx, fmt.Sprintf("limits:%d", userID), &limits)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &limits, nil
+}
+
+// InvalidateIngestLimits drops cached limits after an admin changes them
+func (r *RedisClient) InvalidateIngestLimits(ctx context.Context, userID int) error {
+	if err := r.client.Del(ctx, fmt.Sprintf("limits:%d", userID)).Err(); err != nil {
+		return fmt.Errorf("failed to invalidate cached limits: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+)
+
+type RedactionStorage struct {
+	db *sql.DB
+}
+
+func NewRedactionStorage(db *sql.DB) *RedactionStorage {
+	return &RedactionStorage{db: db}
+}
+
+const redactionRuleColumns = `id, user_id, name, detector, pattern, action, is_active, created_at, updated_at`
+
+func (s *RedactionStorage) CreateRule(rule *models.RedactionRule) error {
+	query := `
+        INSERT INTO redaction_rules (user_id, name, detector, pattern, action, is_active, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id
+    `
+
+	now := time.Now()
+	err := s.db.QueryRow(
+		query,
+		rule.UserID,
+		rule.Name,
+		rule.Detector,
+		rule.Pattern,
+		rule.Action,
+		rule.IsActive,
+		now,
+		now,
+	).Scan(&rule.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create redaction rule: %w", err)
+	}
+
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+	return nil
+}
+
+// returns all of the user's rules, in the order they are applied
+func (s *RedactionStorage) GetUserRules(userID int) ([]*models.RedactionRule, error) {
+	return s.listRules(`SELECT `+redactionRuleColumns+` FROM redaction_rules WHERE user_id = $1 ORDER BY id`, userID)
+}
+
+// returns the user's active rules, in the order they are applied
+func (s *RedactionStorage) GetActiveRules(userID int) ([]*models.RedactionRule, error) {
+	return s.listRules(`SELECT `+redactionRuleColumns+` FROM redaction_rules WHERE user_id = $1 AND is_active = true ORDER BY id`, userID)
+}
+
+func (s *RedactionStorage) listRules(query string, userID int) ([]*models.RedactionRule, error) {
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get redaction rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []*models.RedactionRule
+	for rows.Next() {
+		rule := &models.RedactionRule{}
+		err := rows.Scan(
+			&rule.ID,
+			&rule.UserID,
+			&rule.Name,
+			&rule.Detector,
+			&rule.Pattern,
+			&rule.Action,
+			&rule.IsActive,
+			&rule.CreatedAt,
+			&rule.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan redaction rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+func (s *RedactionStorage) UpdateRule(rule *models.RedactionRule) error {
+	query := `
+        UPDATE redaction_rules
+        SET name = $1, detector = $2, pattern = $3, action = $4, is_active = $5, updated_at = $6
+        WHERE id = $7 AND user_id = $8
+        RETURNING created_at
+    `
+
+	rule.UpdatedAt = time.Now()
+	err := s.db.QueryRow(
+		query,
+		rule.Name,
+		rule.Detector,
+		rule.Pattern,
+		rule.Action,
+		rule.IsActive,
+		rule.UpdatedAt,
+		rule.ID,
+		rule.UserID,
+	).Scan(&rule.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("redaction rule not found or not owned by user")
+		}
+		return fmt.Errorf("failed to update redaction rule: %w", err)
+	}
+
+	return nil
+}
+
+func (s *RedactionStorage) DeleteRule(id, userID int) error {
+	result, err := s.db.Exec(`DELETE FROM redaction_rules WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete redaction rule: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("redaction rule not found or not owned by user")
+	}
+
+	return nil
+}
+
+// returns the Redis hash holding a user's redaction hit counters, keyed by rule
+func redactionHitsKey(userID int) string {
+	return "redaction:hits:" + strconv.Itoa(userID)
+}
+
+// IncrRedactionHits adds to a user's per-rule hit counters
+func (r *RedisClient) IncrRedactionHits(ctx context.Context, userID int, hits map[string]int64) error {
+	if len(hits) == 0 {
+		return nil
+	}
+
+	pipe := r.client.Pipeline()
+	for rule, count := range hits {
+		pipe.HIncrBy(ctx, redactionHitsKey(userID), rule, count)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to count redaction hits: %w", err)
+	}
+	return nil
+}
+
+// GetRedactionHits returns a user's hit counters keyed by rule
+func (r *RedisClient) GetRedactionHits(ctx context.Context, userID int) (map[string]int64, error) {
+	values, err := r.client.HGetAll(ctx, redactionHitsKey(userID)).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get redaction hits: %w", err)
+	}
+
+	hits := make(map[string]int64, len(values))
+	for rule, value := range values {
+		hits[rule], _ = strconv.ParseInt(value, 10, 64)
+	}
+	return hits, nil
+}
+
+// ResetRedactionHits clears the counter of one rule
+func (r *RedisClient) ResetRedactionHits(ctx context.Context, userID int, rule string) error {
+	if err := r.client.HDel(ctx, redactionHitsKey(userID), rule).Err(); err != nil {
+		return fmt.Errorf("failed to reset redaction hits: %w", err)
+	}
+	return nil
+}
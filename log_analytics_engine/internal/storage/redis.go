@@ -2,31 +2,52 @@ package storage
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/consumer"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/metrics"
 	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/queue"
 	"github.com/sirupsen/logrus"
 )
 
+var _ queue.Queue = (*RedisClient)(nil)
+
 type RedisClient struct {
 	client *redis.Client
+	stream string
 	logger *logrus.Logger
 }
 
+// connection settings for NewRedisClient
+type RedisOptions struct {
+	Addr         string
+	Password     string
+	DB           int
+	PoolSize     int
+	MinIdleConns int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	Stream       string // stream carrying logs to the processors
+}
+
 // creates a new Redis Client for the server to connect to
-func NewRedisClient(addr string, password string, db int) (*RedisClient, error) {
+func NewRedisClient(opts RedisOptions) (*RedisClient, error) {
 	client := redis.NewClient(&redis.Options{
-		Addr:         addr,
-		Password:     password,
-		DB:           db,
-		DialTimeout:  5 * time.Second,
-		ReadTimeout:  3 * time.Second,
-		WriteTimeout: 3 * time.Second,
-		PoolSize:     10,
-		MinIdleConns: 5,
+		Addr:         opts.Addr,
+		Password:     opts.Password,
+		DB:           opts.DB,
+		DialTimeout:  opts.DialTimeout,
+		ReadTimeout:  opts.ReadTimeout,
+		WriteTimeout: opts.WriteTimeout,
+		PoolSize:     opts.PoolSize,
+		MinIdleConns: opts.MinIdleConns,
 	})
 
 	// Test connection
@@ -42,6 +63,7 @@ func NewRedisClient(addr string, password string, db int) (*RedisClient, error)
 
 	return &RedisClient{
 		client: client,
+		stream: opts.Stream,
 		logger: logger,
 	}, nil
 }
@@ -59,20 +81,23 @@ func (r *RedisClient) PublishLog(ctx context.Context, log *models.LogEntry) erro
 	}
 
 	// Add to Redis Stream
-	streamName := "logs:incoming"
+	start := time.Now()
 	result := r.client.XAdd(ctx, &redis.XAddArgs{
-		Stream: streamName,
+		Stream: r.stream,
 		Values: map[string]interface{}{
 			"log": string(logJSON),
 		},
 	})
 
+	metrics.PublishDuration.WithLabelValues("single").Observe(time.Since(start).Seconds())
 	if err := result.Err(); err != nil {
+		metrics.Errors.WithLabelValues("publish").Inc()
 		return fmt.Errorf("failed to add log to stream: %w", err)
 	}
+	metrics.LogsIngested.Inc()
 
 	r.logger.WithFields(logrus.Fields{
-		"stream":  streamName,
+		"stream":  r.stream,
 		"log_id":  result.Val(),
 		"user_id": log.UserID,
 		"level":   log.Level,
@@ -87,7 +112,6 @@ func (r *RedisClient) PublishLogs(ctx context.Context, logs []*models.LogEntry)
 		return nil
 	}
 
-	streamName := "logs:incoming"
 	pipe := r.client.Pipeline()
 
 	for _, log := range logs {
@@ -98,137 +122,117 @@ func (r *RedisClient) PublishLogs(ctx context.Context, logs []*models.LogEntry)
 		}
 
 		pipe.XAdd(ctx, &redis.XAddArgs{
-			Stream: streamName,
+			Stream: r.stream,
 			Values: map[string]interface{}{
 				"log": string(logJSON),
 			},
 		})
 	}
 
-	_, err := pipe.Exec(ctx)
+	start := time.Now()
+	cmds, err := pipe.Exec(ctx)
+	metrics.PublishDuration.WithLabelValues("batch").Observe(time.Since(start).Seconds())
 	if err != nil {
+		metrics.Errors.WithLabelValues("publish").Inc()
 		return fmt.Errorf("failed to publish batch logs: %w", err)
 	}
+	metrics.LogsIngested.Add(float64(len(cmds)))
 
 	r.logger.WithField("count", len(logs)).Info("Batch logs published to stream")
 	return nil
 }
 
-// consumes logs from Redis Stream
-func (r *RedisClient) ConsumeLogStream(ctx context.Context, consumerGroup, consumerName string, handler func(*models.LogEntry) error) error {
-	streamName := "logs:incoming"
-
-	// Create consumer group if it doesn't exist
-	err := r.client.XGroupCreateMkStream(ctx, streamName, consumerGroup, "0").Err()
+// creates the consumer group on the log stream if it doesn't exist yet
+func (r *RedisClient) EnsureGroup(ctx context.Context, group string) error {
+	err := r.client.XGroupCreateMkStream(ctx, r.stream, group, "0").Err()
 	if err != nil && err.Error() != "BUSYGROUP Consumer Group name already exists" {
-		return fmt.Errorf("failed to create consumer group: %w", err)
+		return err
 	}
+	return nil
+}
 
-	r.logger.WithFields(logrus.Fields{
-		"stream":   streamName,
-		"group":    consumerGroup,
-		"consumer": consumerName,
-	}).Info("Starting to consume from stream")
-
-	for {
-		select {
-		case <-ctx.Done():
-			r.logger.Info("Consumer context cancelled, stopping...")
-			return ctx.Err()
-		default:
-			// Read from stream
-			streams, err := r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
-				Group:    consumerGroup,
-				Consumer: consumerName,
-				Streams:  []string{streamName, ">"},
-				Count:    10,              // Process 10 messages at a time
-				Block:    1 * time.Second, // Block for 1 second if no messages
-			}).Result()
-
-			if err != nil {
-				if err == redis.Nil {
-					// No new messages, continue
-					continue
-				}
-				r.logger.WithError(err).Error("Failed to read from stream")
-				time.Sleep(1 * time.Second)
-				continue
-			}
-
-			// Process messages
-			for _, stream := range streams {
-				for _, message := range stream.Messages {
-					if err := r.processMessage(ctx, streamName, consumerGroup, message, handler); err != nil {
-						r.logger.WithError(err).Error("Failed to process message")
-					}
-				}
-			}
+// reads up to count new messages from the log stream for a consumer, blocking up to block for them
+func (r *RedisClient) ReadGroup(ctx context.Context, group, consumerName string, count int, block time.Duration) ([]consumer.Message, error) {
+	streams, err := r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumerName,
+		Streams:  []string{r.stream, ">"},
+		Count:    int64(count),
+		Block:    block,
+	}).Result()
+	if err != nil {
+		if err == redis.Nil {
+			// No new messages
+			return nil, nil
 		}
+		return nil, err
 	}
-}
 
-// consumes a single Redis stream message, deserialize its contents into a structured log entry, pass it to a handler function, and acknowledge the message in Redis if processing succeeded
-func (r *RedisClient) processMessage(ctx context.Context, streamName, consumerGroup string, message redis.XMessage, handler func(*models.LogEntry) error) error {
-	// Extract log JSON from message
-	logJSON, ok := message.Values["log"].(string)
-	if !ok {
-		r.logger.Error("Invalid message format: missing log field")
-		// Acknowledge bad message to remove it from pending
-		r.client.XAck(ctx, streamName, consumerGroup, message.ID)
-		return fmt.Errorf("invalid message format")
+	var messages []consumer.Message
+	for _, stream := range streams {
+		messages = append(messages, streamMessages(stream.Messages)...)
 	}
+	return messages, nil
+}
 
-	// Deserialize log
-	var log models.LogEntry
-	if err := json.Unmarshal([]byte(logJSON), &log); err != nil {
-		r.logger.WithError(err).Error("Failed to unmarshal log")
-		// Acknowledge bad message
-		r.client.XAck(ctx, streamName, consumerGroup, message.ID)
-		return fmt.Errorf("failed to unmarshal log: %w", err)
-	}
+// acknowledges processed messages so they leave the group's pending list
+func (r *RedisClient) Ack(ctx context.Context, group string, ids ...string) error {
+	return r.client.XAck(ctx, r.stream, group, ids...).Err()
+}
 
-	// Call handler function
-	if err := handler(&log); err != nil {
-		r.logger.WithError(err).WithField("log_id", log.ID).Error("Handler failed to process log")
-		// Don't acknowledge - message will be retried
-		return fmt.Errorf("handler failed: %w", err)
+// takes over up to count messages that have been pending for at least minIdle
+func (r *RedisClient) Claim(ctx context.Context, group, consumerName string, minIdle time.Duration, count int) ([]consumer.Message, error) {
+	messages, _, err := r.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   r.stream,
+		Group:    group,
+		Consumer: consumerName,
+		MinIdle:  minIdle,
+		Start:    "0-0",
+		Count:    int64(count),
+	}).Result()
+	if err != nil {
+		return nil, err
 	}
+	return streamMessages(messages), nil
+}
 
-	// Acknowledge successful processing
-	if err := r.client.XAck(ctx, streamName, consumerGroup, message.ID).Err(); err != nil {
-		r.logger.WithError(err).Error("Failed to acknowledge message")
-		return fmt.Errorf("failed to acknowledge: %w", err)
+func streamMessages(entries []redis.XMessage) []consumer.Message {
+	messages := make([]consumer.Message, 0, len(entries))
+	for _, entry := range entries {
+		data, _ := entry.Values["log"].(string)
+		messages = append(messages, consumer.Message{ID: entry.ID, Data: data})
 	}
-
-	r.logger.WithFields(logrus.Fields{
-		"message_id": message.ID,
-		"user_id":    log.UserID,
-		"level":      log.Level,
-	}).Debug("Message processed and acknowledged")
-
-	return nil
+	return messages
 }
 
 // returns information about the stream
-func (r *RedisClient) GetStreamInfo(ctx context.Context) (map[string]interface{}, error) {
-	streamName := "logs:incoming"
+func (r *RedisClient) Info(ctx context.Context) (*queue.Info, error) {
 
 	// Get stream length
-	length, err := r.client.XLen(ctx, streamName).Result()
+	length, err := r.client.XLen(ctx, r.stream).Result()
 	if err != nil {
 		return nil, err
 	}
 
 	// Get consumer group info
-	groups, err := r.client.XInfoGroups(ctx, streamName).Result()
+	groups, err := r.client.XInfoGroups(ctx, r.stream).Result()
 	if err != nil && err != redis.Nil {
 		return nil, err
 	}
 
-	info := map[string]interface{}{
-		"stream_name":   streamName,
-		"stream_length": length,
-		"groups":        groups,
+	info := &queue.Info{
+		Name:   r.stream,
+		Length: length,
+		Groups: make([]queue.GroupInfo, 0, len(groups)),
+	}
+	for _, group := range groups {
+		info.Groups = append(info.Groups, queue.GroupInfo{
+			Name:            group.Name,
+			Consumers:       group.Consumers,
+			Pending:         group.Pending,
+			Lag:             group.Lag,
+			LastDeliveredID: group.LastDeliveredID,
+		})
 	}
 
 	return info, nil
@@ -280,3 +284,193 @@ func (r *RedisClient) InvalidateCachedAPIKey(ctx context.Context, apiKey string)
 	r.logger.Debug("API key invalidated from cache")
 	return nil
 }
+
+// CacheClientCert caches the user owning a client certificate fingerprint
+func (r *RedisClient) CacheClientCert(ctx context.Context, fingerprint string, userID int, ttl time.Duration) error {
+	if err := r.client.Set(ctx, "clientcert:"+fingerprint, userID, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to cache client certificate: %w", err)
+	}
+	return nil
+}
+
+// GetCachedClientCert returns the cached owner of a client certificate fingerprint
+func (r *RedisClient) GetCachedClientCert(ctx context.Context, fingerprint string) (int, error) {
+	result, err := r.client.Get(ctx, "clientcert:"+fingerprint).Int()
+	if err != nil {
+		if err == redis.Nil {
+			return 0, fmt.Errorf("client certificate not in cache")
+		}
+		return 0, fmt.Errorf("failed to get cached client certificate: %w", err)
+	}
+	return result, nil
+}
+
+// InvalidateCachedClientCert removes a client certificate fingerprint from the cache
+func (r *RedisClient) InvalidateCachedClientCert(ctx context.Context, fingerprint string) error {
+	if err := r.client.Del(ctx, "clientcert:"+fingerprint).Err(); err != nil {
+		return fmt.Errorf("failed to invalidate cached client certificate: %w", err)
+	}
+	return nil
+}
+
+// QuarantineLogs stores rejected entries in a capped per-user list that expires after a week
+func (r *RedisClient) QuarantineLogs(ctx context.Context, userID int, entries []models.QuarantinedLog) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	key := fmt.Sprintf("quarantine:%d", userID)
+	values := make([]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		entryJSON, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to marshal quarantined log: %w", err)
+		}
+		values = append(values, string(entryJSON))
+	}
+
+	pipe := r.client.Pipeline()
+	pipe.LPush(ctx, key, values...)
+	pipe.LTrim(ctx, key, 0, 999) // keep the 1000 most recent rejects
+	pipe.Expire(ctx, key, 7*24*time.Hour)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to quarantine logs: %w", err)
+	}
+
+	r.logger.WithFields(logrus.Fields{
+		"user_id": userID,
+		"count":   len(entries),
+	}).Debug("Rejected logs quarantined")
+
+	return nil
+}
+
+// GetQuarantinedLogs returns the most recent rejected entries for a user, newest first
+func (r *RedisClient) GetQuarantinedLogs(ctx context.Context, userID int, limit int) ([]models.QuarantinedLog, error) {
+	key := fmt.Sprintf("quarantine:%d", userID)
+	values, err := r.client.LRange(ctx, key, 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quarantined logs: %w", err)
+	}
+
+	entries := make([]models.QuarantinedLog, 0, len(values))
+	for _, value := range values {
+		var entry models.QuarantinedLog
+		if err := json.Unmarshal([]byte(value), &entry); err != nil {
+			r.logger.WithError(err).Warn("Skipping malformed quarantine entry")
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// ClearQuarantine removes all quarantined entries for a user
+func (r *RedisClient) ClearQuarantine(ctx context.Context, userID int) error {
+	key := fmt.Sprintf("quarantine:%d", userID)
+	if err := r.client.Del(ctx, key).Err(); err != nil {
+		return fmt.Errorf("failed to clear quarantine: %w", err)
+	}
+	return nil
+}
+
+// IdempotencyRecord is the state remembered for an Idempotency-Key
+type IdempotencyRecord struct {
+	State      string `json:"state"` // pending or completed
+	StatusCode int    `json:"status_code,omitempty"`
+	Body       string `json:"body,omitempty"`
+}
+
+const (
+	IdempotencyPending   = "pending"
+	IdempotencyCompleted = "completed"
+)
+
+// the raw key can be long and arbitrary, so it is hashed before being used in a Redis key
+func idempotencyRedisKey(userID int, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("idempotency:%d:%s", userID, hex.EncodeToString(sum[:]))
+}
+
+// ReserveIdempotencyKey marks a key as pending; if the key was already seen it returns the existing record instead
+func (r *RedisClient) ReserveIdempotencyKey(ctx context.Context, userID int, key string, ttl time.Duration) (*IdempotencyRecord, error) {
+	redisKey := idempotencyRedisKey(userID, key)
+	pending, err := json.Marshal(IdempotencyRecord{State: IdempotencyPending})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal idempotency record: %w", err)
+	}
+
+	reserved, err := r.client.SetNX(ctx, redisKey, string(pending), ttl).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	if reserved {
+		return nil, nil
+	}
+
+	value, err := r.client.Get(ctx, redisKey).Result()
+	if err != nil {
+		if err == redis.Nil {
+			// Expired between SETNX and GET, treat it as a fresh reservation attempt
+			return r.ReserveIdempotencyKey(ctx, userID, key, ttl)
+		}
+		return nil, fmt.Errorf("failed to get idempotency record: %w", err)
+	}
+
+	var record IdempotencyRecord
+	if err := json.Unmarshal([]byte(value), &record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal idempotency record: %w", err)
+	}
+
+	return &record, nil
+}
+
+// CompleteIdempotencyKey stores the response for a key so retries can replay it within the TTL
+func (r *RedisClient) CompleteIdempotencyKey(ctx context.Context, userID int, key string, record IdempotencyRecord, ttl time.Duration) error {
+	record.State = IdempotencyCompleted
+	value, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal idempotency record: %w", err)
+	}
+
+	if err := r.client.Set(ctx, idempotencyRedisKey(userID, key), string(value), ttl).Err(); err != nil {
+		return fmt.Errorf("failed to store idempotency record: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey forgets a key so a failed request can be retried with it
+func (r *RedisClient) ReleaseIdempotencyKey(ctx context.Context, userID int, key string) error {
+	if err := r.client.Del(ctx, idempotencyRedisKey(userID, key)).Err(); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// stores a value as JSON under key with a TTL
+func (r *RedisClient) setJSON(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", key, err)
+	}
+	if err := r.client.Set(ctx, key, string(encoded), ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set %s: %w", key, err)
+	}
+	return nil
+}
+
+// loads a JSON value stored under key, reporting whether it was found
+func (r *RedisClient) getJSON(ctx context.Context, key string, value interface{}) (bool, error) {
+	encoded, err := r.client.Get(ctx, key).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get %s: %w", key, err)
+	}
+	if err := json.Unmarshal([]byte(encoded), value); err != nil {
+		return false, fmt.Errorf("failed to unmarshal %s: %w", key, err)
+	}
+	return true, nil
+}
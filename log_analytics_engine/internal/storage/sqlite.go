@@ -0,0 +1,465 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+	"github.com/sirupsen/logrus"
+	"modernc.org/sqlite"
+)
+
+/*
+this file is a LogStore backed by an embedded SQLite database, for single-node and development installs
+and for tests that shouldn't need a Postgres server; timestamps are stored as unix nanoseconds and
+"contains" searches are case-insensitive for ASCII letters only. Full-text searches are approximated
+with substring matches and aren't ranked, so they return the newest first and sorting by relevance is
+refused with ErrRelevanceUnsupported
+*/
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS logs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    timestamp INTEGER NOT NULL,
+    source TEXT NOT NULL,
+    level TEXT NOT NULL,
+    severity_number INTEGER NOT NULL DEFAULT 0,
+    message TEXT NOT NULL,
+    service TEXT,
+    fields TEXT,
+    raw_message TEXT,
+    created_at INTEGER NOT NULL,
+    received_at INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    event_id TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_logs_user_timestamp ON logs (user_id, timestamp);
+CREATE INDEX IF NOT EXISTS idx_logs_user_received_at ON logs (user_id, received_at);
+CREATE INDEX IF NOT EXISTS idx_logs_user_severity ON logs (user_id, severity_number);
+
+CREATE TABLE IF NOT EXISTS log_event_ids (
+    user_id INTEGER NOT NULL,
+    event_id TEXT NOT NULL,
+    created_at INTEGER NOT NULL,
+    PRIMARY KEY (user_id, event_id)
+);
+CREATE INDEX IF NOT EXISTS idx_log_event_ids_created_at ON log_event_ids (created_at);
+`
+
+type SQLiteStorage struct {
+	db          *sql.DB
+	logger      *logrus.Logger
+	dedupWindow atomic.Int64 // nanoseconds event_ids are remembered, 0 for ever; changes on reload
+}
+
+// opens (creating if needed) the SQLite database at path, or an in-memory database for ":memory:"
+func NewSQLiteStorage(path string) (*SQLiteStorage, error) {
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+
+	// SQLite allows one writer at a time; a single connection also keeps ":memory:" databases shared
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create SQLite schema: %w", err)
+	}
+
+	return &SQLiteStorage{
+		db:     db,
+		logger: logrus.New(),
+	}, nil
+}
+
+// closes the database
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
+
+// checks that the database can be reached
+func (s *SQLiteStorage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+var sqliteDialect = sqlDialect{
+	placeholder: func(n int) string { return fmt.Sprintf("?%d", n) },
+	containsOp:  "LIKE",
+	timeArg:     func(t time.Time) interface{} { return t.UnixNano() },
+	timeBucket: func(column, width string) string {
+		return fmt.Sprintf("((%s / 1000000000) / %s) * %s", column, width, width)
+	},
+	search: sqliteSearch,
+	regex: func(column, pattern string, ignoreCase bool) string {
+		if ignoreCase {
+			return fmt.Sprintf("%s REGEXP ('(?i)' || %s)", column, pattern)
+		}
+		return column + " REGEXP " + pattern
+	},
+	fieldKey: func(b *queryBuilder, key string) string {
+		// Keys are limited to letters, digits, _, . and -, so quoting them makes a valid JSON path
+		return "json_extract(fields, " + b.arg(`$."`+key+`"`) + ")"
+	},
+}
+
+// SQLite has REGEXP syntax but no implementation; patterns were checked by models.ValidatePattern
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction("regexp", 2, sqliteRegexp)
+}
+
+// compiled patterns, so each row doesn't recompile them; cleared when it grows past sqliteRegexpCacheSize
+var (
+	sqliteRegexpMu    sync.Mutex
+	sqliteRegexpCache = map[string]*regexp.Regexp{}
+)
+
+const sqliteRegexpCacheSize = 100
+
+// implements "value REGEXP pattern", which SQLite calls as regexp(pattern, value); NULL values don't match
+func sqliteRegexp(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	pattern, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("REGEXP pattern must be text")
+	}
+	var value string
+	switch v := args[1].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		value = fmt.Sprint(v)
+	}
+
+	sqliteRegexpMu.Lock()
+	re, ok := sqliteRegexpCache[pattern]
+	sqliteRegexpMu.Unlock()
+	if !ok {
+		var err error
+		if re, err = regexp.Compile(pattern); err != nil {
+			return nil, err
+		}
+		sqliteRegexpMu.Lock()
+		if len(sqliteRegexpCache) >= sqliteRegexpCacheSize {
+			clear(sqliteRegexpCache)
+		}
+		sqliteRegexpCache[pattern] = re
+		sqliteRegexpMu.Unlock()
+	}
+	return re.MatchString(value), nil
+}
+
+// approximates a full-text search with LIKE: words and phrases match as substrings and prefixes
+// are plain words, so "err*" and "err" both match "error" and "server"
+func sqliteSearch(b *queryBuilder, q *models.SearchQuery) string {
+	switch q.Op {
+	case models.SearchAnd, models.SearchOr:
+		parts := make([]string, len(q.Children))
+		for i, child := range q.Children {
+			parts[i] = sqliteSearch(b, child)
+		}
+		return "(" + strings.Join(parts, " "+strings.ToUpper(q.Op)+" ") + ")"
+	case models.SearchNot:
+		return "NOT " + sqliteSearch(b, q.Children[0])
+	}
+	return "message LIKE " + b.arg("%"+likeEscaper.Replace(strings.Join(q.Words, " "))+"%") + ` ESCAPE '\'`
+}
+
+// converts Postgres INSERT arguments to the values SQLite stores
+func sqliteInsertArgs(log *models.LogEntry) ([]interface{}, error) {
+	args, err := insertArgs(log)
+	if err != nil {
+		return nil, err
+	}
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case time.Time:
+			args[i] = v.UnixNano()
+		case []byte:
+			args[i] = string(v)
+		}
+	}
+	return args, nil
+}
+
+const sqliteInsertLog = `
+    INSERT INTO logs (timestamp, source, level, severity_number, message, service, fields, raw_message, created_at, received_at, user_id, event_id)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+// sets how long event_ids are remembered, like PostgresStorage.SetDedupWindow
+func (s *SQLiteStorage) SetDedupWindow(window time.Duration) {
+	s.dedupWindow.Store(int64(window))
+}
+
+// records the log's event_id for its user, returning false if it was already recorded within the dedup window
+func (s *SQLiteStorage) claimEventID(tx *sql.Tx, log *models.LogEntry) (bool, error) {
+	seenAt := log.ReceivedAt
+	if seenAt.IsZero() {
+		seenAt = time.Now()
+	}
+
+	// Without a window nothing is ever old enough to reclaim
+	reclaimBefore := int64(math.MinInt64)
+	if window := time.Duration(s.dedupWindow.Load()); window > 0 {
+		reclaimBefore = seenAt.Add(-window).UnixNano()
+	}
+	result, err := tx.Exec(`
+        INSERT INTO log_event_ids (user_id, event_id, created_at) VALUES (?1, ?2, ?3)
+        ON CONFLICT (user_id, event_id) DO UPDATE SET created_at = excluded.created_at
+        WHERE log_event_ids.created_at < ?4`,
+		log.UserID, log.EventID, seenAt.UnixNano(), reclaimBefore)
+	if err != nil {
+		return false, fmt.Errorf("failed to record event_id: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	return rowsAffected == 1, nil
+}
+
+// deletes event_ids older than the dedup window, returning how many were removed
+func (s *SQLiteStorage) PruneEventIDs(ctx context.Context) (int64, error) {
+	window := time.Duration(s.dedupWindow.Load())
+	if window <= 0 {
+		return 0, nil
+	}
+	result, err := s.db.ExecContext(ctx, `DELETE FROM log_event_ids WHERE created_at < ?`,
+		time.Now().Add(-window).UnixNano())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune event_ids: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// stores a single log entry
+func (s *SQLiteStorage) InsertLog(log *models.LogEntry) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	inserted, err := s.insert(tx, log)
+	if err != nil {
+		return err
+	}
+	if !inserted {
+		return ErrDuplicateEvent
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// stores multiple log entries in a single transaction, skipping repeated event_ids
+func (s *SQLiteStorage) InsertLogs(logs []*models.LogEntry) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	duplicates := 0
+	for _, log := range logs {
+		inserted, err := s.insert(tx, log)
+		if err != nil {
+			return err
+		}
+		if !inserted {
+			duplicates++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.logger.Infof("Successfully inserted %d logs (%d duplicates skipped)", len(logs)-duplicates, duplicates)
+	return nil
+}
+
+// inserts one log within tx, returning false if its event_id was already stored
+func (s *SQLiteStorage) insert(tx *sql.Tx, log *models.LogEntry) (bool, error) {
+	if log.EventID != "" {
+		claimed, err := s.claimEventID(tx, log)
+		if err != nil || !claimed {
+			return false, err
+		}
+	}
+
+	args, err := sqliteInsertArgs(log)
+	if err != nil {
+		return false, err
+	}
+	result, err := tx.Exec(sqliteInsertLog, args...)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to insert log")
+		return false, fmt.Errorf("failed to insert log: %w", err)
+	}
+	if log.ID, err = result.LastInsertId(); err != nil {
+		return false, fmt.Errorf("failed to read log id: %w", err)
+	}
+	return true, nil
+}
+
+// QueryLogs returns a page of the logs matching the query
+func (s *SQLiteStorage) QueryLogs(q *models.LogQuery) ([]*models.LogEntry, error) {
+	b := &queryBuilder{dialect: sqliteDialect}
+	where, err := b.where(q)
+	if err != nil {
+		return nil, err
+	}
+	order, err := orderBy(q)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM logs WHERE %s ORDER BY %s`, logColumns, where, order)
+	if q.Limit > 0 || q.Offset > 0 {
+		// SQLite needs a LIMIT before an OFFSET; -1 means no limit
+		limit := q.Limit
+		if limit <= 0 {
+			limit = -1
+		}
+		query += " LIMIT " + b.arg(limit) + " OFFSET " + b.arg(q.Offset)
+	}
+
+	rows, err := s.db.Query(query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query logs: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []*models.LogEntry
+	for rows.Next() {
+		log := &models.LogEntry{}
+		var fieldsJSON sql.NullString
+		var timestamp, createdAt, receivedAt int64
+
+		err := rows.Scan(
+			&log.ID,
+			&timestamp,
+			&log.Source,
+			&log.Level,
+			&log.SeverityNumber,
+			&log.Message,
+			&log.Service,
+			&fieldsJSON,
+			&log.RawMessage,
+			&createdAt,
+			&receivedAt,
+			&log.UserID,
+			&log.EventID,
+		)
+		if err != nil {
+			s.logger.WithError(err).Error("Failed to scan log row")
+			continue
+		}
+		log.Timestamp = time.Unix(0, timestamp).UTC()
+		log.CreatedAt = time.Unix(0, createdAt).UTC()
+		log.ReceivedAt = time.Unix(0, receivedAt).UTC()
+
+		if fieldsJSON.Valid && fieldsJSON.String != "" {
+			if err := json.Unmarshal([]byte(fieldsJSON.String), &log.Fields); err != nil {
+				s.logger.WithError(err).Error("Failed to unmarshal fields")
+			}
+		}
+
+		logs = append(logs, log)
+	}
+
+	return logs, rows.Err()
+}
+
+// SearchLogs returns a page of the logs matching the query's search, with highlighted snippets;
+// relevance isn't computed, so searches default to newest first and relevance sorts are refused
+func (s *SQLiteStorage) SearchLogs(q *models.LogQuery) ([]*models.LogEntry, []models.SearchMatch, error) {
+	if q.Search == nil {
+		return nil, nil, fmt.Errorf("search logs needs a search")
+	}
+	if q.SortBy == models.SortRelevance {
+		return nil, nil, ErrRelevanceUnsupported
+	}
+	logs, err := s.QueryLogs(q)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	matches := make([]models.SearchMatch, len(logs))
+	for i, log := range logs {
+		matches[i] = models.SearchMatch{LogID: log.ID, Snippet: highlight(log.Message, q.Search)}
+	}
+	return logs, matches, nil
+}
+
+// CountLogs counts the total number of logs matching the query
+func (s *SQLiteStorage) CountLogs(q *models.LogQuery) (int, error) {
+	b := &queryBuilder{dialect: sqliteDialect}
+	where, err := b.where(q)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM logs WHERE `+where, b.args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count logs: %w", err)
+	}
+	return count, nil
+}
+
+// DeleteLogs deletes logs matching the query
+func (s *SQLiteStorage) DeleteLogs(q *models.LogQuery) (int, error) {
+	b := &queryBuilder{dialect: sqliteDialect}
+	where, err := b.where(q)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := s.db.Exec(`DELETE FROM logs WHERE `+where, b.args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete logs: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return int(rowsAffected), nil
+}
+
+// AggregateLogs counts the logs matching the query per group and time bucket
+func (s *SQLiteStorage) AggregateLogs(q *models.AggregateQuery) ([]models.AggregateBucket, error) {
+	b := &queryBuilder{dialect: sqliteDialect}
+	query, err := b.aggregate(q)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate logs: %w", err)
+	}
+	defer rows.Close()
+
+	return scanAggregateRows(rows, q)
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+)
+
+/*
+this file defines StateStore, the short-lived state the services share besides the log queue: auth and
+limit caches, rate limit buckets and quotas, idempotency keys, quarantined logs, rule counters, multiline
+buffers and processor heartbeats. RedisClient keeps it in Redis so separate ingester and processor
+processes see the same state; MemoryState keeps it in process for the combined logbuilder binary
+*/
+
+var (
+	_ StateStore = (*RedisClient)(nil)
+	_ StateStore = (*MemoryState)(nil)
+)
+
+type StateStore interface {
+	// API key and client certificate owners, so authentication doesn't hit Postgres on every request
+	CacheAPIKey(ctx context.Context, apiKey string, userID int, ttl time.Duration) error
+	GetCachedAPIKey(ctx context.Context, apiKey string) (int, error)
+	InvalidateCachedAPIKey(ctx context.Context, apiKey string) error
+	CacheClientCert(ctx context.Context, fingerprint string, userID int, ttl time.Duration) error
+	GetCachedClientCert(ctx context.Context, fingerprint string) (int, error)
+	InvalidateCachedClientCert(ctx context.Context, fingerprint string) error
+
+	// ingestion limits, rate limit buckets and daily quotas
+	CacheIngestLimits(ctx context.Context, limits *models.IngestLimits, ttl time.Duration) error
+	GetCachedIngestLimits(ctx context.Context, userID int) (*models.IngestLimits, error)
+	InvalidateIngestLimits(ctx context.Context, userID int) error
+	TakeIngestTokens(ctx context.Context, limits *models.IngestLimits, apiKey string, events, bytes int64) (*RateLimitResult, error)
+	GetIngestUsage(ctx context.Context, limits *models.IngestLimits) (*models.IngestUsage, error)
+
+	ReserveIdempotencyKey(ctx context.Context, userID int, key string, ttl time.Duration) (*IdempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, userID int, key string, record IdempotencyRecord, ttl time.Duration) error
+	ReleaseIdempotencyKey(ctx context.Context, userID int, key string) error
+
+	QuarantineLogs(ctx context.Context, userID int, entries []models.QuarantinedLog) error
+	GetQuarantinedLogs(ctx context.Context, userID int, limit int) ([]models.QuarantinedLog, error)
+	ClearQuarantine(ctx context.Context, userID int) error
+
+	IncrIngestRuleStats(ctx context.Context, userID int, matched, dropped map[int]int64) error
+	GetIngestRuleStats(ctx context.Context, userID int) (matched, dropped map[int]int64, err error)
+	ResetIngestRuleStats(ctx context.Context, userID, ruleID int) error
+	IncrRedactionHits(ctx context.Context, userID int, hits map[string]int64) error
+	GetRedactionHits(ctx context.Context, userID int) (map[string]int64, error)
+	ResetRedactionHits(ctx context.Context, userID int, rule string) error
+
+	AppendMultiline(ctx context.Context, entry *models.LogEntry, pipelineID int, continuation bool, maxWait time.Duration, maxLines int) (*MultilineEvent, error)
+	ExpireMultiline(ctx context.Context, limit int) ([]*MultilineEvent, error)
+	RetryMultilineLater(ctx context.Context, event *MultilineEvent) error
+	RetryMultiline(ctx context.Context, limit int) ([]*MultilineEvent, error)
+
+	ProcessorHeartbeat(ctx context.Context, name string, maxAge time.Duration) error
+	RemoveProcessorHeartbeat(ctx context.Context, name string) error
+	LiveProcessors(ctx context.Context, maxAge time.Duration) ([]ProcessorHeartbeat, error)
+
+	Ping(ctx context.Context) error
+	Close() error
+}
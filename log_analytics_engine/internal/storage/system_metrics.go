@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/internal/models"
+)
+
+type SystemMetricsStorage struct {
+	db *sql.DB
+}
+
+func NewSystemMetricsStorage(db *sql.DB) *SystemMetricsStorage {
+	return &SystemMetricsStorage{db: db}
+}
+
+// writes a snapshot of samples in one transaction
+func (s *SystemMetricsStorage) InsertMetrics(metrics []models.SystemMetric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+        INSERT INTO system_metrics (metric_name, metric_value, metric_type, labels, timestamp)
+        VALUES ($1, $2, $3, $4, $5)
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, metric := range metrics {
+		labels, err := json.Marshal(metric.Labels)
+		if err != nil {
+			return fmt.Errorf("failed to marshal labels: %w", err)
+		}
+		if _, err := stmt.Exec(metric.Name, metric.Value, metric.Type, labels, metric.Timestamp); err != nil {
+			return fmt.Errorf("failed to insert system metric: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit system metrics: %w", err)
+	}
+	return nil
+}
+
+// deletes samples older than the cutoff, returning how many were removed
+func (s *SystemMetricsStorage) DeleteMetricsBefore(cutoff time.Time) (int64, error) {
+	result, err := s.db.Exec(`DELETE FROM system_metrics WHERE timestamp < $1`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old system metrics: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,120 @@
+package timestamp
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+This package parses timestamps written in the formats log shippers commonly use:
+RFC3339/ISO8601 variants, epoch seconds/millis/micros/nanos, Apache/HTTP dates, syslog and RFC1123
+used by pipeline timestamp stages and for client-supplied ingest timestamps
+*/
+
+// formats tried when none are listed, e.g. by a timestamp stage or for client timestamps
+var DefaultFormats = []string{"ISO8601", "EPOCH", "HTTPDATE", "SYSLOG", "RFC1123", "RFC822", "ANSIC", "UNIXDATE"}
+
+// named formats accepted in addition to Go layouts
+var namedLayouts = map[string][]string{
+	"RFC3339":     {time.RFC3339},
+	"RFC3339NANO": {time.RFC3339Nano},
+	"ISO8601": {
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05.999999999Z0700",
+		"2006-01-02 15:04:05.999999999Z07:00",
+		"2006-01-02 15:04:05.999999999",
+		"2006-01-02T15:04:05.999999999",
+		"2006-01-02 15:04:05,999",
+	},
+	"RFC1123":  {time.RFC1123, time.RFC1123Z},
+	"RFC822":   {time.RFC822, time.RFC822Z},
+	"ANSIC":    {time.ANSIC},
+	"UNIXDATE": {time.UnixDate},
+	"HTTPDATE": {"02/Jan/2006:15:04:05 -0700"},
+	"SYSLOG":   {time.Stamp, time.StampMilli},
+}
+
+// Parse parses value with the first matching format; layouts without a zone use loc
+func Parse(value string, formats []string, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	for _, format := range formats {
+		switch strings.ToUpper(format) {
+		case "EPOCH", "UNIX", "UNIX_MS", "UNIX_US", "UNIX_NS":
+			if t, ok := parseEpoch(value, strings.ToUpper(format)); ok {
+				return t, nil
+			}
+			continue
+		}
+
+		layouts, named := namedLayouts[strings.ToUpper(format)]
+		if !named {
+			layouts = []string{format}
+		}
+		for _, layout := range layouts {
+			t, err := time.ParseInLocation(layout, value, loc)
+			if err != nil {
+				continue
+			}
+			if t.Year() == 0 {
+				// Syslog timestamps have no year
+				now := time.Now().In(loc)
+				t = t.AddDate(now.Year(), 0, 0)
+				if t.After(now.Add(24 * time.Hour)) {
+					t = t.AddDate(-1, 0, 0)
+				}
+			}
+			return t.UTC(), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("timestamp %q does not match any of %v", value, formats)
+}
+
+var epochSeconds = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// parses an epoch timestamp; UNIX accepts fractional seconds and
+// EPOCH picks seconds, milliseconds, microseconds or nanoseconds by magnitude
+func parseEpoch(value, unit string) (time.Time, bool) {
+	if unit == "EPOCH" {
+		digits := len(strings.TrimPrefix(value, "-"))
+		switch {
+		case strings.Contains(value, ".") || digits <= 10:
+			unit = "UNIX"
+		case digits <= 13:
+			unit = "UNIX_MS"
+		case digits <= 16:
+			unit = "UNIX_US"
+		default:
+			unit = "UNIX_NS"
+		}
+	}
+
+	if unit == "UNIX" {
+		if !epochSeconds.MatchString(value) {
+			return time.Time{}, false
+		}
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		whole := int64(seconds)
+		return time.Unix(whole, int64((seconds-float64(whole))*1e9)).UTC(), true
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	switch unit {
+	case "UNIX_MS":
+		return time.UnixMilli(n).UTC(), true
+	case "UNIX_US":
+		return time.UnixMicro(n).UTC(), true
+	default:
+		return time.Unix(0, n).UTC(), true
+	}
+}
@@ -0,0 +1,135 @@
+package timestamp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseEpoch(t *testing.T) {
+	tests := []struct {
+		value string
+		unit  string
+		want  time.Time // zero when the value is rejected
+	}{
+		// EPOCH picks the unit by the number of digits
+		{"1700000000", "EPOCH", time.Unix(1700000000, 0)},
+		{"0", "EPOCH", time.Unix(0, 0)},
+		{"-86400", "EPOCH", time.Unix(-86400, 0)},
+		{"9999999999", "EPOCH", time.Unix(9999999999, 0)},                   // 10 digits, the last in seconds
+		{"10000000000", "EPOCH", time.UnixMilli(10000000000)},               // 11 digits
+		{"1700000000123", "EPOCH", time.UnixMilli(1700000000123)},           // 13 digits
+		{"17000000001234", "EPOCH", time.UnixMicro(17000000001234)},         // 14 digits
+		{"1700000000123456", "EPOCH", time.UnixMicro(1700000000123456)},     // 16 digits
+		{"17000000001234567", "EPOCH", time.Unix(0, 17000000001234567)},     // 17 digits
+		{"1700000000123456789", "EPOCH", time.Unix(0, 1700000000123456789)}, // 19 digits
+		{"1700000000123.5", "EPOCH", time.Unix(1700000000123, 500000000)},   // a fraction always means seconds
+		{"1700000000.25", "EPOCH", time.Unix(1700000000, 250000000)},
+		{"abc", "EPOCH", time.Time{}},
+		{"1e9", "EPOCH", time.Time{}},
+
+		// explicit units
+		{"1700000000.5", "UNIX", time.Unix(1700000000, 500000000)},
+		{"1700000000123", "UNIX", time.Unix(1700000000123, 0)},
+		{"+1700000000", "UNIX", time.Time{}},
+		{"1700000000123", "UNIX_MS", time.UnixMilli(1700000000123)},
+		{"1700", "UNIX_MS", time.UnixMilli(1700)},
+		{"1700000000123456", "UNIX_US", time.UnixMicro(1700000000123456)},
+		{"1700000000123456789", "UNIX_NS", time.Unix(0, 1700000000123456789)},
+		{"1700000000.5", "UNIX_MS", time.Time{}},
+		{"99999999999999999999", "UNIX_NS", time.Time{}}, // overflows int64
+	}
+	for _, tt := range tests {
+		t.Run(tt.unit+"/"+tt.value, func(t *testing.T) {
+			got, ok := parseEpoch(tt.value, tt.unit)
+			if ok != !tt.want.IsZero() || !got.Equal(tt.want) {
+				t.Errorf("parseEpoch(%q, %s) = %v, %v, want %v", tt.value, tt.unit, got, ok, tt.want)
+			}
+			if ok && got.Location() != time.UTC {
+				t.Errorf("parseEpoch returned a time in %s, want UTC", got.Location())
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	want := time.Date(2024, 3, 15, 14, 30, 45, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		value   string
+		formats []string
+		loc     *time.Location
+		want    time.Time
+	}{
+		{"RFC3339", "2024-03-15T14:30:45Z", []string{"RFC3339"}, time.UTC, want},
+		{"RFC3339 with an offset", "2024-03-15T16:30:45+02:00", []string{"rfc3339"}, time.UTC, want},
+		{"RFC3339NANO", "2024-03-15T14:30:45.123456789Z", []string{"RFC3339NANO"}, time.UTC, want.Add(123456789)},
+		{"ISO8601 compact offset", "2024-03-15T14:30:45.5+0000", []string{"ISO8601"}, time.UTC, want.Add(500 * time.Millisecond)},
+		{"ISO8601 with a space", "2024-03-15 14:30:45Z", []string{"ISO8601"}, time.UTC, want},
+		{"ISO8601 without a zone uses loc", "2024-03-15 10:30:45", []string{"ISO8601"}, newYork, want},
+		{"ISO8601 comma millis", "2024-03-15 14:30:45,250", []string{"ISO8601"}, time.UTC, want.Add(250 * time.Millisecond)},
+		{"RFC1123", "Fri, 15 Mar 2024 14:30:45 UTC", []string{"RFC1123"}, time.UTC, want},
+		{"RFC1123Z", "Fri, 15 Mar 2024 16:30:45 +0200", []string{"RFC1123"}, time.UTC, want},
+		{"RFC822", "15 Mar 24 14:30 UTC", []string{"RFC822"}, time.UTC, want.Add(-45 * time.Second)},
+		{"ANSIC", "Fri Mar 15 14:30:45 2024", []string{"ANSIC"}, time.UTC, want},
+		{"UNIXDATE", "Fri Mar 15 14:30:45 UTC 2024", []string{"UNIXDATE"}, time.UTC, want},
+		{"HTTPDATE", "15/Mar/2024:10:30:45 -0400", []string{"HTTPDATE"}, time.UTC, want},
+		{"EPOCH", "1710513045", []string{"EPOCH"}, time.UTC, want},
+		{"UNIX_MS", "1710513045000", []string{"unix_ms"}, time.UTC, want},
+		{"Go layout", "15.03.2024 14:30:45", []string{"02.01.2006 15:04:05"}, time.UTC, want},
+		{"surrounding space", "  2024-03-15T14:30:45Z\n", []string{"RFC3339"}, time.UTC, want},
+		{"first matching format wins", "1710513045", []string{"RFC3339", "UNIX_MS", "EPOCH"}, time.UTC, time.UnixMilli(1710513045)},
+		{"defaults", "15/Mar/2024:14:30:45 +0000", DefaultFormats, time.UTC, want},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.value, tt.formats, tt.loc)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Errorf("got %v, want %v in UTC", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		value   string
+		formats []string
+	}{
+		{"2024-03-15T14:30:45Z", []string{"EPOCH"}},
+		{"1710513045", []string{"RFC3339", "HTTPDATE"}},
+		{"yesterday", DefaultFormats},
+		{"2024-03-15T14:30:45Z", nil},
+	}
+	for _, tt := range tests {
+		if got, err := Parse(tt.value, tt.formats, time.UTC); err == nil {
+			t.Errorf("Parse(%q, %v) = %v, want an error", tt.value, tt.formats, got)
+		}
+	}
+}
+
+func TestParseSyslogInfersYear(t *testing.T) {
+	now := time.Now().UTC()
+	for _, offset := range []time.Duration{-time.Hour, 30 * 24 * time.Hour} {
+		sent := now.Add(offset).Truncate(time.Second)
+		got, err := Parse(sent.Format(time.Stamp), []string{"SYSLOG"}, time.UTC)
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		// Timestamps more than a day ahead are taken to be from last year
+		want := sent
+		if offset > 24*time.Hour {
+			want = sent.AddDate(-1, 0, 0)
+		}
+		if !got.Equal(want) {
+			t.Errorf("offset %s: got %v, want %v", offset, got, want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+/*
+this file builds the ingester's HTTPS configuration; the certificate and key are reloaded when their files
+change, so renewed certificates are served without a restart
+*/
+
+// serves a certificate/key pair, reloading it when either file's modification time changes
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// loads the certificate and key, failing if they can't be read or don't match
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// the later modification time of the certificate and key files
+func (r *CertReloader) filesModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (r *CertReloader) reload() error {
+	modTime, err := r.filesModTime()
+	if err != nil {
+		return fmt.Errorf("failed to stat TLS certificate: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.mu.Unlock()
+	return nil
+}
+
+// GetCertificate returns the current certificate, for tls.Config.GetCertificate
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Watch reloads the certificate whenever its files change, until ctx is done
+func (r *CertReloader) Watch(ctx context.Context, interval time.Duration, logger *logrus.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			modTime, err := r.filesModTime()
+			if err != nil {
+				logger.WithError(err).Warn("Failed to check TLS certificate")
+				continue
+			}
+
+			r.mu.RLock()
+			changed := !modTime.Equal(r.modTime)
+			r.mu.RUnlock()
+			if !changed {
+				continue
+			}
+
+			if err := r.reload(); err != nil {
+				// Keep serving the previous certificate; a half-written pair is retried on the next tick
+				logger.WithError(err).Warn("Failed to reload TLS certificate")
+				continue
+			}
+			logger.WithField("path", r.certFile).Info("Reloaded TLS certificate")
+		}
+	}
+}
+
+// returns the server TLS configuration; when clientCAFile is set, clients may present a certificate
+// signed by one of its CAs, which is verified and made available to handlers (it is never required)
+func ServerConfig(certs *CertReloader, clientCAFile string) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: certs.GetCertificate,
+	}
+
+	if clientCAFile != "" {
+		pemData, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pemData) {
+			return nil, fmt.Errorf("client CA file %s holds no PEM certificates", clientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return cfg, nil
+}
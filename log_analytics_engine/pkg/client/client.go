@@ -0,0 +1,292 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+This package is the Go client for LogBuilder:
+Log buffers entries and a background goroutine sends them to /logs/batch once BatchSize
+entries are queued or FlushInterval has passed
+sends are retried with exponential backoff and jitter on 429, 5xx and network errors
+batches that still can't be delivered are written to SpoolDir, when set, and resent later
+Flush(ctx) and Close(ctx) drain the buffer for graceful shutdown
+Query wraps /logs/query with typed requests and responses
+*/
+
+var (
+	ErrClosed     = errors.New("client is closed")
+	ErrBufferFull = errors.New("log buffer is full")
+)
+
+// Config configures a Client; only BaseURL and APIKey are required
+type Config struct {
+	BaseURL string // server address, e.g. http://localhost:8080
+	APIKey  string
+
+	// Defaults for entries that don't set their own
+	Source  string
+	Service string
+
+	BatchSize     int           // entries per request, default 100 (max 1000)
+	FlushInterval time.Duration // longest an entry waits in the buffer, default 2s
+	BufferSize    int           // entries Log can queue before returning ErrBufferFull, default 10000
+
+	MaxRetries int           // retries per request after the first attempt, default 5
+	MinBackoff time.Duration // default 200ms
+	MaxBackoff time.Duration // default 10s
+	Timeout    time.Duration // per HTTP request, default 10s
+
+	SpoolDir      string // directory for undeliverable batches, empty disables the disk buffer
+	MaxSpoolBytes int64  // default 100MB; batches beyond it are dropped
+
+	HTTPClient *http.Client
+
+	// OnError is called from the background goroutine when logs are spooled or dropped
+	OnError func(error)
+}
+
+func (c *Config) setDefaults() {
+	c.BaseURL = strings.TrimRight(c.BaseURL, "/")
+	if c.BatchSize <= 0 {
+		c.BatchSize = 100
+	}
+	if c.BatchSize > 1000 {
+		c.BatchSize = 1000
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = 2 * time.Second
+	}
+	if c.BufferSize <= 0 {
+		c.BufferSize = 10000
+	}
+	if c.MaxRetries < 0 {
+		c.MaxRetries = 0
+	} else if c.MaxRetries == 0 {
+		c.MaxRetries = 5
+	}
+	if c.MinBackoff <= 0 {
+		c.MinBackoff = 200 * time.Millisecond
+	}
+	if c.MaxBackoff < c.MinBackoff {
+		c.MaxBackoff = 10 * time.Second
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+	if c.MaxSpoolBytes <= 0 {
+		c.MaxSpoolBytes = 100 << 20
+	}
+	if c.HTTPClient == nil {
+		c.HTTPClient = &http.Client{}
+	}
+	if c.OnError == nil {
+		c.OnError = func(error) {}
+	}
+}
+
+// Entry is a single log; Timestamp defaults to the time Log was called and Level to INFO
+type Entry struct {
+	Timestamp time.Time         `json:"timestamp"`
+	Source    string            `json:"source"`
+	Level     string            `json:"level"`
+	Message   string            `json:"message"`
+	Service   string            `json:"service,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	EventID   string            `json:"event_id,omitempty"`
+}
+
+// Client sends logs to a LogBuilder server; it is safe for concurrent use
+type Client struct {
+	config Config
+	spool  *spool // nil when SpoolDir is empty
+
+	queue   chan Entry
+	flushes chan flushRequest
+	stop    chan struct{}
+	done    chan struct{}
+
+	closed    atomic.Bool
+	closeOnce sync.Once
+}
+
+type flushRequest struct {
+	ctx  context.Context
+	done chan struct{}
+}
+
+// New creates a client and starts its background sender
+func New(config Config) (*Client, error) {
+	if config.BaseURL == "" {
+		return nil, errors.New("BaseURL is required")
+	}
+	if config.APIKey == "" {
+		return nil, errors.New("APIKey is required")
+	}
+	config.setDefaults()
+
+	c := &Client{
+		config:  config,
+		queue:   make(chan Entry, config.BufferSize),
+		flushes: make(chan flushRequest),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if config.SpoolDir != "" {
+		s, err := openSpool(config.SpoolDir, config.MaxSpoolBytes)
+		if err != nil {
+			return nil, err
+		}
+		c.spool = s
+	}
+
+	go c.run()
+	return c, nil
+}
+
+// Log queues an entry without blocking; it returns ErrBufferFull if the buffer is full
+func (c *Client) Log(entry Entry) error {
+	if c.closed.Load() {
+		return ErrClosed
+	}
+
+	select {
+	case c.queue <- c.withDefaults(entry):
+		return nil
+	default:
+		return ErrBufferFull
+	}
+}
+
+// fills in the timestamp, level, source and service an entry leaves empty
+func (c *Client) withDefaults(entry Entry) Entry {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	if entry.Level == "" {
+		entry.Level = "INFO"
+	}
+	if entry.Source == "" {
+		entry.Source = c.config.Source
+	}
+	if entry.Service == "" {
+		entry.Service = c.config.Service
+	}
+	return entry
+}
+
+func (c *Client) Debug(message string, fields map[string]string) error {
+	return c.Log(Entry{Level: "DEBUG", Message: message, Fields: fields})
+}
+
+func (c *Client) Info(message string, fields map[string]string) error {
+	return c.Log(Entry{Level: "INFO", Message: message, Fields: fields})
+}
+
+func (c *Client) Warn(message string, fields map[string]string) error {
+	return c.Log(Entry{Level: "WARN", Message: message, Fields: fields})
+}
+
+func (c *Client) Error(message string, fields map[string]string) error {
+	return c.Log(Entry{Level: "ERROR", Message: message, Fields: fields})
+}
+
+// Flush sends everything queued so far and returns once it was delivered, spooled or
+// dropped. If ctx ends first, remaining batches are spooled (or dropped) rather than retried
+func (c *Client) Flush(ctx context.Context) error {
+	if c.closed.Load() {
+		return ErrClosed
+	}
+	req := flushRequest{ctx: ctx, done: make(chan struct{})}
+	select {
+	case c.flushes <- req:
+	case <-c.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-req.done:
+		return ctx.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close flushes the buffer and stops the background sender; Log fails afterwards
+func (c *Client) Close(ctx context.Context) error {
+	err := c.Flush(ctx)
+	c.closeOnce.Do(func() {
+		c.closed.Store(true)
+		close(c.stop)
+	})
+
+	select {
+	case <-c.done:
+		if errors.Is(err, ErrClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// background sender: batches queued entries and periodically retries spooled batches
+func (c *Client) run() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Entry, 0, c.config.BatchSize)
+	send := func(ctx context.Context) {
+		if len(batch) > 0 {
+			c.deliver(ctx, batch)
+			batch = make([]Entry, 0, c.config.BatchSize)
+		}
+	}
+	// moves everything currently queued into batches
+	drain := func(ctx context.Context) {
+		for {
+			select {
+			case entry := <-c.queue:
+				batch = append(batch, entry)
+				if len(batch) >= c.config.BatchSize {
+					send(ctx)
+				}
+			default:
+				send(ctx)
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case entry := <-c.queue:
+			batch = append(batch, entry)
+			if len(batch) >= c.config.BatchSize {
+				send(context.Background())
+			}
+		case <-ticker.C:
+			send(context.Background())
+			if c.spool != nil {
+				c.replaySpool(context.Background())
+			}
+		case req := <-c.flushes:
+			drain(req.ctx)
+			close(req.done)
+		case <-c.stop:
+			drain(context.Background())
+			return
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// FieldValue renders a value as the string stored in a log's fields; composite values become JSON
+func FieldValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case error:
+		return v.Error()
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case time.Duration:
+		return v.String()
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct, reflect.Pointer:
+		if data, err := json.Marshal(value); err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprint(value)
+}
+
+// AddField sets fields[key], flattening nested maps into dotted keys such as "http.status"
+func AddField(fields map[string]string, key string, value any) {
+	if nested, ok := value.(map[string]any); ok {
+		for k, v := range nested {
+			AddField(fields, key+"."+k, v)
+		}
+		return
+	}
+	fields[key] = FieldValue(value)
+}
@@ -0,0 +1,86 @@
+package logrusadapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/pkg/client"
+	"github.com/sirupsen/logrus"
+)
+
+/*
+This package forwards logrus entries to LogBuilder:
+logger.AddHook(logrusadapter.NewHook(c, logrus.InfoLevel)) sends every entry at or above the level
+through the client's batching sender, with entry.Data as fields
+Fatal and panic entries flush the client first, since the process is about to exit
+*/
+
+// how long a fatal or panic entry waits for the buffer to be sent
+const fatalFlushTimeout = 5 * time.Second
+
+// Hook is a logrus.Hook that queues entries on a client
+type Hook struct {
+	client *client.Client
+	levels []logrus.Level
+}
+
+// NewHook forwards entries at minLevel and above
+func NewHook(c *client.Client, minLevel logrus.Level) *Hook {
+	var levels []logrus.Level
+	for _, level := range logrus.AllLevels {
+		if level <= minLevel { // logrus levels count down in severity
+			levels = append(levels, level)
+		}
+	}
+	return &Hook{client: c, levels: levels}
+}
+
+func (h *Hook) Levels() []logrus.Level {
+	return h.levels
+}
+
+func (h *Hook) Fire(entry *logrus.Entry) error {
+	var fields map[string]string
+	if len(entry.Data) > 0 || entry.HasCaller() {
+		fields = make(map[string]string, len(entry.Data)+1)
+		for k, v := range entry.Data {
+			client.AddField(fields, k, v)
+		}
+		if entry.HasCaller() {
+			fields["caller"] = fmt.Sprintf("%s:%d", entry.Caller.File, entry.Caller.Line)
+		}
+	}
+
+	err := h.client.Log(client.Entry{
+		Timestamp: entry.Time,
+		Level:     Level(entry.Level),
+		Message:   entry.Message,
+		Fields:    fields,
+	})
+
+	if entry.Level <= logrus.FatalLevel {
+		ctx, cancel := context.WithTimeout(context.Background(), fatalFlushTimeout)
+		h.client.Flush(ctx)
+		cancel()
+	}
+	return err
+}
+
+// Level maps a logrus level onto LogBuilder's; panic is reported as FATAL
+func Level(level logrus.Level) string {
+	switch level {
+	case logrus.TraceLevel:
+		return "TRACE"
+	case logrus.DebugLevel:
+		return "DEBUG"
+	case logrus.InfoLevel:
+		return "INFO"
+	case logrus.WarnLevel:
+		return "WARN"
+	case logrus.ErrorLevel:
+		return "ERROR"
+	default:
+		return "FATAL"
+	}
+}
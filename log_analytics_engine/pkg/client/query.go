@@ -0,0 +1,155 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// QueryRequest mirrors the filters accepted by /logs/query; zero values are omitted
+type QueryRequest struct {
+	Level   string `json:"level,omitempty"` // a level, or a comparison like ">= WARN"
+	Source  string `json:"source,omitempty"`
+	Service string `json:"service,omitempty"`
+	Message string `json:"message,omitempty"`
+
+	Levels   []string `json:"levels,omitempty"`
+	Sources  []string `json:"sources,omitempty"`
+	Services []string `json:"services,omitempty"`
+
+	MinLevel string `json:"min_level,omitempty"`
+	MaxLevel string `json:"max_level,omitempty"`
+
+	ExcludeLevel   string   `json:"exclude_level,omitempty"`
+	ExcludeLevels  []string `json:"exclude_levels,omitempty"`
+	ExcludeSource  string   `json:"exclude_source,omitempty"`
+	ExcludeSources []string `json:"exclude_sources,omitempty"`
+
+	MessageContains    string `json:"message_contains,omitempty"`
+	MessageNotContains string `json:"message_not_contains,omitempty"`
+
+	// exact, prefix and regular expression matches; case-sensitive unless RegexIgnoreCase is set
+	MessageEquals     string `json:"message_equals,omitempty"`
+	MessageStartsWith string `json:"message_starts_with,omitempty"`
+	MessageRegex      string `json:"message_regex,omitempty"`
+	MessageNotRegex   string `json:"message_not_regex,omitempty"`
+	SourceStartsWith  string `json:"source_starts_with,omitempty"`
+	SourceRegex       string `json:"source_regex,omitempty"`
+	SourceNotRegex    string `json:"source_not_regex,omitempty"`
+	ServiceStartsWith string `json:"service_starts_with,omitempty"`
+	ServiceRegex      string `json:"service_regex,omitempty"`
+	ServiceNotRegex   string `json:"service_not_regex,omitempty"`
+	RegexIgnoreCase   bool   `json:"regex_ignore_case,omitempty"`
+
+	// nested conditions, ANDed with the fields above
+	Where *Filter `json:"where,omitempty"`
+
+	// full-text search: words, "quoted phrases", prefix*, AND/OR/NOT, -word and parentheses
+	Search string `json:"search,omitempty"`
+
+	StartTime      *time.Time `json:"start_time,omitempty"`
+	EndTime        *time.Time `json:"end_time,omitempty"`
+	ReceivedAfter  *time.Time `json:"received_after,omitempty"`
+	ReceivedBefore *time.Time `json:"received_before,omitempty"`
+
+	LastMinutes int `json:"last_minutes,omitempty"`
+	LastHours   int `json:"last_hours,omitempty"`
+	LastDays    int `json:"last_days,omitempty"`
+
+	Limit  int `json:"limit,omitempty"` // default 100, max 1000
+	Offset int `json:"offset,omitempty"`
+
+	SortBy    string `json:"sort_by,omitempty"`    // defaults to relevance with a Search, otherwise timestamp
+	SortOrder string `json:"sort_order,omitempty"` // ASC or DESC
+}
+
+// Filter is a node of a QueryRequest.Where tree: And, Or, Not, or a comparison of Field
+// (a log column, or "fields.<key>" for a custom field) using Op, which defaults to "eq"
+type Filter struct {
+	And []*Filter `json:"and,omitempty"`
+	Or  []*Filter `json:"or,omitempty"`
+	Not *Filter   `json:"not,omitempty"`
+
+	Field  string        `json:"field,omitempty"`
+	Op     string        `json:"op,omitempty"`     // eq, ne, in, not_in, gte, lte, contains, starts_with, regex, ...
+	Value  interface{}   `json:"value,omitempty"`  // for single-value operators
+	Values []interface{} `json:"values,omitempty"` // for in and not_in
+}
+
+// LogRecord is a stored log as returned by queries
+type LogRecord struct {
+	ID             int64             `json:"id"`
+	Timestamp      time.Time         `json:"timestamp"`
+	Source         string            `json:"source"`
+	Level          string            `json:"level"`
+	SeverityNumber int               `json:"severity_number"`
+	Message        string            `json:"message"`
+	Service        string            `json:"service"`
+	Fields         map[string]string `json:"fields,omitempty"`
+	RawMessage     string            `json:"raw_message,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	ReceivedAt     time.Time         `json:"received_at"`
+	UserID         int               `json:"user_id"`
+	EventID        string            `json:"event_id,omitempty"`
+}
+
+type QueryResponse struct {
+	Logs       []LogRecord `json:"logs"`
+	TotalCount int         `json:"total_count"` // matching logs across all pages
+	Limit      int         `json:"limit"`
+	Offset     int         `json:"offset"`
+	ExecutedAt time.Time   `json:"executed_at"`
+	Matches    []Match     `json:"matches,omitempty"` // with a Search, one per log in Logs order
+}
+
+// Match describes how a log matched a full-text search
+type Match struct {
+	LogID   int64   `json:"log_id"`
+	Rank    float64 `json:"rank"`    // higher is more relevant
+	Snippet string  `json:"snippet"` // HTML-escaped, with matches wrapped in <mark></mark>
+}
+
+// Query runs a single query, retrying temporary failures
+func (c *Client) Query(ctx context.Context, query QueryRequest) (*QueryResponse, error) {
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+
+	// Queries have no side effects, so they need no Idempotency-Key
+	respBody, err := c.postWithRetry(ctx, "/api/v1/logs/query", "", body)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp QueryResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// QueryAll pages through every log matching query, calling fn for each one until fn
+// returns an error. Logs ingested while paging can shift offsets, so pin EndTime for a stable result
+func (c *Client) QueryAll(ctx context.Context, query QueryRequest, fn func(LogRecord) error) error {
+	if query.Limit <= 0 {
+		query.Limit = 1000
+	}
+
+	for {
+		resp, err := c.Query(ctx, query)
+		if err != nil {
+			return err
+		}
+		for _, record := range resp.Logs {
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+
+		query.Offset += len(resp.Logs)
+		if len(resp.Logs) < query.Limit || query.Offset >= resp.TotalCount {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	mrand "math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// APIError is a non-2xx response from the server
+type APIError struct {
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration // from the Retry-After header, if any
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("logbuilder: %d %s", e.StatusCode, e.Message)
+}
+
+// Temporary reports whether the request may succeed if retried. 409 means an earlier
+// attempt with the same Idempotency-Key is still being processed
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusConflict || e.StatusCode >= 500
+}
+
+// network failures, timeouts and temporary responses are worth retrying (or spooling)
+func retryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary()
+	}
+	return true
+}
+
+// BatchError reports entries of a delivered batch that the server rejected
+type BatchError struct {
+	Rejected []RejectedEntry
+}
+
+type RejectedEntry struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("logbuilder: server rejected %d log(s), first: %s", len(e.Rejected), e.Rejected[0].Error)
+}
+
+// Send delivers one entry synchronously through /logs/ingest, retrying like batches do
+func (c *Client) Send(ctx context.Context, entry Entry) error {
+	body, err := json.Marshal(c.withDefaults(entry))
+	if err != nil {
+		return err
+	}
+	_, err = c.postWithRetry(ctx, "/api/v1/logs/ingest", newIdempotencyKey(), body)
+	return err
+}
+
+// sends a batch, spooling it when delivery fails for a reason other than the request itself
+func (c *Client) deliver(ctx context.Context, batch []Entry) {
+	key := newIdempotencyKey()
+	body, err := marshalBatch(batch)
+	if err != nil {
+		c.config.OnError(fmt.Errorf("logbuilder: dropped %d log(s): %w", len(batch), err))
+		return
+	}
+
+	err = c.postBatch(ctx, key, body)
+	if err == nil {
+		return
+	}
+
+	if c.spool != nil && retryable(err) {
+		if spoolErr := c.spool.write(key, body); spoolErr != nil {
+			c.config.OnError(fmt.Errorf("logbuilder: dropped %d log(s) after %v: %w", len(batch), err, spoolErr))
+			return
+		}
+		c.config.OnError(fmt.Errorf("logbuilder: spooled %d log(s) to disk: %w", len(batch), err))
+		return
+	}
+	c.config.OnError(fmt.Errorf("logbuilder: dropped %d log(s): %w", len(batch), err))
+}
+
+func marshalBatch(batch []Entry) ([]byte, error) {
+	return json.Marshal(struct {
+		Logs []Entry `json:"logs"`
+	}{Logs: batch})
+}
+
+// posts a batch with partial success enabled, so invalid entries don't hold back valid ones
+func (c *Client) postBatch(ctx context.Context, key string, body []byte) error {
+	respBody, err := c.postWithRetry(ctx, "/api/v1/logs/batch?partial=true", key, body)
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		Errors []RejectedEntry `json:"errors"`
+	}
+	if json.Unmarshal(respBody, &result) == nil && len(result.Errors) > 0 {
+		// The batch was accepted, so this is reported but not retried
+		c.config.OnError(&BatchError{Rejected: result.Errors})
+	}
+	return nil
+}
+
+// POSTs body, retrying temporary failures with exponential backoff and jitter until ctx ends.
+// The same Idempotency-Key is sent on every attempt so the server can discard replays
+func (c *Client) postWithRetry(ctx context.Context, path, key string, body []byte) ([]byte, error) {
+	var lastErr error
+	for attempt := 0; attempt <= c.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			wait := c.backoff(attempt)
+			var apiErr *APIError
+			if errors.As(lastErr, &apiErr) && apiErr.RetryAfter > wait {
+				wait = apiErr.RetryAfter
+			}
+
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, fmt.Errorf("%w (last error: %v)", ctx.Err(), lastErr)
+			case <-timer.C:
+			}
+		}
+
+		respBody, err := c.post(ctx, path, key, body)
+		if err == nil {
+			return respBody, nil
+		}
+		lastErr = err
+		if !retryable(err) || ctx.Err() != nil {
+			return nil, err
+		}
+	}
+	return nil, lastErr
+}
+
+// delay before the given retry: a random duration between half and all of
+// MinBackoff * 2^(attempt-1), capped at MaxBackoff
+func (c *Client) backoff(attempt int) time.Duration {
+	ceiling := c.config.MaxBackoff
+	if attempt < 32 {
+		if d := c.config.MinBackoff << (attempt - 1); d > 0 && d < ceiling {
+			ceiling = d
+		}
+	}
+	// Keep at least half so retries don't collapse to zero
+	return ceiling/2 + time.Duration(mrand.Int64N(int64(ceiling/2)+1))
+}
+
+func (c *Client) post(ctx context.Context, path, key string, body []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.config.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	return c.do(req)
+}
+
+// performs a request and turns non-2xx responses into an *APIError
+func (c *Client) do(req *http.Request) ([]byte, error) {
+	resp, err := c.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return respBody, nil
+	}
+
+	apiErr := &APIError{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	var errorBody struct {
+		Error   string `json:"error"`
+		Details string `json:"details"`
+	}
+	if json.Unmarshal(respBody, &errorBody) == nil && errorBody.Error != "" {
+		apiErr.Message = errorBody.Error
+		if errorBody.Details != "" {
+			apiErr.Message += ": " + errorBody.Details
+		}
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return nil, apiErr
+}
+
+func newIdempotencyKey() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,128 @@
+package slogadapter
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/pkg/client"
+)
+
+/*
+This package forwards log/slog records to LogBuilder:
+slog.New(slogadapter.NewHandler(c, nil)) sends every record through the client's batching sender
+attributes become fields, with groups flattened into dotted keys such as "request.id"
+*/
+
+type Options struct {
+	// Minimum level sent, default slog.LevelInfo
+	Level slog.Leveler
+}
+
+// Handler is an slog.Handler that queues records on a client
+type Handler struct {
+	client *client.Client
+	level  slog.Leveler
+	fields map[string]string // attributes added with WithAttrs, already flattened
+	prefix string            // open groups, e.g. "request."
+}
+
+func NewHandler(c *client.Client, opts *Options) *Handler {
+	h := &Handler{client: c, level: slog.LevelInfo}
+	if opts != nil && opts.Level != nil {
+		h.level = opts.Level
+	}
+	return h
+}
+
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *Handler) Handle(_ context.Context, record slog.Record) error {
+	fields := make(map[string]string, len(h.fields)+record.NumAttrs())
+	for k, v := range h.fields {
+		fields[k] = v
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		addAttr(fields, h.prefix, attr)
+		return true
+	})
+	if len(fields) == 0 {
+		fields = nil
+	}
+
+	return h.client.Log(client.Entry{
+		Timestamp: record.Time, // zero falls back to now
+		Level:     Level(record.Level),
+		Message:   record.Message,
+		Fields:    fields,
+	})
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	clone := *h
+	clone.fields = make(map[string]string, len(h.fields)+len(attrs))
+	for k, v := range h.fields {
+		clone.fields[k] = v
+	}
+	for _, attr := range attrs {
+		addAttr(clone.fields, h.prefix, attr)
+	}
+	return &clone
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix += name + "."
+	return &clone
+}
+
+// Level maps an slog level onto LogBuilder's, keeping levels between the named ones
+// at the lower of the two and anything above ERROR+4 at FATAL
+func Level(level slog.Level) string {
+	switch {
+	case level < slog.LevelDebug:
+		return "TRACE"
+	case level < slog.LevelInfo:
+		return "DEBUG"
+	case level < slog.LevelWarn:
+		return "INFO"
+	case level < slog.LevelError:
+		return "WARN"
+	case level < slog.LevelError+4:
+		return "ERROR"
+	default:
+		return "FATAL"
+	}
+}
+
+// follows the slog.Handler rules: empty attributes are dropped and groups without a key are inlined
+func addAttr(fields map[string]string, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindGroup:
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, member := range attr.Value.Group() {
+			addAttr(fields, prefix, member)
+		}
+	case slog.KindTime:
+		fields[prefix+attr.Key] = attr.Value.Time().Format(time.RFC3339Nano)
+	case slog.KindAny:
+		client.AddField(fields, prefix+attr.Key, attr.Value.Any())
+	default:
+		fields[prefix+attr.Key] = attr.Value.String()
+	}
+}
@@ -0,0 +1,143 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrSpoolFull is reported when a batch doesn't fit within MaxSpoolBytes
+var ErrSpoolFull = errors.New("spool is full")
+
+// spool is an on-disk FIFO of undeliverable batches, one file per batch named so that
+// lexical order is arrival order. Batches keep their Idempotency-Key so a replay of a
+// batch the server did receive is discarded
+type spool struct {
+	dir      string
+	maxBytes int64
+
+	mu    sync.Mutex
+	bytes int64
+	seq   uint64
+}
+
+type spooledBatch struct {
+	IdempotencyKey string          `json:"idempotency_key"`
+	Body           json.RawMessage `json:"body"`
+}
+
+func openSpool(dir string, maxBytes int64) (*spool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	s := &spool{dir: dir, maxBytes: maxBytes}
+	files, err := s.files()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range files {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			s.bytes += info.Size()
+		}
+	}
+	return s, nil
+}
+
+// spooled batch files, oldest first
+func (s *spool) files() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".batch") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// writes a batch atomically so a crash never leaves a partial file to replay
+func (s *spool) write(key string, body []byte) error {
+	data, err := json.Marshal(spooledBatch{IdempotencyKey: key, Body: body})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.bytes+int64(len(data)) > s.maxBytes {
+		return ErrSpoolFull
+	}
+
+	s.seq++
+	name := fmt.Sprintf("%020d-%06d.batch", time.Now().UnixNano(), s.seq%1000000)
+	tmp := filepath.Join(s.dir, name+".tmp")
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write spool file: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, name)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write spool file: %w", err)
+	}
+	s.bytes += int64(len(data))
+	return nil
+}
+
+func (s *spool) remove(name string) {
+	path := filepath.Join(s.dir, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	if os.Remove(path) == nil {
+		s.mu.Lock()
+		s.bytes -= info.Size()
+		s.mu.Unlock()
+	}
+}
+
+// resends spooled batches oldest first, stopping at the first one that still can't be delivered
+func (c *Client) replaySpool(ctx context.Context) {
+	files, err := c.spool.files()
+	if err != nil {
+		c.config.OnError(err)
+		return
+	}
+
+	for _, name := range files {
+		data, err := os.ReadFile(filepath.Join(c.spool.dir, name))
+		if err != nil {
+			c.config.OnError(fmt.Errorf("logbuilder: failed to read spooled batch: %w", err))
+			return
+		}
+
+		var batch spooledBatch
+		if err := json.Unmarshal(data, &batch); err != nil {
+			c.config.OnError(fmt.Errorf("logbuilder: discarded corrupt spooled batch %s: %w", name, err))
+			c.spool.remove(name)
+			continue
+		}
+
+		// A single attempt: the next tick tries again, so the sender isn't held up by a dead server
+		if _, err := c.post(ctx, "/api/v1/logs/batch?partial=true", batch.IdempotencyKey, batch.Body); err != nil {
+			if retryable(err) {
+				return
+			}
+			c.config.OnError(fmt.Errorf("logbuilder: discarded spooled batch the server refused: %w", err))
+		}
+		c.spool.remove(name)
+	}
+}
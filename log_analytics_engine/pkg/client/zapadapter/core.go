@@ -0,0 +1,116 @@
+package zapadapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sbalaji09/LogBuilder/log_analytics_engine/pkg/client"
+	"go.uber.org/zap/zapcore"
+)
+
+/*
+This package forwards zap logs to LogBuilder:
+zap.New(zapadapter.NewCore(c, zapcore.InfoLevel)) sends every entry through the client's batching sender,
+or zapcore.NewTee combines it with an existing core
+fields become LogBuilder fields, with namespaces and objects flattened into dotted keys
+*/
+
+// how long a panic or fatal entry waits for the buffer to be sent
+const fatalFlushTimeout = 5 * time.Second
+
+// Core is a zapcore.Core that queues entries on a client
+type Core struct {
+	zapcore.LevelEnabler
+	client *client.Client
+	fields map[string]string // fields added with With, already flattened
+}
+
+func NewCore(c *client.Client, enabler zapcore.LevelEnabler) *Core {
+	return &Core{LevelEnabler: enabler, client: c}
+}
+
+func (c *Core) With(fields []zapcore.Field) zapcore.Core {
+	if len(fields) == 0 {
+		return c
+	}
+	clone := *c
+	clone.fields = c.encode(fields)
+	return &clone
+}
+
+func (c *Core) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *Core) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	encoded := c.encode(fields)
+	if entry.LoggerName != "" {
+		encoded["logger"] = entry.LoggerName
+	}
+	if entry.Caller.Defined {
+		encoded["caller"] = fmt.Sprintf("%s:%d", entry.Caller.File, entry.Caller.Line)
+	}
+	if entry.Stack != "" {
+		encoded["stacktrace"] = entry.Stack
+	}
+	if len(encoded) == 0 {
+		encoded = nil
+	}
+
+	err := c.client.Log(client.Entry{
+		Timestamp: entry.Time,
+		Level:     Level(entry.Level),
+		Message:   entry.Message,
+		Fields:    encoded,
+	})
+
+	// zap exits or panics after writing these, so don't leave them in the buffer
+	if entry.Level > zapcore.ErrorLevel {
+		c.Sync()
+	}
+	return err
+}
+
+// Sync sends everything buffered so far
+func (c *Core) Sync() error {
+	ctx, cancel := context.WithTimeout(context.Background(), fatalFlushTimeout)
+	defer cancel()
+	return c.client.Flush(ctx)
+}
+
+// the core's own fields plus the given ones, flattened to strings
+func (c *Core) encode(fields []zapcore.Field) map[string]string {
+	encoder := zapcore.NewMapObjectEncoder()
+	for _, field := range fields {
+		field.AddTo(encoder)
+	}
+
+	encoded := make(map[string]string, len(c.fields)+len(encoder.Fields))
+	for k, v := range c.fields {
+		encoded[k] = v
+	}
+	for k, v := range encoder.Fields {
+		client.AddField(encoded, k, v)
+	}
+	return encoded
+}
+
+// Level maps a zap level onto LogBuilder's; DPanic, panic and fatal are reported as FATAL
+func Level(level zapcore.Level) string {
+	switch {
+	case level < zapcore.InfoLevel:
+		return "DEBUG"
+	case level == zapcore.InfoLevel:
+		return "INFO"
+	case level == zapcore.WarnLevel:
+		return "WARN"
+	case level == zapcore.ErrorLevel:
+		return "ERROR"
+	default:
+		return "FATAL"
+	}
+}
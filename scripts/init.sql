@@ -6,7 +6,20 @@ CREATE TABLE users (
     password_hash VARCHAR(255) NOT NULL,
     created_at TIMESTAMPTZ DEFAULT NOW(),
     updated_at TIMESTAMPTZ DEFAULT NOW(),
-    is_active BOOLEAN DEFAULT true
+    is_active BOOLEAN DEFAULT true,
+    is_admin BOOLEAN DEFAULT false
+);
+
+-- Per-user ingestion limit overrides (0 means unlimited); users without a row use the configured defaults
+CREATE TABLE ingest_limits (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    events_per_second INTEGER NOT NULL DEFAULT 0,
+    bytes_per_second BIGINT NOT NULL DEFAULT 0,
+    key_events_per_second INTEGER NOT NULL DEFAULT 0,
+    key_bytes_per_second BIGINT NOT NULL DEFAULT 0,
+    daily_event_quota BIGINT NOT NULL DEFAULT 0,
+    daily_byte_quota BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ DEFAULT NOW()
 );
 
 -- API keys table
@@ -20,20 +33,50 @@ CREATE TABLE api_keys (
     is_active BOOLEAN DEFAULT true
 );
 
+-- Client certificates agents present over mutual TLS instead of an API key, matched by SHA-256 fingerprint
+CREATE TABLE client_certificates (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    fingerprint CHAR(64) UNIQUE NOT NULL,
+    subject TEXT NOT NULL,
+    not_after TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ,
+    is_active BOOLEAN DEFAULT true
+);
+
 -- Create the main logs table with partitioning
 CREATE TABLE logs (
     id BIGSERIAL,
     timestamp TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     source VARCHAR(255) NOT NULL,
     level VARCHAR(50) NOT NULL,
+    severity_number SMALLINT NOT NULL DEFAULT 0, -- ordinal of level (TRACE=1 ... FATAL=21) for range filters and sorting
     message TEXT NOT NULL,
     service VARCHAR(255),
     fields JSONB,
     raw_message TEXT,
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-    user_id INTEGER REFERENCES users(id)
+    received_at TIMESTAMPTZ NOT NULL DEFAULT NOW(), -- arrival time at the ingester, independent of the client timestamp
+    user_id INTEGER REFERENCES users(id),
+    event_id VARCHAR(255),
+    -- words of the message for full-text search; 'simple' keeps every word unstemmed, stop words included
+    message_tsv TSVECTOR GENERATED ALWAYS AS (to_tsvector('simple', message)) STORED
 ) PARTITION BY RANGE (timestamp);
 
+-- Client-supplied event IDs seen per user. Unique indexes on the partitioned logs table
+-- would have to include timestamp, so uniqueness of (user_id, event_id) is enforced here.
+-- created_at is when the ingester received the event; IDs older than IDEMPOTENCY_TTL can be
+-- claimed again and are pruned by the processors
+CREATE TABLE log_event_ids (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    event_id VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, event_id)
+);
+CREATE INDEX idx_log_event_ids_created_at ON log_event_ids(created_at);
+
 -- Create initial partitions for current and next month
 -- This will be automated later, but we need at least one partition to start
 CREATE TABLE logs_2024_01 PARTITION OF logs
@@ -131,6 +174,65 @@ CREATE TABLE saved_queries (
     last_used_at TIMESTAMPTZ
 );
 
+-- Create table for server-side parsing pipelines
+CREATE TABLE pipelines (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    source VARCHAR(255) NOT NULL DEFAULT '',
+    service VARCHAR(255) NOT NULL DEFAULT '',
+    priority INTEGER NOT NULL DEFAULT 0,
+    stages JSONB NOT NULL,
+    multiline JSONB,
+    is_active BOOLEAN DEFAULT true,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Create table for PII and secret redaction rules
+CREATE TABLE redaction_rules (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    detector VARCHAR(50) NOT NULL,
+    pattern TEXT NOT NULL DEFAULT '',
+    action VARCHAR(10) NOT NULL DEFAULT 'mask' CHECK (action IN ('mask', 'hash', 'drop')),
+    is_active BOOLEAN DEFAULT true,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Create table for ingest-time drop, sample and rewrite rules
+CREATE TABLE ingest_rules (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 0,
+    match JSONB NOT NULL DEFAULT '{}',
+    action VARCHAR(10) NOT NULL CHECK (action IN ('drop', 'sample', 'rewrite')),
+    sample_rate DOUBLE PRECISION NOT NULL DEFAULT 0,
+    set_level VARCHAR(20) NOT NULL DEFAULT '',
+    set_service VARCHAR(255) NOT NULL DEFAULT '',
+    is_active BOOLEAN DEFAULT true,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Create table for per-user CSV lookup tables joined onto logs during processing
+CREATE TABLE lookup_tables (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    match_field VARCHAR(255) NOT NULL,
+    key_column VARCHAR(255) NOT NULL,
+    columns JSONB NOT NULL DEFAULT '[]',
+    rows JSONB NOT NULL DEFAULT '{}',
+    row_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (user_id, name)
+);
+
 -- Create table for system metrics (for monitoring your own system)
 CREATE TABLE system_metrics (
     id SERIAL PRIMARY KEY,
@@ -141,6 +243,10 @@ CREATE TABLE system_metrics (
     timestamp TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
+-- Services write a snapshot per interval; reads filter by metric and time, pruning by time
+CREATE INDEX idx_system_metrics_name_time ON system_metrics (metric_name, timestamp DESC);
+CREATE INDEX idx_system_metrics_timestamp ON system_metrics (timestamp);
+
 -- Insert some sample alert rules
 INSERT INTO alert_rules (name, description, condition_query, threshold_value, threshold_operator, time_window_minutes, notification_channels) VALUES
 ('High Error Rate', 'Alert when error rate exceeds 5% in 10 minutes', 
@@ -193,8 +299,12 @@ BEGIN
     -- Add indexes to the new partition
     EXECUTE format('CREATE INDEX IF NOT EXISTS idx_%s_timestamp ON %I USING BRIN (timestamp)',
                    partition_name, partition_name);
+    EXECUTE format('CREATE INDEX IF NOT EXISTS idx_%s_received_at ON %I USING BRIN (received_at)',
+                   partition_name, partition_name);
     EXECUTE format('CREATE INDEX IF NOT EXISTS idx_%s_level ON %I (level)',
                    partition_name, partition_name);
+    EXECUTE format('CREATE INDEX IF NOT EXISTS idx_%s_severity ON %I (user_id, severity_number)',
+                   partition_name, partition_name);
     EXECUTE format('CREATE INDEX IF NOT EXISTS idx_%s_source ON %I (source)',
                    partition_name, partition_name);
 END;
@@ -205,7 +315,28 @@ CREATE INDEX idx_users_username ON users(username);
 CREATE INDEX idx_users_email ON users(email);
 CREATE INDEX idx_api_keys_key ON api_keys(api_key);
 CREATE INDEX idx_api_keys_user_id ON api_keys(user_id);
+CREATE INDEX idx_client_certificates_user_id ON client_certificates(user_id);
 CREATE INDEX idx_logs_user_id ON logs(user_id);
+CREATE INDEX idx_logs_severity ON logs(user_id, severity_number);
+-- Defined on the parent, so every partition (including ones create_monthly_partition adds) gets its own GIN index
+CREATE INDEX idx_logs_message_tsv ON logs USING GIN (message_tsv);
+CREATE INDEX idx_pipelines_user_id ON pipelines(user_id, priority);
+CREATE INDEX idx_redaction_rules_user_id ON redaction_rules(user_id);
+CREATE INDEX idx_ingest_rules_user_id ON ingest_rules(user_id, priority);
+
+-- Backfill severity_number from level for rows stored before the column was added,
+-- so range filters and sorting by severity cover them too
+UPDATE logs SET severity_number = CASE upper(level)
+        WHEN 'TRACE' THEN 1
+        WHEN 'DEBUG' THEN 5
+        WHEN 'INFO' THEN 9
+        WHEN 'WARN' THEN 13
+        WHEN 'WARNING' THEN 13
+        WHEN 'ERROR' THEN 17
+        WHEN 'FATAL' THEN 21
+        ELSE 0
+    END
+    WHERE severity_number = 0;
 
 -- Insert a test user (password is "password123" hashed with bcrypt)
 INSERT INTO users (username, email, password_hash) VALUES